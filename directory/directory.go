@@ -0,0 +1,138 @@
+// Package directory implements password authentication against external user directories
+package directory
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("directory: invalid credentials")
+	ErrUserNotFound       = errors.New("directory: user not found")
+	ErrNotConfigured      = errors.New("directory: no backend configured")
+)
+
+// Identity is a user authenticated by the directory
+type Identity struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string // Group distinguished names or names
+}
+
+// Authenticator verifies user credentials. ErrUserNotFound is returned if the directory doesn't know the user
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (*Identity, error)
+}
+
+// Directory decides which logins are checked against the backend and how authenticated users are mapped onto local accounts
+type Directory struct {
+	Name            string            `yaml:"name"`
+	TenantID        uuid.UUID         `yaml:"tenant_id"`    // Logins to this tenant use the directory, users get membership in it
+	Domains         []string          `yaml:"domains"`      // Login names in these email domains use the directory
+	RoleMapping     map[string]string `yaml:"role_mapping"` // Group DN or common name to role
+	DefaultRole     string            `yaml:"default_role"`
+	JITProvisioning bool              `yaml:"jit_provisioning"`
+	LDAP            *LDAP             `yaml:"ldap"`
+
+	Authenticator Authenticator `yaml:"-"` // Overrides LDAP
+}
+
+// Authenticate checks the credentials with the configured backend
+func (d *Directory) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	if d.Authenticator != nil {
+		return d.Authenticator.Authenticate(ctx, username, password)
+	}
+
+	if d.LDAP != nil {
+		return d.LDAP.Authenticate(ctx, username, password)
+	}
+
+	return nil, ErrNotConfigured
+}
+
+// Matches returns true if the login to the tenant (may be nil) under the name should be checked against the directory.
+// Directories without tenant and domains match any login
+func (d *Directory) Matches(tenantID uuid.UUID, username string) bool {
+	if d.TenantID == uuid.Nil && len(d.Domains) == 0 {
+		return true
+	}
+
+	if d.TenantID != uuid.Nil && d.TenantID == tenantID {
+		return true
+	}
+
+	if i := strings.LastIndexByte(username, '@'); i >= 0 {
+		domain := username[i+1:]
+		for _, dom := range d.Domains {
+			if strings.EqualFold(dom, domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// groupName returns the value of the first RDN
+func groupName(dn string) string {
+	if i := strings.IndexByte(dn, '='); i >= 0 {
+		dn = dn[i+1:]
+	}
+
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case ',', '+':
+			return dn[:i]
+		}
+	}
+
+	return dn
+}
+
+// MapRoles returns roles corresponding to the groups. Mapping keys are compared with both group DNs and common names
+func (d *Directory) MapRoles(groups []string) []string {
+	var res []string
+	seen := make(map[string]struct{})
+
+	for key, role := range d.RoleMapping {
+		if role == "" {
+			continue
+		}
+
+		for _, g := range groups {
+			if strings.EqualFold(key, g) || (!strings.Contains(key, "=") && strings.EqualFold(key, groupName(g))) {
+				if _, ok := seen[role]; !ok {
+					res = append(res, role)
+					seen[role] = struct{}{}
+				}
+				break
+			}
+		}
+	}
+
+	if len(res) == 0 && d.DefaultRole != "" {
+		res = []string{d.DefaultRole}
+	}
+
+	return res
+}
+
+// ManagedRoles returns all roles the directory may assign
+func (d *Directory) ManagedRoles() []string {
+	res := make([]string, 0, len(d.RoleMapping)+1)
+	for _, role := range d.RoleMapping {
+		res = append(res, role)
+	}
+
+	if d.DefaultRole != "" {
+		res = append(res, d.DefaultRole)
+	}
+
+	return res
+}
@@ -0,0 +1,258 @@
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	ldap "gopkg.in/ldap.v3"
+)
+
+const (
+	defaultLDAPTimeout    = 10 * time.Second
+	defaultUserFilter     = "(&(objectClass=person)(|(uid={username})(mail={username})))"
+	defaultEmailAttribute = "mail"
+	defaultNameAttribute  = "cn"
+)
+
+// LDAP authenticates users by binding with their distinguished name found by the search filter
+type LDAP struct {
+	URL                string        `yaml:"url"`       // ldap://host[:port] or ldaps://host[:port]
+	StartTLS           bool          `yaml:"start_tls"` // Upgrade ldap:// connections
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	CAFile             string        `yaml:"ca_file"` // PEM bundle, system roots if empty
+	BindDN             string        `yaml:"bind_dn"` // Service account used for searches, anonymous if empty
+	BindPassword       string        `yaml:"bind_password"`
+	BaseDN             string        `yaml:"base_dn"`
+	UserFilter         string        `yaml:"user_filter"` // {username} is replaced by the escaped login name
+	EmailAttribute     string        `yaml:"email_attribute"`
+	NameAttribute      string        `yaml:"name_attribute"`
+	GroupAttribute     string        `yaml:"group_attribute"` // User attribute listing groups, i.e. memberOf
+	GroupBaseDN        string        `yaml:"group_base_dn"`   // Search base for groups, BaseDN if empty
+	GroupFilter        string        `yaml:"group_filter"`    // {dn} and {username} are replaced by escaped values, i.e. (member={dn})
+	Timeout            time.Duration `yaml:"timeout"`
+
+	TLSConfig *tls.Config `yaml:"-"` // Overrides ca_file and insecure_skip_verify
+}
+
+func (l *LDAP) tlsConfig(host string) (*tls.Config, error) {
+	if l.TLSConfig != nil {
+		return l.TLSConfig, nil
+	}
+
+	config := tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: l.InsecureSkipVerify,
+	}
+
+	if l.CAFile != "" {
+		buf, err := ioutil.ReadFile(l.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("%s: no certificates found", l.CAFile)
+		}
+	}
+
+	return &config, nil
+}
+
+func (l *LDAP) dial(ctx context.Context) (*ldap.Conn, error) {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host, port = u.Host, ""
+	}
+
+	timeout := l.Timeout
+	if timeout == 0 {
+		timeout = defaultLDAPTimeout
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d < timeout {
+			timeout = d
+		}
+	}
+
+	var secure bool
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = ldap.DefaultLdapPort
+		}
+	case "ldaps":
+		if port == "" {
+			port = ldap.DefaultLdapsPort
+		}
+		secure = true
+	default:
+		return nil, fmt.Errorf("directory: unknown scheme `%s'", u.Scheme)
+	}
+
+	var tlsConfig *tls.Config
+	if secure || l.StartTLS {
+		if tlsConfig, err = l.tlsConfig(host); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	c, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	// Bound the whole exchange
+	c.SetDeadline(time.Now().Add(timeout))
+
+	if secure {
+		tc := tls.Client(c, tlsConfig)
+		if err := tc.Handshake(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tc
+	}
+
+	conn := ldap.NewConn(c, secure)
+	conn.Start()
+	conn.SetTimeout(timeout)
+
+	if l.StartTLS && !secure {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func replacePlaceholders(filter string, values map[string]string) string {
+	for k, v := range values {
+		filter = strings.Replace(filter, "{"+k+"}", ldap.EscapeFilter(v), -1)
+	}
+	return filter
+}
+
+func (l *LDAP) bindService(conn *ldap.Conn) error {
+	if l.BindDN == "" {
+		return nil
+	}
+
+	if err := conn.Bind(l.BindDN, l.BindPassword); err != nil {
+		return fmt.Errorf("directory: service bind: %v", err)
+	}
+
+	return nil
+}
+
+// Authenticate looks the user up using the service account and binds with the password
+func (l *LDAP) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	// Empty password would result in an unauthenticated bind which succeeds on many servers
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := l.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	filter := l.UserFilter
+	if filter == "" {
+		filter = defaultUserFilter
+	}
+
+	emailAttr := l.EmailAttribute
+	if emailAttr == "" {
+		emailAttr = defaultEmailAttribute
+	}
+
+	nameAttr := l.NameAttribute
+	if nameAttr == "" {
+		nameAttr = defaultNameAttribute
+	}
+
+	attrs := []string{emailAttr, nameAttr}
+	if l.GroupAttribute != "" {
+		attrs = append(attrs, l.GroupAttribute)
+	}
+
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		replacePlaceholders(filter, map[string]string{"username": username}),
+		attrs, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("directory: user search: %v", err)
+	}
+
+	switch len(res.Entries) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+	default:
+		return nil, errors.New("directory: user search returned multiple entries")
+	}
+
+	entry := res.Entries[0]
+	ident := Identity{
+		DN:    entry.DN,
+		Email: entry.GetAttributeValue(emailAttr),
+		Name:  entry.GetAttributeValue(nameAttr),
+	}
+
+	if l.GroupAttribute != "" {
+		ident.Groups = append(ident.Groups, entry.GetAttributeValues(l.GroupAttribute)...)
+	}
+
+	if l.GroupFilter != "" {
+		base := l.GroupBaseDN
+		if base == "" {
+			base = l.BaseDN
+		}
+
+		groups, err := conn.Search(ldap.NewSearchRequest(
+			base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			replacePlaceholders(l.GroupFilter, map[string]string{"username": username, "dn": entry.DN}),
+			[]string{"dn"}, nil,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("directory: group search: %v", err)
+		}
+
+		for _, g := range groups.Entries {
+			ident.Groups = append(ident.Groups, g.DN)
+		}
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("directory: user bind: %v", err)
+	}
+
+	return &ident, nil
+}
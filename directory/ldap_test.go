@@ -0,0 +1,192 @@
+package directory
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ecadlabs/auth/directory/ldaptest"
+)
+
+const (
+	serviceDN = "cn=service,dc=example,dc=com"
+	aliceDN   = "uid=alice,ou=people,dc=example,dc=com"
+	adminsDN  = "cn=admins,ou=groups,dc=example,dc=com"
+	opsDN     = "cn=ops,ou=groups,dc=example,dc=com"
+)
+
+func testEntries() []*ldaptest.Entry {
+	return []*ldaptest.Entry{
+		{DN: serviceDN, Password: "service"},
+		{
+			DN:       aliceDN,
+			Password: "secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"alice"},
+				"mail":        {"alice@example.com"},
+				"cn":          {"Alice"},
+				"memberOf":    {opsDN},
+			},
+		},
+		{DN: adminsDN, Attributes: map[string][]string{"objectClass": {"groupOfNames"}, "member": {aliceDN}}},
+		{DN: opsDN, Attributes: map[string][]string{"objectClass": {"groupOfNames"}}},
+	}
+}
+
+func testTLSConfig(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tpl, &tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+
+	return server, client
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	srv := ldaptest.NewServer(testEntries()...)
+	defer srv.Close()
+
+	backend := LDAP{
+		URL:            srv.URL,
+		BindDN:         serviceDN,
+		BindPassword:   "service",
+		BaseDN:         "dc=example,dc=com",
+		GroupAttribute: "memberOf",
+		GroupBaseDN:    "ou=groups,dc=example,dc=com",
+		GroupFilter:    "(&(objectClass=groupOfNames)(member={dn}))",
+	}
+
+	type testCase struct {
+		username string
+		password string
+		err      error
+	}
+
+	cases := []testCase{
+		{username: "alice", password: "secret"},
+		{username: "alice@example.com", password: "secret"},
+		{username: "alice", password: "wrong", err: ErrInvalidCredentials},
+		{username: "alice", password: "", err: ErrInvalidCredentials},
+		{username: "bob", password: "secret", err: ErrUserNotFound},
+		{username: "*", password: "secret", err: ErrUserNotFound},
+	}
+
+	for _, c := range cases {
+		ident, err := backend.Authenticate(context.Background(), c.username, c.password)
+		if err != c.err {
+			t.Errorf("%s: expected %v, got %v", c.username, c.err, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		sort.Strings(ident.Groups)
+		expected := &Identity{
+			DN:     aliceDN,
+			Email:  "alice@example.com",
+			Name:   "Alice",
+			Groups: []string{adminsDN, opsDN},
+		}
+
+		if !reflect.DeepEqual(ident, expected) {
+			t.Errorf("%s: unexpected identity %#v", c.username, ident)
+		}
+	}
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	srv := ldaptest.NewServer(testEntries()...)
+	defer srv.Close()
+
+	serverConfig, clientConfig := testTLSConfig(t)
+	srv.TLSConfig = serverConfig
+
+	backend := LDAP{
+		URL:          srv.URL,
+		StartTLS:     true,
+		BindDN:       serviceDN,
+		BindPassword: "service",
+		BaseDN:       "dc=example,dc=com",
+		TLSConfig:    clientConfig,
+	}
+
+	if _, err := backend.Authenticate(context.Background(), "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	binds := srv.Binds()
+	if len(binds) != 2 {
+		t.Fatalf("unexpected binds: %v", binds)
+	}
+
+	for _, b := range binds {
+		if !b.TLS {
+			t.Errorf("plaintext bind: %s", b.DN)
+		}
+	}
+
+	// Untrusted server certificate
+	backend.TLSConfig = nil
+	if _, err := backend.Authenticate(context.Background(), "alice", "secret"); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestMapRoles(t *testing.T) {
+	dir := Directory{
+		RoleMapping: map[string]string{
+			adminsDN:    "admin",
+			"ops":       "ops",
+			"cn=other":  "other",
+			"unmatched": "unmatched",
+		},
+		DefaultRole: "regular",
+	}
+
+	roles := dir.MapRoles([]string{adminsDN, opsDN, "cn=other,dc=example,dc=com"})
+	sort.Strings(roles)
+	if !reflect.DeepEqual(roles, []string{"admin", "ops"}) {
+		t.Errorf("unexpected roles: %v", roles)
+	}
+
+	if roles := dir.MapRoles(nil); !reflect.DeepEqual(roles, []string{"regular"}) {
+		t.Errorf("unexpected roles: %v", roles)
+	}
+}
@@ -0,0 +1,308 @@
+// Package ldaptest provides an in-memory LDAP server for tests. It understands simple binds, StartTLS and
+// searches with equality, presence and boolean filters
+package ldaptest
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+
+	ber "gopkg.in/asn1-ber.v1"
+	ldap "gopkg.in/ldap.v3"
+)
+
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Entry is a directory object. Entries with a password accept simple binds
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+func (e *Entry) values(attr string) []string {
+	for k, v := range e.Attributes {
+		if strings.EqualFold(k, attr) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Bind is a recorded successful bind
+type Bind struct {
+	DN  string
+	TLS bool
+}
+
+// Server is a LDAP server listening on the loopback interface
+type Server struct {
+	URL       string
+	Listener  net.Listener
+	TLSConfig *tls.Config // Enables StartTLS if set
+
+	mtx     sync.Mutex
+	entries []*Entry
+	binds   []Bind
+	wg      sync.WaitGroup
+}
+
+// NewServer starts the server populated with the entries
+func NewServer(entries ...*Entry) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("ldaptest: failed to listen on a port: " + err.Error())
+	}
+
+	s := &Server{
+		URL:      "ldap://" + l.Addr().String(),
+		Listener: l,
+		entries:  entries,
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.Listener.Close()
+	s.wg.Wait()
+}
+
+// Binds returns successful binds in order
+func (s *Server) Binds() []Bind {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]Bind(nil), s.binds...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		c, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(c)
+	}
+}
+
+type session struct {
+	conn  net.Conn
+	bound string
+	tls   bool
+}
+
+func envelope(id int64, op *ber.Packet) []byte {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	p.AppendChild(op)
+	return p.Bytes()
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return op
+}
+
+func (s *Server) handle(c net.Conn) {
+	sess := session{conn: c}
+	defer func() { sess.conn.Close() }()
+
+	for {
+		packet, err := ber.ReadPacket(sess.conn)
+		if err != nil {
+			return
+		}
+
+		if len(packet.Children) < 2 {
+			return
+		}
+
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := s.bind(&sess, op)
+			sess.conn.Write(envelope(id, result(ldap.ApplicationBindResponse, code)))
+
+		case ldap.ApplicationSearchRequest:
+			s.search(&sess, id, op)
+
+		case ldap.ApplicationExtendedRequest:
+			if len(op.Children) == 0 || op.Children[0].Data.String() != startTLSOID || s.TLSConfig == nil || sess.tls {
+				sess.conn.Write(envelope(id, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError)))
+				continue
+			}
+
+			sess.conn.Write(envelope(id, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess)))
+
+			tc := tls.Server(sess.conn, s.TLSConfig)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			sess.conn, sess.tls = tc, true
+
+		case ldap.ApplicationUnbindRequest:
+			return
+
+		default:
+			sess.conn.Write(envelope(id, result(op.Tag+1, ldap.LDAPResultUnwillingToPerform)))
+		}
+	}
+}
+
+func (s *Server) find(dn string) *Entry {
+	for _, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *Server) bind(sess *session, op *ber.Packet) uint16 {
+	if len(op.Children) < 3 || op.Children[2].Tag != 0 {
+		return ldap.LDAPResultAuthMethodNotSupported
+	}
+
+	dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	sess.bound = ""
+	if password == "" {
+		// Anonymous or unauthenticated
+		return ldap.LDAPResultSuccess
+	}
+
+	if e := s.find(dn); e == nil || e.Password == "" || e.Password != password {
+		return ldap.LDAPResultInvalidCredentials
+	}
+
+	sess.bound = dn
+	s.binds = append(s.binds, Bind{DN: dn, TLS: sess.tls})
+
+	return ldap.LDAPResultSuccess
+}
+
+func match(e *Entry, f *ber.Packet) bool {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			if !match(e, c) {
+				return false
+			}
+		}
+		return true
+
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			if match(e, c) {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterNot:
+		return len(f.Children) == 1 && !match(e, f.Children[0])
+
+	case ldap.FilterEqualityMatch:
+		if len(f.Children) != 2 {
+			return false
+		}
+
+		attr, value := f.Children[0].Data.String(), f.Children[1].Data.String()
+		if strings.EqualFold(attr, "dn") {
+			return strings.EqualFold(e.DN, value)
+		}
+
+		for _, v := range e.values(attr) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterPresent:
+		return len(e.values(f.Data.String())) != 0
+	}
+
+	return false
+}
+
+func inScope(dn, base string) bool {
+	dn, base = strings.ToLower(dn), strings.ToLower(base)
+	return base == "" || dn == base || strings.HasSuffix(dn, ","+base)
+}
+
+func (s *Server) search(sess *session, id int64, op *ber.Packet) {
+	if len(op.Children) < 8 {
+		sess.conn.Write(envelope(id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError)))
+		return
+	}
+
+	// Anonymous searches are refused like by most directories
+	if sess.bound == "" {
+		sess.conn.Write(envelope(id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights)))
+		return
+	}
+
+	base := op.Children[0].Data.String()
+	filter := op.Children[6]
+
+	var attrs []string
+	for _, a := range op.Children[7].Children {
+		attrs = append(attrs, a.Data.String())
+	}
+
+	s.mtx.Lock()
+	var found []*Entry
+	for _, e := range s.entries {
+		if inScope(e.DN, base) && match(e, filter) {
+			found = append(found, e)
+		}
+	}
+	s.mtx.Unlock()
+
+	for _, e := range found {
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "objectName"))
+
+		list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+		for _, a := range attrs {
+			values := e.values(a)
+			if len(values) == 0 {
+				continue
+			}
+
+			attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a, "type"))
+
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+			for _, v := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+			}
+			attr.AppendChild(set)
+			list.AppendChild(attr)
+		}
+		entry.AppendChild(list)
+
+		if _, err := sess.conn.Write(envelope(id, entry)); err != nil {
+			return
+		}
+	}
+
+	sess.conn.Write(envelope(id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)))
+}
@@ -15,8 +15,9 @@ func Wrap(e error, code Code) *Error {
 }
 
 type Response struct {
-	Error string `json:"error,omitempty"`
-	Code  Code   `json:"code,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Code       Code     `json:"code,omitempty"`
+	Violations []string `json:"violations,omitempty"`
 }
 
 func (r *Response) HTTPStatus() int {
@@ -24,20 +25,27 @@ func (r *Response) HTTPStatus() int {
 }
 
 func ErrorResponse(err error) *Response {
-	var code Code
+	var (
+		code       Code
+		violations []string
+	)
 
 	switch e := err.(type) {
 	case *Error:
 		code = e.Code
 	case Error:
 		code = e.Code
+	case *PasswordPolicyError:
+		code = CodePasswordPolicy
+		violations = e.Violations
 	default:
 		code = CodeUnknown
 	}
 
 	return &Response{
-		Error: err.Error(),
-		Code:  code,
+		Error:      err.Error(),
+		Code:       code,
+		Violations: violations,
 	}
 }
 
@@ -81,6 +89,30 @@ const (
 	CodeService             Code = "service_account"
 	CodeKeyNotFound         Code = "api_key_not_found"
 	CodeAddrExists          Code = "address_exists"
+	CodeClientNotFound      Code = "client_not_found"
+	CodeInvalidGrant        Code = "invalid_grant"
+	CodeSessionNotFound     Code = "session_not_found"
+	CodeSessionRevoked      Code = "session_revoked"
+	CodeTokenRevoked        Code = "token_revoked"
+	CodeKeyExpired          Code = "api_key_expired"
+	CodeMFARequired         Code = "mfa_required"
+	CodeMFACode             Code = "invalid_mfa_code"
+	CodeMFAEnrolled         Code = "mfa_enrolled"
+	CodeMFANotEnrolled      Code = "mfa_not_enrolled"
+	CodeWebAuthn            Code = "webauthn_failed"
+	CodeCredentialNotFound  Code = "credential_not_found"
+	CodeCredentialExists    Code = "credential_exists"
+	CodeMFAEnrollRequired   Code = "mfa_enrollment_required"
+	CodeLoginLocked         Code = "login_locked"
+	CodeRateLimit           Code = "rate_limit"
+	CodePasswordPolicy      Code = "password_policy"
+	CodeConnectionNotFound  Code = "connection_not_found"
+	CodeFederation          Code = "federation_failed"
+	CodeDirectory           Code = "directory_unavailable"
+	CodeExternalIDInUse     Code = "external_id_in_use"
+	CodeEmailDomain         Code = "email_domain_not_allowed"
+	CodeIdentityNotLinked   Code = "identity_not_linked"
+	CodeIdentityNotFound    Code = "identity_not_found"
 )
 
 var httpStatus = map[Code]int{
@@ -112,6 +144,30 @@ var httpStatus = map[Code]int{
 	CodeKeyNotFound:         http.StatusNotFound,
 	CodeAddrExists:          http.StatusConflict,
 	CodeMembershipNotFound:  http.StatusNotFound,
+	CodeClientNotFound:      http.StatusNotFound,
+	CodeInvalidGrant:        http.StatusBadRequest,
+	CodeSessionNotFound:     http.StatusNotFound,
+	CodeSessionRevoked:      http.StatusUnauthorized,
+	CodeTokenRevoked:        http.StatusUnauthorized,
+	CodeKeyExpired:          http.StatusUnauthorized,
+	CodeMFARequired:         http.StatusUnauthorized,
+	CodeMFACode:             http.StatusUnauthorized,
+	CodeMFAEnrolled:         http.StatusConflict,
+	CodeMFANotEnrolled:      http.StatusNotFound,
+	CodeWebAuthn:            http.StatusUnauthorized,
+	CodeCredentialNotFound:  http.StatusNotFound,
+	CodeCredentialExists:    http.StatusConflict,
+	CodeMFAEnrollRequired:   http.StatusForbidden,
+	CodeLoginLocked:         http.StatusTooManyRequests,
+	CodeRateLimit:           http.StatusTooManyRequests,
+	CodePasswordPolicy:      http.StatusBadRequest,
+	CodeConnectionNotFound:  http.StatusNotFound,
+	CodeFederation:          http.StatusUnauthorized,
+	CodeDirectory:           http.StatusServiceUnavailable,
+	CodeExternalIDInUse:     http.StatusConflict,
+	CodeEmailDomain:         http.StatusForbidden,
+	CodeIdentityNotLinked:   http.StatusForbidden,
+	CodeIdentityNotFound:    http.StatusNotFound,
 }
 
 // Some predefined errors
@@ -143,4 +199,34 @@ var (
 	ErrKeyNotFound         = &Error{errors.New("Key not found"), CodeKeyNotFound}
 	ErrAddrExists          = &Error{errors.New("Address exists"), CodeAddrExists}
 	ErrAddrSyntax          = &Error{errors.New("Error parsing address"), CodeBadRequest}
+	ErrUnauthorized        = &Error{errors.New("Unauthorized"), CodeUnauthorized}
+	ErrClientNotFound      = &Error{errors.New("OAuth client not found"), CodeClientNotFound}
+	ErrInvalidGrant        = &Error{errors.New("Invalid or expired authorization grant"), CodeInvalidGrant}
+	ErrRefreshTokenReuse   = &Error{errors.New("Refresh token reuse detected"), CodeInvalidGrant}
+	ErrSessionNotFound     = &Error{errors.New("Session not found"), CodeSessionNotFound}
+	ErrSessionRevoked      = &Error{errors.New("Session is revoked or expired"), CodeSessionRevoked}
+	ErrTokenRevoked        = &Error{errors.New("Token is revoked"), CodeTokenRevoked}
+	ErrKeyExpired          = &Error{errors.New("API key is expired"), CodeKeyExpired}
+	ErrKeyScope            = &Error{errors.New("API key scope exceeds membership roles"), CodeBadRequest}
+	ErrMFARequired         = &Error{errors.New("Second factor is required"), CodeMFARequired}
+	ErrMFACode             = &Error{errors.New("Invalid authentication code"), CodeMFACode}
+	ErrMFAEnrolled         = &Error{errors.New("Authenticator is already enrolled"), CodeMFAEnrolled}
+	ErrMFANotEnrolled      = &Error{errors.New("Authenticator is not enrolled"), CodeMFANotEnrolled}
+	ErrWebAuthn            = &Error{errors.New("WebAuthn verification failed"), CodeWebAuthn}
+	ErrCredentialNotFound  = &Error{errors.New("Credential not found"), CodeCredentialNotFound}
+	ErrCredentialExists    = &Error{errors.New("Credential is already registered"), CodeCredentialExists}
+	ErrMFAEnrollRequired   = &Error{errors.New("Tenant requires multi-factor authentication, enroll an allowed authenticator"), CodeMFAEnrollRequired}
+	ErrMFAPolicy           = &Error{errors.New("Invalid MFA policy"), CodeBadRequest}
+	ErrLoginLocked         = &Error{errors.New("Too many failed login attempts, try again later"), CodeLoginLocked}
+	ErrRateLimit           = &Error{errors.New("Too many requests"), CodeRateLimit}
+	ErrCurrentPassword     = &Error{errors.New("Current password is incorrect"), CodeForbidden}
+	ErrImpersonate         = &Error{errors.New("Can't impersonate a user with more privileges"), CodeForbidden}
+	ErrImpersonated        = &Error{errors.New("Not allowed with an impersonation token"), CodeForbidden}
+	ErrConnectionNotFound  = &Error{errors.New("Identity provider connection not found"), CodeConnectionNotFound}
+	ErrFederation          = &Error{errors.New("Upstream identity provider authentication failed"), CodeFederation}
+	ErrDirectory           = &Error{errors.New("User directory is unavailable"), CodeDirectory}
+	ErrExternalIDInUse     = &Error{errors.New("External ID is in use"), CodeExternalIDInUse}
+	ErrEmailDomain         = &Error{errors.New("Email domain is not allowed to sign up"), CodeEmailDomain}
+	ErrIdentityNotLinked   = &Error{errors.New("Account is not linked to the identity provider"), CodeIdentityNotLinked}
+	ErrIdentityNotFound    = &Error{errors.New("Federated identity not found"), CodeIdentityNotFound}
 )
@@ -0,0 +1,46 @@
+package errors
+
+import (
+	"strings"
+)
+
+// Password policy violations
+const (
+	ViolationMinLength = "min_length"
+	ViolationMaxLength = "max_length"
+	ViolationUpper     = "uppercase"
+	ViolationLower     = "lowercase"
+	ViolationDigit     = "digit"
+	ViolationSymbol    = "symbol"
+	ViolationReused    = "reused"
+	ViolationBreached  = "breached"
+)
+
+var violationText = map[string]string{
+	ViolationMinLength: "too short",
+	ViolationMaxLength: "too long",
+	ViolationUpper:     "no uppercase letters",
+	ViolationLower:     "no lowercase letters",
+	ViolationDigit:     "no digits",
+	ViolationSymbol:    "no symbols",
+	ViolationReused:    "used recently",
+	ViolationBreached:  "found in a data breach",
+}
+
+// PasswordPolicyError lists the password policy rules the password violates
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		if t, ok := violationText[v]; ok {
+			s[i] = t
+		} else {
+			s[i] = v
+		}
+	}
+
+	return "Password doesn't meet the policy: " + strings.Join(s, ", ")
+}
@@ -0,0 +1,102 @@
+package federation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout = 10 * time.Second
+	maxRedirects       = 3
+)
+
+var ErrAddress = errors.New("federation: address is not public")
+
+var defaultHTTPClient = NewHTTPClient(false)
+
+// PublicIP returns false for loopback, private, link-local, multicast and unspecified addresses
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// RFC 6598 carrier-grade NAT range
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// ValidIssuer checks the issuer is an https URL without query and fragment. Hosts given as IP literals
+// or localhost must be public. Names are checked again when connecting as they may resolve to anything
+func ValidIssuer(issuer string, insecure bool) bool {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+
+	if insecure {
+		return u.Scheme == "https" || u.Scheme == "http"
+	}
+
+	if u.Scheme != "https" {
+		return false
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return PublicIP(ip)
+	}
+
+	return host != "localhost"
+}
+
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+		return fmt.Errorf("%v: %s", ErrAddress, host)
+	}
+
+	return nil
+}
+
+// NewHTTPClient returns a client for requests to identity providers registered by tenant owners. Requests time out,
+// redirects are limited and, unless insecure is set, must stay on https and never connect to non public addresses
+func NewHTTPClient(insecure bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   defaultHTTPTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if !insecure {
+		dialer.Control = dialControl
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   defaultHTTPTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   defaultHTTPTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("federation: stopped after %d redirects", maxRedirects)
+			}
+			if !insecure && req.URL.Scheme != "https" {
+				return fmt.Errorf("federation: redirect to %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package federation
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidIssuer(t *testing.T) {
+	tests := []struct {
+		issuer   string
+		insecure bool
+		valid    bool
+	}{
+		{"https://login.example.com", false, true},
+		{"https://login.example.com/tenant/", false, true},
+		{"http://login.example.com", false, false},
+		{"https://login.example.com?x=1", false, false},
+		{"https://localhost", false, false},
+		{"https://127.0.0.1", false, false},
+		{"https://10.1.2.3", false, false},
+		{"https://169.254.169.254", false, false},
+		{"https://[::1]", false, false},
+		{"https://100.64.0.1", false, false},
+		{"https://8.8.8.8", false, true},
+		{"http://127.0.0.1:8080", true, true},
+		{"ftp://login.example.com", true, false},
+	}
+
+	for _, tt := range tests {
+		if v := ValidIssuer(tt.issuer, tt.insecure); v != tt.valid {
+			t.Errorf("%s (insecure: %t): got %t", tt.issuer, tt.insecure, v)
+		}
+	}
+}
+
+func TestHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+
+	var c Client
+	if _, err := c.Discover(context.Background(), idp.URL); err == nil {
+		t.Error("loopback provider reached")
+	}
+}
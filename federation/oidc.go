@@ -0,0 +1,346 @@
+// Package federation implements the relying party side of upstream identity providers
+package federation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/keys"
+)
+
+const (
+	defaultCacheTTL = time.Hour
+	minKeysRefresh  = time.Minute
+	maxResponseSize = 1 << 20
+)
+
+var (
+	ErrIssuer    = errors.New("federation: issuer mismatch")
+	ErrAudience  = errors.New("federation: audience mismatch")
+	ErrNonce     = errors.New("federation: nonce mismatch")
+	ErrNoIDToken = errors.New("federation: ID token is missing")
+	ErrKey       = errors.New("federation: verification key not found")
+)
+
+// Metadata is a subset of the OpenID Provider metadata
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider describes an upstream OpenID Connect provider registration
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// Client talks to upstream OpenID Connect providers. Discovery documents and key sets are cached
+type Client struct {
+	HTTPClient *http.Client
+	CacheTTL   time.Duration
+
+	mtx   sync.Mutex
+	cache map[string]*cacheEntry
+	now   func() time.Time
+}
+
+type cacheEntry struct {
+	meta    *Metadata
+	keys    *keys.JWKSet
+	fetched time.Time
+	expires time.Time
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+func (c *Client) timeNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Client) getJSON(ctx context.Context, uri string, v interface{}) error {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("federation: %s: %s", uri, res.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+}
+
+func (c *Client) entry(ctx context.Context, issuer string, refreshKeys bool) (*cacheEntry, error) {
+	c.mtx.Lock()
+	e, ok := c.cache[issuer]
+	c.mtx.Unlock()
+
+	now := c.timeNow()
+	if ok && now.Before(e.expires) && (!refreshKeys || now.Before(e.fetched.Add(minKeysRefresh))) {
+		return e, nil
+	}
+
+	var meta Metadata
+	if err := c.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, err
+	}
+
+	if meta.Issuer != issuer {
+		return nil, ErrIssuer
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("federation: incomplete provider metadata: %s", issuer)
+	}
+
+	var set keys.JWKSet
+	if err := c.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	ttl := c.CacheTTL
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+
+	e = &cacheEntry{
+		meta:    &meta,
+		keys:    &set,
+		fetched: now,
+		expires: now.Add(ttl),
+	}
+
+	c.mtx.Lock()
+	if c.cache == nil {
+		c.cache = make(map[string]*cacheEntry)
+	}
+	c.cache[issuer] = e
+	c.mtx.Unlock()
+
+	return e, nil
+}
+
+// Discover returns the provider metadata
+func (c *Client) Discover(ctx context.Context, issuer string) (*Metadata, error) {
+	e, err := c.entry(ctx, issuer, false)
+	if err != nil {
+		return nil, err
+	}
+	return e.meta, nil
+}
+
+// CodeChallenge returns the PKCE S256 challenge for the verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider's authorization endpoint URL to redirect the user agent to
+func (c *Client) AuthCodeURL(ctx context.Context, p *Provider, redirectURI, state, nonce, verifier string) (string, error) {
+	meta, err := c.Discover(ctx, p.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	scope := []string{"openid"}
+	for _, s := range p.Scopes {
+		if s != "openid" {
+			scope = append(scope, s)
+		}
+	}
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(scope, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems the authorization code and returns verified ID token claims
+func (c *Client) Exchange(ctx context.Context, p *Provider, redirectURI, code, verifier, nonce string) (jwt.MapClaims, error) {
+	meta, err := c.Discover(ctx, p.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequest("POST", meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 Section 2.3.1
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	res, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("federation: token endpoint: %s: %v", res.Status, err)
+	}
+
+	if res.StatusCode != http.StatusOK || tokenResponse.Error != "" {
+		return nil, fmt.Errorf("federation: token endpoint: %s: %s %s", res.Status, tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+
+	if tokenResponse.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	return c.VerifyIDToken(ctx, p, tokenResponse.IDToken, nonce)
+}
+
+func (c *Client) lookupKey(ctx context.Context, issuer, kid string) (interface{}, error) {
+	for _, refresh := range []bool{false, true} {
+		e, err := c.entry(ctx, issuer, refresh)
+		if err != nil {
+			return nil, err
+		}
+
+		var found *keys.JWK
+		for _, k := range e.keys.Keys {
+			if k.Use != "" && k.Use != "sig" {
+				continue
+			}
+
+			if kid == "" || k.KeyID == kid {
+				if found != nil {
+					// Ambiguous
+					return nil, ErrKey
+				}
+				found = k
+			}
+		}
+
+		if found != nil {
+			return found.PublicKey()
+		}
+		// The provider may have rotated its keys
+	}
+
+	return nil, ErrKey
+}
+
+func hasAudience(claims jwt.MapClaims, aud string) bool {
+	switch v := claims["aud"].(type) {
+	case string:
+		return v == aud
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyIDToken checks the ID token signature, issuer, audience, lifetime and nonce
+func (c *Client) VerifyIDToken(ctx context.Context, p *Provider, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			// Symmetric signatures use the client secret as a key
+			if p.ClientSecret == "" {
+				return nil, ErrKey
+			}
+			return []byte(p.ClientSecret), nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return c.lookupKey(ctx, p.Issuer, kid)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, errors.New("federation: ID token has no expiry")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, ErrIssuer
+	}
+
+	if !hasAudience(claims, p.ClientID) {
+		return nil, ErrAudience
+	}
+
+	if aud, ok := claims["aud"].([]interface{}); ok && len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, ErrAudience
+		}
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, ErrNonce
+	}
+
+	return claims, nil
+}
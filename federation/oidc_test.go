@@ -0,0 +1,306 @@
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/keys"
+)
+
+// fakeIdP is a minimal in-process OpenID Provider
+type fakeIdP struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	clientID string
+	secret   string
+	claims   jwt.MapClaims
+
+	// Issued code and its binding
+	code      string
+	nonce     string
+	challenge string
+	redirect  string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdP{
+		key:      key,
+		kid:      "key1",
+		clientID: "client",
+		secret:   "s3cr3t+/",
+	}
+
+	m := http.NewServeMux()
+	m.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Metadata{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+
+	m.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		j := keys.NewJWK(&idp.key.PublicKey)
+		j.KeyID = idp.kid
+		j.Use = "sig"
+		json.NewEncoder(w).Encode(&keys.JWKSet{Keys: []*keys.JWK{j}})
+	})
+
+	// Approves the request immediately
+	m.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client_id") != idp.clientID || q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		idp.code = "code123"
+		idp.nonce = q.Get("nonce")
+		idp.challenge = q.Get("code_challenge")
+		idp.redirect = q.Get("redirect_uri")
+
+		u, _ := url.Parse(idp.redirect)
+		v := url.Values{"code": {idp.code}, "state": {q.Get("state")}}
+		u.RawQuery = v.Encode()
+		http.Redirect(w, r, u.String(), http.StatusFound)
+	})
+
+	m.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+
+		if id != idp.clientID || secret != idp.secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		if r.PostFormValue("code") != idp.code ||
+			r.PostFormValue("redirect_uri") != idp.redirect ||
+			CodeChallenge(r.PostFormValue("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		idToken, err := idp.sign(jwt.MapClaims{"nonce": idp.nonce})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+
+	idp.Server = httptest.NewServer(m)
+	return idp
+}
+
+func (idp *fakeIdP) sign(extra jwt.MapClaims) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "upstream-user",
+		"aud":            idp.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	return token.SignedString(idp.key)
+}
+
+func (idp *fakeIdP) provider() *Provider {
+	return &Provider{
+		Issuer:       idp.URL,
+		ClientID:     idp.clientID,
+		ClientSecret: idp.secret,
+		Scopes:       []string{"email", "profile"},
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+
+	c := Client{HTTPClient: NewHTTPClient(true)}
+	p := idp.provider()
+	ctx := context.Background()
+
+	authURL, err := c.AuthCodeURL(ctx, p, "https://rp.example.com/callback", "state1", "nonce1", "verifier1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(authURL)
+	if s := u.Query().Get("scope"); s != "openid email profile" {
+		t.Errorf("scope: %q", s)
+	}
+
+	// Follow the user agent up to the redirect back
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := noRedirect.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	loc, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loc.Query().Get("state") != "state1" {
+		t.Errorf("state: %q", loc.Query().Get("state"))
+	}
+
+	code := loc.Query().Get("code")
+
+	if _, err := c.Exchange(ctx, p, "https://rp.example.com/callback", code, "wrong", "nonce1"); err == nil {
+		t.Error("PKCE verifier mismatch must fail")
+	}
+
+	if _, err := c.Exchange(ctx, p, "https://rp.example.com/callback", code, "verifier1", "nonce2"); err != ErrNonce {
+		t.Errorf("expected nonce mismatch, got %v", err)
+	}
+
+	claims, err := c.Exchange(ctx, p, "https://rp.example.com/callback", code, "verifier1", "nonce1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims["email"] != "user@example.com" || claims["email_verified"] != true {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+
+	c := Client{HTTPClient: NewHTTPClient(true)}
+	p := idp.provider()
+	ctx := context.Background()
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": idp.URL, "aud": idp.clientID, "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n",
+	})
+	forged.Header["kid"] = idp.kid
+	forgedStr, err := forged.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() (string, error)
+		ok    bool
+	}{
+		{"valid", func() (string, error) { return idp.sign(jwt.MapClaims{"nonce": "n"}) }, true},
+		{"multiple audiences", func() (string, error) {
+			return idp.sign(jwt.MapClaims{"nonce": "n", "aud": []string{idp.clientID, "other"}, "azp": idp.clientID})
+		}, true},
+		{"foreign azp", func() (string, error) {
+			return idp.sign(jwt.MapClaims{"nonce": "n", "aud": []string{idp.clientID, "other"}, "azp": "other"})
+		}, false},
+		{"audience", func() (string, error) { return idp.sign(jwt.MapClaims{"nonce": "n", "aud": "other"}) }, false},
+		{"issuer", func() (string, error) {
+			return idp.sign(jwt.MapClaims{"nonce": "n", "iss": "https://evil.example.com"})
+		}, false},
+		{"expired", func() (string, error) {
+			return idp.sign(jwt.MapClaims{"nonce": "n", "exp": time.Now().Add(-time.Minute).Unix()})
+		}, false},
+		{"no expiry", func() (string, error) { return idp.sign(jwt.MapClaims{"nonce": "n", "exp": nil}) }, false},
+		{"signature", func() (string, error) { return forgedStr, nil }, false},
+		{"symmetric", func() (string, error) {
+			return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"iss": idp.URL, "aud": idp.clientID, "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n",
+			}).SignedString([]byte(idp.secret))
+		}, true},
+	}
+
+	for _, tc := range tests {
+		tok, err := tc.token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.VerifyIDToken(ctx, p, tok, "n")
+		if tc.ok && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%s: error expected", tc.name)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+
+	now := time.Now()
+	c := Client{HTTPClient: NewHTTPClient(true), now: func() time.Time { return now }}
+	p := idp.provider()
+	ctx := context.Background()
+
+	if _, err := c.Discover(ctx, p.Issuer); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.key = key
+	idp.kid = "key2"
+
+	tok, err := idp.sign(jwt.MapClaims{"nonce": "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached key set is too fresh to be refetched
+	if _, err := c.VerifyIDToken(ctx, p, tok, "n"); err == nil {
+		t.Error("error expected")
+	}
+
+	now = now.Add(2 * minKeysRefresh)
+	if _, err := c.VerifyIDToken(ctx, p, tok, "n"); err != nil {
+		t.Error(err)
+	}
+}
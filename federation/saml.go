@@ -0,0 +1,536 @@
+package federation
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// SAML 2.0 identifiers
+const (
+	SAMLProtocolNS      = "urn:oasis:names:tc:SAML:2.0:protocol"
+	SAMLAssertionNS     = "urn:oasis:names:tc:SAML:2.0:assertion"
+	SAMLMetadataNS      = "urn:oasis:names:tc:SAML:2.0:metadata"
+	SAMLRedirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	SAMLPOSTBinding     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	SAMLEmailNameID     = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	SAMLStatusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	SAMLBearer          = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	xmldsigNS        = "http://www.w3.org/2000/09/xmldsig#"
+	sigAlgRSASHA256  = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	defaultClockSkew = 2 * time.Minute
+	maxSAMLSize      = 1 << 20
+)
+
+var (
+	ErrSAMLSignature = errors.New("saml: assertion is not signed by the identity provider")
+	ErrSAMLResponse  = errors.New("saml: malformed response")
+)
+
+// IdentityProvider is a SAML identity provider description taken from its metadata
+type IdentityProvider struct {
+	EntityID     string
+	SSOURL       string // HTTP-Redirect binding endpoint
+	Certificates []*x509.Certificate
+}
+
+type idpMetadata struct {
+	EntityID          string `xml:"entityID,attr"`
+	IDPSSODescriptors []struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+	EntityDescriptors []*idpMetadata `xml:"EntityDescriptor"` // EntitiesDescriptor
+}
+
+// ParseIdPMetadata extracts the identity provider entity ID, SSO endpoint and signing certificates
+func ParseIdPMetadata(data []byte) (*IdentityProvider, error) {
+	var md idpMetadata
+	if err := xml.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("saml: metadata: %v", err)
+	}
+
+	// Pick the first identity provider of the group
+	entity := &md
+	if len(md.IDPSSODescriptors) == 0 {
+		entity = nil
+		for _, e := range md.EntityDescriptors {
+			if len(e.IDPSSODescriptors) != 0 {
+				entity = e
+				break
+			}
+		}
+	}
+
+	if entity == nil || entity.EntityID == "" {
+		return nil, errors.New("saml: metadata: no identity provider found")
+	}
+
+	idp := IdentityProvider{
+		EntityID: entity.EntityID,
+	}
+
+	for _, desc := range entity.IDPSSODescriptors {
+		for _, svc := range desc.SingleSignOnServices {
+			if svc.Binding == SAMLRedirectBinding && idp.SSOURL == "" {
+				idp.SSOURL = svc.Location
+			}
+		}
+
+		for _, kd := range desc.KeyDescriptors {
+			if kd.Use != "" && kd.Use != "signing" {
+				continue
+			}
+
+			for _, c := range kd.Certificates {
+				der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(c), ""))
+				if err != nil {
+					return nil, fmt.Errorf("saml: metadata: %v", err)
+				}
+
+				cert, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, fmt.Errorf("saml: metadata: %v", err)
+				}
+
+				idp.Certificates = append(idp.Certificates, cert)
+			}
+		}
+	}
+
+	if idp.SSOURL == "" {
+		return nil, errors.New("saml: metadata: HTTP-Redirect single sign-on endpoint is required")
+	}
+
+	if len(idp.Certificates) == 0 {
+		return nil, errors.New("saml: metadata: no signing certificate found")
+	}
+
+	return &idp, nil
+}
+
+// ServiceProvider describes the local SAML service provider
+type ServiceProvider struct {
+	EntityID    string
+	ACSURL      string
+	Key         *rsa.PrivateKey // Signs authentication requests if set
+	Certificate *x509.Certificate
+	ClockSkew   time.Duration
+
+	now func() time.Time
+}
+
+func (sp *ServiceProvider) timeNow() time.Time {
+	if sp.now != nil {
+		return sp.now()
+	}
+	return time.Now()
+}
+
+func (sp *ServiceProvider) clockSkew() time.Duration {
+	if sp.ClockSkew != 0 {
+		return sp.ClockSkew
+	}
+	return defaultClockSkew
+}
+
+type spMetadata struct {
+	XMLName  xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID string   `xml:"entityID,attr"`
+
+	SPSSODescriptor struct {
+		AuthnRequestsSigned        bool   `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool   `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string `xml:"protocolSupportEnumeration,attr"`
+
+		KeyDescriptor *struct {
+			Use     string `xml:"use,attr"`
+			KeyInfo struct {
+				XMLName         xml.Name `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+				X509Certificate string   `xml:"X509Data>X509Certificate"`
+			}
+		} `xml:"KeyDescriptor,omitempty"`
+
+		NameIDFormat string `xml:"NameIDFormat"`
+
+		AssertionConsumerService struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+			Index    int    `xml:"index,attr"`
+		}
+	}
+}
+
+// Metadata returns the service provider metadata document
+func (sp *ServiceProvider) Metadata() ([]byte, error) {
+	var md spMetadata
+	md.EntityID = sp.EntityID
+
+	desc := &md.SPSSODescriptor
+	desc.AuthnRequestsSigned = sp.Key != nil
+	desc.WantAssertionsSigned = true
+	desc.ProtocolSupportEnumeration = SAMLProtocolNS
+	desc.NameIDFormat = SAMLEmailNameID
+	desc.AssertionConsumerService.Binding = SAMLPOSTBinding
+	desc.AssertionConsumerService.Location = sp.ACSURL
+	desc.AssertionConsumerService.Index = 1
+
+	if sp.Certificate != nil {
+		desc.KeyDescriptor = &struct {
+			Use     string `xml:"use,attr"`
+			KeyInfo struct {
+				XMLName         xml.Name `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+				X509Certificate string   `xml:"X509Data>X509Certificate"`
+			}
+		}{Use: "signing"}
+		desc.KeyDescriptor.KeyInfo.X509Certificate = base64.StdEncoding.EncodeToString(sp.Certificate.Raw)
+	}
+
+	buf, err := xml.MarshalIndent(&md, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), buf...), nil
+}
+
+type authnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      struct {
+		XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+		Value   string   `xml:",chardata"`
+	}
+	NameIDPolicy struct {
+		XMLName     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+		Format      string   `xml:"Format,attr"`
+		AllowCreate bool     `xml:"AllowCreate,attr"`
+	}
+}
+
+func samlID() (string, error) {
+	var buf [20]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	// xs:ID must not start with a digit
+	return "_" + hex.EncodeToString(buf[:]), nil
+}
+
+// AuthnRequestURL returns the HTTP-Redirect binding URL of a new authentication request along with the request ID
+func (sp *ServiceProvider) AuthnRequestURL(idp *IdentityProvider, relayState string) (string, string, error) {
+	id, err := samlID()
+	if err != nil {
+		return "", "", err
+	}
+
+	req := authnRequest{
+		ID:                          id,
+		Version:                     "2.0",
+		IssueInstant:                sp.timeNow().UTC().Format(time.RFC3339),
+		Destination:                 idp.SSOURL,
+		AssertionConsumerServiceURL: sp.ACSURL,
+		ProtocolBinding:             SAMLPOSTBinding,
+	}
+	req.Issuer.Value = sp.EntityID
+	req.NameIDPolicy.Format = SAMLEmailNameID
+	req.NameIDPolicy.AllowCreate = true
+
+	data, err := xml.Marshal(&req)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", "", err
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+
+	// SAML Bindings Section 3.4.4.1: the signature covers the query string in this exact order
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+
+	if sp.Key != nil {
+		query += "&SigAlg=" + url.QueryEscape(sigAlgRSASHA256)
+
+		ctx, err := dsig.NewSigningContext(sp.Key, nil)
+		if err != nil {
+			return "", "", err
+		}
+
+		sig, err := ctx.SignString(query)
+		if err != nil {
+			return "", "", err
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig))
+	}
+
+	dest := idp.SSOURL
+	if strings.Contains(dest, "?") {
+		dest += "&"
+	} else {
+		dest += "?"
+	}
+
+	return dest + query, id, nil
+}
+
+// Assertion holds the authenticated subject and its attributes
+type Assertion struct {
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	Attributes   map[string][]string
+}
+
+func childElements(el *etree.Element, tag string) []*etree.Element {
+	var res []*etree.Element
+	for _, c := range el.ChildElements() {
+		if c.Tag == tag {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func childElement(el *etree.Element, tag string) *etree.Element {
+	if el == nil {
+		return nil
+	}
+	if c := childElements(el, tag); len(c) == 1 {
+		return c[0]
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// ParseResponse validates the base64 encoded HTTP-POST binding response and returns its assertion.
+// The response or the assertion must be signed by one of the identity provider's certificates
+func (sp *ServiceProvider) ParseResponse(idp *IdentityProvider, samlResponse, requestID string) (*Assertion, error) {
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, ErrSAMLResponse
+	}
+
+	if len(raw) > maxSAMLSize {
+		return nil, ErrSAMLResponse
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, ErrSAMLResponse
+	}
+
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" || response.NamespaceURI() != SAMLProtocolNS {
+		return nil, ErrSAMLResponse
+	}
+
+	now := sp.timeNow()
+	vctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: idp.Certificates})
+	vctx.Clock = dsig.NewFakeClockAt(now)
+
+	// Only use what the signature covers from now on
+	var signed bool
+	if len(childElements(response, "Signature")) != 0 {
+		if response, err = vctx.Validate(response); err != nil {
+			return nil, fmt.Errorf("saml: %v", err)
+		}
+		signed = true
+	}
+
+	if v := response.SelectAttrValue("Destination", ""); v != "" && v != sp.ACSURL {
+		return nil, errors.New("saml: destination mismatch")
+	}
+
+	if v := response.SelectAttrValue("InResponseTo", ""); v != requestID {
+		return nil, errors.New("saml: response doesn't match the request")
+	}
+
+	if iss := childElement(response, "Issuer"); iss != nil && strings.TrimSpace(iss.Text()) != idp.EntityID {
+		return nil, errors.New("saml: issuer mismatch")
+	}
+
+	code := childElement(childElement(response, "Status"), "StatusCode")
+	if code == nil {
+		return nil, ErrSAMLResponse
+	}
+
+	if status := code.SelectAttrValue("Value", ""); status != SAMLStatusSuccess {
+		return nil, fmt.Errorf("saml: authentication failed: %s", status)
+	}
+
+	if len(childElements(response, "EncryptedAssertion")) != 0 {
+		return nil, errors.New("saml: encrypted assertions are not supported")
+	}
+
+	assertions := childElements(response, "Assertion")
+	if len(assertions) != 1 {
+		return nil, ErrSAMLResponse
+	}
+	assertion := assertions[0]
+
+	if len(childElements(assertion, "Signature")) != 0 {
+		// Carry namespaces declared by the response over to the detached assertion
+		nsCtx, err := etreeutils.NSBuildParentContext(assertion)
+		if err != nil {
+			return nil, ErrSAMLResponse
+		}
+
+		if assertion, err = etreeutils.NSDetatch(nsCtx, assertion); err != nil {
+			return nil, ErrSAMLResponse
+		}
+
+		if assertion, err = vctx.Validate(assertion); err != nil {
+			return nil, fmt.Errorf("saml: %v", err)
+		}
+		signed = true
+	}
+
+	if !signed {
+		return nil, ErrSAMLSignature
+	}
+
+	return sp.validateAssertion(idp, assertion, requestID, now)
+}
+
+func (sp *ServiceProvider) validateAssertion(idp *IdentityProvider, assertion *etree.Element, requestID string, now time.Time) (*Assertion, error) {
+	skew := sp.clockSkew()
+
+	if iss := childElement(assertion, "Issuer"); iss == nil || strings.TrimSpace(iss.Text()) != idp.EntityID {
+		return nil, errors.New("saml: issuer mismatch")
+	}
+
+	// Time window and audience
+	cond := childElement(assertion, "Conditions")
+	if cond == nil {
+		return nil, errors.New("saml: conditions are missing")
+	}
+
+	if v := cond.SelectAttrValue("NotBefore", ""); v != "" {
+		t, ok := parseTime(v)
+		if !ok || now.Add(skew).Before(t) {
+			return nil, errors.New("saml: assertion is not yet valid")
+		}
+	}
+
+	if v := cond.SelectAttrValue("NotOnOrAfter", ""); v != "" {
+		t, ok := parseTime(v)
+		if !ok || !now.Add(-skew).Before(t) {
+			return nil, errors.New("saml: assertion is expired")
+		}
+	}
+
+	restrictions := childElements(cond, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return nil, errors.New("saml: audience restriction is missing")
+	}
+
+	// Each restriction must include us
+	for _, r := range restrictions {
+		var ok bool
+		for _, a := range childElements(r, "Audience") {
+			if strings.TrimSpace(a.Text()) == sp.EntityID {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return nil, errors.New("saml: audience mismatch")
+		}
+	}
+
+	// Subject
+	subject := childElement(assertion, "Subject")
+	nameID := childElement(subject, "NameID")
+	if nameID == nil {
+		return nil, errors.New("saml: subject is missing")
+	}
+
+	var confirmed bool
+	for _, sc := range childElements(subject, "SubjectConfirmation") {
+		if sc.SelectAttrValue("Method", "") != SAMLBearer {
+			continue
+		}
+
+		data := childElement(sc, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+
+		exp, ok := parseTime(data.SelectAttrValue("NotOnOrAfter", ""))
+		if !ok || !now.Add(-skew).Before(exp) {
+			continue
+		}
+
+		if data.SelectAttrValue("Recipient", "") != sp.ACSURL || data.SelectAttrValue("InResponseTo", "") != requestID {
+			continue
+		}
+
+		confirmed = true
+		break
+	}
+
+	if !confirmed {
+		return nil, errors.New("saml: subject confirmation failed")
+	}
+
+	res := Assertion{
+		NameID:       strings.TrimSpace(nameID.Text()),
+		NameIDFormat: nameID.SelectAttrValue("Format", ""),
+		Attributes:   make(map[string][]string),
+	}
+
+	if st := childElement(assertion, "AuthnStatement"); st != nil {
+		res.SessionIndex = st.SelectAttrValue("SessionIndex", "")
+	}
+
+	for _, st := range childElements(assertion, "AttributeStatement") {
+		for _, attr := range childElements(st, "Attribute") {
+			name := attr.SelectAttrValue("Name", "")
+			for _, v := range childElements(attr, "AttributeValue") {
+				res.Attributes[name] = append(res.Attributes[name], strings.TrimSpace(v.Text()))
+			}
+		}
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,358 @@
+package federation
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const (
+	testSPEntityID = "https://sp.example.com/saml/metadata"
+	testACSURL     = "https://sp.example.com/saml/acs"
+	testIdPEntity  = "https://idp.example.com/metadata"
+	testRequestID  = "_request"
+)
+
+func newCertificate(t *testing.T, key *rsa.PrivateKey, cn string) *x509.Certificate {
+	tpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tpl, &tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+type testSAMLIdP struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestSAMLIdP(t *testing.T) *testSAMLIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSAMLIdP{
+		key:  key,
+		cert: newCertificate(t, key, "idp"),
+	}
+}
+
+func (idp *testSAMLIdP) metadata() []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+  <md:EntityDescriptor entityID="%s">
+    <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+      <md:KeyDescriptor use="encryption">
+        <ds:KeyInfo><ds:X509Data><ds:X509Certificate>invalid</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+      </md:KeyDescriptor>
+      <md:KeyDescriptor use="signing">
+        <ds:KeyInfo><ds:X509Data><ds:X509Certificate>
+          %s
+        </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+      </md:KeyDescriptor>
+      <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+      <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+    </md:IDPSSODescriptor>
+  </md:EntityDescriptor>
+</md:EntitiesDescriptor>`, testIdPEntity, base64.StdEncoding.EncodeToString(idp.cert.Raw)))
+}
+
+type responseParams struct {
+	now          time.Time
+	audience     string
+	inResponseTo string
+	signResponse bool
+	signAssert   bool
+}
+
+func (idp *testSAMLIdP) response(t *testing.T, p *responseParams) string {
+	ts := func(d time.Duration) string { return p.now.Add(d).UTC().Format(time.RFC3339) }
+
+	src := fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_response" Version="2.0" IssueInstant="%[1]s" Destination="%[2]s" InResponseTo="%[3]s">
+  <saml:Issuer>%[4]s</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion ID="_assertion" Version="2.0" IssueInstant="%[1]s">
+    <saml:Issuer>%[4]s</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">user@example.com</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData NotOnOrAfter="%[5]s" Recipient="%[2]s" InResponseTo="%[3]s"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="%[6]s" NotOnOrAfter="%[5]s">
+      <saml:AudienceRestriction><saml:Audience>%[7]s</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AuthnStatement AuthnInstant="%[1]s" SessionIndex="_session"/>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="groups">
+        <saml:AttributeValue>admins</saml:AttributeValue>
+        <saml:AttributeValue>users</saml:AttributeValue>
+      </saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`, ts(0), testACSURL, p.inResponseTo, testIdPEntity, ts(5*time.Minute), ts(-time.Minute), p.audience)
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(src); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := dsig.NewSigningContext(idp.key, [][]byte{idp.cert.Raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(el *etree.Element) *etree.Element {
+		res, err := ctx.SignEnveloped(el)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	root := doc.Root()
+	if p.signAssert {
+		assertion := root.SelectElement("Assertion")
+		nsCtx, err := etreeutils.NSBuildParentContext(assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		detached, err := etreeutils.NSDetatch(nsCtx, assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root.InsertChildAt(assertion.Index(), sign(detached))
+		root.RemoveChild(assertion)
+	}
+
+	if p.signResponse {
+		doc.SetRoot(sign(root))
+	}
+
+	buf, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func TestParseIdPMetadata(t *testing.T) {
+	idp := newTestSAMLIdP(t)
+
+	res, err := ParseIdPMetadata(idp.metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.EntityID != testIdPEntity || res.SSOURL != "https://idp.example.com/sso" {
+		t.Errorf("unexpected provider: %#v", res)
+	}
+
+	if len(res.Certificates) != 1 || !res.Certificates[0].Equal(idp.cert) {
+		t.Errorf("unexpected certificates: %v", res.Certificates)
+	}
+
+	if _, err := ParseIdPMetadata([]byte(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="sp"/>`)); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := newTestSAMLIdP(t)
+
+	provider, err := ParseIdPMetadata(idp.metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	sp := ServiceProvider{
+		EntityID: testSPEntityID,
+		ACSURL:   testACSURL,
+		now:      func() time.Time { return now },
+	}
+
+	valid := func() *responseParams {
+		return &responseParams{
+			now:          now,
+			audience:     testSPEntityID,
+			inResponseTo: testRequestID,
+			signAssert:   true,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		params func(p *responseParams)
+		mangle func(s string) string
+		ok     bool
+	}{
+		{name: "assertion signed", ok: true},
+		{name: "response signed", params: func(p *responseParams) { p.signAssert, p.signResponse = false, true }, ok: true},
+		{name: "both signed", params: func(p *responseParams) { p.signResponse = true }, ok: true},
+		{name: "unsigned", params: func(p *responseParams) { p.signAssert = false }},
+		{name: "wrong audience", params: func(p *responseParams) { p.audience = "https://other.example.com" }},
+		{name: "wrong request", params: func(p *responseParams) { p.inResponseTo = "_other" }},
+		{name: "expired", params: func(p *responseParams) { p.now = now.Add(-time.Hour) }},
+		{name: "not yet valid", params: func(p *responseParams) { p.now = now.Add(time.Hour) }},
+		{
+			name: "tampered",
+			mangle: func(s string) string {
+				raw, _ := base64.StdEncoding.DecodeString(s)
+				return base64.StdEncoding.EncodeToString(bytes.Replace(raw, []byte("user@example.com"), []byte("evil@example.com"), 1))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := valid()
+			if test.params != nil {
+				test.params(p)
+			}
+
+			resp := idp.response(t, p)
+			if test.mangle != nil {
+				resp = test.mangle(resp)
+			}
+
+			res, err := sp.ParseResponse(provider, resp, testRequestID)
+			if !test.ok {
+				if err == nil {
+					t.Error("error expected")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.NameID != "user@example.com" || res.SessionIndex != "_session" {
+				t.Errorf("unexpected assertion: %#v", res)
+			}
+
+			if g := res.Attributes["groups"]; len(g) != 2 || g[0] != "admins" || g[1] != "users" {
+				t.Errorf("unexpected attributes: %v", res.Attributes)
+			}
+		})
+	}
+
+	// Someone else's certificate
+	other := newTestSAMLIdP(t)
+	if _, err := sp.ParseResponse(provider, other.response(t, valid()), testRequestID); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sp := ServiceProvider{
+		EntityID:    testSPEntityID,
+		ACSURL:      testACSURL,
+		Key:         key,
+		Certificate: newCertificate(t, key, "sp"),
+	}
+
+	idp := IdentityProvider{EntityID: testIdPEntity, SSOURL: "https://idp.example.com/sso"}
+
+	dest, id, err := sp.AuthnRequestURL(&idp, "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if q.Get("RelayState") != "relay" || q.Get("SigAlg") != sigAlgRSASHA256 {
+		t.Errorf("unexpected query: %v", q)
+	}
+
+	// Verify the signature over the raw query
+	signed := u.RawQuery[:strings.Index(u.RawQuery, "&Signature=")]
+	sig, err := base64.StdEncoding.DecodeString(q.Get("Signature"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Error(err)
+	}
+
+	// Decode the request
+	raw, err := base64.StdEncoding.DecodeString(q.Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		t.Fatal(err)
+	}
+
+	req := doc.Root()
+	if req.Tag != "AuthnRequest" || req.SelectAttrValue("ID", "") != id || req.SelectAttrValue("AssertionConsumerServiceURL", "") != testACSURL {
+		t.Errorf("unexpected request: %s", data)
+	}
+
+	if iss := req.SelectElement("Issuer"); iss == nil || iss.Text() != testSPEntityID {
+		t.Errorf("unexpected request: %s", data)
+	}
+
+	// Metadata advertises the signing certificate
+	md, err := sp.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(md, []byte(base64.StdEncoding.EncodeToString(sp.Certificate.Raw))) || !bytes.Contains(md, []byte(`AuthnRequestsSigned="true"`)) {
+		t.Errorf("unexpected metadata: %s", md)
+	}
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ecadlabs/auth/errors"
 	"github.com/ecadlabs/auth/middleware"
@@ -15,8 +17,22 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// apiKeySecretHeader carries the key secret on token exchange
+const apiKeySecretHeader = "X-Api-Key-Secret"
+
 type newKey struct {
-	TenantID uuid.UUID `json:"tenant_id"`
+	TenantID    uuid.UUID  `json:"tenant_id"`
+	Label       string     `json:"label"`
+	Expires     *time.Time `json:"expires"`
+	Roles       []string   `json:"roles"`
+	Permissions []string   `json:"permissions"`
+}
+
+// apiKeyCredentials is returned only once, on key creation
+type apiKeyCredentials struct {
+	*storage.APIKey
+	ClientID     uuid.UUID `json:"client_id"`
+	ClientSecret string    `json:"client_secret"`
 }
 
 func (u *Users) NewAPIKey(w http.ResponseWriter, r *http.Request) {
@@ -52,16 +68,79 @@ func (u *Users) NewAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key, err := u.Storage.NewKey(ctx, uid, req.TenantID)
+	if req.Expires != nil && !req.Expires.After(time.Now()) {
+		utils.JSONError(w, "Expiration time is in the past", errors.CodeBadRequest)
+		return
+	}
+
+	keyMembership, err := u.Storage.GetMembership(ctx, req.TenantID, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// The key scope can only narrow the membership down
+	for _, r := range req.Roles {
+		if _, ok := keyMembership.Roles[r]; !ok {
+			utils.JSONErrorResponse(w, errors.ErrKeyScope)
+			return
+		}
+	}
+
+	if len(req.Permissions) != 0 {
+		keyRole, err := u.Enforcer.GetRole(ctx, (&storage.APIKey{Roles: req.Roles}).ScopeRoles(keyMembership.Roles).Get()...)
+		if err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		ok, err := keyRole.IsAllGranted(req.Permissions...)
+		if err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		if !ok {
+			utils.JSONErrorResponse(w, errors.ErrKeyScope)
+			return
+		}
+	}
+
+	secret, secretHash, err := randomToken()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	key, err := u.Storage.NewKey(ctx, &storage.APIKey{
+		UserID:      uid,
+		TenantID:    req.TenantID,
+		Label:       req.Label,
+		Expires:     req.Expires,
+		Roles:       req.Roles,
+		Permissions: req.Permissions,
+		SecretHash:  secretHash,
+	})
 	if err != nil {
 		log.Error(err)
 		utils.JSONErrorResponse(w, err)
 		return
 	}
 
-	u.AuxLogger.WithFields(logFields(EvDeleteAPIKey, self.ID, uid, r)).WithFields(log.Fields{"key_id": key.ID, "tenant_id": key.TenantID}).Printf("User %v issued API key for service account %v in tenant in tenant %v", self.ID, uid, key.TenantID)
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvNewAPIKey, self.ID, uid, r)).WithFields(log.Fields{"key_id": key.ID, "tenant_id": key.TenantID}).Printf("User %v issued API key for service account %v in tenant %v", self.ID, uid, key.TenantID)
+	}
 
-	utils.JSONResponse(w, http.StatusCreated, key)
+	utils.JSONResponse(w, http.StatusCreated, &apiKeyCredentials{
+		APIKey:       key,
+		ClientID:     key.ID,
+		ClientSecret: secret,
+	})
 }
 
 func (u *Users) GetAPIKey(w http.ResponseWriter, r *http.Request) {
@@ -187,11 +266,75 @@ func (u *Users) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u.AuxLogger.WithFields(logFields(EvDeleteAPIKey, self.ID, uid, r)).WithField("key_id", kid).Printf("User %v removed API key for service account %v", self.ID, uid)
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvDeleteAPIKey, self.ID, uid, r)).WithField("key_id", kid).Printf("User %v removed API key for service account %v", self.ID, uid)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RotateAPIKey replaces the key secret. The new secret is returned only once
+func (u *Users) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	kid, err := uuid.FromString(mux.Vars(r)["keyId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkWritePermissions(role, storage.AccountService, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	secret, secretHash, err := randomToken()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	key, err := u.Storage.UpdateKeySecret(ctx, uid, kid, secretHash)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvRotateAPIKey, self.ID, uid, r)).WithField("key_id", kid).Printf("User %v rotated API key secret for service account %v", self.ID, uid)
+	}
+
+	utils.JSONResponse(w, http.StatusOK, &apiKeyCredentials{
+		APIKey:       key,
+		ClientID:     key.ID,
+		ClientSecret: secret,
+	})
+}
+
+// GetAPIToken exchanges the key and its secret for a token
 func (u *Users) GetAPIToken(w http.ResponseWriter, r *http.Request) {
 	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
 	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
@@ -238,6 +381,21 @@ func (u *Users) GetAPIToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	secret := r.Header.Get(apiKeySecretHeader)
+	if secret == "" {
+		secret = r.PostFormValue("client_secret")
+	}
+
+	if !verifyKeySecret(key, secret) {
+		utils.JSONErrorResponse(w, errors.ErrUnauthorized)
+		return
+	}
+
+	if key.Expired() {
+		utils.JSONErrorResponse(w, errors.ErrKeyExpired)
+		return
+	}
+
 	keyMembership, err := u.Storage.GetMembership(ctx, key.TenantID, uid)
 	if err != nil {
 		log.Error(err)
@@ -245,26 +403,68 @@ func (u *Users) GetAPIToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var keyRole rbac.Role
-	if writePerm {
-		keyRole, err = u.Enforcer.GetRole(ctx, keyMembership.Roles.Get()...)
-		if err != nil {
-			utils.JSONErrorResponse(w, err)
-			return
-		}
+	keyMembership, keyRole, err := u.keyScope(ctx, key, keyMembership)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if !writePerm {
+		keyRole = nil
 	}
 
 	opt := userTokenOptions{
 		user: &storage.User{
 			ID: uid,
 		},
-		key:        key,
-		membership: keyMembership,
-		role:       keyRole,
-		baseURL:    site.GetBaseURL(),
+		key:           key,
+		membership:    keyMembership,
+		role:          keyRole,
+		sessionMaxAge: keyTokenMaxAge(key, serviceTokenMaxAge(site)),
+		baseURL:       site.GetBaseURL(),
 	}
 
 	if err := u.writeUserToken(w, &opt); err != nil {
 		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if err := u.Storage.UpdateKeyLastUsed(ctx, key.ID); err != nil {
+		log.Error(err)
 	}
 }
+
+// keyScope narrows the membership and its role down to the key scope
+func (u *Users) keyScope(ctx context.Context, key *storage.APIKey, membership *storage.Membership) (*storage.Membership, rbac.Role, error) {
+	m := *membership
+	m.Roles = key.ScopeRoles(membership.Roles)
+
+	role, err := u.Enforcer.GetRole(ctx, m.Roles.Get()...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &m, rbac.Restrict(role, key.Permissions), nil
+}
+
+// serviceTokenMaxAge returns the lifetime of tokens issued for API keys
+func serviceTokenMaxAge(site *middleware.DomainConfigData) time.Duration {
+	if site.ServiceTokenMaxAge != 0 {
+		return site.ServiceTokenMaxAge
+	}
+	return defaultServiceTokenMaxAge
+}
+
+// keyTokenMaxAge limits the token lifetime by the key expiration time
+func keyTokenMaxAge(key *storage.APIKey, maxAge time.Duration) time.Duration {
+	if key.Expires == nil {
+		return maxAge
+	}
+
+	if d := time.Until(*key.Expires); maxAge == 0 || d < maxAge {
+		return d
+	}
+
+	return maxAge
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ecadlabs/auth/directory"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// findDirectory returns the first site directory responsible for the login or nil
+func findDirectory(r *http.Request, name string) *directory.Directory {
+	site, ok := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+	if !ok {
+		return nil
+	}
+
+	// Tenant specific login
+	tenantID, _ := uuid.FromString(mux.Vars(r)["id"])
+
+	for _, dir := range site.Directories {
+		if dir.Matches(tenantID, name) {
+			return dir
+		}
+	}
+
+	return nil
+}
+
+// directoryClaimsAccounts reports whether the directory may log in as local accounts and provision new ones.
+// Without domains a tenant directory matches every login to the tenant and could assert any address
+func directoryClaimsAccounts(dir *directory.Directory) bool {
+	return dir.TenantID == uuid.Nil || len(dir.Domains) != 0
+}
+
+func directoryDomainAllowed(dir *directory.Directory, email string) bool {
+	if len(dir.Domains) == 0 {
+		return true
+	}
+
+	i := strings.LastIndexByte(email, '@')
+	if i < 0 {
+		return false
+	}
+
+	for _, dom := range dir.Domains {
+		if strings.EqualFold(dom, email[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// directoryAuthenticate checks user credentials with the directory. Local account is linked by email or provisioned if allowed.
+// ok is false if the directory doesn't know the user and the local password should be checked instead
+func (u *Users) directoryAuthenticate(ctx context.Context, r *http.Request, dir *directory.Directory, name, password string) (user *storage.User, ok bool, err error) {
+	// Account lockout applies before the bind to protect directory accounts from guessing
+	local, err := u.Storage.GetUserByEmail(ctx, storage.AccountRegular, name)
+	if err != nil {
+		if err != errors.ErrUserNotFound {
+			log.Error(err)
+			return nil, true, errors.ErrUnauthorized
+		}
+		local = nil
+	}
+
+	if local != nil {
+		if err := u.checkAccountLock(ctx, r, local); err != nil {
+			return nil, true, err
+		}
+	}
+
+	ident, err := dir.Authenticate(ctx, name, password)
+	if err != nil {
+		switch err {
+		case directory.ErrUserNotFound:
+			return nil, false, nil
+		case directory.ErrInvalidCredentials:
+			u.loginFailed(ctx, r, local)
+			return nil, true, errors.ErrUnauthorized
+		default:
+			log.WithField("directory", dir.Name).Error(err)
+			return nil, true, errors.ErrDirectory
+		}
+	}
+
+	email := ident.Email
+	if email == "" && strings.Contains(name, "@") {
+		email = name
+	}
+
+	if email == "" {
+		log.WithField("directory", dir.Name).WithField("dn", ident.DN).Warn("Directory entry has no email")
+		return nil, true, errors.ErrEmailEmpty
+	}
+
+	// Directory entries can't claim addresses outside of its domains
+	if !directoryDomainAllowed(dir, email) {
+		log.WithField("directory", dir.Name).WithField("dn", ident.DN).Warnf("Email %s is outside of the directory domains", email)
+		return nil, true, errors.ErrForbidden
+	}
+
+	if !directoryClaimsAccounts(dir) {
+		log.WithField("directory", dir.Name).WithField("dn", ident.DN).Warnf("Tenant directory has no domains to claim %s", email)
+		return nil, true, errors.ErrForbidden
+	}
+
+	var roles storage.Roles
+	if mapped := dir.MapRoles(ident.Groups); len(mapped) != 0 {
+		roles = make(storage.Roles, len(mapped))
+		for _, role := range mapped {
+			roles[role] = true
+		}
+	}
+
+	if local == nil || !strings.EqualFold(local.Email, email) {
+		if local, err = u.Storage.GetUserByEmail(ctx, storage.AccountRegular, email); err != nil {
+			if err != errors.ErrUserNotFound {
+				log.Error(err)
+				return nil, true, errors.ErrUnauthorized
+			}
+			local = nil
+		}
+
+		if local != nil {
+			if err := u.checkAccountLock(ctx, r, local); err != nil {
+				return nil, true, err
+			}
+		}
+	}
+
+	if local == nil {
+		if !dir.JITProvisioning {
+			log.WithField("directory", dir.Name).WithField("dn", ident.DN).Warn("No local account")
+			return nil, true, errors.ErrUnauthorized
+		}
+
+		if user, err = u.provisionDirectoryUser(ctx, r, dir, ident, email, roles); err != nil {
+			log.Error(err)
+			return nil, true, err
+		}
+	} else {
+		user = local
+		if dir.TenantID != uuid.Nil {
+			if err := u.linkDirectoryUser(ctx, r, dir, user, roles); err != nil {
+				log.Error(err)
+				return nil, true, err
+			}
+		}
+	}
+
+	if err := u.Storage.ResetLoginFailures(ctx, storage.LoginFailuresUser, user.ID.String()); err != nil {
+		log.Error(err)
+	}
+
+	// Don't allow unverified users to log in
+	if !user.EmailVerified {
+		return nil, true, errors.ErrEmailNotVerified
+	}
+
+	return user, true, nil
+}
+
+// provisionDirectoryUser creates the local account for the directory entry
+func (u *Users) provisionDirectoryUser(ctx context.Context, r *http.Request, dir *directory.Directory, ident *directory.Identity, email string, roles storage.Roles) (user *storage.User, err error) {
+	create := storage.CreateUser{
+		Email:         email,
+		Name:          ident.Name,
+		EmailVerified: true,
+		Type:          storage.AccountRegular,
+	}
+
+	if dir.TenantID != uuid.Nil {
+		user, err = u.Storage.NewUserWithMembership(ctx, &create, dir.TenantID, roles)
+	} else {
+		user, err = u.Storage.NewUser(ctx, &create)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvProvision, user.ID, user.ID, r)).WithField("email", user.Email).WithField("tenant", dir.TenantID).WithField("directory", dir.Name).Printf("User %v provisioned by directory %s", user.ID, dir.Name)
+	}
+
+	return user, nil
+}
+
+// linkDirectoryUser makes sure the existing account is a member of the directory's tenant with the mapped roles
+func (u *Users) linkDirectoryUser(ctx context.Context, r *http.Request, dir *directory.Directory, user *storage.User, roles storage.Roles) error {
+	_, err := u.Storage.GetMembership(ctx, dir.TenantID, user.ID)
+	if err == errors.ErrMembershipNotFound {
+		if err := u.Storage.AddMembership(ctx, dir.TenantID, user, storage.ActiveState, storage.MemberMembership, roles); err != nil {
+			return err
+		}
+
+		// Log
+		if u.AuxLogger != nil {
+			u.AuxLogger.WithFields(logFields(EvAddMembership, user.ID, dir.TenantID, r)).WithField("directory", dir.Name).Printf("Directory %s added user %v to tenant %v", dir.Name, user.ID, dir.TenantID)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(dir.RoleMapping) == 0 || len(roles) == 0 {
+		return nil
+	}
+
+	return u.syncRoles(ctx, r, &roleSync{
+		tenantID: dir.TenantID,
+		user:     user,
+		roles:    roles,
+		managed:  dir.ManagedRoles(),
+		source:   "Directory " + dir.Name,
+		fields:   log.Fields{"directory": dir.Name},
+	})
+}
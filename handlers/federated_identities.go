@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// connectionExists returns nil if the connection is either OIDC or SAML one
+func (u *Users) connectionExists(ctx context.Context, id uuid.UUID) error {
+	if _, err := u.Storage.GetOIDCConnection(ctx, id); err != errors.ErrConnectionNotFound {
+		return err
+	}
+	_, err := u.Storage.GetSAMLConnection(ctx, id)
+	return err
+}
+
+// NewFederatedIdentity links the account to the identity provider connection. Only the account holder
+// or a global administrator may do it as the link lets the connection's owner log in as the user
+func (u *Users) NewFederatedIdentity(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	var request struct {
+		ConnectionID uuid.UUID `json:"connection_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	perm := []string{permissionFull}
+	if self.ID == uid {
+		perm = append(perm, permissionWriteSelf)
+	}
+
+	granted, err := role.IsAnyGranted(perm...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if !granted {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, uid)
+	if err != nil {
+		if err != errors.ErrUserNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Whoever set the password of an unverified account may not own the address the provider asserts
+	if !user.EmailVerified {
+		utils.JSONErrorResponse(w, errors.ErrEmailNotVerified)
+		return
+	}
+
+	if err := u.connectionExists(ctx, request.ConnectionID); err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	identity, err := u.Storage.NewFederatedIdentity(ctx, user.ID, request.ConnectionID)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLinkIdentity, self.ID, uid, r)).WithField("connection", request.ConnectionID).Printf("User %v linked user %v to identity provider connection %v", self.ID, uid, request.ConnectionID)
+	}
+
+	utils.JSONResponse(w, http.StatusCreated, identity)
+}
+
+func (u *Users) GetFederatedIdentities(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkReadPermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	identities, err := u.Storage.GetFederatedIdentities(ctx, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if len(identities) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, identities)
+}
+
+func (u *Users) DeleteFederatedIdentity(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	cid, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkWritePermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if err = u.Storage.DeleteFederatedIdentity(ctx, uid, cid); err != nil {
+		if err != errors.ErrIdentityNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvUnlinkIdentity, self.ID, uid, r)).WithField("connection", cid).Printf("User %v unlinked user %v from identity provider connection %v", self.ID, uid, cid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/federation"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	federationStateMaxAge = 10 * time.Minute
+	federationStateCookie = "federation_state"
+)
+
+func (u *Users) OIDCCallbackURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + u.OIDCCallbackPath
+}
+
+func (u *Users) federationClient() *federation.Client {
+	if u.Federation != nil {
+		return u.Federation
+	}
+	return &federation.Client{}
+}
+
+func oidcProvider(conn *storage.OIDCConnection) *federation.Provider {
+	return &federation.Provider{
+		Issuer:       conn.Issuer,
+		ClientID:     conn.ClientID,
+		ClientSecret: conn.ClientSecret,
+		Scopes:       conn.Scopes,
+	}
+}
+
+// BeginOIDCLogin redirects the user agent to the tenant's upstream OpenID Connect provider
+func (u *Users) BeginOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	conn, err := u.Storage.GetOIDCConnection(ctx, id)
+	if err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// State is passed through the provider, the rest is bound to the user agent
+	var state, stateHash, nonce, verifier string
+	if state, stateHash, err = randomToken(); err == nil {
+		if nonce, _, err = randomToken(); err == nil {
+			verifier, _, err = randomToken()
+		}
+	}
+
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	writePerm := true
+	if v := r.FormValue("permissions"); v != "" {
+		writePerm, _ = strconv.ParseBool(v)
+	}
+
+	stateToken, err := u.TokenFactory.Create(
+		jwt.MapClaims{
+			"federation_connection": conn.ID,
+			"federation_state":      stateHash,
+			"federation_nonce":      nonce,
+			"federation_verifier":   verifier,
+			"link_permissions":      writePerm,
+			"link_scope":            r.FormValue("scope"),
+			"link_client_id":        r.FormValue("client_id"),
+			"link_nonce":            r.FormValue("nonce"),
+		},
+		&storage.User{},
+		u.OIDCCallbackPath,
+		federationStateMaxAge,
+		site,
+	)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	dest, err := u.federationClient().AuthCodeURL(ctx, oidcProvider(conn), u.OIDCCallbackURL(site), state, nonce, verifier)
+	if err != nil {
+		log.WithField("issuer", conn.Issuer).Error(err)
+		utils.JSONErrorResponse(w, errors.ErrFederation)
+		return
+	}
+
+	setFederationCookie(w, site, u.OIDCCallbackPath, stateToken, false)
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, dest, http.StatusFound)
+}
+
+// setFederationCookie binds the login state to the user agent. Cross site cookies are required by POST bindings
+func setFederationCookie(w http.ResponseWriter, site *middleware.DomainConfigData, path, value string, crossSite bool) {
+	secure := strings.HasPrefix(site.GetBaseURL(), "https:")
+	sameSite := http.SameSiteLaxMode
+	if crossSite {
+		// SameSite=None is only accepted along with Secure
+		sameSite = http.SameSiteDefaultMode
+		if secure {
+			sameSite = http.SameSiteNoneMode
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationStateCookie,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(federationStateMaxAge / time.Second),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+func clearFederationCookie(w http.ResponseWriter, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationStateCookie,
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+type federationState struct {
+	connectionID uuid.UUID
+	nonce        string
+	verifier     string
+	requestID    string
+	params       loginParams
+}
+
+// consumeFederationState verifies the state cookie issued for the path against the returned state and makes sure it's used only once
+func (u *Users) consumeFederationState(ctx context.Context, r *http.Request, path, state string) (*federationState, error) {
+	cookie, err := r.Cookie(federationStateCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, errors.ErrTokenEmpty
+	}
+
+	token, err := u.TokenFactory.Verify(cookie.Value)
+	if err != nil {
+		return nil, errors.ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience(path, true) {
+		return nil, errors.ErrAudience
+	}
+
+	stateHash, _ := u.TokenFactory.GetClaim(token, "federation_state").(string)
+	if stateHash == "" || subtle.ConstantTimeCompare([]byte(stateHash), []byte(hashToken(state))) != 1 {
+		return nil, errors.ErrInvalidToken
+	}
+
+	var s federationState
+	var ok bool
+	if s.connectionID, ok = claimUUID(claims, utils.NSClaim(u.Namespace, "federation_connection")); !ok {
+		return nil, errors.ErrInvalidToken
+	}
+
+	s.nonce, _ = u.TokenFactory.GetClaim(token, "federation_nonce").(string)
+	s.verifier, _ = u.TokenFactory.GetClaim(token, "federation_verifier").(string)
+	s.requestID, _ = u.TokenFactory.GetClaim(token, "federation_request").(string)
+	s.params.writePerm, _ = u.TokenFactory.GetClaim(token, "link_permissions").(bool)
+	s.params.scope, _ = u.TokenFactory.GetClaim(token, "link_scope").(string)
+	s.params.clientID, _ = u.TokenFactory.GetClaim(token, "link_client_id").(string)
+	s.params.nonce, _ = u.TokenFactory.GetClaim(token, "link_nonce").(string)
+
+	jti, ok := claimUUID(claims, "jti")
+	if !ok {
+		return nil, errors.ErrInvalidToken
+	}
+
+	revoked, err := u.Storage.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.ErrTokenRevoked
+	}
+
+	var expires *time.Time
+	if exp, ok := claims["exp"].(float64); ok {
+		t := time.Unix(int64(exp), 0)
+		expires = &t
+	}
+
+	if err := u.Storage.RevokeToken(ctx, jti, uuid.Nil, expires); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func isFederationStateError(err error) bool {
+	return err == errors.ErrTokenEmpty || err == errors.ErrInvalidToken || err == errors.ErrTokenRevoked || err == errors.ErrAudience
+}
+
+func claimVerified(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
+
+// OIDCCallback completes the upstream OpenID Connect login. The user is matched by the verified email address
+// or provisioned if the connection allows it
+func (u *Users) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	// Clear the state cookie in any case
+	clearFederationCookie(w, u.OIDCCallbackPath)
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	state, err := u.consumeFederationState(ctx, r, u.OIDCCallbackPath, r.FormValue("state"))
+	if err != nil {
+		if !isFederationStateError(err) {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if e := r.FormValue("error"); e != "" {
+		log.WithField("error", e).WithField("description", r.FormValue("error_description")).Warn("Upstream authorization failed")
+		utils.JSONErrorResponse(w, errors.ErrFederation)
+		return
+	}
+
+	conn, err := u.Storage.GetOIDCConnection(ctx, state.connectionID)
+	if err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	claims, err := u.federationClient().Exchange(ctx, oidcProvider(conn), u.OIDCCallbackURL(site), r.FormValue("code"), state.verifier, state.nonce)
+	if err != nil {
+		log.WithField("issuer", conn.Issuer).Error(err)
+		utils.JSONErrorResponse(w, errors.ErrFederation)
+		return
+	}
+
+	email, _ := claims[conn.EmailClaim].(string)
+	if email == "" {
+		utils.JSONErrorResponse(w, errors.ErrEmailEmpty)
+		return
+	}
+
+	if !conn.TrustEmail && !claimVerified(claims[conn.EmailVerifiedClaim]) {
+		utils.JSONErrorResponse(w, errors.ErrEmailNotVerified)
+		return
+	}
+
+	if !conn.DomainAllowed(email) {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	name, _ := claims[conn.NameClaim].(string)
+
+	var roles storage.Roles
+	if conn.DefaultRole != "" {
+		roles = storage.Roles{conn.DefaultRole: true}
+	}
+
+	user, err := u.federatedUser(ctx, r, &federatedIdentity{
+		tenantID:     conn.TenantID,
+		connectionID: conn.ID,
+		provider:     conn.Issuer,
+		jit:          conn.JITProvisioning && len(conn.Domains) != 0,
+		email:        email,
+		name:         name,
+		roles:        roles,
+	})
+	if err != nil {
+		if err != errors.ErrForbidden && err != errors.ErrUserNotFound && err != errors.ErrIdentityNotLinked && err != errors.ErrEmailNotVerified {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	state.params.tenantID = conn.TenantID
+	u.beginLogin(ctx, w, r, user, &state.params)
+}
+
+// federatedIdentity is an identity asserted by the tenant's identity provider
+type federatedIdentity struct {
+	tenantID     uuid.UUID
+	connectionID uuid.UUID
+	provider     string
+	jit          bool
+	email        string
+	name         string
+	roles        storage.Roles // Membership roles of provisioned users
+}
+
+// federatedUser returns the local user for the identity asserted by the connection. The asserted email alone
+// proves nothing as connections are managed by tenant owners, so existing accounts must be explicitly linked
+// to the connection and be active members of its tenant. Provisioned accounts confirm their email first
+func (u *Users) federatedUser(ctx context.Context, r *http.Request, id *federatedIdentity) (*storage.User, error) {
+	user, err := u.Storage.GetUserByEmail(ctx, storage.AccountRegular, id.email)
+	if err == nil {
+		linked, err := u.Storage.HasFederatedIdentity(ctx, user.ID, id.connectionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !linked {
+			return nil, errors.ErrIdentityNotLinked
+		}
+
+		member, err := u.Storage.GetMembership(ctx, id.tenantID, user.ID)
+		if err != nil {
+			if err == errors.ErrMembershipNotFound {
+				return nil, errors.ErrForbidden
+			}
+			return nil, err
+		}
+
+		if member.MembershipStatus != storage.ActiveState {
+			return nil, errors.ErrForbidden
+		}
+
+		if !user.EmailVerified {
+			if u.resendAllowed(ctx, user) {
+				if err := u.sendVerification(ctx, r, user); err != nil {
+					log.Error(err)
+				}
+			}
+			return nil, errors.ErrEmailNotVerified
+		}
+
+		return user, nil
+	}
+
+	if err != errors.ErrUserNotFound || !id.jit {
+		return nil, err
+	}
+
+	user, err = u.Storage.NewFederatedUser(ctx, &storage.CreateUser{
+		Email: id.email,
+		Name:  id.name,
+		Type:  storage.AccountRegular,
+	}, id.tenantID, id.connectionID, id.roles)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvProvision, user.ID, user.ID, r)).WithField("email", user.Email).WithField("tenant", id.tenantID).WithField("connection", id.connectionID).Printf("User %v provisioned by identity provider %s", user.ID, id.provider)
+	}
+
+	if err := u.sendVerification(ctx, r, user); err != nil {
+		log.Error(err)
+	}
+
+	return nil, errors.ErrEmailNotVerified
+}
+
+// roleSync describes roles of the tenant member account which are owned by an external source
+type roleSync struct {
+	tenantID uuid.UUID
+	user     *storage.User
+	roles    storage.Roles // Roles granted by the source
+	managed  []string      // Roles the source may grant or revoke
+	source   string        // Used in log messages
+	fields   log.Fields
+}
+
+// syncRoles brings roles managed by the external source in line with the granted ones.
+// Other roles and owner memberships are left intact
+func (u *Users) syncRoles(ctx context.Context, r *http.Request, s *roleSync) error {
+	member, err := u.Storage.GetMembership(ctx, s.tenantID, s.user.ID)
+	if err != nil {
+		return err
+	}
+
+	if member.MembershipType != storage.MemberMembership {
+		return nil
+	}
+
+	managed := make(map[string]struct{}, len(s.managed))
+	for _, role := range s.managed {
+		managed[role] = struct{}{}
+	}
+
+	ops := storage.Ops{
+		Update: map[string]interface{}{},
+		Add:    map[string][]string{},
+		Remove: map[string][]string{},
+	}
+
+	for role := range s.roles {
+		if _, ok := member.Roles[role]; !ok {
+			ops.Add["roles"] = append(ops.Add["roles"], role)
+		}
+	}
+
+	for role := range member.Roles {
+		if _, ok := managed[role]; ok {
+			if _, ok := s.roles[role]; !ok {
+				ops.Remove["roles"] = append(ops.Remove["roles"], role)
+			}
+		}
+	}
+
+	if len(ops.Add["roles"]) == 0 && len(ops.Remove["roles"]) == 0 {
+		return nil
+	}
+
+	if _, err := u.Storage.UpdateMembership(ctx, s.tenantID, s.user.ID, &ops); err != nil {
+		return err
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		for _, role := range ops.Add["roles"] {
+			u.AuxLogger.WithFields(logFields(EvAddRole, member.ID, member.ID, r)).WithField("role", role).WithFields(s.fields).Printf("%s added role `%s' to account %v in tenant %v", s.source, role, s.user.ID, s.tenantID)
+		}
+
+		for _, role := range ops.Remove["roles"] {
+			u.AuxLogger.WithFields(logFields(EvRemoveRole, member.ID, member.ID, r)).WithField("role", role).WithFields(s.fields).Printf("%s removed role `%s' from account %v in tenant %v", s.source, role, s.user.ID, s.tenantID)
+		}
+	}
+
+	return nil
+}
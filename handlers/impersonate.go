@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultImpersonationMaxAge = 15 * time.Minute
+
+func impersonationMaxAge(c *middleware.DomainConfigData) time.Duration {
+	if c.ImpersonationMaxAge != 0 {
+		return c.ImpersonationMaxAge
+	}
+	return defaultImpersonationMaxAge
+}
+
+// Impersonate issues a short-lived token for the target user's membership. The token carries the `act` claim
+// naming the operator and is bound to the operator's session so it dies with it
+func (u *Users) Impersonate(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+	token := r.Context().Value(middleware.TokenContextKey).(*jwt.Token)
+
+	// No chains
+	if _, ok := r.Context().Value(middleware.ActorContextKey).(uuid.UUID); ok {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	// Interactive sessions only
+	sid, ok := claimUUID(token.Claims.(jwt.MapClaims), "sid")
+	if !ok {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	if uid == self.ID {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	var request struct {
+		TenantID uuid.UUID `json:"tenant_id"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.TenantID = uuid.FromStringOrNil(r.FormValue("tenant_id"))
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	granted, err := role.IsAnyGranted(permissionImpersonate)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if !granted {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, uid)
+	if err != nil {
+		if err != errors.ErrUserNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if len(user.Membership) == 0 {
+		utils.JSONErrorResponse(w, errors.ErrMembershipNotFound)
+		return
+	}
+
+	tid := request.TenantID
+	if tid == uuid.Nil {
+		tid = user.GetDefaultMembership()
+	}
+
+	membership, err := u.getMembershipLogin(ctx, tid, user.ID)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	targetRole, err := u.Enforcer.GetRole(ctx, membership.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Refuse to impersonate users with more privileges
+	if granted, err = role.IsAllGranted(targetRole.Permissions()...); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if !granted {
+		utils.JSONErrorResponse(w, errors.ErrImpersonate)
+		return
+	}
+
+	opt := userTokenOptions{
+		user:          user,
+		membership:    membership,
+		role:          targetRole,
+		sessionMaxAge: impersonationMaxAge(site),
+		baseURL:       site.GetBaseURL(),
+		actor:         self.ID,
+		sessionID:     sid,
+	}
+
+	if err := u.writeUserToken(w, &opt); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvImpersonate, self.ID, user.ID, r)).WithField("tenant", membership.TenantID).WithField("email", user.Email).Printf("User %v impersonated user %v in tenant %v", self.ID, user.ID, membership.TenantID)
+	}
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func (u *Users) IntrospectURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + u.IntrospectPath
+}
+
+// authenticateResourceServer accepts either a confidential OAuth client or a service account API key
+func (u *Users) authenticateResourceServer(r *http.Request) error {
+	id, _, ok := r.BasicAuth()
+	if !ok {
+		id = r.PostFormValue("client_id")
+	}
+
+	if _, err := uuid.FromString(id); err == nil {
+		_, err = u.authenticateAPIKey(r)
+		return err
+	}
+
+	client, err := u.authenticateClient(r)
+	if err != nil {
+		return err
+	}
+
+	if client.Public() {
+		return errors.ErrUnauthorized
+	}
+
+	return nil
+}
+
+func claimUUID(claims jwt.MapClaims, name string) (uuid.UUID, bool) {
+	if s, ok := claims[name].(string); ok {
+		if id, err := uuid.FromString(s); err == nil {
+			return id, true
+		}
+	}
+	return uuid.Nil, false
+}
+
+// introspectToken returns token claims if the token is valid and all the objects it refers to are still alive.
+// Error is returned only in case of storage failure
+func (u *Users) introspectToken(ctx context.Context, token string, site *middleware.DomainConfigData) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := u.Keyring.Parse(token, claims); err != nil {
+		return nil, nil
+	}
+
+	if !claims.VerifyAudience(site.GetBaseURL(), true) {
+		return nil, nil
+	}
+
+	uid, ok := claimUUID(claims, "sub")
+	if !ok {
+		return nil, nil
+	}
+
+	jti, ok := claimUUID(claims, "jti")
+	if !ok {
+		return nil, nil
+	}
+
+	revoked, err := u.Storage.IsTokenRevoked(ctx, jti)
+	if err != nil || revoked {
+		return nil, err
+	}
+
+	if sid, ok := claimUUID(claims, "sid"); ok {
+		session, err := u.Storage.GetSession(ctx, sid)
+		if err != nil {
+			if err == errors.ErrSessionNotFound {
+				err = nil
+			}
+			return nil, err
+		}
+
+		if !session.Active() {
+			return nil, nil
+		}
+	} else if _, ok := claims[utils.NSClaim(u.Namespace, "api_key")]; !ok {
+		// Only API key and IP login tokens are sessionless
+		if _, ok := claims[utils.NSClaim(u.Namespace, "address")]; !ok {
+			return nil, nil
+		}
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, "", uid)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			err = nil
+		}
+		return nil, err
+	}
+
+	if user.Type != storage.AccountService && !user.EmailVerified {
+		return nil, nil
+	}
+
+	if tid, ok := claimUUID(claims, utils.NSClaim(u.Namespace, "tenant")); ok {
+		membership, err := u.Storage.GetMembership(ctx, tid, uid)
+		if err != nil {
+			if err == errors.ErrMembershipNotFound {
+				err = nil
+			}
+			return nil, err
+		}
+
+		if membership.MembershipStatus != storage.ActiveState {
+			return nil, nil
+		}
+	}
+
+	if kid, ok := claimUUID(claims, utils.NSClaim(u.Namespace, "api_key")); ok {
+		key, err := u.Storage.GetKey(ctx, uid, kid)
+		if err != nil {
+			if err == errors.ErrKeyNotFound {
+				err = nil
+			}
+			return nil, err
+		}
+
+		if key.Expired() {
+			return nil, nil
+		}
+	}
+
+	return claims, nil
+}
+
+// Introspect implements RFC 7662 token introspection
+func (u *Users) Introspect(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	if err := u.authenticateResourceServer(r); err != nil {
+		if err != errors.ErrUnauthorized && err != errors.ErrClientNotFound && err != errors.ErrKeyNotFound && err != errors.ErrKeyExpired {
+			log.Error(err)
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, "")
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, "token is required")
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	claims, err := u.introspectToken(ctx, token, site)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	res := map[string]interface{}{
+		"active": claims != nil,
+	}
+
+	if claims != nil {
+		for k, v := range claims {
+			res[k] = v
+		}
+		res["token_type"] = "Bearer"
+		if kid, ok := claims[utils.NSClaim(u.Namespace, "api_key")]; ok {
+			res["client_id"] = kid
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// RevokeToken adds the access token to the revocation list. The presented token is revoked if none is specified.
+// Revoking other user's tokens requires write permission
+func (u *Users) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	current := r.Context().Value(middleware.TokenContextKey).(*jwt.Token)
+
+	var request struct {
+		Token string `json:"token"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.Token = r.PostFormValue("token")
+	}
+
+	claims := current.Claims.(jwt.MapClaims)
+	if request.Token != "" {
+		claims = jwt.MapClaims{}
+		if _, err := u.Keyring.Parse(request.Token, claims); err != nil {
+			// Invalid and expired tokens need no revocation (RFC 7009)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	jti, ok := claimUUID(claims, "jti")
+	if !ok {
+		utils.JSONError(w, "Token has no ID", errors.CodeBadRequest)
+		return
+	}
+
+	uid, ok := claimUUID(claims, "sub")
+	if !ok {
+		utils.JSONErrorResponse(w, errors.ErrInvalidToken)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	if uid != self.ID {
+		user, err := u.Storage.GetUserByID(ctx, "", uid)
+		if err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+		if err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		if _, err = u.checkWritePermissions(role, user.Type, false); err != nil {
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+	}
+
+	var expires *time.Time
+	if exp, ok := claims["exp"].(float64); ok {
+		t := time.Unix(int64(exp), 0)
+		expires = &t
+	}
+
+	if err := u.Storage.RevokeToken(ctx, jti, uid, expires); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvRevokeToken, self.ID, uid, r)).WithField("jti", jti).Printf("User %v revoked token %v of user %v", self.ID, jti, uid)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ecadlabs/auth/keys"
+	"github.com/ecadlabs/auth/utils"
+)
+
+// JWKSHandler publishes public signing keys
+type JWKSHandler struct {
+	Keyring *keys.Keyring
+}
+
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	utils.JSONResponse(w, http.StatusOK, h.Keyring.JWKSet())
+}
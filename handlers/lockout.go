@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultLockoutThreshold     = 5
+	defaultAddrLockoutThreshold = 20
+	defaultLockoutDuration      = time.Minute
+	defaultLockoutMaxDuration   = time.Hour
+	defaultLockoutWindow        = 24 * time.Hour
+)
+
+// loginLockedError is returned while login attempts are suspended
+type loginLockedError struct {
+	until time.Time
+}
+
+func (e *loginLockedError) Error() string {
+	return errors.ErrLoginLocked.Error()
+}
+
+func writeLoginLocked(w http.ResponseWriter, e *loginLockedError) {
+	retry := int(math.Ceil(time.Until(e.until).Seconds()))
+	if retry < 1 {
+		retry = 1
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	utils.JSONErrorResponse(w, errors.ErrLoginLocked)
+}
+
+// lockoutDuration grows exponentially with the number of failures past the threshold
+func lockoutDuration(failures, threshold int, base, max time.Duration) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	d := base
+	for i := threshold; i < failures && d < max; i++ {
+		d *= 2
+	}
+
+	if d > max {
+		d = max
+	}
+
+	return d
+}
+
+func lockoutPolicy(site *middleware.DomainConfigData) (threshold, addrThreshold int, base, max, window time.Duration) {
+	if threshold = site.LockoutThreshold; threshold == 0 {
+		threshold = defaultLockoutThreshold
+	}
+
+	if addrThreshold = site.AddrLockoutThreshold; addrThreshold == 0 {
+		addrThreshold = defaultAddrLockoutThreshold
+	}
+
+	if base = site.LockoutDuration; base == 0 {
+		base = defaultLockoutDuration
+	}
+
+	if max = site.LockoutMaxDuration; max == 0 {
+		max = defaultLockoutMaxDuration
+	}
+
+	if window = site.LockoutWindow; window == 0 {
+		window = defaultLockoutWindow
+	}
+
+	return
+}
+
+// checkLoginLock returns loginLockedError if login attempts are suspended for the account or the address
+func (u *Users) checkLoginLock(ctx context.Context, kind, key string) error {
+	f, err := u.Storage.GetLoginFailures(ctx, kind, key)
+	if err != nil {
+		return err
+	}
+
+	if f.Locked() {
+		return &loginLockedError{until: *f.LockedUntil}
+	}
+
+	return nil
+}
+
+// checkAccountLock is checkLoginLock for the password login. Telling the client the account is locked would
+// disclose it exists, so the lock is only recorded in the log and the attempt fails as an unknown account would
+func (u *Users) checkAccountLock(ctx context.Context, r *http.Request, user *storage.User) error {
+	f, err := u.Storage.GetLoginFailures(ctx, storage.LoginFailuresUser, user.ID.String())
+	if err != nil {
+		return err
+	}
+
+	if !f.Locked() {
+		return nil
+	}
+
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLockout, uuid.Nil, user.ID, r)).WithField("locked_until", *f.LockedUntil).Printf("Login attempt for user %v rejected as the account is locked until %v", user.ID, *f.LockedUntil)
+	}
+
+	u.loginFailed(ctx, r, nil)
+	return errors.ErrUnauthorized
+}
+
+// addLoginFailure counts failed attempt and suspends login if the threshold is reached
+func (u *Users) addLoginFailure(ctx context.Context, r *http.Request, kind, key string, userID uuid.UUID, threshold int) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+	_, _, base, max, window := lockoutPolicy(site)
+
+	f, err := u.Storage.AddLoginFailure(ctx, kind, key, window)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	d := lockoutDuration(f.Failures, threshold, base, max)
+	if d == 0 {
+		return
+	}
+
+	until := time.Now().Add(d)
+	if err := u.Storage.LockLogin(ctx, kind, key, until); err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.WithFields(log.Fields{"kind": kind, "key": key, "failures": f.Failures}).Warnf("Login locked until %v", until)
+
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLockout, uuid.Nil, userID, r)).WithFields(log.Fields{
+			"kind":         kind,
+			"failures":     f.Failures,
+			"locked_until": until,
+		}).Printf("Login locked for %v %v until %v after %d failed attempts", kind, key, until, f.Failures)
+	}
+}
+
+// loginFailed records failed attempt for the source address and the account if known
+func (u *Users) loginFailed(ctx context.Context, r *http.Request, user *storage.User) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+	threshold, addrThreshold, _, _, _ := lockoutPolicy(site)
+
+	if addr := utils.GetRemoteAddr(r); addr != "" {
+		u.addLoginFailure(ctx, r, storage.LoginFailuresAddr, addr, uuid.Nil, addrThreshold)
+	}
+
+	if user != nil {
+		u.addLoginFailure(ctx, r, storage.LoginFailuresUser, user.ID.String(), user.ID, threshold)
+	}
+}
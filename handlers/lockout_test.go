@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		expect   time.Duration
+	}{
+		{failures: 0, expect: 0},
+		{failures: 4, expect: 0},
+		{failures: 5, expect: time.Minute},
+		{failures: 6, expect: 2 * time.Minute},
+		{failures: 8, expect: 8 * time.Minute},
+		{failures: 11, expect: time.Hour},
+		{failures: 1000, expect: time.Hour},
+	}
+
+	for _, test := range tests {
+		if d := lockoutDuration(test.failures, 5, time.Minute, time.Hour); d != test.expect {
+			t.Errorf("%d failures: expected %v, got %v", test.failures, test.expect, d)
+		}
+	}
+}
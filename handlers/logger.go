@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/ecadlabs/auth/logger"
+	"github.com/ecadlabs/auth/middleware"
 	"github.com/ecadlabs/auth/utils"
 	"github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
@@ -40,6 +41,50 @@ const (
 	EvEmailUpdate  = "email_update"
 	EvNewAPIKey    = "create_api_key"
 	EvDeleteAPIKey = "delete_api_key"
+	//EvRotateAPIKey constant for the API key secret rotation event
+	EvRotateAPIKey = "rotate_api_key"
+	//EvRefreshTokenReuse constant for the refresh token reuse event
+	EvRefreshTokenReuse = "refresh_token_reuse"
+	//EvRevokeSession constant for the revoke session event
+	EvRevokeSession = "revoke_session"
+	//EvLogout constant for the logout event
+	EvLogout = "logout"
+	//EvRevokeToken constant for the revoke access token event
+	EvRevokeToken = "revoke_token"
+	//EvMFAEnroll constant for the second factor enrollment event
+	EvMFAEnroll = "mfa_enroll"
+	//EvMFADisable constant for the second factor removal event
+	EvMFADisable = "mfa_disable"
+	//EvMFAFailure constant for the failed second factor verification event
+	EvMFAFailure = "mfa_failure"
+	//EvLockout constant for the login lockout event
+	EvLockout = "lockout"
+	//EvUnlock constant for the login unlock event
+	EvUnlock = "unlock"
+	//EvPasswordChange constant for the change password event
+	EvPasswordChange = "password_change"
+	//EvLoginLinkRequest constant for the login link request event
+	EvLoginLinkRequest = "login_link_request"
+	//EvImpersonate constant for the impersonation token issue event
+	EvImpersonate = "impersonate"
+	//EvNewConnection constant for the identity provider connection create event
+	EvNewConnection = "create_connection"
+	//EvUpdateConnection constant for the identity provider connection update event
+	EvUpdateConnection = "update_connection"
+	//EvDeleteConnection constant for the identity provider connection delete event
+	EvDeleteConnection = "delete_connection"
+	//EvProvision constant for the just-in-time user provisioning event
+	EvProvision = "provision"
+	//EvAddMembership constant for the automatic tenant membership event
+	EvAddMembership = "add_membership"
+	//EvSignup constant for the self-service sign-up event
+	EvSignup = "signup"
+	//EvEmailVerify constant for the email verification event
+	EvEmailVerify = "email_verify"
+	//EvLinkIdentity constant for the federated identity link event
+	EvLinkIdentity = "link_identity"
+	//EvUnlinkIdentity constant for the federated identity unlink event
+	EvUnlinkIdentity = "unlink_identity"
 )
 
 const (
@@ -65,6 +110,28 @@ var evSourceTypeMap = map[string]string{
 	EvEmailUpdate:        UserIdType,
 	EvDeleteAPIKey:       UserIdType,
 	EvNewAPIKey:          UserIdType,
+	EvRotateAPIKey:       UserIdType,
+	EvRefreshTokenReuse:  UserIdType,
+	EvRevokeSession:      UserIdType,
+	EvLogout:             UserIdType,
+	EvRevokeToken:        UserIdType,
+	EvMFAEnroll:          UserIdType,
+	EvMFADisable:         UserIdType,
+	EvMFAFailure:         UserIdType,
+	EvLockout:            UserIdType,
+	EvUnlock:             MembeshipIdType,
+	EvPasswordChange:     UserIdType,
+	EvLoginLinkRequest:   UserIdType,
+	EvImpersonate:        UserIdType,
+	EvNewConnection:      MembeshipIdType,
+	EvUpdateConnection:   MembeshipIdType,
+	EvDeleteConnection:   MembeshipIdType,
+	EvProvision:          UserIdType,
+	EvAddMembership:      UserIdType,
+	EvSignup:             UserIdType,
+	EvEmailVerify:        UserIdType,
+	EvLinkIdentity:       UserIdType,
+	EvUnlinkIdentity:     UserIdType,
 }
 
 var evTargetTypeMap = map[string]string{
@@ -84,6 +151,28 @@ var evTargetTypeMap = map[string]string{
 	EvEmailUpdate:        UserIdType,
 	EvDeleteAPIKey:       UserIdType,
 	EvNewAPIKey:          UserIdType,
+	EvRotateAPIKey:       UserIdType,
+	EvRefreshTokenReuse:  UserIdType,
+	EvRevokeSession:      UserIdType,
+	EvLogout:             UserIdType,
+	EvRevokeToken:        UserIdType,
+	EvMFAEnroll:          UserIdType,
+	EvMFADisable:         UserIdType,
+	EvMFAFailure:         UserIdType,
+	EvLockout:            UserIdType,
+	EvUnlock:             UserIdType,
+	EvPasswordChange:     UserIdType,
+	EvLoginLinkRequest:   UserIdType,
+	EvImpersonate:        UserIdType,
+	EvNewConnection:      TenantIdType,
+	EvUpdateConnection:   TenantIdType,
+	EvDeleteConnection:   TenantIdType,
+	EvProvision:          UserIdType,
+	EvAddMembership:      TenantIdType,
+	EvSignup:             UserIdType,
+	EvEmailVerify:        UserIdType,
+	EvLinkIdentity:       UserIdType,
+	EvUnlinkIdentity:     UserIdType,
 }
 
 func logFields(ev string, self, id uuid.UUID, r *http.Request) logrus.Fields {
@@ -103,5 +192,9 @@ func logFields(ev string, self, id uuid.UUID, r *http.Request) logrus.Fields {
 	d[logger.DefaultEventKey] = ev
 	d[logger.DefaultAddrKey] = utils.GetRemoteAddr(r)
 
+	if actor, ok := r.Context().Value(middleware.ActorContextKey).(uuid.UUID); ok {
+		d[logger.DefaultActorIDKey] = actor
+	}
+
 	return d
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/hasher"
 	"github.com/ecadlabs/auth/middleware"
 	"github.com/ecadlabs/auth/rbac"
 	"github.com/ecadlabs/auth/storage"
@@ -18,7 +19,6 @@ import (
 	"github.com/gorilla/mux"
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type userTokenOptions struct {
@@ -29,10 +29,24 @@ type userTokenOptions struct {
 	role          rbac.Role
 	sessionMaxAge time.Duration
 	refresh       string
+	refreshToken  string
+	sessionID     uuid.UUID
 	baseURL       string
+	actor         uuid.UUID // Real operator behind an impersonation token
+	// OpenID Connect
+	openID   bool
+	clientID string
+	nonce    string
+	authTime time.Time
 }
 
-func (u *Users) writeUserToken(w http.ResponseWriter, opt *userTokenOptions) error {
+type userToken struct {
+	claims  jwt.MapClaims
+	token   string
+	idToken string
+}
+
+func (u *Users) newUserToken(opt *userTokenOptions) (*userToken, error) {
 	now := time.Now()
 
 	claims := jwt.MapClaims{
@@ -40,6 +54,15 @@ func (u *Users) writeUserToken(w http.ResponseWriter, opt *userTokenOptions) err
 		"iat": now.Unix(),
 		"iss": opt.baseURL,
 		"aud": opt.baseURL,
+		"jti": uuid.NewV4(),
+	}
+
+	if opt.sessionID != uuid.Nil {
+		claims["sid"] = opt.sessionID
+	}
+
+	if opt.actor != uuid.Nil {
+		claims["act"] = map[string]interface{}{"sub": opt.actor}
 	}
 
 	if opt.sessionMaxAge != 0 {
@@ -72,25 +95,43 @@ func (u *Users) writeUserToken(w http.ResponseWriter, opt *userTokenOptions) err
 		claims[utils.NSClaim(u.Namespace, "permissions")] = opt.role.Permissions()
 	}
 
-	token := jwt.NewWithClaims(u.JWTSigningMethod, claims)
-	secret, err := u.JWTSecretGetter()
+	tokenString, err := u.Keyring.Sign(claims)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tokenString, err := token.SignedString(secret)
+	res := userToken{
+		claims: claims,
+		token:  tokenString,
+	}
+
+	if opt.openID {
+		if res.idToken, err = u.idToken(claims, opt.user, opt, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+func (u *Users) writeUserToken(w http.ResponseWriter, opt *userTokenOptions) error {
+	tok, err := u.newUserToken(opt)
 	if err != nil {
 		return err
 	}
 
 	response := struct {
-		Token      string    `json:"token"`
-		ID         uuid.UUID `json:"id,omitempty"`
-		RefreshURL string    `json:"refresh,omitempty"`
+		Token        string    `json:"token"`
+		IDToken      string    `json:"id_token,omitempty"`
+		RefreshToken string    `json:"refresh_token,omitempty"`
+		ID           uuid.UUID `json:"id,omitempty"`
+		RefreshURL   string    `json:"refresh,omitempty"`
 	}{
-		Token:      tokenString,
-		ID:         opt.user.ID,
-		RefreshURL: opt.refresh,
+		Token:        tok.token,
+		IDToken:      tok.idToken,
+		RefreshToken: opt.refreshToken,
+		ID:           opt.user.ID,
+		RefreshURL:   opt.refresh,
 	}
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -136,10 +177,64 @@ func (u *Users) getMembershipLogin(ctx context.Context, tenantID, userID uuid.UU
 	return membership, nil
 }
 
+// authenticate checks user credentials against the site directory or the local password hash.
+// Failed attempts are counted per account and source address
+func (u *Users) authenticate(ctx context.Context, r *http.Request, name, password string) (*storage.User, error) {
+	if name == "" || password == "" {
+		return nil, errors.ErrUnauthorized
+	}
+
+	if err := u.checkLoginLock(ctx, storage.LoginFailuresAddr, utils.GetRemoteAddr(r)); err != nil {
+		return nil, err
+	}
+
+	// External directory takes precedence over the local password
+	if dir := findDirectory(r, name); dir != nil {
+		if user, ok, err := u.directoryAuthenticate(ctx, r, dir, name, password); ok {
+			return user, err
+		}
+	}
+
+	user, err := u.Storage.GetUserByEmail(ctx, storage.AccountRegular, name)
+	if err != nil {
+		log.Error(err)
+		if err == errors.ErrUserNotFound {
+			u.loginFailed(ctx, r, nil)
+		}
+		return nil, errors.ErrUnauthorized
+	}
+
+	if err := u.checkAccountLock(ctx, r, user); err != nil {
+		return nil, err
+	}
+
+	if len(user.PasswordHash) == 0 {
+		u.loginFailed(ctx, r, user)
+		return nil, errors.ErrUnauthorized
+	}
+
+	if err = hasher.Compare(user.PasswordHash, password); err != nil {
+		log.Error(err)
+		u.loginFailed(ctx, r, user)
+		return nil, errors.ErrUnauthorized
+	}
+
+	u.rehashPassword(ctx, user, password)
+
+	if err := u.Storage.ResetLoginFailures(ctx, storage.LoginFailuresUser, user.ID.String()); err != nil {
+		log.Error(err)
+	}
+
+	// Don't allow unverified users to log in
+	if !user.EmailVerified {
+		return nil, errors.ErrEmailNotVerified
+	}
+
+	return user, nil
+}
+
 // Login is a login endpoint handler
 func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
-	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
-
 	writePerm := true
 	if v := r.FormValue("permissions"); v != "" {
 		writePerm, _ = strconv.ParseBool(v)
@@ -148,6 +243,9 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 	type loginRequest struct {
 		Name     string `json:"name"`
 		Password string `json:"password"`
+		Scope    string `json:"scope"`
+		ClientID string `json:"client_id"`
+		Nonce    string `json:"nonce"`
 	}
 
 	var request *loginRequest
@@ -163,6 +261,19 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	scope, clientID, nonce := r.FormValue("scope"), r.FormValue("client_id"), r.FormValue("nonce")
+	if request != nil {
+		if request.Scope != "" {
+			scope = request.Scope
+		}
+		if request.ClientID != "" {
+			clientID = request.ClientID
+		}
+		if request.Nonce != "" {
+			nonce = request.Nonce
+		}
+	}
+
 	ctx, cancel := u.context(r)
 	defer cancel()
 
@@ -193,33 +304,15 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// Normal login
-		if request.Name == "" || request.Password == "" {
-			utils.JSONError(w, "", errors.CodeUnauthorized)
-			return
-		}
-
 		var err error
-		user, err = u.Storage.GetUserByEmail(ctx, storage.AccountRegular, request.Name)
-		if err != nil {
-			log.Error(err)
-			utils.JSONError(w, "", errors.CodeUnauthorized)
-			return
-		}
-
-		if len(user.PasswordHash) == 0 {
-			utils.JSONError(w, "", errors.CodeUnauthorized)
-			return
-		}
-
-		if err = bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(request.Password)); err != nil {
-			log.Error(err)
-			utils.JSONError(w, "", errors.CodeUnauthorized)
-			return
-		}
-
-		// Don't allow unverified users to log in
-		if !user.EmailVerified {
-			utils.JSONErrorResponse(w, errors.ErrEmailNotVerified)
+		if user, err = u.authenticate(ctx, r, request.Name, request.Password); err != nil {
+			if err == errors.ErrUnauthorized {
+				utils.JSONError(w, "", errors.CodeUnauthorized)
+			} else if e, ok := err.(*loginLockedError); ok {
+				writeLoginLocked(w, e)
+			} else {
+				utils.JSONErrorResponse(w, err)
+			}
 			return
 		}
 	}
@@ -232,14 +325,75 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	membership, err := u.getMembershipLogin(ctx, tid, user.ID)
+	params := loginParams{
+		tenantID:  tid,
+		writePerm: writePerm,
+		scope:     scope,
+		clientID:  clientID,
+		nonce:     nonce,
+	}
+
+	if remoteAddr == nil {
+		u.beginLogin(ctx, w, r, user, &params)
+		return
+	}
+
+	u.completeLogin(ctx, w, r, user, remoteAddr, &params)
+}
+
+// beginLogin continues interactive login after the first factor. It either responds with the MFA challenge or issues the token
+func (u *Users) beginLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, user *storage.User, params *loginParams) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	methods, err := u.loginMFAMethods(ctx, user, params.tenantID)
+	if err != nil {
+		if err != errors.ErrMFAEnrollRequired && err != errors.ErrTenantNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if len(methods) != 0 {
+		u.writeMFAChallenge(w, user, params, methods, site)
+		return
+	}
+
+	u.completeLogin(ctx, w, r, user, nil, params)
+}
+
+// loginParams are passed through the MFA challenge
+type loginParams struct {
+	tenantID  uuid.UUID
+	writePerm bool
+	scope     string
+	clientID  string
+	nonce     string
+	mfaMethod string // Second factor used, if any
+}
+
+// completeLogin issues the token for authenticated user
+func (u *Users) completeLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, user *storage.User, remoteAddr net.IP, params *loginParams) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	membership, err := u.getMembershipLogin(ctx, params.tenantID, user.ID)
 	if err != nil {
 		utils.JSONErrorResponse(w, err)
 		return
 	}
 
+	if remoteAddr == nil {
+		if err := u.checkMFAPolicy(ctx, user, membership.TenantID, params.mfaMethod); err != nil {
+			if err != errors.ErrMFARequired && err != errors.ErrMFAEnrollRequired {
+				log.Error(err)
+			}
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+	}
+
 	var role rbac.Role
-	if writePerm {
+	if params.writePerm {
 		role, err = u.Enforcer.GetRole(ctx, membership.Roles.Get()...)
 		if err != nil {
 			utils.JSONErrorResponse(w, err)
@@ -251,13 +405,33 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 		user:          user,
 		membership:    membership,
 		role:          role,
-		sessionMaxAge: site.SessionMaxAge,
-		refresh:       u.RefreshURL(site),
+		sessionMaxAge: accessTokenMaxAge(site),
 		baseURL:       site.GetBaseURL(),
+		openID:        hasScope(params.scope, scopeOpenID),
+		clientID:      params.clientID,
+		nonce:         params.nonce,
+		authTime:      time.Now(),
 	}
 
 	if remoteAddr != nil {
 		opt.addr = remoteAddr.String()
+	} else {
+		// Only interactive logins get sessions and refresh tokens
+		session, err := u.newSession(ctx, r, user, params.mfaMethod, site)
+		if err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		// The client ID of a login request isn't authenticated, so the refresh token is a first-party one
+		if opt.refreshToken, err = u.newRefreshToken(ctx, session, membership, "", params.scope, params.writePerm); err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+		opt.sessionID = session.ID
+		opt.refresh = u.RefreshURL(site)
 	}
 
 	if err := u.writeUserToken(w, &opt); err != nil {
@@ -276,47 +450,40 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Refresh is a refresh endpoint handler
+// Refresh is a refresh endpoint handler. It exchanges one-time refresh token for a new access token
 func (u *Users) Refresh(w http.ResponseWriter, r *http.Request) {
-	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
-	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
-	token := r.Context().Value(middleware.TokenContextKey).(*jwt.Token)
-	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.RefreshToken = r.PostFormValue("refresh_token")
+	}
 
 	ctx, cancel := u.context(r)
 	defer cancel()
 
-	if err := u.Storage.UpdateRefreshInfo(ctx, self.ID, utils.GetRemoteAddr(r)); err != nil {
+	// First-party tokens only, OAuth clients authenticate at the token endpoint
+	_, opt, err := u.rotateRefreshToken(ctx, r, request.RefreshToken, "")
+	if err != nil {
+		if err != errors.ErrInvalidGrant && err != errors.ErrRefreshTokenReuse && err != errors.ErrMFARequired && err != errors.ErrMFAEnrollRequired {
+			log.Error(err)
+		}
 		utils.JSONErrorResponse(w, err)
 		return
 	}
 
-	claims := token.Claims.(jwt.MapClaims)
-
-	var (
-		role rbac.Role
-		err  error
-	)
-	if _, ok := claims[utils.NSClaim(u.Namespace, "permissions")]; ok {
-		role, err = u.Enforcer.GetRole(ctx, member.Roles.Get()...)
-		if err != nil {
-			utils.JSONErrorResponse(w, err)
-			return
-		}
-	}
-
-	opt := userTokenOptions{
-		user:          self,
-		membership:    member,
-		role:          role,
-		sessionMaxAge: site.SessionMaxAge,
-		refresh:       u.RefreshURL(site),
-		baseURL:       site.GetBaseURL(),
+	if err := u.Storage.UpdateRefreshInfo(ctx, opt.user.ID, utils.GetRemoteAddr(r)); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
 	}
 
-	opt.addr, _ = claims[utils.NSClaim(u.Namespace, "address")].(string)
-
-	if err := u.writeUserToken(w, &opt); err != nil {
+	if err := u.writeUserToken(w, opt); err != nil {
 		utils.JSONErrorResponse(w, err)
 		return
 	}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/notification"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultLoginLinkMaxAge = 15 * time.Minute
+
+func loginLinkMaxAge(site *middleware.DomainConfigData) time.Duration {
+	if site.LoginLinkMaxAge != 0 {
+		return site.LoginLinkMaxAge
+	}
+	return defaultLoginLinkMaxAge
+}
+
+func (u *Users) loginLinkToken(user *storage.User, params *loginParams, site *middleware.DomainConfigData) (string, error) {
+	return u.TokenFactory.Create(
+		jwt.MapClaims{
+			"gen":              user.LoginGen,
+			"link_tenant":      params.tenantID,
+			"link_permissions": params.writePerm,
+			"link_scope":       params.scope,
+			"link_client_id":   params.clientID,
+			"link_nonce":       params.nonce,
+		},
+		user,
+		u.LoginLinkPath,
+		loginLinkMaxAge(site),
+		site,
+	)
+}
+
+// verifyLoginLink returns user ID, generation and login parameters carried by the link token
+func (u *Users) verifyLoginLink(requestToken string) (uuid.UUID, int, *loginParams, error) {
+	token, err := u.TokenFactory.Verify(requestToken)
+	if err != nil {
+		return uuid.Nil, 0, nil, errors.ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience(u.LoginLinkPath, true) {
+		return uuid.Nil, 0, nil, errors.ErrAudience
+	}
+
+	uid, ok := claimUUID(claims, "sub")
+	if !ok {
+		return uuid.Nil, 0, nil, errors.ErrInvalidToken
+	}
+
+	gen, ok := u.TokenFactory.GetClaim(token, "gen").(float64)
+	if !ok {
+		return uuid.Nil, 0, nil, errors.ErrInvalidToken
+	}
+
+	var params loginParams
+	params.tenantID, _ = claimUUID(claims, utils.NSClaim(u.Namespace, "link_tenant"))
+	params.writePerm, _ = u.TokenFactory.GetClaim(token, "link_permissions").(bool)
+	params.scope, _ = u.TokenFactory.GetClaim(token, "link_scope").(string)
+	params.clientID, _ = u.TokenFactory.GetClaim(token, "link_client_id").(string)
+	params.nonce, _ = u.TokenFactory.GetClaim(token, "link_nonce").(string)
+
+	return uid, int(gen), &params, nil
+}
+
+// EmailLogin is a passwordless login endpoint handler. A request carrying an email address sends a one-time
+// login link to the user. A request carrying the token from the link is treated as a successful first login step
+func (u *Users) EmailLogin(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email    string    `json:"email"`
+		Token    string    `json:"token"`
+		TenantID uuid.UUID `json:"tenant_id"`
+		Scope    string    `json:"scope"`
+		ClientID string    `json:"client_id"`
+		Nonce    string    `json:"nonce"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.Email = r.PostFormValue("email")
+		request.Token = r.PostFormValue("token")
+		request.TenantID = uuid.FromStringOrNil(r.PostFormValue("tenant_id"))
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	if request.Token != "" {
+		u.loginWithLink(ctx, w, r, request.Token)
+		return
+	}
+
+	if request.Email == "" {
+		utils.JSONErrorResponse(w, errors.ErrEmailEmpty)
+		return
+	}
+
+	params := loginParams{
+		tenantID:  request.TenantID,
+		writePerm: true,
+		scope:     request.Scope,
+		clientID:  request.ClientID,
+		nonce:     request.Nonce,
+	}
+
+	if v := r.FormValue("permissions"); v != "" {
+		params.writePerm, _ = strconv.ParseBool(v)
+	}
+
+	// Don't reveal whether the account exists
+	defer w.WriteHeader(http.StatusNoContent)
+
+	if err := u.sendLoginLink(ctx, r, request.Email, &params); err != nil && err != errors.ErrUserNotFound {
+		log.Error(err)
+	}
+}
+
+func (u *Users) sendLoginLink(ctx context.Context, r *http.Request, email string, params *loginParams) error {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	user, err := u.Storage.GetUserByEmail(ctx, storage.AccountRegular, email)
+	if err != nil {
+		return err
+	}
+
+	if !user.EmailVerified {
+		return nil
+	}
+
+	token, err := u.loginLinkToken(user, params, site)
+	if err != nil {
+		return err
+	}
+
+	if err := u.Notifier.Notify(r.Context(), notification.NotificationLoginLink, &notification.NotificationData{
+		Addr:        utils.GetRemoteAddr(r),
+		CurrentUser: user,
+		TargetUser:  user,
+		Token:       token,
+		TokenMaxAge: loginLinkMaxAge(site),
+		Misc:        &site.TemplateData,
+	}); err != nil {
+		return err
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLoginLinkRequest, user.ID, user.ID, r)).WithField("email", user.Email).Printf("User %v requested login link", user.ID)
+	}
+
+	return nil
+}
+
+func (u *Users) loginWithLink(ctx context.Context, w http.ResponseWriter, r *http.Request, requestToken string) {
+	uid, gen, params, err := u.verifyLoginLink(requestToken)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, "", errors.CodeUnauthorized)
+		return
+	}
+
+	if !user.EmailVerified {
+		utils.JSONErrorResponse(w, errors.ErrEmailNotVerified)
+		return
+	}
+
+	// Single use
+	if err := u.Storage.UseLoginGen(ctx, user.ID, gen); err != nil {
+		if err != errors.ErrTokenExpired {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if params.tenantID == uuid.Nil {
+		params.tenantID = user.GetDefaultMembership()
+	}
+
+	u.beginLogin(ctx, w, r, user, params)
+}
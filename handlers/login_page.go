@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const loginPageSrc = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in{{with .AppName}} to {{.}}{{end}}</title>
+<style>
+body { font-family: sans-serif; background: #f4f5f7; margin: 0; }
+main { max-width: 22em; margin: 10vh auto; padding: 2em; background: #fff; border-radius: 4px; box-shadow: 0 1px 3px rgba(0,0,0,.2); }
+h1 { font-size: 1.4em; margin-top: 0; }
+label { display: block; margin: 1em 0 .3em; }
+input[type=email], input[type=password], input[type=text] { width: 100%; box-sizing: border-box; padding: .5em; }
+button { margin-top: 1.5em; width: 100%; padding: .6em; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<main>
+<h1>Sign in{{with .AppName}} to {{.}}{{end}}</h1>
+{{with .ClientName}}<p>{{.}} is requesting access to your account.</p>{{end}}
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+{{if .Form}}<form method="post">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{range $k, $v := .Form}}<input type="hidden" name="{{$k}}" value="{{$v}}">
+{{end}}{{if .MFA}}<label for="code">Authentication or recovery code</label>
+<input id="code" type="text" name="code" autocomplete="one-time-code" required autofocus>
+<button type="submit">Verify</button>{{else}}<label for="email">Email</label>
+<input id="email" type="email" name="email" value="{{.Email}}" autocomplete="username" required autofocus>
+<label for="password">Password</label>
+<input id="password" type="password" name="password" autocomplete="current-password" required>
+<button type="submit">Sign in</button>{{end}}
+</form>{{end}}
+</main>
+</body>
+</html>
+`
+
+var loginPageTpl = template.Must(template.New("login").Parse(loginPageSrc))
+
+type loginPageData struct {
+	AppName    string
+	ClientName string
+	Error      string
+	Email      string
+	MFA        bool              // Second step
+	Form       map[string]string // Hidden fields
+	CSRFToken  string
+}
+
+const (
+	csrfCookieName = "auth_csrf"
+	csrfFieldName  = "csrf_token"
+)
+
+// loginCSRFToken returns the double submit token of the login form, the same value is kept in a cookie
+func loginCSRFToken(w http.ResponseWriter, r *http.Request, path string, secure bool) (string, error) {
+	if c, err := r.Cookie(csrfCookieName); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+
+	token, _, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     path,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return token, nil
+}
+
+// verifyLoginCSRF checks that the submitted form carries the token from the cookie
+func verifyLoginCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookieName)
+	if err != nil || c.Value == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostFormValue(csrfFieldName))) == 1
+}
+
+func writeLoginPage(w http.ResponseWriter, status int, data *loginPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// Prevent clickjacking
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+
+	if err := loginPageTpl.Execute(w, data); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLoginCSRF(t *testing.T) {
+	w := httptest.NewRecorder()
+	token, err := loginCSRFToken(w, httptest.NewRequest("GET", "/oauth/authorize", nil), "/oauth/authorize", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != token || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+
+	post := func(field string, cookie bool) *http.Request {
+		r := httptest.NewRequest("POST", "/oauth/authorize", strings.NewReader(url.Values{csrfFieldName: {field}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie {
+			r.AddCookie(cookies[0])
+		}
+		return r
+	}
+
+	if !verifyLoginCSRF(post(token, true)) {
+		t.Error("valid token rejected")
+	}
+
+	if verifyLoginCSRF(post(token, false)) {
+		t.Error("token without cookie accepted")
+	}
+
+	if verifyLoginCSRF(post("", true)) || verifyLoginCSRF(post(token+"x", true)) {
+		t.Error("invalid token accepted")
+	}
+
+	// Existing cookie is reused
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/oauth/authorize", nil)
+	r.AddCookie(cookies[0])
+	if tok, err := loginCSRFToken(w, r, "/oauth/authorize", true); err != nil || tok != token || len(w.Result().Cookies()) != 0 {
+		t.Errorf("cookie expected to be reused: %s %v", tok, err)
+	}
+}
@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/totp"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMFAChallengeMaxAge = 5 * time.Minute
+	recoveryCodesNum          = 10
+)
+
+// MFA methods
+const (
+	mfaMethodTOTP     = "totp"
+	mfaMethodRecovery = "recovery_code"
+	mfaMethodWebAuthn = "webauthn"
+)
+
+type mfaChallenge struct {
+	errors.Response
+	MFAToken string   `json:"mfa_token"`
+	Methods  []string `json:"methods"`
+}
+
+// mfaMethods returns second factor methods available to the user. Empty list means no second factor is required
+func (u *Users) mfaMethods(ctx context.Context, user *storage.User) ([]string, error) {
+	t, err := u.Storage.GetTOTP(ctx, user.ID)
+	if err != nil {
+		if err == errors.ErrMFANotEnrolled {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !t.Confirmed {
+		return nil, nil
+	}
+
+	return []string{mfaMethodTOTP, mfaMethodRecovery}, nil
+}
+
+// mfaMethodAllowed checks the method against the tenant policy. Recovery codes go along with TOTP
+func mfaMethodAllowed(tenant *storage.TenantModel, method string) bool {
+	if method == mfaMethodRecovery {
+		method = mfaMethodTOTP
+	}
+	return tenant.MFAMethodAllowed(method)
+}
+
+// loginMFAMethods returns second factor methods to be asked during the login into the tenant
+func (u *Users) loginMFAMethods(ctx context.Context, user *storage.User, tenantID uuid.UUID) ([]string, error) {
+	tenant, err := u.Storage.GetTenant(ctx, tenantID, uuid.Nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if tenant.MFAPolicy == storage.MFAPolicyNone {
+		return nil, nil
+	}
+
+	methods, err := u.mfaMethods(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []string
+	for _, m := range methods {
+		if mfaMethodAllowed(tenant, m) {
+			allowed = append(allowed, m)
+		}
+	}
+
+	if len(allowed) == 0 && tenant.MFAPolicy == storage.MFAPolicyRequired {
+		return nil, errors.ErrMFAEnrollRequired
+	}
+
+	return allowed, nil
+}
+
+// checkMFAPolicy makes sure the second factor used to authenticate the user satisfies the tenant policy
+func (u *Users) checkMFAPolicy(ctx context.Context, user *storage.User, tenantID uuid.UUID, method string) error {
+	tenant, err := u.Storage.GetTenant(ctx, tenantID, uuid.Nil, false)
+	if err != nil {
+		return err
+	}
+
+	if tenant.MFAPolicy != storage.MFAPolicyRequired || method != "" && mfaMethodAllowed(tenant, method) {
+		return nil
+	}
+
+	if _, err := u.loginMFAMethods(ctx, user, tenantID); err != nil {
+		return err
+	}
+
+	// Enrolled but not used
+	return errors.ErrMFARequired
+}
+
+func (u *Users) mfaChallengeToken(user *storage.User, params *loginParams, site *middleware.DomainConfigData) (string, error) {
+	maxAge := site.MFAChallengeMaxAge
+	if maxAge == 0 {
+		maxAge = defaultMFAChallengeMaxAge
+	}
+
+	return u.TokenFactory.Create(
+		jwt.MapClaims{
+			"mfa_tenant":      params.tenantID,
+			"mfa_permissions": params.writePerm,
+			"mfa_scope":       params.scope,
+			"mfa_client_id":   params.clientID,
+			"mfa_nonce":       params.nonce,
+		},
+		user,
+		u.MFAPath,
+		maxAge,
+		site,
+	)
+}
+
+// writeMFAChallenge responds with a short-lived token to be exchanged along with the second factor at MFAPath
+func (u *Users) writeMFAChallenge(w http.ResponseWriter, user *storage.User, params *loginParams, methods []string, site *middleware.DomainConfigData) {
+	token, err := u.mfaChallengeToken(user, params, site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	res := mfaChallenge{
+		Response: *errors.ErrorResponse(errors.ErrMFARequired),
+		MFAToken: token,
+		Methods:  methods,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, res.HTTPStatus(), &res)
+}
+
+// verifyMFAChallenge returns user ID and login parameters carried by the challenge token
+func (u *Users) verifyMFAChallenge(requestToken string) (uuid.UUID, *loginParams, error) {
+	if requestToken == "" {
+		return uuid.Nil, nil, errors.ErrTokenEmpty
+	}
+
+	token, err := u.TokenFactory.Verify(requestToken)
+	if err != nil {
+		return uuid.Nil, nil, errors.ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience(u.MFAPath, true) {
+		return uuid.Nil, nil, errors.ErrAudience
+	}
+
+	uid, ok := claimUUID(claims, "sub")
+	if !ok {
+		return uuid.Nil, nil, errors.ErrInvalidToken
+	}
+
+	var params loginParams
+	params.tenantID, _ = claimUUID(claims, utils.NSClaim(u.Namespace, "mfa_tenant"))
+	params.writePerm, _ = u.TokenFactory.GetClaim(token, "mfa_permissions").(bool)
+	params.scope, _ = u.TokenFactory.GetClaim(token, "mfa_scope").(string)
+	params.clientID, _ = u.TokenFactory.GetClaim(token, "mfa_client_id").(string)
+	params.nonce, _ = u.TokenFactory.GetClaim(token, "mfa_nonce").(string)
+
+	return uid, &params, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// verifyMFACode checks the second factor and returns the method used. The method is guessed from the code format if not specified
+func (u *Users) verifyMFACode(ctx context.Context, userID uuid.UUID, method, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", errors.ErrMFACode
+	}
+
+	if method == "" {
+		if len(code) == totp.Digits {
+			method = mfaMethodTOTP
+		} else {
+			method = mfaMethodRecovery
+		}
+	}
+
+	switch method {
+	case mfaMethodTOTP:
+		t, err := u.Storage.GetTOTP(ctx, userID)
+		if err != nil {
+			if err == errors.ErrMFANotEnrolled {
+				return "", errors.ErrMFACode
+			}
+			return "", err
+		}
+
+		step, ok := totp.Validate(t.Secret, code, time.Now())
+		if !t.Confirmed || !ok {
+			return "", errors.ErrMFACode
+		}
+
+		return method, u.Storage.UseTOTPStep(ctx, userID, step)
+
+	case mfaMethodRecovery:
+		return method, u.Storage.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	return "", errors.ErrMFACode
+}
+
+// LoginMFA is the second login step. It exchanges the MFA challenge token and the second factor for the session token
+func (u *Users) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		MFAToken string `json:"mfa_token"`
+		Method   string `json:"method"`
+		Code     string `json:"code"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.MFAToken = r.PostFormValue("mfa_token")
+		request.Method = r.PostFormValue("method")
+		request.Code = r.PostFormValue("code")
+	}
+
+	uid, params, err := u.verifyMFAChallenge(request.MFAToken)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, "", errors.CodeUnauthorized)
+		return
+	}
+
+	if err := u.checkLoginLock(ctx, storage.LoginFailuresUser, user.ID.String()); err != nil {
+		if e, ok := err.(*loginLockedError); ok {
+			writeLoginLocked(w, e)
+		} else {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+		}
+		return
+	}
+
+	if params.mfaMethod, err = u.verifyMFACode(ctx, user.ID, request.Method, request.Code); err != nil {
+		if err != errors.ErrMFACode {
+			log.Error(err)
+		} else {
+			u.loginFailed(ctx, r, user)
+			if u.AuxLogger != nil {
+				u.AuxLogger.WithFields(logFields(EvMFAFailure, user.ID, user.ID, r)).Printf("Invalid second factor for user %v", user.ID)
+			}
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	u.completeLogin(ctx, w, r, user, nil, params)
+}
+
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes = make([]string, recoveryCodesNum)
+	hashes = make([]string, recoveryCodesNum)
+
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		c := strings.ToLower(enc.EncodeToString(buf))
+		codes[i] = c[:4] + "-" + c[4:]
+		hashes[i] = hashToken(c)
+	}
+
+	return codes, hashes, nil
+}
+
+// EnrollTOTP starts authenticator enrollment. The user must confirm it with a code before it takes effect
+func (u *Users) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	// Nobody can enroll an authenticator on behalf of the user
+	if self.ID != uid || self.Type != storage.AccountRegular {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	if err := u.Storage.NewTOTP(ctx, uid, secret); err != nil {
+		if err != errors.ErrMFAEnrolled {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	issuer := site.TemplateData.AppName
+	if issuer == "" {
+		if base, err := url.Parse(site.GetBaseURL()); err == nil {
+			issuer = base.Host
+		}
+	}
+
+	res := struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}{
+		Secret: secret,
+		URI:    totp.URI(issuer, self.Email, secret),
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, http.StatusOK, &res)
+}
+
+// ConfirmTOTP completes authenticator enrollment and returns recovery codes. The codes are shown only once
+func (u *Users) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	if self.ID != uid {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	t, err := u.Storage.GetTOTP(ctx, uid)
+	if err != nil {
+		if err != errors.ErrMFANotEnrolled {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.Confirmed {
+		utils.JSONErrorResponse(w, errors.ErrMFAEnrolled)
+		return
+	}
+
+	step, ok := totp.Validate(t.Secret, request.Code, time.Now())
+	if !ok {
+		utils.JSONErrorResponse(w, errors.ErrMFACode)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if err := u.Storage.ConfirmTOTP(ctx, uid, step, hashes); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvMFAEnroll, self.ID, uid, r)).WithField("method", mfaMethodTOTP).Printf("User %v enrolled authenticator", uid)
+	}
+
+	res := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, http.StatusOK, &res)
+}
+
+// DeleteTOTP removes the authenticator. Users must confirm it with a current code, admins can reset other users' authenticators
+func (u *Users) DeleteTOTP(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	// The code is taken from the body to keep it out of access logs
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	if self.ID == uid {
+		if _, err := u.verifyMFACode(ctx, uid, "", request.Code); err != nil {
+			if err != errors.ErrMFACode {
+				log.Error(err)
+			}
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+	} else {
+		role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+		if err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		if _, err = u.checkWritePermissions(role, storage.AccountRegular, false); err != nil {
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+	}
+
+	if err := u.Storage.DeleteTOTP(ctx, uid); err != nil {
+		if err != errors.ErrMFANotEnrolled {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvMFADisable, self.ID, uid, r)).WithField("method", mfaMethodTOTP).Printf("User %v removed authenticator of user %v", self.ID, uid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,647 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultAuthCodeMaxAge     = 5 * time.Minute
+	defaultServiceTokenMaxAge = time.Hour
+	pkceMethodS256            = "S256"
+)
+
+// OAuth2 error codes (RFC 6749)
+const (
+	oauthInvalidRequest          = "invalid_request"
+	oauthInvalidClient           = "invalid_client"
+	oauthInvalidGrant            = "invalid_grant"
+	oauthUnsupportedGrantType    = "unsupported_grant_type"
+	oauthUnsupportedResponseType = "unsupported_response_type"
+	oauthAccessDenied            = "access_denied"
+	oauthServerError             = "server_error"
+)
+
+func (u *Users) AuthorizeURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + u.AuthorizePath
+}
+
+func (u *Users) TokenURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + u.TokenPath
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	res := struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description,omitempty"`
+	}{
+		Error:       code,
+		Description: description,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	utils.JSONResponse(w, status, &res)
+}
+
+func redirectOAuthError(w http.ResponseWriter, r *http.Request, redirectURI, state, code, description string) {
+	dest, err := url.Parse(redirectURI)
+	if err != nil {
+		writeLoginPage(w, http.StatusBadRequest, &loginPageData{Error: "Invalid redirect URI"})
+		return
+	}
+
+	q := dest.Query()
+	q.Set("error", code)
+	if description != "" {
+		q.Set("error_description", description)
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	dest.RawQuery = q.Encode()
+
+	http.Redirect(w, r, dest.String(), http.StatusFound)
+}
+
+func randomToken() (token, hash string, err error) {
+	var buf [32]byte
+	if _, err = rand.Read(buf[:]); err != nil {
+		return
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(buf[:])
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyPKCE checks code verifier against the challenge (RFC 7636)
+func verifyPKCE(challenge, method, verifier string) bool {
+	if method != pkceMethodS256 || verifier == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// Authorize is an OAuth2 authorization endpoint handler. It renders the hosted login page
+// and redirects back to the client with an authorization code
+func (u *Users) Authorize(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	params := map[string]string{}
+	for _, k := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method", "tenant"} {
+		if v := r.FormValue(k); v != "" {
+			params[k] = v
+		}
+	}
+
+	page := loginPageData{
+		AppName: site.TemplateData.AppName,
+	}
+
+	csrfToken, err := loginCSRFToken(w, r, u.AuthorizePath, strings.HasPrefix(site.GetBaseURL(), "https://"))
+	if err != nil {
+		log.Error(err)
+		page.Error = "Sign in failed"
+		writeLoginPage(w, http.StatusInternalServerError, &page)
+		return
+	}
+	page.CSRFToken = csrfToken
+
+	client, err := u.Storage.GetOAuthClient(ctx, params["client_id"])
+	if err != nil {
+		if err != errors.ErrClientNotFound {
+			log.Error(err)
+		}
+		page.Error = "Unknown client"
+		writeLoginPage(w, http.StatusBadRequest, &page)
+		return
+	}
+
+	// Never redirect to unregistered URIs
+	redirectURI := params["redirect_uri"]
+	if !client.ValidRedirectURI(redirectURI) {
+		page.Error = "Invalid redirect URI"
+		writeLoginPage(w, http.StatusBadRequest, &page)
+		return
+	}
+
+	state := params["state"]
+
+	if params["response_type"] != "code" {
+		redirectOAuthError(w, r, redirectURI, state, oauthUnsupportedResponseType, "")
+		return
+	}
+
+	if params["code_challenge"] == "" || params["code_challenge_method"] != pkceMethodS256 {
+		redirectOAuthError(w, r, redirectURI, state, oauthInvalidRequest, "S256 code challenge is required")
+		return
+	}
+
+	page.ClientName = client.Name
+	page.Form = params
+
+	if r.Method != http.MethodPost {
+		writeLoginPage(w, http.StatusOK, &page)
+		return
+	}
+
+	// Login CSRF
+	if !verifyLoginCSRF(r) {
+		page.Error = "Sign in session expired, please try again"
+		writeLoginPage(w, http.StatusForbidden, &page)
+		return
+	}
+
+	var tid uuid.UUID
+	if v, ok := params["tenant"]; ok {
+		if tid, err = uuid.FromString(v); err != nil {
+			redirectOAuthError(w, r, redirectURI, state, oauthInvalidRequest, "Invalid tenant ID")
+			return
+		}
+	}
+
+	var (
+		user      *storage.User
+		mfaMethod string
+	)
+	if mfaToken := r.PostFormValue("mfa_token"); mfaToken != "" {
+		// Second step
+		uid, _, err := u.verifyMFAChallenge(mfaToken)
+		if err != nil {
+			page.Error = "Sign in session expired"
+			writeLoginPage(w, http.StatusUnauthorized, &page)
+			return
+		}
+
+		if err = u.checkLoginLock(ctx, storage.LoginFailuresUser, uid.String()); err != nil {
+			page.Error = err.Error()
+			writeLoginPage(w, http.StatusTooManyRequests, &page)
+			return
+		}
+
+		if mfaMethod, err = u.verifyMFACode(ctx, uid, "", r.PostFormValue("code")); err != nil {
+			if err != errors.ErrMFACode {
+				log.Error(err)
+			} else {
+				u.loginFailed(ctx, r, &storage.User{ID: uid})
+				if u.AuxLogger != nil {
+					u.AuxLogger.WithFields(logFields(EvMFAFailure, uid, uid, r)).Printf("Invalid second factor for user %v", uid)
+				}
+			}
+			page.Error = "Invalid authentication code"
+			page.MFA = true
+			page.Form["mfa_token"] = mfaToken
+			writeLoginPage(w, http.StatusUnauthorized, &page)
+			return
+		}
+
+		if user, err = u.Storage.GetUserByID(ctx, storage.AccountRegular, uid); err != nil {
+			log.Error(err)
+			page.Error = "Sign in failed"
+			writeLoginPage(w, http.StatusUnauthorized, &page)
+			return
+		}
+	} else {
+		page.Email = r.PostFormValue("email")
+		if user, err = u.authenticate(ctx, r, page.Email, r.PostFormValue("password")); err != nil {
+			status := http.StatusUnauthorized
+			if err == errors.ErrUnauthorized {
+				page.Error = "Invalid email or password"
+			} else {
+				if _, ok := err.(*loginLockedError); ok {
+					status = http.StatusTooManyRequests
+				}
+				page.Error = err.Error()
+			}
+			writeLoginPage(w, status, &page)
+			return
+		}
+
+		if tid == uuid.Nil {
+			tid = user.GetDefaultMembership()
+		}
+
+		methods, err := u.loginMFAMethods(ctx, user, tid)
+		if err != nil {
+			if err == errors.ErrMFAEnrollRequired {
+				page.Error = err.Error()
+				writeLoginPage(w, http.StatusForbidden, &page)
+			} else {
+				log.Error(err)
+				page.Error = "Sign in failed"
+				writeLoginPage(w, http.StatusInternalServerError, &page)
+			}
+			return
+		}
+
+		if len(methods) != 0 {
+			mfaToken, err := u.mfaChallengeToken(user, &loginParams{}, site)
+			if err != nil {
+				log.Error(err)
+				page.Error = "Sign in failed"
+				writeLoginPage(w, http.StatusInternalServerError, &page)
+				return
+			}
+
+			page.MFA = true
+			page.Form["mfa_token"] = mfaToken
+			writeLoginPage(w, http.StatusOK, &page)
+			return
+		}
+	}
+
+	if tid == uuid.Nil {
+		tid = user.GetDefaultMembership()
+	}
+
+	membership, err := u.getMembershipLogin(ctx, tid, user.ID)
+	if err != nil {
+		redirectOAuthError(w, r, redirectURI, state, oauthAccessDenied, err.Error())
+		return
+	}
+
+	if err := u.checkMFAPolicy(ctx, user, membership.TenantID, mfaMethod); err != nil {
+		if err != errors.ErrMFARequired && err != errors.ErrMFAEnrollRequired {
+			log.Error(err)
+		}
+		redirectOAuthError(w, r, redirectURI, state, oauthAccessDenied, err.Error())
+		return
+	}
+
+	code, codeHash, err := randomToken()
+	if err != nil {
+		log.Error(err)
+		redirectOAuthError(w, r, redirectURI, state, oauthServerError, "")
+		return
+	}
+
+	maxAge := site.AuthCodeMaxAge
+	if maxAge == 0 {
+		maxAge = defaultAuthCodeMaxAge
+	}
+
+	now := time.Now()
+	authCode := storage.AuthCode{
+		CodeHash:            codeHash,
+		ClientID:            client.ID,
+		MembershipID:        membership.ID,
+		RedirectURI:         redirectURI,
+		Scope:               params["scope"],
+		Nonce:               params["nonce"],
+		CodeChallenge:       params["code_challenge"],
+		CodeChallengeMethod: params["code_challenge_method"],
+		AuthTime:            now,
+		Expires:             now.Add(maxAge),
+		MFAMethod:           mfaMethod,
+	}
+
+	if err := u.Storage.NewAuthCode(ctx, &authCode); err != nil {
+		log.Error(err)
+		redirectOAuthError(w, r, redirectURI, state, oauthServerError, "")
+		return
+	}
+
+	addr := utils.GetRemoteAddr(r)
+	if err := u.Storage.UpdateLoginInfo(ctx, user.ID, addr); err != nil {
+		log.Error(err)
+	}
+
+	dest, _ := url.Parse(redirectURI)
+	q := dest.Query()
+	q.Set("code", code)
+	if state != "" {
+		q.Set("state", state)
+	}
+	dest.RawQuery = q.Encode()
+
+	http.Redirect(w, r, dest.String(), http.StatusFound)
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLogin, membership.ID, membership.ID, r)).WithFields(map[string]interface{}{
+			"email":     user.Email,
+			"client_id": client.ID,
+		}).Printf("User %v logged into tenant %v via OAuth2 client %v", user.ID, membership.TenantID, client.ID)
+	}
+}
+
+// authenticateClient checks OAuth2 client credentials passed either using Basic scheme or in the request body
+func (u *Users) authenticateClient(r *http.Request) (*storage.OAuthClient, error) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	client, err := u.Storage.GetOAuthClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.Public() {
+		if err := bcrypt.CompareHashAndPassword(client.SecretHash, []byte(secret)); err != nil {
+			return nil, errors.ErrUnauthorized
+		}
+	}
+
+	return client, nil
+}
+
+// Token is an OAuth2 token endpoint handler
+func (u *Users) Token(w http.ResponseWriter, r *http.Request) {
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		u.authorizationCodeGrant(w, r)
+	case "client_credentials":
+		u.clientCredentialsGrant(w, r)
+	case "refresh_token":
+		u.refreshTokenGrant(w, r)
+	case "":
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, "grant_type is required")
+	default:
+		writeOAuthError(w, http.StatusBadRequest, oauthUnsupportedGrantType, "")
+	}
+}
+
+func (u *Users) authorizationCodeGrant(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	client, err := u.authenticateClient(r)
+	if err != nil {
+		if err != errors.ErrClientNotFound && err != errors.ErrUnauthorized {
+			log.Error(err)
+		}
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, "")
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	code, err := u.Storage.ConsumeAuthCode(ctx, hashToken(r.PostFormValue("code")))
+	if err != nil {
+		if err != errors.ErrInvalidGrant {
+			log.Error(err)
+		}
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "")
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != r.PostFormValue("redirect_uri") {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "")
+		return
+	}
+
+	if !verifyPKCE(code.CodeChallenge, code.CodeChallengeMethod, r.PostFormValue("code_verifier")) {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "Code verifier mismatch")
+		return
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, code.UserID)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "")
+		return
+	}
+
+	membership, err := u.getMembershipLogin(ctx, code.TenantID, code.UserID)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, err.Error())
+		return
+	}
+
+	role, err := u.Enforcer.GetRole(ctx, membership.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	session, err := u.newSession(ctx, r, user, code.MFAMethod, site)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	refreshToken, err := u.newRefreshToken(ctx, session, membership, client.ID, code.Scope, true)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	opt := userTokenOptions{
+		user:          user,
+		membership:    membership,
+		role:          role,
+		sessionMaxAge: accessTokenMaxAge(site),
+		refreshToken:  refreshToken,
+		sessionID:     session.ID,
+		baseURL:       site.GetBaseURL(),
+		openID:        hasScope(code.Scope, scopeOpenID),
+		clientID:      client.ID,
+		nonce:         code.Nonce,
+		authTime:      code.AuthTime,
+	}
+
+	tok, err := u.newUserToken(&opt)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	writeOAuthToken(w, tok, &opt, code.Scope)
+}
+
+func (u *Users) refreshTokenGrant(w http.ResponseWriter, r *http.Request) {
+	client, err := u.authenticateClient(r)
+	if err != nil {
+		if err != errors.ErrClientNotFound && err != errors.ErrUnauthorized {
+			log.Error(err)
+		}
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, "")
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	// Other client's token must be rejected before it's rotated
+	rt, opt, err := u.rotateRefreshToken(ctx, r, r.PostFormValue("refresh_token"), client.ID)
+	if err != nil {
+		if err != errors.ErrInvalidGrant && err != errors.ErrRefreshTokenReuse {
+			log.Error(err)
+		}
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "")
+		return
+	}
+
+	if err := u.Storage.UpdateRefreshInfo(ctx, opt.user.ID, utils.GetRemoteAddr(r)); err != nil {
+		log.Error(err)
+	}
+
+	tok, err := u.newUserToken(opt)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	writeOAuthToken(w, tok, opt, rt.Scope)
+}
+
+// verifyKeySecret compares the secret with the stored hash. Keys issued before secrets were introduced can't be used until rotated
+func verifyKeySecret(key *storage.APIKey, secret string) bool {
+	return key.SecretHash != "" && secret != "" && subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashToken(secret))) == 1
+}
+
+// authenticateAPIKey checks service account API key credentials. Key ID is used as a client ID
+func (u *Users) authenticateAPIKey(r *http.Request) (*storage.APIKey, error) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	kid, err := uuid.FromString(id)
+	if err != nil || secret == "" {
+		return nil, errors.ErrUnauthorized
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	key, err := u.Storage.GetKeyByID(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !verifyKeySecret(key, secret) {
+		return nil, errors.ErrUnauthorized
+	}
+
+	if key.Expired() {
+		return nil, errors.ErrKeyExpired
+	}
+
+	return key, nil
+}
+
+func (u *Users) clientCredentialsGrant(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	key, err := u.authenticateAPIKey(r)
+	if err != nil {
+		if err != errors.ErrKeyNotFound && err != errors.ErrUnauthorized && err != errors.ErrKeyExpired {
+			log.Error(err)
+		}
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, "")
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountService, key.UserID)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, "")
+		return
+	}
+
+	membership, err := u.getMembershipLogin(ctx, key.TenantID, key.UserID)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, err.Error())
+		return
+	}
+
+	membership, role, err := u.keyScope(ctx, key, membership)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	opt := userTokenOptions{
+		user:          user,
+		key:           key,
+		membership:    membership,
+		role:          role,
+		sessionMaxAge: keyTokenMaxAge(key, serviceTokenMaxAge(site)),
+		baseURL:       site.GetBaseURL(),
+	}
+
+	tok, err := u.newUserToken(&opt)
+	if err != nil {
+		log.Error(err)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	writeOAuthToken(w, tok, &opt, "")
+
+	if err := u.Storage.UpdateLoginInfo(ctx, user.ID, utils.GetRemoteAddr(r)); err != nil {
+		log.Error(err)
+	}
+
+	if err := u.Storage.UpdateKeyLastUsed(ctx, key.ID); err != nil {
+		log.Error(err)
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLogin, membership.ID, membership.ID, r)).WithField("key_id", key.ID).Printf("Service account %v logged into tenant %v using API key %v", user.ID, membership.TenantID, key.ID)
+	}
+}
+
+func writeOAuthToken(w http.ResponseWriter, tok *userToken, opt *userTokenOptions, scope string) {
+	res := struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		IDToken      string `json:"id_token,omitempty"`
+		Scope        string `json:"scope,omitempty"`
+	}{
+		AccessToken:  tok.token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(opt.sessionMaxAge / time.Second),
+		RefreshToken: opt.refreshToken,
+		IDToken:      tok.idToken,
+		Scope:        scope,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	utils.JSONResponse(w, http.StatusOK, &res)
+}
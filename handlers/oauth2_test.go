@@ -0,0 +1,23 @@
+package handlers
+
+import "testing"
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 Appendix B
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+
+	if !verifyPKCE(challenge, pkceMethodS256, verifier) {
+		t.Error("valid verifier rejected")
+	}
+
+	if verifyPKCE(challenge, pkceMethodS256, verifier+"x") {
+		t.Error("invalid verifier accepted")
+	}
+
+	if verifyPKCE(challenge, "plain", challenge) {
+		t.Error("plain method accepted")
+	}
+}
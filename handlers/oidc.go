@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	scopeOpenID   = "openid"
+	idTokenMaxAge = time.Hour
+	// Audience of ID tokens issued without a client ID. It must never match the API audience
+	// otherwise ID tokens would be accepted as access tokens
+	idTokenAudience = "id_token"
+)
+
+// Namespaced claims exposed as standard OpenID Connect claims
+var oidcClaims = map[string]string{
+	"email":  "email",
+	"name":   "name",
+	"tenant": "tenant",
+	"roles":  "roles",
+}
+
+func (u *Users) UserInfoURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + u.UserInfoPath
+}
+
+func (u *Users) JWKSURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + u.JWKSPath
+}
+
+func hasScope(scope, value string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// mapOIDCClaims converts namespaced access token claims to OpenID Connect ones
+func (u *Users) mapOIDCClaims(src, dst jwt.MapClaims, user *storage.User) {
+	for ns, name := range oidcClaims {
+		if v, ok := src[utils.NSClaim(u.Namespace, ns)]; ok {
+			dst[name] = v
+		}
+	}
+
+	dst["sub"] = user.ID
+	if user.Email != "" {
+		dst["email_verified"] = user.EmailVerified
+	}
+}
+
+// idToken returns OpenID Connect ID token for previously issued access token claims
+func (u *Users) idToken(claims jwt.MapClaims, user *storage.User, opt *userTokenOptions, now time.Time) (string, error) {
+	aud := opt.clientID
+	if aud == "" || aud == opt.baseURL {
+		aud = idTokenAudience
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss": opt.baseURL,
+		"aud": aud,
+		"iat": now.Unix(),
+		"jti": uuid.NewV4(),
+	}
+
+	if sid, ok := claims["sid"]; ok {
+		idClaims["sid"] = sid
+	}
+
+	if exp, ok := claims["exp"]; ok {
+		idClaims["exp"] = exp
+	} else {
+		idClaims["exp"] = now.Add(idTokenMaxAge).Unix()
+	}
+
+	if !opt.authTime.IsZero() {
+		idClaims["auth_time"] = opt.authTime.Unix()
+	} else if user.LoginTimestamp != nil {
+		idClaims["auth_time"] = user.LoginTimestamp.Unix()
+	}
+
+	if opt.nonce != "" {
+		idClaims["nonce"] = opt.nonce
+	}
+
+	u.mapOIDCClaims(claims, idClaims, user)
+
+	return u.Keyring.Sign(idClaims)
+}
+
+// UserInfo is an OpenID Connect UserInfo endpoint handler
+func (u *Users) UserInfo(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	token := r.Context().Value(middleware.TokenContextKey).(*jwt.Token)
+
+	res := jwt.MapClaims{}
+	u.mapOIDCClaims(token.Claims.(jwt.MapClaims), res, self)
+
+	// Take fresh values from DB
+	if self.Email != "" {
+		res["email"] = self.Email
+	}
+
+	if self.Name != "" {
+		res["name"] = self.Name
+	}
+
+	res["updated_at"] = self.Modified.Unix()
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// OpenIDConfiguration is an OpenID Connect discovery document handler
+func (u *Users) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	claims := []string{"sub", "iss", "aud", "exp", "iat", "jti", "sid", "auth_time", "nonce", "email_verified"}
+	for _, name := range oidcClaims {
+		claims = append(claims, name)
+	}
+	sort.Strings(claims)
+
+	res := map[string]interface{}{
+		"issuer":                                site.GetBaseURL(),
+		"jwks_uri":                              u.JWKSURL(site),
+		"userinfo_endpoint":                     u.UserInfoURL(site),
+		"authorization_endpoint":                u.AuthorizeURL(site),
+		"token_endpoint":                        u.TokenURL(site),
+		"introspection_endpoint":                u.IntrospectURL(site),
+		"scopes_supported":                      []string{scopeOpenID, "email", "profile"},
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials", "refresh_token"},
+		"code_challenge_methods_supported":      []string{pkceMethodS256},
+		"token_endpoint_auth_methods_supported": []string{"none", "client_secret_basic", "client_secret_post"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{u.Keyring.SigningKey().Method.Alg()},
+		"claims_supported":                      claims,
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	utils.JSONResponse(w, http.StatusOK, res)
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/federation"
+	"github.com/ecadlabs/auth/jsonpatch"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/rbac"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func (t *Tenants) validIssuer(issuer string) bool {
+	return federation.ValidIssuer(issuer, t.InsecureFederation)
+}
+
+// connectionAccess checks the caller may manage identity provider connections of the tenant
+func (t *Tenants) connectionAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (rbac.Role, *storage.Membership, uuid.UUID, bool) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	tid, err := uuid.FromString(mux.Vars(r)["tenantId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return nil, nil, uuid.Nil, false
+	}
+
+	role, err := t.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return nil, nil, uuid.Nil, false
+	}
+
+	if !t.canUpdateTenant(role, member, tid) {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return nil, nil, uuid.Nil, false
+	}
+
+	return role, member, tid, true
+}
+
+// canAssignRole returns true if the caller is allowed to grant the role to provisioned users
+func canAssignRole(role rbac.Role, name string) bool {
+	if name == "" {
+		return true
+	}
+	granted, _ := role.IsAllGranted(permissionDelegatePrefix + name)
+	return granted
+}
+
+// NewOIDCConnection is a endpoint handler to register an upstream OpenID Connect provider for the tenant
+func (t *Tenants) NewOIDCConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	role, member, tid, ok := t.connectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		storage.OIDCConnection
+		ClientSecret string `json:"client_secret"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	conn := request.OIDCConnection
+	conn.TenantID = tid
+	conn.ClientSecret = request.ClientSecret
+
+	if !t.validIssuer(conn.Issuer) {
+		utils.JSONError(w, "Invalid issuer", errors.CodeBadRequest)
+		return
+	}
+
+	if conn.ClientID == "" {
+		utils.JSONError(w, "Client ID is required", errors.CodeBadRequest)
+		return
+	}
+
+	if conn.JITProvisioning && len(conn.Domains) == 0 {
+		utils.JSONError(w, "Just-in-time provisioning requires email domains", errors.CodeBadRequest)
+		return
+	}
+
+	if !canAssignRole(role, conn.DefaultRole) {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	res, err := t.Storage.NewOIDCConnection(ctx, &conn)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.AuxLogger != nil {
+		t.AuxLogger.WithFields(logFields(EvNewConnection, member.ID, tid, r)).WithField("connection", res.ID).WithField("issuer", res.Issuer).Printf("User %v added identity provider %s to tenant %v", member.UserID, res.Issuer, tid)
+	}
+
+	w.Header().Set("Location", t.tenantsURL(r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData))+tid.String()+"/oidc/"+res.ID.String())
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
+
+// GetOIDCConnections is a endpoint handler to list the tenant's identity provider connections
+func (t *Tenants) GetOIDCConnections(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	_, _, tid, ok := t.connectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	res, err := t.Storage.GetOIDCConnections(ctx, tid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if res == nil {
+		res = []*storage.OIDCConnection{}
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// GetOIDCConnection is a endpoint handler to get the identity provider connection
+func (t *Tenants) GetOIDCConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	_, _, tid, ok := t.connectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	res, err := t.Storage.GetOIDCConnection(ctx, id)
+	if err != nil || res.TenantID != tid {
+		if err != nil && err != errors.ErrConnectionNotFound {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+		utils.JSONErrorResponse(w, errors.ErrConnectionNotFound)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// PatchOIDCConnection is a endpoint handler to update the identity provider connection
+func (t *Tenants) PatchOIDCConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	role, member, tid, ok := t.connectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	var p jsonpatch.Patch
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ops, err := storage.OpsFromPatch(p)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if v, ok := ops.Update["issuer"]; ok {
+		if s, _ := v.(string); !t.validIssuer(s) {
+			utils.JSONError(w, "Invalid issuer", errors.CodeBadRequest)
+			return
+		}
+	}
+
+	if v, ok := ops.Update["default_role"]; ok {
+		if s, _ := v.(string); !canAssignRole(role, s) {
+			utils.JSONErrorResponse(w, errors.ErrForbidden)
+			return
+		}
+	}
+
+	res, err := t.Storage.PatchOIDCConnection(ctx, tid, id, ops)
+	if err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.AuxLogger != nil {
+		fields := make(map[string]interface{}, len(ops.Update))
+		for k, v := range ops.Update {
+			if k != "client_secret" {
+				fields[k] = v
+			}
+		}
+		t.AuxLogger.WithFields(logFields(EvUpdateConnection, member.ID, tid, r)).WithField("connection", id).WithFields(fields).Printf("User %v updated identity provider %v of tenant %v", member.UserID, id, tid)
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// DeleteOIDCConnection is a endpoint handler to remove the identity provider connection
+func (t *Tenants) DeleteOIDCConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	_, member, tid, ok := t.connectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	if err := t.Storage.DeleteOIDCConnection(ctx, tid, id); err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.AuxLogger != nil {
+		t.AuxLogger.WithFields(logFields(EvDeleteConnection, member.ID, tid, r)).WithField("connection", id).Printf("User %v removed identity provider %v from tenant %v", member.UserID, id, tid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
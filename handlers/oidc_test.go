@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/keys"
+	"github.com/ecadlabs/auth/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestIDTokenAudience(t *testing.T) {
+	const baseURL = "https://auth.example.com"
+
+	kr, err := keys.NewKeyring(keys.NewSecretKey([]byte("secret"), jwt.SigningMethodHS256))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := Users{Keyring: kr}
+	user := &storage.User{ID: uuid.NewV4()}
+	sid := uuid.NewV4()
+
+	cases := map[string]string{
+		"":        idTokenAudience,
+		baseURL:   idTokenAudience,
+		"client1": "client1",
+	}
+
+	for clientID, expected := range cases {
+		opt := userTokenOptions{baseURL: baseURL, clientID: clientID}
+
+		tokStr, err := u.idToken(jwt.MapClaims{"sid": sid}, user, &opt, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tok, err := kr.Parse(tokStr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims := tok.Claims.(jwt.MapClaims)
+		if claims.VerifyAudience(baseURL, true) || !claims.VerifyAudience(expected, true) {
+			t.Errorf("%q: unexpected audience %v", clientID, claims["aud"])
+		}
+
+		if claims["jti"] == nil || claims["sid"] != sid.String() {
+			t.Errorf("%q: jti and sid expected: %v", clientID, claims)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/hasher"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/notification"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func (u *Users) hasher() hasher.Hasher {
+	if u.Hasher != nil {
+		return u.Hasher
+	}
+	return hasher.Default
+}
+
+// rehashPassword upgrades the stored hash to the configured algorithm after successful authentication
+func (u *Users) rehashPassword(ctx context.Context, user *storage.User, password string) {
+	h := u.hasher()
+	if !h.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := u.Storage.RehashPassword(ctx, user.ID, user.PasswordHash, hash); err != nil {
+		log.Error(err)
+		return
+	}
+
+	user.PasswordHash = hash
+}
+
+// checkPassword applies the domain password policy to the user's new password
+func (u *Users) checkPassword(ctx context.Context, user *storage.User, password string, site *middleware.DomainConfigData) error {
+	policy := &site.PasswordPolicy
+
+	var history [][]byte
+	if user != nil && policy.History != 0 {
+		history = append(history, user.PasswordHash)
+
+		if policy.History > 1 {
+			prev, err := u.Storage.GetPasswordHistory(ctx, user.ID, policy.History-1)
+			if err != nil {
+				return err
+			}
+			history = append(history, prev...)
+		}
+	}
+
+	return policy.Check(password, history)
+}
+
+// ChangePassword sets a new password after verifying the current one
+func (u *Users) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	// Administrators use PatchUser
+	if self.ID != uid || self.Type != storage.AccountRegular {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+		RevokeSessions  bool   `json:"revoke_sessions"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	if request.CurrentPassword == "" || request.Password == "" {
+		utils.JSONErrorResponse(w, errors.ErrPasswordEmpty)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	// Stolen token must not help to guess the password
+	if err := u.checkLoginLock(ctx, storage.LoginFailuresUser, self.ID.String()); err != nil {
+		if e, ok := err.(*loginLockedError); ok {
+			writeLoginLocked(w, e)
+		} else {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+		}
+		return
+	}
+
+	if len(self.PasswordHash) == 0 || hasher.Compare(self.PasswordHash, request.CurrentPassword) != nil {
+		u.loginFailed(ctx, r, self)
+		utils.JSONErrorResponse(w, errors.ErrCurrentPassword)
+		return
+	}
+
+	if err = u.checkPassword(ctx, self, request.Password, site); err != nil {
+		if _, ok := err.(*errors.PasswordPolicyError); !ok {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	hash, err := u.hasher().Hash(request.Password)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Invalidates outstanding reset tokens as well
+	if err = u.Storage.UpdatePasswordWithGen(ctx, uid, hash, self.PasswordGen); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if request.RevokeSessions {
+		if err = u.Storage.RevokeSessions(ctx, uid, currentSession(r)); err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+	}
+
+	if err = u.Notifier.Notify(ctx, notification.NotificationPasswordChange, &notification.NotificationData{
+		Addr:        utils.GetRemoteAddr(r),
+		CurrentUser: self,
+		TargetUser:  self,
+		Misc:        &site.TemplateData,
+	}); err != nil {
+		// The password is changed anyway
+		log.Error(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvPasswordChange, uid, uid, r)).WithField("revoke_sessions", request.RevokeSessions).Printf("User %v changed password", uid)
+	}
+}
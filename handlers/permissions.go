@@ -1,12 +1,13 @@
 package handlers
 
 const (
-	permissionWrite     = "com.ecadlabs.users.write"
-	permissionRead      = "com.ecadlabs.users.read"
-	permissionReadSelf  = "com.ecadlabs.users.read_self"
-	permissionWriteSelf = "com.ecadlabs.users.write_self"
-	permissionFull      = "com.ecadlabs.users.full_control"
-	permissionLogs      = "com.ecadlabs.users.read_logs"
+	permissionWrite       = "com.ecadlabs.users.write"
+	permissionRead        = "com.ecadlabs.users.read"
+	permissionReadSelf    = "com.ecadlabs.users.read_self"
+	permissionWriteSelf   = "com.ecadlabs.users.write_self"
+	permissionFull        = "com.ecadlabs.users.full_control"
+	permissionLogs        = "com.ecadlabs.users.read_logs"
+	permissionImpersonate = "com.ecadlabs.users.impersonate"
 
 	permissionDelegatePrefix = "com.ecadlabs.users.delegate:"
 
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/rbac"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAccessTokenMaxAge = 15 * time.Minute
+	defaultSessionMaxAge     = 72 * time.Hour
+)
+
+func accessTokenMaxAge(c *middleware.DomainConfigData) time.Duration {
+	if c.AccessTokenMaxAge != 0 {
+		return c.AccessTokenMaxAge
+	}
+	return defaultAccessTokenMaxAge
+}
+
+func sessionMaxAge(c *middleware.DomainConfigData) time.Duration {
+	if c.SessionMaxAge != 0 {
+		return c.SessionMaxAge
+	}
+	return defaultSessionMaxAge
+}
+
+// newRefreshToken issues the first refresh token of the session
+func (u *Users) newRefreshToken(ctx context.Context, session *storage.Session, membership *storage.Membership, clientID, scope string, permissions bool) (string, error) {
+	token, hash, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	tok := storage.RefreshToken{
+		TokenHash:    hash,
+		SessionID:    session.ID,
+		MembershipID: membership.ID,
+		ClientID:     clientID,
+		Scope:        scope,
+		Permissions:  permissions,
+		Expires:      session.Expires,
+	}
+
+	if err := u.Storage.NewRefreshToken(ctx, &tok); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// rotateRefreshToken exchanges the refresh token for the new one and returns new access token options.
+// The token must be bound to the client, empty client ID stands for first-party tokens
+func (u *Users) rotateRefreshToken(ctx context.Context, r *http.Request, refreshToken, clientID string) (*storage.RefreshToken, *userTokenOptions, error) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	if refreshToken == "" {
+		return nil, nil, errors.ErrTokenEmpty
+	}
+
+	newToken, newHash, err := randomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tok, err := u.Storage.RotateRefreshToken(ctx, hashToken(refreshToken), newHash, clientID)
+	if err != nil {
+		if err == errors.ErrRefreshTokenReuse {
+			log.WithField("session", tok.SessionID).Warnf("Refresh token reuse detected for user %v", tok.UserID)
+
+			if u.AuxLogger != nil {
+				u.AuxLogger.WithFields(logFields(EvRefreshTokenReuse, tok.UserID, tok.UserID, r)).WithField("session", tok.SessionID).Printf("Refresh token reuse detected for user %v, session %v revoked", tok.UserID, tok.SessionID)
+			}
+		}
+		return nil, nil, err
+	}
+
+	session, err := u.Storage.GetSession(ctx, tok.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := u.Storage.TouchSession(ctx, tok.SessionID, utils.GetRemoteAddr(r)); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, tok.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !user.EmailVerified {
+		return nil, nil, errors.ErrEmailNotVerified
+	}
+
+	membership, err := u.getMembershipLogin(ctx, tok.TenantID, tok.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The policy may have been changed since the session started
+	if err := u.checkMFAPolicy(ctx, user, membership.TenantID, session.MFAMethod); err != nil {
+		return nil, nil, err
+	}
+
+	var role rbac.Role
+	if tok.Permissions {
+		if role, err = u.Enforcer.GetRole(ctx, membership.Roles.Get()...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	opt := userTokenOptions{
+		user:          user,
+		membership:    membership,
+		role:          role,
+		sessionMaxAge: accessTokenMaxAge(site),
+		refresh:       u.RefreshURL(site),
+		refreshToken:  newToken,
+		sessionID:     tok.SessionID,
+		baseURL:       site.GetBaseURL(),
+		openID:        hasScope(tok.Scope, scopeOpenID),
+		clientID:      tok.ClientID,
+	}
+
+	return tok, &opt, nil
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/federation"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// serviceProvider returns the SAML service provider of the domain
+func (u *Users) serviceProvider(c *middleware.DomainConfigData) *federation.ServiceProvider {
+	return &federation.ServiceProvider{
+		EntityID:    c.GetBaseURL() + u.SAMLMetadataPath,
+		ACSURL:      c.GetBaseURL() + u.SAMLACSPath,
+		Key:         u.SAMLKey,
+		Certificate: u.SAMLCertificate,
+	}
+}
+
+// SAMLMetadata is a endpoint handler to get the service provider metadata
+func (u *Users) SAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	md, err := u.serviceProvider(site).Metadata()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write(md)
+}
+
+// BeginSAMLLogin redirects the user agent to the tenant's SAML identity provider
+func (u *Users) BeginSAMLLogin(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	conn, err := u.Storage.GetSAMLConnection(ctx, id)
+	if err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	idp, err := federation.ParseIdPMetadata([]byte(conn.Metadata))
+	if err != nil {
+		log.WithField("connection", conn.ID).Error(err)
+		utils.JSONErrorResponse(w, errors.ErrFederation)
+		return
+	}
+
+	// Relay state is passed through the provider, the rest is bound to the user agent
+	state, stateHash, err := randomToken()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	dest, requestID, err := u.serviceProvider(site).AuthnRequestURL(idp, state)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	writePerm := true
+	if v := r.FormValue("permissions"); v != "" {
+		writePerm, _ = strconv.ParseBool(v)
+	}
+
+	stateToken, err := u.TokenFactory.Create(
+		jwt.MapClaims{
+			"federation_connection": conn.ID,
+			"federation_state":      stateHash,
+			"federation_request":    requestID,
+			"link_permissions":      writePerm,
+			"link_scope":            r.FormValue("scope"),
+			"link_client_id":        r.FormValue("client_id"),
+			"link_nonce":            r.FormValue("nonce"),
+		},
+		&storage.User{},
+		u.SAMLACSPath,
+		federationStateMaxAge,
+		site,
+	)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// The response is posted back by the identity provider's page
+	setFederationCookie(w, site, u.SAMLACSPath, stateToken, true)
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, dest, http.StatusFound)
+}
+
+func firstValue(v []string) string {
+	if len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// SAMLACS is the assertion consumer service completing the SAML login. The user is matched by the asserted
+// email address or provisioned if the connection allows it
+func (u *Users) SAMLACS(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	// Clear the state cookie in any case
+	clearFederationCookie(w, u.SAMLACSPath)
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	state, err := u.consumeFederationState(ctx, r, u.SAMLACSPath, r.PostFormValue("RelayState"))
+	if err != nil {
+		if !isFederationStateError(err) {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	conn, err := u.Storage.GetSAMLConnection(ctx, state.connectionID)
+	if err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	idp, err := federation.ParseIdPMetadata([]byte(conn.Metadata))
+	if err != nil {
+		log.WithField("connection", conn.ID).Error(err)
+		utils.JSONErrorResponse(w, errors.ErrFederation)
+		return
+	}
+
+	assertion, err := u.serviceProvider(site).ParseResponse(idp, r.PostFormValue("SAMLResponse"), state.requestID)
+	if err != nil {
+		log.WithField("entity_id", idp.EntityID).Warn(err)
+		utils.JSONErrorResponse(w, errors.ErrFederation)
+		return
+	}
+
+	email := assertion.NameID
+	if conn.EmailAttribute != "" {
+		email = firstValue(assertion.Attributes[conn.EmailAttribute])
+	}
+
+	if email == "" || !strings.Contains(email, "@") {
+		utils.JSONErrorResponse(w, errors.ErrEmailEmpty)
+		return
+	}
+
+	if !conn.DomainAllowed(email) {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	roles := samlRoles(conn, assertion)
+
+	user, err := u.federatedUser(ctx, r, &federatedIdentity{
+		tenantID:     conn.TenantID,
+		connectionID: conn.ID,
+		provider:     idp.EntityID,
+		jit:          conn.JITProvisioning && len(conn.Domains) != 0,
+		email:        email,
+		name:         firstValue(assertion.Attributes[conn.NameAttribute]),
+		roles:        roles,
+	})
+	if err != nil {
+		if err != errors.ErrForbidden && err != errors.ErrUserNotFound && err != errors.ErrIdentityNotLinked && err != errors.ErrEmailNotVerified {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if conn.RoleAttribute != "" && len(roles) != 0 {
+		if err := u.syncRoles(ctx, r, &roleSync{
+			tenantID: conn.TenantID,
+			user:     user,
+			roles:    roles,
+			managed:  conn.ManagedRoles(),
+			source:   "Identity provider " + conn.EntityID,
+			fields:   log.Fields{"connection": conn.ID},
+		}); err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+	}
+
+	state.params.tenantID = conn.TenantID
+	u.beginLogin(ctx, w, r, user, &state.params)
+}
+
+// samlRoles returns membership roles for the assertion. An empty result means the storage default role
+func samlRoles(conn *storage.SAMLConnection, assertion *federation.Assertion) storage.Roles {
+	var roles storage.Roles
+	if conn.RoleAttribute != "" {
+		roles = conn.MapRoles(assertion.Attributes[conn.RoleAttribute])
+	}
+
+	if len(roles) == 0 && conn.DefaultRole != "" {
+		roles = storage.Roles{conn.DefaultRole: true}
+	}
+
+	return roles
+}
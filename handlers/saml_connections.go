@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/federation"
+	"github.com/ecadlabs/auth/jsonpatch"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/rbac"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// samlConnectionAccess is like connectionAccess but only lets holders of the tenants full control permission in.
+// SAML connections assert identities and roles on their own, so tenant owners can't manage them
+func (t *Tenants) samlConnectionAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (rbac.Role, *storage.Membership, uuid.UUID, bool) {
+	role, member, tid, ok := t.connectionAccess(ctx, w, r)
+	if !ok {
+		return nil, nil, uuid.Nil, false
+	}
+
+	granted, err := role.IsAnyGranted(permissionTenantsFull)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return nil, nil, uuid.Nil, false
+	}
+
+	if !granted {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return nil, nil, uuid.Nil, false
+	}
+
+	return role, member, tid, true
+}
+
+// canAssignMapping returns true if the caller is allowed to grant every mapped role
+func canAssignMapping(role rbac.Role, mapping map[string]string) bool {
+	for _, name := range mapping {
+		if !canAssignRole(role, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewSAMLConnection is a endpoint handler to register a SAML identity provider for the tenant
+func (t *Tenants) NewSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	role, member, tid, ok := t.samlConnectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var conn storage.SAMLConnection
+	if err := json.NewDecoder(r.Body).Decode(&conn); err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	idp, err := federation.ParseIdPMetadata([]byte(conn.Metadata))
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	conn.TenantID = tid
+	conn.EntityID = idp.EntityID
+
+	if conn.JITProvisioning && len(conn.Domains) == 0 {
+		utils.JSONError(w, "Just-in-time provisioning requires email domains", errors.CodeBadRequest)
+		return
+	}
+
+	if !canAssignRole(role, conn.DefaultRole) || !canAssignMapping(role, conn.RoleMapping) {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	res, err := t.Storage.NewSAMLConnection(ctx, &conn)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.AuxLogger != nil {
+		t.AuxLogger.WithFields(logFields(EvNewConnection, member.ID, tid, r)).WithField("connection", res.ID).WithField("entity_id", res.EntityID).Printf("User %v added identity provider %s to tenant %v", member.UserID, res.EntityID, tid)
+	}
+
+	w.Header().Set("Location", t.tenantsURL(r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData))+tid.String()+"/saml/"+res.ID.String())
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
+
+// GetSAMLConnections is a endpoint handler to list the tenant's SAML identity providers
+func (t *Tenants) GetSAMLConnections(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	_, _, tid, ok := t.samlConnectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	res, err := t.Storage.GetSAMLConnections(ctx, tid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// GetSAMLConnection is a endpoint handler to get the SAML identity provider
+func (t *Tenants) GetSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	_, _, tid, ok := t.samlConnectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	res, err := t.Storage.GetSAMLConnection(ctx, id)
+	if err != nil || res.TenantID != tid {
+		if err != nil && err != errors.ErrConnectionNotFound {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+		utils.JSONErrorResponse(w, errors.ErrConnectionNotFound)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// PatchSAMLConnection is a endpoint handler to update the SAML identity provider. Replaced metadata is validated
+// and the entity ID is taken from it
+func (t *Tenants) PatchSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	role, member, tid, ok := t.samlConnectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	var p jsonpatch.Patch
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ops, err := storage.OpsFromPatch(p)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, ok := ops.Update["entity_id"]; ok {
+		utils.JSONError(w, "Entity ID is taken from the metadata", errors.CodeBadRequest)
+		return
+	}
+
+	if v, ok := ops.Update["metadata"]; ok {
+		s, _ := v.(string)
+		idp, err := federation.ParseIdPMetadata([]byte(s))
+		if err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+		ops.Update["entity_id"] = idp.EntityID
+	}
+
+	if v, ok := ops.Update["default_role"]; ok {
+		if s, _ := v.(string); !canAssignRole(role, s) {
+			utils.JSONErrorResponse(w, errors.ErrForbidden)
+			return
+		}
+	}
+
+	if v, ok := ops.Update["role_mapping"]; ok {
+		obj, _ := v.(map[string]interface{})
+		for _, name := range obj {
+			if s, _ := name.(string); !canAssignRole(role, s) {
+				utils.JSONErrorResponse(w, errors.ErrForbidden)
+				return
+			}
+		}
+	}
+
+	res, err := t.Storage.PatchSAMLConnection(ctx, tid, id, ops)
+	if err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.AuxLogger != nil {
+		fields := make(map[string]interface{}, len(ops.Update))
+		for k, v := range ops.Update {
+			if k != "metadata" {
+				fields[k] = v
+			}
+		}
+		t.AuxLogger.WithFields(logFields(EvUpdateConnection, member.ID, tid, r)).WithField("connection", id).WithFields(fields).Printf("User %v updated identity provider %v of tenant %v", member.UserID, id, tid)
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// DeleteSAMLConnection is a endpoint handler to remove the SAML identity provider
+func (t *Tenants) DeleteSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := t.context(r)
+	defer cancel()
+
+	_, member, tid, ok := t.samlConnectionAccess(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["connectionId"])
+	if err != nil {
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	if err := t.Storage.DeleteSAMLConnection(ctx, tid, id); err != nil {
+		if err != errors.ErrConnectionNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if t.AuxLogger != nil {
+		t.AuxLogger.WithFields(logFields(EvDeleteConnection, member.ID, tid, r)).WithField("connection", id).Printf("User %v removed identity provider %v from tenant %v", member.UserID, id, tid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
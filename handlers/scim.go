@@ -0,0 +1,1435 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/jq"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/notification"
+	"github.com/ecadlabs/auth/rbac"
+	"github.com/ecadlabs/auth/scim"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// scimMaxResults limits the page size of list requests
+const scimMaxResults = 1000
+
+// SCIM is a handler for the SCIM 2.0 provisioning API. Requests are authenticated with service account
+// API keys and scoped to the key's tenant. Users are tenant members and groups are roles
+type SCIM struct {
+	Storage  Storage
+	Timeout  time.Duration
+	Enforcer rbac.Enforcer
+	Roles    rbac.RoleDB
+
+	BasePath  string
+	AuxLogger *log.Logger
+
+	// Existing accounts are invited to the tenant
+	TokenFactory *TokenFactory
+	InvitePath   string
+	Notifier     notification.Notifier
+}
+
+func (s *SCIM) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if s.Timeout != 0 {
+		return context.WithTimeout(r.Context(), s.Timeout)
+	}
+	return r.Context(), func() {}
+}
+
+func (s *SCIM) usersURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + s.BasePath + "Users/"
+}
+
+func (s *SCIM) groupsURL(c *middleware.DomainConfigData) string {
+	return c.GetBaseURL() + s.BasePath + "Groups/"
+}
+
+// writeSCIMError converts storage and access errors into SCIM error responses
+func writeSCIMError(w http.ResponseWriter, err error) {
+	switch err {
+	case errors.ErrEmailInUse, errors.ErrExternalIDInUse, errors.ErrMembershipExisits:
+		err = scim.NewError(scim.ErrUniqueness, err.Error())
+	case errors.ErrMembershipNotFound:
+		err = errors.ErrUserNotFound
+	}
+
+	scim.WriteError(w, errors.ErrorResponse(err).HTTPStatus(), err)
+}
+
+// authenticate checks the bearer token which is the key ID and its secret joined by a dot
+func (s *SCIM) authenticate(ctx context.Context, r *http.Request) (*storage.APIKey, *storage.User, *storage.Membership, error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return nil, nil, nil, errors.ErrUnauthorized
+	}
+
+	token := strings.TrimSpace(auth[7:])
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, nil, nil, errors.ErrUnauthorized
+	}
+
+	kid, err := uuid.FromString(token[:i])
+	if err != nil {
+		return nil, nil, nil, errors.ErrUnauthorized
+	}
+
+	key, err := s.Storage.GetKeyByID(ctx, kid)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			err = errors.ErrUnauthorized
+		}
+		return nil, nil, nil, err
+	}
+
+	if !verifyKeySecret(key, token[i+1:]) {
+		return nil, nil, nil, errors.ErrUnauthorized
+	}
+
+	if key.Expired() {
+		return nil, nil, nil, errors.ErrKeyExpired
+	}
+
+	user, err := s.Storage.GetUserByID(ctx, storage.AccountService, key.UserID)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			err = errors.ErrUnauthorized
+		}
+		return nil, nil, nil, err
+	}
+
+	member, err := s.Storage.GetMembership(ctx, key.TenantID, key.UserID)
+	if err != nil {
+		if err == errors.ErrMembershipNotFound {
+			err = errors.ErrUnauthorized
+		}
+		return nil, nil, nil, err
+	}
+
+	if member.MembershipStatus != storage.ActiveState {
+		return nil, nil, nil, errors.ErrMembershipNotActive
+	}
+
+	// Narrow the membership down to the key scope
+	member.Roles = key.ScopeRoles(member.Roles)
+
+	return key, user, member, nil
+}
+
+// Handler authenticates the provisioning client and checks it may manage members of its tenant
+func (s *SCIM) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := s.context(r)
+		defer cancel()
+
+		key, user, member, err := s.authenticate(ctx, r)
+		if err != nil {
+			switch err {
+			case errors.ErrUnauthorized, errors.ErrKeyExpired:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="SCIM"`)
+			case errors.ErrMembershipNotActive:
+			default:
+				log.Error(err)
+			}
+			writeSCIMError(w, err)
+			return
+		}
+
+		rctx := rbac.WithScope(r.Context(), key.Permissions)
+
+		role, err := s.Enforcer.GetRole(rbac.WithScope(ctx, key.Permissions), member.Roles.Get()...)
+		if err != nil {
+			log.Error(err)
+			writeSCIMError(w, err)
+			return
+		}
+
+		// The key's own tenant only, and only if the service account owns it
+		if !canUpdateTenant(role, member, key.TenantID) {
+			writeSCIMError(w, errors.ErrForbidden)
+			return
+		}
+
+		if err := s.Storage.UpdateKeyLastUsed(ctx, key.ID); err != nil {
+			log.Error(err)
+		}
+
+		rctx = context.WithValue(rctx, middleware.UserContextKey, user)
+		rctx = context.WithValue(rctx, middleware.MembershipContextKey, member)
+		h.ServeHTTP(w, r.WithContext(rctx))
+	})
+}
+
+// ServiceProviderConfig is a endpoint handler to describe supported SCIM features
+func (s *SCIM) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	supported := func(v bool) map[string]interface{} { return map[string]interface{}{"supported": v} }
+
+	scim.WriteResponse(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scim.SchemaConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": scimMaxResults},
+		"changePassword": supported(false),
+		"sort":           supported(true),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{
+			{
+				"type":        "oauthbearertoken",
+				"name":        "API key",
+				"description": "Service account API key ID and secret joined by a dot",
+				"primary":     true,
+			},
+		},
+	})
+}
+
+// scimListParams are list request parameters common for all resources
+type scimListParams struct {
+	filter     scim.Filter
+	start      int // One based index of the first result
+	count      int
+	descending bool
+	sortBy     string
+}
+
+func parseSCIMListParams(r *http.Request) (*scimListParams, error) {
+	v := r.URL.Query()
+	p := scimListParams{
+		start: 1,
+		count: DefaultLimit,
+	}
+
+	if s := v.Get("startIndex"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, scim.NewError(scim.ErrInvalidValue, "invalid startIndex")
+		}
+		if n > 1 {
+			p.start = n
+		}
+	}
+
+	if s := v.Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, scim.NewError(scim.ErrInvalidValue, "invalid count")
+		}
+		if n < 0 {
+			n = 0
+		}
+		if n > scimMaxResults {
+			n = scimMaxResults
+		}
+		p.count = n
+	}
+
+	if s := v.Get("filter"); s != "" {
+		f, err := scim.ParseFilter(s)
+		if err != nil {
+			return nil, err
+		}
+		p.filter = f
+	}
+
+	p.sortBy = v.Get("sortBy")
+	p.descending = strings.EqualFold(v.Get("sortOrder"), "descending")
+
+	return &p, nil
+}
+
+// excludesAttribute returns true if the client asked to omit the attribute
+func excludesAttribute(r *http.Request, attr string) bool {
+	for _, a := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(a), attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Users
+
+func scimIDExpr(op string, value interface{}) (jq.Node, error) {
+	s, ok := value.(string)
+	if !ok || (op != scim.OpEqual && op != scim.OpNotEqual) {
+		return nil, scim.NewError(scim.ErrInvalidFilter, "`id' supports only eq and ne with a string value")
+	}
+
+	if _, err := uuid.FromString(s); err != nil {
+		return nil, scim.NewError(scim.ErrInvalidValue, err.Error())
+	}
+
+	var n jq.Node = &jq.EQExpr{Key: "user_id", Value: s}
+	if op == scim.OpNotEqual {
+		n = &jq.NOTExpr{Node: n}
+	}
+	return n, nil
+}
+
+// Users are active unless the membership is suspended
+func scimActiveExpr(op string, value interface{}) (jq.Node, error) {
+	if op == scim.OpPresent {
+		return &jq.NEExpr{Key: "membership_status", Value: ""}, nil
+	}
+
+	v, ok := value.(bool)
+	if !ok || (op != scim.OpEqual && op != scim.OpNotEqual) {
+		return nil, scim.NewError(scim.ErrInvalidFilter, "`active' supports only eq and ne with a boolean value")
+	}
+
+	var n jq.Node = &jq.EQExpr{Key: "membership_status", Value: storage.SuspendedState}
+	if v == (op == scim.OpEqual) {
+		n = &jq.NOTExpr{Node: n}
+	}
+	return n, nil
+}
+
+// scimUserAttributes maps User attributes onto membership query columns
+var scimUserAttributes = scim.Attributes{
+	"id":                {Column: "user_id", Sort: true, Expr: scimIDExpr},
+	"username":          {Column: "email", Sort: true},
+	"emails":            {Column: "email", Sort: true},
+	"emails.value":      {Column: "email", Sort: true},
+	"externalid":        {Column: "external_id", CaseExact: true, Sort: true},
+	"displayname":       {Column: "name", Sort: true},
+	"name.formatted":    {Column: "name", Sort: true},
+	"active":            {Column: "membership_status", Sort: true, Expr: scimActiveExpr},
+	"meta.created":      {Column: "added", Type: scim.TypeDateTime, Sort: true},
+	"meta.lastmodified": {Column: "modified", Type: scim.TypeDateTime, Sort: true},
+}
+
+func (s *SCIM) scimUser(site *middleware.DomainConfigData, m *storage.Membership) *scim.User {
+	active := m.MembershipStatus != storage.SuspendedState
+	added, modified := m.Added, m.Modified
+
+	u := scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          m.UserID.String(),
+		ExternalID:  m.ExternalID,
+		UserName:    m.Email,
+		DisplayName: m.Name,
+		Emails:      []scim.Value{{Value: m.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      &added,
+			LastModified: &modified,
+			Location:     s.usersURL(site) + m.UserID.String(),
+		},
+	}
+
+	if m.Name != "" {
+		u.Name = &scim.Name{Formatted: m.Name}
+	}
+
+	for _, role := range m.Roles.Get() {
+		u.Groups = append(u.Groups, scim.Value{Value: role, Display: role, Ref: s.groupsURL(site) + role})
+	}
+
+	return &u
+}
+
+// tenantUsersExpr scopes the query down to regular accounts of the tenant
+func tenantUsersExpr(tenantID uuid.UUID, expr *jq.Expr) *jq.Expr {
+	scope := &jq.Expr{Node: &jq.ANDExpr{
+		&jq.Expr{Node: &jq.EQExpr{Key: "tenant_id", Value: tenantID.String()}},
+		&jq.Expr{Node: &jq.EQExpr{Key: "account_type", Value: storage.AccountRegular}},
+	}}
+
+	if expr == nil {
+		return scope
+	}
+
+	return &jq.Expr{Node: &jq.ANDExpr{expr, scope}}
+}
+
+// GetUsers is a endpoint handler to list tenant members
+func (s *SCIM) GetUsers(w http.ResponseWriter, r *http.Request) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	p, err := parseSCIMListParams(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	q := jq.Query{
+		Limit:      p.count,
+		Offset:     p.start - 1,
+		TotalCount: true,
+	}
+
+	// Zero limit means no limit
+	if p.count == 0 {
+		q.Limit = 1
+	}
+
+	if p.descending {
+		q.Order = jq.OrderDesc
+	}
+
+	if p.sortBy != "" {
+		if q.SortBy, err = scimUserAttributes.SortColumn(p.sortBy); err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+	}
+
+	var expr *jq.Expr
+	if p.filter != nil {
+		if expr, err = scimUserAttributes.Expr(p.filter); err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+	}
+	q.Expr = tenantUsersExpr(member.TenantID, expr)
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	memberships, count, _, err := s.Storage.GetMemberships(ctx, &q)
+	if err != nil {
+		log.Error(err)
+		writeSCIMError(w, err)
+		return
+	}
+
+	var resources []interface{}
+	if p.count != 0 {
+		for _, m := range memberships {
+			resources = append(resources, s.scimUser(site, m))
+		}
+	}
+
+	scim.WriteResponse(w, http.StatusOK, scim.NewListResponse(count, p.start, resources))
+}
+
+// scimTarget loads the regular account and its membership in the caller's tenant
+func (s *SCIM) scimTarget(ctx context.Context, r *http.Request) (*storage.User, *storage.Membership, error) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, nil, errors.ErrUserNotFound
+	}
+
+	user, err := s.Storage.GetUserByID(ctx, storage.AccountRegular, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := s.Storage.GetMembership(ctx, member.TenantID, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, m, nil
+}
+
+// GetUser is a endpoint handler to get a tenant member
+func (s *SCIM) GetUser(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	_, m, err := s.scimTarget(ctx, r)
+	if err != nil {
+		if err != errors.ErrUserNotFound && err != errors.ErrMembershipNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	scim.WriteResponse(w, http.StatusOK, s.scimUser(site, m))
+}
+
+// scimUserUpdate collects modifications of the User resource
+type scimUserUpdate struct {
+	active      *bool
+	externalID  *string
+	displayName *string
+	name        *scim.Name
+	email       *string
+}
+
+func (u *scimUserUpdate) fullName() *string {
+	if u.displayName != nil {
+		return u.displayName
+	}
+
+	if u.name != nil {
+		s := u.name.String()
+		return &s
+	}
+
+	return nil
+}
+
+func (u *scimUserUpdate) setString(dst **string, value json.RawMessage, remove bool) error {
+	var v string
+	if !remove {
+		var err error
+		if v, err = scim.ParseString(value); err != nil {
+			return err
+		}
+	}
+	*dst = &v
+	return nil
+}
+
+// set applies the PATCH operation value. Attributes without a counterpart are ignored
+func (u *scimUserUpdate) set(path *scim.Path, value json.RawMessage, remove bool) error {
+	switch path.Attr {
+	case "active":
+		if remove {
+			return scim.NewError(scim.ErrMutability, "`active' can't be removed")
+		}
+
+		v, err := scim.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		u.active = &v
+
+	case "externalid":
+		return u.setString(&u.externalID, value, remove)
+
+	case "displayname":
+		return u.setString(&u.displayName, value, remove)
+
+	case "name":
+		if u.name == nil {
+			u.name = &scim.Name{}
+		}
+
+		if path.SubAttr == "" {
+			if remove {
+				*u.name = scim.Name{}
+			} else if err := json.Unmarshal(value, u.name); err != nil {
+				return scim.NewError(scim.ErrInvalidValue, err.Error())
+			}
+			return nil
+		}
+
+		var dst *string
+		switch path.SubAttr {
+		case "formatted":
+			dst = &u.name.Formatted
+		case "givenname":
+			dst = &u.name.GivenName
+		case "familyname":
+			dst = &u.name.FamilyName
+		default:
+			return nil
+		}
+
+		var v *string
+		if err := u.setString(&v, value, remove); err != nil {
+			return err
+		}
+		*dst = *v
+
+	case "username", "emails":
+		if remove {
+			return scim.NewError(scim.ErrMutability, "`"+path.Attr+"' can't be removed")
+		}
+
+		if path.Attr == "username" || path.SubAttr == "value" {
+			return u.setString(&u.email, value, false)
+		}
+
+		var emails []scim.Value
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return scim.NewError(scim.ErrInvalidValue, err.Error())
+		}
+
+		if v := (&scim.User{Emails: emails}).Email(); v != "" {
+			u.email = &v
+		}
+	}
+
+	return nil
+}
+
+// patch applies PATCH operations to the update
+func (u *scimUserUpdate) patch(req *scim.PatchRequest) error {
+	for _, op := range req.Operations {
+		remove := op.Op == scim.PatchRemove
+
+		if op.Path != "" {
+			path, err := scim.ParsePath(op.Path)
+			if err != nil {
+				return err
+			}
+
+			if err := u.set(path, op.Value, remove); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Attributes are value object members
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return scim.NewError(scim.ErrInvalidValue, "object expected")
+		}
+
+		for attr, value := range values {
+			path, err := scim.ParsePath(attr)
+			if err != nil {
+				return err
+			}
+
+			if err := u.set(path, value, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyUserUpdate updates the membership and, if the tenant provisioned the account, the account itself.
+// Returns the resulting membership
+func (s *SCIM) applyUserUpdate(ctx context.Context, r *http.Request, user *storage.User, m *storage.Membership, upd *scimUserUpdate) (*storage.Membership, error) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	if upd.email != nil && !strings.EqualFold(*upd.email, user.Email) {
+		return nil, scim.NewError(scim.ErrMutability, "`userName' can't be changed")
+	}
+
+	ops := storage.Ops{Update: map[string]interface{}{}}
+
+	if upd.active != nil {
+		if *upd.active && m.MembershipStatus == storage.SuspendedState {
+			ops.Update["membership_status"] = storage.ActiveState
+		} else if !*upd.active && m.MembershipStatus != storage.SuspendedState {
+			if m.MembershipType == storage.OwnerMembership {
+				return nil, errors.ErrForbidden
+			}
+			ops.Update["membership_status"] = storage.SuspendedState
+		}
+	}
+
+	if upd.externalID != nil && *upd.externalID != m.ExternalID {
+		if *upd.externalID == "" {
+			ops.Update["external_id"] = nil
+		} else {
+			ops.Update["external_id"] = *upd.externalID
+		}
+	}
+
+	if len(ops.Update) != 0 {
+		if _, err := s.Storage.UpdateMembership(ctx, m.TenantID, m.UserID, &ops); err != nil {
+			return nil, err
+		}
+
+		// Log
+		if s.AuxLogger != nil {
+			s.AuxLogger.WithFields(logFields(EvUpdate, member.ID, m.UserID, r)).WithFields(log.Fields(ops.Update)).Printf("Provisioning client %v updated account %v in tenant %v", member.UserID, m.UserID, m.TenantID)
+		}
+	}
+
+	// Attributes are shared by all tenants of the account, so only the tenant which created it may change them.
+	// Other accounts are updated within the tenant only and keep the attributes their holders chose
+	if name := upd.fullName(); name != nil && *name != user.Name && user.ProvisionedBy == m.TenantID {
+		userOps := storage.Ops{Update: map[string]interface{}{"name": *name}}
+		if _, err := s.Storage.UpdateUser(ctx, storage.AccountRegular, user.ID, &userOps); err != nil {
+			return nil, err
+		}
+
+		// Log
+		if s.AuxLogger != nil {
+			s.AuxLogger.WithFields(logFields(EvUpdate, member.ID, user.ID, r)).WithFields(log.Fields(userOps.Update)).Printf("Provisioning client %v updated account %v", member.UserID, user.ID)
+		}
+	}
+
+	return s.Storage.GetMembership(ctx, m.TenantID, m.UserID)
+}
+
+// externalIDInUse returns true if another member of the tenant has the external ID
+func (s *SCIM) externalIDInUse(ctx context.Context, tenantID uuid.UUID, externalID string) (bool, error) {
+	q := jq.Query{
+		Limit: 1,
+		Expr: &jq.Expr{Node: &jq.ANDExpr{
+			&jq.Expr{Node: &jq.EQExpr{Key: "tenant_id", Value: tenantID.String()}},
+			&jq.Expr{Node: &jq.EQExpr{Key: "external_id", Value: externalID}},
+		}},
+	}
+
+	res, _, _, err := s.Storage.GetMemberships(ctx, &q)
+	return len(res) != 0, err
+}
+
+// sendInvite sends the tenant invite to the existing account
+func (s *SCIM) sendInvite(ctx context.Context, r *http.Request, user *storage.User) error {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	tenant, err := s.Storage.GetTenant(ctx, member.TenantID, member.UserID, false)
+	if err != nil {
+		return err
+	}
+
+	token, err := newInviteToken(s.TokenFactory, s.InvitePath, user, member.TenantID, site)
+	if err != nil {
+		return err
+	}
+
+	return s.Notifier.Notify(ctx, notification.NotificationTenantInvite, &notification.NotificationData{
+		Tenant:      tenant,
+		CurrentUser: self,
+		TargetUser:  user,
+		Token:       token,
+		TokenMaxAge: site.TenantInviteMaxAge,
+		Misc:        &site.TemplateData,
+	})
+}
+
+// NewUser is a endpoint handler to provision a tenant member. Existing accounts are invited to the tenant
+func (s *SCIM) NewUser(w http.ResponseWriter, r *http.Request) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	var req scim.User
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	email := req.UserName
+	if !utils.ValidEmail(email) {
+		email = req.Email()
+	}
+
+	if !utils.ValidEmail(email) {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidValue, "`userName' or `emails' must contain an email address"))
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	if req.ExternalID != "" {
+		inUse, err := s.externalIDInUse(ctx, member.TenantID, req.ExternalID)
+		if err != nil {
+			log.Error(err)
+			writeSCIMError(w, err)
+			return
+		}
+
+		if inUse {
+			writeSCIMError(w, errors.ErrExternalIDInUse)
+			return
+		}
+	}
+
+	user, err := s.Storage.GetUserByEmail(ctx, storage.AccountRegular, email)
+	if err == errors.ErrUserNotFound {
+		create := storage.CreateUser{
+			Email:         email,
+			Name:          req.FullName(),
+			EmailVerified: true,
+			Type:          storage.AccountRegular,
+		}
+
+		if user, err = s.Storage.NewUserWithMembership(ctx, &create, member.TenantID, nil); err != nil {
+			log.Error(err)
+			writeSCIMError(w, err)
+			return
+		}
+
+		// Log
+		if s.AuxLogger != nil {
+			s.AuxLogger.WithFields(logFields(EvProvision, user.ID, user.ID, r)).WithField("email", user.Email).WithField("tenant", member.TenantID).Printf("User %v provisioned by client %v", user.ID, member.UserID)
+		}
+	} else if err != nil {
+		log.Error(err)
+		writeSCIMError(w, err)
+		return
+	} else if !user.EmailVerified {
+		// Unverified accounts may have been registered by someone else than the address owner
+		writeSCIMError(w, errors.ErrEmailInUse)
+		return
+	} else {
+		// The account belongs to its holder, not to the tenant, so it joins only after accepting the invite
+		if err = s.Storage.AddMembership(ctx, member.TenantID, user, storage.InvitedState, storage.MemberMembership, nil); err != nil {
+			if err != errors.ErrMembershipExisits {
+				log.Error(err)
+			}
+			writeSCIMError(w, err)
+			return
+		}
+
+		// Log
+		if s.AuxLogger != nil {
+			s.AuxLogger.WithFields(logFields(EvAddMembership, user.ID, member.TenantID, r)).Printf("Provisioning client %v invited user %v to tenant %v", member.UserID, user.ID, member.TenantID)
+		}
+
+		if err := s.sendInvite(ctx, r, user); err != nil {
+			log.Error(err)
+		}
+	}
+
+	m, err := s.Storage.GetMembership(ctx, member.TenantID, user.ID)
+	if err != nil {
+		log.Error(err)
+		writeSCIMError(w, err)
+		return
+	}
+
+	upd := scimUserUpdate{active: req.Active}
+	if req.ExternalID != "" {
+		upd.externalID = &req.ExternalID
+	}
+
+	if m, err = s.applyUserUpdate(ctx, r, user, m, &upd); err != nil {
+		log.Error(err)
+		writeSCIMError(w, err)
+		return
+	}
+
+	res := s.scimUser(site, m)
+	w.Header().Set("Location", res.Meta.Location)
+	scim.WriteResponse(w, http.StatusCreated, res)
+}
+
+// ReplaceUser is a endpoint handler to replace member attributes
+func (s *SCIM) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	var req scim.User
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	user, m, err := s.scimTarget(ctx, r)
+	if err != nil {
+		if err != errors.ErrUserNotFound && err != errors.ErrMembershipNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	active := req.Active == nil || *req.Active
+	email := req.Email()
+	name := req.FullName()
+
+	upd := scimUserUpdate{
+		active:      &active,
+		externalID:  &req.ExternalID,
+		displayName: &name,
+	}
+
+	if email != "" {
+		upd.email = &email
+	}
+
+	if m, err = s.applyUserUpdate(ctx, r, user, m, &upd); err != nil {
+		if _, ok := err.(*scim.Error); !ok && err != errors.ErrForbidden {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	scim.WriteResponse(w, http.StatusOK, s.scimUser(site, m))
+}
+
+// PatchUser is a endpoint handler to modify member attributes
+func (s *SCIM) PatchUser(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	var req scim.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	var upd scimUserUpdate
+	if err := upd.patch(&req); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	user, m, err := s.scimTarget(ctx, r)
+	if err != nil {
+		if err != errors.ErrUserNotFound && err != errors.ErrMembershipNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	if m, err = s.applyUserUpdate(ctx, r, user, m, &upd); err != nil {
+		if _, ok := err.(*scim.Error); !ok && err != errors.ErrForbidden {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	scim.WriteResponse(w, http.StatusOK, s.scimUser(site, m))
+}
+
+// DeleteUser is a endpoint handler to remove the member from the tenant. The account itself is kept
+func (s *SCIM) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	_, m, err := s.scimTarget(ctx, r)
+	if err != nil {
+		if err != errors.ErrUserNotFound && err != errors.ErrMembershipNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	if m.MembershipType == storage.OwnerMembership {
+		writeSCIMError(w, errors.ErrForbidden)
+		return
+	}
+
+	if err := s.Storage.DeleteMembership(ctx, m.TenantID, m.UserID); err != nil {
+		log.Error(err)
+		writeSCIMError(w, err)
+		return
+	}
+
+	// Log
+	if s.AuxLogger != nil {
+		s.AuxLogger.WithFields(logFields(EvMembershipDelete, member.ID, m.UserID, r)).Printf("Provisioning client %v removed member %v in tenant %v", member.UserID, m.UserID, m.TenantID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Groups
+
+// groupRoles returns roles the caller may grant
+func (s *SCIM) groupRoles(ctx context.Context, r *http.Request) ([]*rbac.RoleDesc, error) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	role, err := s.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.Roles.GetRolesDesc(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*rbac.RoleDesc, 0, len(roles))
+	for _, desc := range roles {
+		if canAssignRole(role, desc.Name) {
+			res = append(res, desc)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+// groupRole returns the role the caller may grant. Others are reported as missing
+func (s *SCIM) groupRole(ctx context.Context, r *http.Request, name string) (*rbac.RoleDesc, error) {
+	roles, err := s.groupRoles(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range roles {
+		if desc.Name == name {
+			return desc, nil
+		}
+	}
+
+	return nil, errors.ErrRoleNotFound
+}
+
+// groupMembers returns regular accounts of the tenant having the role
+func (s *SCIM) groupMembers(ctx context.Context, tenantID uuid.UUID, name string) ([]*storage.Membership, error) {
+	q := &jq.Query{
+		Limit: scimMaxResults,
+		Expr:  tenantUsersExpr(tenantID, &jq.Expr{Node: &jq.HasExpr{Key: "roles", Value: name}}),
+	}
+
+	var res []*storage.Membership
+	for {
+		page, _, next, err := s.Storage.GetMemberships(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page) == 0 {
+			return res, nil
+		}
+
+		res = append(res, page...)
+		q = next
+	}
+}
+
+func (s *SCIM) scimGroup(site *middleware.DomainConfigData, desc *rbac.RoleDesc, members []*storage.Membership) *scim.Group {
+	g := scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          desc.Name,
+		DisplayName: desc.Name,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Location:     s.groupsURL(site) + desc.Name,
+		},
+	}
+
+	for _, m := range members {
+		g.Members = append(g.Members, scim.Value{Value: m.UserID.String(), Display: m.Email, Ref: s.usersURL(site) + m.UserID.String()})
+	}
+
+	return &g
+}
+
+// GetGroups is a endpoint handler to list roles the caller may grant
+func (s *SCIM) GetGroups(w http.ResponseWriter, r *http.Request) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	p, err := parseSCIMListParams(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	roles, err := s.groupRoles(ctx, r)
+	if err != nil {
+		log.Error(err)
+		writeSCIMError(w, err)
+		return
+	}
+
+	// Filter in memory, there are few roles
+	var matched []*rbac.RoleDesc
+	for _, desc := range roles {
+		if p.filter != nil {
+			ok, err := scim.Match(p.filter, map[string]string{"id": desc.Name, "displayname": desc.Name})
+			if err != nil {
+				writeSCIMError(w, err)
+				return
+			}
+
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, desc)
+	}
+
+	if p.descending {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+
+	page := matched
+	if p.start-1 < len(page) {
+		page = page[p.start-1:]
+	} else {
+		page = nil
+	}
+
+	if len(page) > p.count {
+		page = page[:p.count]
+	}
+
+	withMembers := !excludesAttribute(r, "members")
+
+	var resources []interface{}
+	for _, desc := range page {
+		var members []*storage.Membership
+		if withMembers {
+			if members, err = s.groupMembers(ctx, member.TenantID, desc.Name); err != nil {
+				log.Error(err)
+				writeSCIMError(w, err)
+				return
+			}
+		}
+		resources = append(resources, s.scimGroup(site, desc, members))
+	}
+
+	scim.WriteResponse(w, http.StatusOK, scim.NewListResponse(len(matched), p.start, resources))
+}
+
+func (s *SCIM) writeGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, desc *rbac.RoleDesc) {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	var members []*storage.Membership
+	if !excludesAttribute(r, "members") {
+		var err error
+		if members, err = s.groupMembers(ctx, member.TenantID, desc.Name); err != nil {
+			log.Error(err)
+			writeSCIMError(w, err)
+			return
+		}
+	}
+
+	res := s.scimGroup(site, desc, members)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", res.Meta.Location)
+	}
+	scim.WriteResponse(w, status, res)
+}
+
+// GetGroup is a endpoint handler to get the role and its holders
+func (s *SCIM) GetGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	desc, err := s.groupRole(ctx, r, mux.Vars(r)["id"])
+	if err != nil {
+		if err != errors.ErrRoleNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	s.writeGroup(ctx, w, r, http.StatusOK, desc)
+}
+
+// setMemberRole grants or revokes the role. Members which already are in the desired state are skipped
+func (s *SCIM) setMemberRole(ctx context.Context, r *http.Request, id, name string, grant bool) error {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(id)
+	if err != nil {
+		return scim.NewError(scim.ErrInvalidValue, fmt.Sprintf("invalid member `%s'", id))
+	}
+
+	if _, err := s.Storage.GetUserByID(ctx, storage.AccountRegular, uid); err != nil {
+		if err == errors.ErrUserNotFound {
+			return scim.NewError(scim.ErrInvalidValue, fmt.Sprintf("user `%s' is not a member", id))
+		}
+		return err
+	}
+
+	m, err := s.Storage.GetMembership(ctx, member.TenantID, uid)
+	if err != nil {
+		if err == errors.ErrMembershipNotFound {
+			return scim.NewError(scim.ErrInvalidValue, fmt.Sprintf("user `%s' is not a member", id))
+		}
+		return err
+	}
+
+	if _, ok := m.Roles[name]; ok == grant {
+		return nil
+	}
+
+	ops := storage.Ops{
+		Add:    map[string][]string{},
+		Remove: map[string][]string{},
+	}
+
+	if grant {
+		ops.Add["roles"] = []string{name}
+	} else {
+		ops.Remove["roles"] = []string{name}
+	}
+
+	if _, err := s.Storage.UpdateMembership(ctx, member.TenantID, uid, &ops); err != nil {
+		return err
+	}
+
+	// Log
+	if s.AuxLogger != nil {
+		if grant {
+			s.AuxLogger.WithFields(logFields(EvAddRole, member.ID, m.ID, r)).WithField("role", name).Printf("Provisioning client %v added role `%s' to account %v in tenant %v", member.UserID, name, uid, member.TenantID)
+		} else {
+			s.AuxLogger.WithFields(logFields(EvRemoveRole, member.ID, m.ID, r)).WithField("role", name).Printf("Provisioning client %v removed role `%s' from account %v in tenant %v", member.UserID, name, uid, member.TenantID)
+		}
+	}
+
+	return nil
+}
+
+// replaceMembers makes the listed users the only holders of the role
+func (s *SCIM) replaceMembers(ctx context.Context, r *http.Request, name string, ids []string) error {
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	current, err := s.groupMembers(ctx, member.TenantID, name)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		keep[strings.ToLower(id)] = struct{}{}
+		if err := s.setMemberRole(ctx, r, id, name, true); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range current {
+		if _, ok := keep[m.UserID.String()]; !ok {
+			if err := s.setMemberRole(ctx, r, m.UserID.String(), name, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func memberIDs(value json.RawMessage) ([]string, error) {
+	var members []scim.Value
+	if err := json.Unmarshal(value, &members); err != nil {
+		return nil, scim.NewError(scim.ErrInvalidValue, err.Error())
+	}
+
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.Value
+	}
+	return ids, nil
+}
+
+// NewGroup is a endpoint handler to link an existing role. Roles can't be created
+func (s *SCIM) NewGroup(w http.ResponseWriter, r *http.Request) {
+	var req scim.Group
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	desc, err := s.groupRole(ctx, r, req.DisplayName)
+	if err != nil {
+		if err == errors.ErrRoleNotFound {
+			err = scim.NewError(scim.ErrInvalidValue, fmt.Sprintf("`%s' is not a role", req.DisplayName))
+		} else {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	for _, m := range req.Members {
+		if err := s.setMemberRole(ctx, r, m.Value, desc.Name, true); err != nil {
+			if _, ok := err.(*scim.Error); !ok {
+				log.Error(err)
+			}
+			writeSCIMError(w, err)
+			return
+		}
+	}
+
+	s.writeGroup(ctx, w, r, http.StatusCreated, desc)
+}
+
+// ReplaceGroup is a endpoint handler to replace role holders
+func (s *SCIM) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	var req scim.Group
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	desc, err := s.groupRole(ctx, r, mux.Vars(r)["id"])
+	if err != nil {
+		if err != errors.ErrRoleNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	if req.DisplayName != "" && req.DisplayName != desc.Name {
+		writeSCIMError(w, scim.NewError(scim.ErrMutability, "`displayName' can't be changed"))
+		return
+	}
+
+	ids := make([]string, len(req.Members))
+	for i, m := range req.Members {
+		ids[i] = m.Value
+	}
+
+	if err := s.replaceMembers(ctx, r, desc.Name, ids); err != nil {
+		if _, ok := err.(*scim.Error); !ok {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	s.writeGroup(ctx, w, r, http.StatusOK, desc)
+}
+
+// patchGroup applies a single PATCH operation
+func (s *SCIM) patchGroup(ctx context.Context, r *http.Request, name string, op *scim.PatchOperation) error {
+	if op.Path == "" {
+		// Attributes are value object members
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return scim.NewError(scim.ErrInvalidValue, "object expected")
+		}
+
+		for attr, value := range values {
+			if err := s.patchGroup(ctx, r, name, &scim.PatchOperation{Op: op.Op, Path: attr, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := scim.ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch path.Attr {
+	case "displayname":
+		if v, err := scim.ParseString(op.Value); op.Op == scim.PatchRemove || err != nil || v != name {
+			return scim.NewError(scim.ErrMutability, "`displayName' can't be changed")
+		}
+		return nil
+
+	case "members":
+	default:
+		// Attributes without a counterpart are ignored
+		return nil
+	}
+
+	// members[value eq "id"]
+	if path.Filter != nil {
+		if op.Op != scim.PatchRemove {
+			return scim.NewError(scim.ErrInvalidPath, "value filter is supported by remove only")
+		}
+
+		if c, ok := path.Filter.(*scim.Compare); ok && c.Attr == "value" && c.Op == scim.OpEqual {
+			if id, ok := c.Value.(string); ok {
+				return s.setMemberRole(ctx, r, id, name, false)
+			}
+		}
+
+		return scim.NewError(scim.ErrInvalidPath, "only `value eq' member filter is supported")
+	}
+
+	var ids []string
+	if len(op.Value) != 0 {
+		if ids, err = memberIDs(op.Value); err != nil {
+			return err
+		}
+	}
+
+	switch op.Op {
+	case scim.PatchAdd:
+		for _, id := range ids {
+			if err := s.setMemberRole(ctx, r, id, name, true); err != nil {
+				return err
+			}
+		}
+
+	case scim.PatchReplace:
+		return s.replaceMembers(ctx, r, name, ids)
+
+	case scim.PatchRemove:
+		// Remove all members unless the list is given
+		if len(op.Value) == 0 {
+			return s.replaceMembers(ctx, r, name, nil)
+		}
+
+		for _, id := range ids {
+			if err := s.setMemberRole(ctx, r, id, name, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PatchGroup is a endpoint handler to grant the role to or revoke it from members
+func (s *SCIM) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	var req scim.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.NewError(scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	desc, err := s.groupRole(ctx, r, mux.Vars(r)["id"])
+	if err != nil {
+		if err != errors.ErrRoleNotFound {
+			log.Error(err)
+		}
+		writeSCIMError(w, err)
+		return
+	}
+
+	for i := range req.Operations {
+		if err := s.patchGroup(ctx, r, desc.Name, &req.Operations[i]); err != nil {
+			if _, ok := err.(*scim.Error); !ok && err != errors.ErrRolesEmpty {
+				log.Error(err)
+			}
+			writeSCIMError(w, err)
+			return
+		}
+	}
+
+	// Member lists may be large
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteGroup is a endpoint handler which refuses to delete roles
+func (s *SCIM) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	writeSCIMError(w, scim.NewError(scim.ErrMutability, "roles can't be deleted"))
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/scim"
+	"github.com/ecadlabs/auth/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestSCIMUserPatch(t *testing.T) {
+	// Azure AD style, attributes in the value object
+	const azure = `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "value": {"active": "False", "externalId": "ext-1", "name.givenName": "Barbara", "name.familyName": "Jensen"}},
+			{"op": "Add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "Sales"}
+		]
+	}`
+
+	var req scim.PatchRequest
+	if err := json.Unmarshal([]byte(azure), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var upd scimUserUpdate
+	if err := upd.patch(&req); err != nil {
+		t.Fatal(err)
+	}
+
+	if upd.active == nil || *upd.active {
+		t.Error("active expected to be false")
+	}
+
+	if upd.externalID == nil || *upd.externalID != "ext-1" {
+		t.Errorf("unexpected external ID: %v", upd.externalID)
+	}
+
+	if name := upd.fullName(); name == nil || *name != "Barbara Jensen" {
+		t.Errorf("unexpected name: %v", name)
+	}
+
+	// Okta style, attribute paths
+	const okta = `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "replace", "path": "displayName", "value": "Babs"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "bjensen@example.com"},
+			{"op": "remove", "path": "externalId"}
+		]
+	}`
+
+	req = scim.PatchRequest{}
+	if err := json.Unmarshal([]byte(okta), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	upd = scimUserUpdate{}
+	if err := upd.patch(&req); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := upd.fullName(); name == nil || *name != "Babs" {
+		t.Errorf("unexpected name: %v", name)
+	}
+
+	if upd.email == nil || *upd.email != "bjensen@example.com" {
+		t.Errorf("unexpected email: %v", upd.email)
+	}
+
+	if upd.externalID == nil || *upd.externalID != "" {
+		t.Errorf("external ID expected to be removed: %v", upd.externalID)
+	}
+
+	// Invalid value
+	req = scim.PatchRequest{
+		Schemas:    []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{{Op: scim.PatchReplace, Path: "active", Value: json.RawMessage(`"maybe"`)}},
+	}
+
+	upd = scimUserUpdate{}
+	if err := upd.patch(&req); err == nil {
+		t.Error("error expected")
+	} else if e, ok := err.(*scim.Error); !ok || e.Type != scim.ErrInvalidValue {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// scimTestStorage records account updates
+type scimTestStorage struct {
+	Storage
+	names []string
+}
+
+func (s *scimTestStorage) UpdateUser(ctx context.Context, typ string, id uuid.UUID, ops *storage.Ops) (*storage.User, error) {
+	s.names = append(s.names, ops.Update["name"].(string))
+	return &storage.User{ID: id}, nil
+}
+
+func (s *scimTestStorage) GetMembership(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*storage.Membership, error) {
+	return &storage.Membership{TenantID: id, UserID: userID}, nil
+}
+
+func TestSCIMUserUpdateProvisioned(t *testing.T) {
+	tenantID := uuid.NewV4()
+	other := uuid.NewV4()
+	name := "Barbara Jensen"
+
+	tests := []struct {
+		provisionedBy uuid.UUID
+		updated       bool
+	}{
+		{provisionedBy: tenantID, updated: true},
+		{provisionedBy: other, updated: false},
+		{provisionedBy: uuid.Nil, updated: false},
+	}
+
+	for _, test := range tests {
+		st := &scimTestStorage{}
+		s := &SCIM{Storage: st}
+
+		r := httptest.NewRequest("PATCH", "/scim/v2/Users/1", nil)
+		r = r.WithContext(context.WithValue(r.Context(), middleware.MembershipContextKey, &storage.Membership{TenantID: tenantID}))
+
+		user := &storage.User{ID: uuid.NewV4(), Name: "Babs", ProvisionedBy: test.provisionedBy}
+		m := &storage.Membership{TenantID: tenantID, UserID: user.ID}
+
+		if _, err := s.applyUserUpdate(r.Context(), r, user, m, &scimUserUpdate{displayName: &name}); err != nil {
+			t.Fatal(err)
+		}
+
+		if updated := len(st.names) != 0; updated != test.updated {
+			t.Errorf("provisioned by %v: expected update %t, got %t", test.provisionedBy, test.updated, updated)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type sessionInfo struct {
+	*storage.Session
+	Current bool `json:"current,omitempty"`
+}
+
+// newSession starts a new login session which lives for SessionMaxAge
+func (u *Users) newSession(ctx context.Context, r *http.Request, user *storage.User, mfaMethod string, c *middleware.DomainConfigData) (*storage.Session, error) {
+	return u.Storage.NewSession(ctx, &storage.Session{
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		Address:   utils.GetRemoteAddr(r),
+		Expires:   time.Now().Add(sessionMaxAge(c)),
+		MFAMethod: mfaMethod,
+	})
+}
+
+// currentSession returns session ID of the request token if any
+func currentSession(r *http.Request) uuid.UUID {
+	if token, ok := r.Context().Value(middleware.TokenContextKey).(*jwt.Token); ok {
+		if sid, ok := token.Claims.(jwt.MapClaims)["sid"].(string); ok {
+			return uuid.FromStringOrNil(sid)
+		}
+	}
+	return uuid.Nil
+}
+
+func (u *Users) GetSessions(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkReadPermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	sessions, err := u.Storage.GetSessions(ctx, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if len(sessions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	current := currentSession(r)
+	res := make([]*sessionInfo, len(sessions))
+	for i, s := range sessions {
+		res[i] = &sessionInfo{
+			Session: s,
+			Current: s.ID == current,
+		}
+	}
+
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (u *Users) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	sid, err := uuid.FromString(mux.Vars(r)["sessionId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkWritePermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if err = u.Storage.RevokeSession(ctx, uid, sid); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvRevokeSession, self.ID, uid, r)).WithField("session", sid).Printf("User %v revoked session %v of user %v", self.ID, sid, uid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSessions revokes all user's sessions. The caller's own session is kept unless keep_current=false
+func (u *Users) DeleteSessions(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkWritePermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	var except uuid.UUID
+	if r.FormValue("keep_current") != "false" {
+		except = currentSession(r)
+	}
+
+	if err = u.Storage.RevokeSessions(ctx, uid, except); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvRevokeSession, self.ID, uid, r)).Printf("User %v revoked sessions of user %v", self.ID, uid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout revokes the caller's session
+func (u *Users) Logout(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+
+	sid := currentSession(r)
+	if sid == uuid.Nil {
+		utils.JSONError(w, "Token is not bound to a session", errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	if err := u.Storage.RevokeSession(ctx, self.ID, sid); err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvLogout, self.ID, self.ID, r)).WithField("session", sid).Printf("User %v logged out", self.ID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
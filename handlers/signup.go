@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/notification"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultVerificationMaxAge = 24 * time.Hour
+
+func verificationMaxAge(site *middleware.DomainConfigData) time.Duration {
+	if site.Signup != nil && site.Signup.VerificationMaxAge != 0 {
+		return site.Signup.VerificationMaxAge
+	}
+	return defaultVerificationMaxAge
+}
+
+func (u *Users) verificationToken(user *storage.User, site *middleware.DomainConfigData) (string, error) {
+	return u.TokenFactory.Create(
+		jwt.MapClaims{
+			"gen": user.EmailGen,
+		},
+		user,
+		u.VerifyEmailPath,
+		verificationMaxAge(site),
+		site,
+	)
+}
+
+// verifyVerificationToken returns user ID and email generation carried by the verification token
+func (u *Users) verifyVerificationToken(requestToken string) (uuid.UUID, int, error) {
+	token, err := u.TokenFactory.Verify(requestToken)
+	if err != nil {
+		return uuid.Nil, 0, errors.ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience(u.VerifyEmailPath, true) {
+		return uuid.Nil, 0, errors.ErrAudience
+	}
+
+	uid, ok := claimUUID(claims, "sub")
+	if !ok {
+		return uuid.Nil, 0, errors.ErrInvalidToken
+	}
+
+	gen, ok := u.TokenFactory.GetClaim(token, "gen").(float64)
+	if !ok {
+		return uuid.Nil, 0, errors.ErrInvalidToken
+	}
+
+	return uid, int(gen), nil
+}
+
+// Signup is a public registration endpoint handler. It's enabled per domain and creates an unverified
+// account with its individual tenant, the account can't log in until the email address is confirmed
+func (u *Users) Signup(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	if site.Signup == nil {
+		utils.JSONErrorResponse(w, errors.ErrResourceNotFound)
+		return
+	}
+
+	var request struct {
+		Email    string `json:"email"`
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.Email = r.PostFormValue("email")
+		request.Name = r.PostFormValue("name")
+		request.Password = r.PostFormValue("password")
+	}
+
+	if !utils.ValidEmail(request.Email) {
+		utils.JSONErrorResponse(w, errors.ErrEmailFmt)
+		return
+	}
+
+	if !site.Signup.DomainAllowed(request.Email) {
+		utils.JSONErrorResponse(w, errors.ErrEmailDomain)
+		return
+	}
+
+	if request.Password == "" {
+		utils.JSONErrorResponse(w, errors.ErrPasswordEmpty)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	if err := u.checkPassword(ctx, nil, request.Password, site); err != nil {
+		if _, ok := err.(*errors.PasswordPolicyError); !ok {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	hash, err := u.hasher().Hash(request.Password)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	user, err := u.Storage.NewUser(ctx, &storage.CreateUser{
+		Email:        request.Email,
+		Name:         request.Name,
+		PasswordHash: hash,
+		Type:         storage.AccountRegular,
+	})
+
+	if err == errors.ErrEmailInUse {
+		// Don't reveal whether the account exists
+		if err := u.resendVerification(ctx, r, request.Email, hash); err != nil {
+			log.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if err := u.sendVerification(ctx, r, user); err != nil {
+		log.Error(err)
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvSignup, user.ID, user.ID, r)).WithFields(log.Fields{
+			"email": user.Email,
+			"name":  user.Name,
+			"added": user.Added,
+		}).Printf("User %v signed up", user.ID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendVerification sends a new verification link if the existing account is still unverified. The password of
+// the latest sign-up replaces the stored one, so whoever registered the address first can't keep access to the account
+// once the owner verifies it
+func (u *Users) resendVerification(ctx context.Context, r *http.Request, email string, hash []byte) error {
+	user, err := u.Storage.GetUserByEmail(ctx, storage.AccountRegular, email)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	if err := u.Storage.ReplaceUnverifiedPassword(ctx, user.ID, hash); err != nil {
+		if err == errors.ErrUserNotFound {
+			// Verified in the meantime
+			return nil
+		}
+		return err
+	}
+
+	if !u.resendAllowed(ctx, user) {
+		return nil
+	}
+
+	return u.sendVerification(ctx, r, user)
+}
+
+// resendAllowed returns false if too many verification emails were sent to the address recently
+func (u *Users) resendAllowed(ctx context.Context, user *storage.User) bool {
+	return u.ResendLimit == nil || u.ResendLimit.Allow(ctx, user.Email)
+}
+
+func (u *Users) sendVerification(ctx context.Context, r *http.Request, user *storage.User) error {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	token, err := u.verificationToken(user, site)
+	if err != nil {
+		return err
+	}
+
+	return u.Notifier.Notify(ctx, notification.NotificationEmailVerification, &notification.NotificationData{
+		Addr:        utils.GetRemoteAddr(r),
+		CurrentUser: user,
+		TargetUser:  user,
+		Token:       token,
+		TokenMaxAge: verificationMaxAge(site),
+		Misc:        &site.TemplateData,
+	})
+}
+
+// VerifyEmail confirms the email address of a signed up account using the token from the verification email
+func (u *Users) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	} else {
+		request.Token = r.PostFormValue("token")
+	}
+
+	if request.Token == "" {
+		utils.JSONErrorResponse(w, errors.ErrTokenEmpty)
+		return
+	}
+
+	uid, gen, err := u.verifyVerificationToken(request.Token)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	user, err := u.Storage.VerifyEmail(ctx, uid, gen)
+	if err != nil {
+		if err != errors.ErrTokenExpired {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvEmailVerify, user.ID, user.ID, r)).WithField("email", user.Email).Printf("User %v verified email %s", user.ID, user.Email)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -43,6 +43,8 @@ type Tenants struct {
 	InvitePath   string
 	Notifier     notification.Notifier
 	AuxLogger    *log.Logger
+
+	InsecureFederation bool // Allow plain http and non public identity provider addresses, for testing only
 }
 
 func (t *Tenants) context(r *http.Request) (context.Context, context.CancelFunc) {
@@ -276,6 +278,11 @@ func (t *Tenants) createTenant(ctx context.Context, createTenant *CreateTenantMo
 }
 
 func (t *Tenants) canUpdateTenant(role rbac.Role, member *storage.Membership, uid uuid.UUID) bool {
+	return canUpdateTenant(role, member, uid)
+}
+
+// canUpdateTenant returns true if the role grants full control or the member is an active owner of the tenant
+func canUpdateTenant(role rbac.Role, member *storage.Membership, uid uuid.UUID) bool {
 	fullAccess, _ := role.IsAnyGranted(permissionTenantsFull)
 
 	if fullAccess {
@@ -324,11 +331,15 @@ func (t *Tenants) UpdateTenant(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ops, err := storage.OpsFromPatch(p)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
 
 	tenant, err := t.Storage.PatchTenant(ctx, uid, ops)
-
 	if err != nil {
-		utils.JSONError(w, err.Error(), errors.CodeUnknown)
+		utils.JSONErrorResponse(w, err)
+		return
 	}
 
 	if t.AuxLogger != nil {
@@ -341,12 +352,16 @@ func (t *Tenants) UpdateTenant(w http.ResponseWriter, r *http.Request) {
 }
 
 func (t *Tenants) inviteToken(user *storage.User, tenantID uuid.UUID, conf *middleware.DomainConfigData) (string, error) {
-	return t.TokenFactory.Create(
+	return newInviteToken(t.TokenFactory, t.InvitePath, user, tenantID, conf)
+}
+
+func newInviteToken(f *TokenFactory, path string, user *storage.User, tenantID uuid.UUID, conf *middleware.DomainConfigData) (string, error) {
+	return f.Create(
 		jwt.MapClaims{
 			"tenant_invite": tenantID,
 		},
 		user,
-		t.InvitePath,
+		path,
 		conf.TenantInviteMaxAge,
 		conf,
 	)
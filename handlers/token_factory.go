@@ -5,16 +5,17 @@ import (
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/keys"
 	"github.com/ecadlabs/auth/middleware"
 	"github.com/ecadlabs/auth/storage"
 	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
 )
 
 type TokenFactory struct {
-	JWTSecretGetter  func() ([]byte, error)
-	JWTSigningMethod jwt.SigningMethod
-	Namespace        string
+	Keyring   *keys.Keyring
+	Namespace string
 }
 
 func (t *TokenFactory) Create(claims jwt.MapClaims, user *storage.User, aud string, exp time.Duration, c *middleware.DomainConfigData) (string, error) {
@@ -25,33 +26,22 @@ func (t *TokenFactory) Create(claims jwt.MapClaims, user *storage.User, aud stri
 		"iat": now.Unix(),
 		"iss": c.GetBaseURL(),
 		"aud": aud,
+		"jti": uuid.NewV4(),
 	}
 
 	for i, val := range claims {
 		baseClaims[utils.NSClaim(t.Namespace, i)] = val
 	}
 
-	token := jwt.NewWithClaims(t.JWTSigningMethod, baseClaims)
-
-	secret, err := t.JWTSecretGetter()
-	if err != nil {
-		return "", err
-	}
-
-	return token.SignedString(secret)
+	return t.Keyring.Sign(baseClaims)
 }
 
 func (t *TokenFactory) Verify(requestToken string) (*jwt.Token, error) {
-	token, err := jwt.Parse(requestToken, func(token *jwt.Token) (interface{}, error) { return t.JWTSecretGetter() })
+	token, err := t.Keyring.Parse(requestToken, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if t.JWTSigningMethod.Alg() != token.Header["alg"] {
-		log.Errorf("Expected %s signing method but token specified %s", t.JWTSigningMethod.Alg(), token.Header["alg"])
-		return nil, errors.ErrInvalidToken
-	}
-
 	if !token.Valid {
 		log.Errorln("Invalid token")
 		return nil, errors.ErrInvalidToken
@@ -10,4 +10,15 @@ type Storage interface {
 	storage.MembershipStorage
 	storage.TenantStorage
 	storage.LogStorage
+	storage.OAuthStorage
+	storage.RefreshTokenStorage
+	storage.SessionStorage
+	storage.RevocationStorage
+	storage.MFAStorage
+	storage.WebAuthnStorage
+	storage.LoginFailuresStorage
+	storage.PasswordHistoryStorage
+	storage.OIDCConnectionStorage
+	storage.SAMLConnectionStorage
+	storage.FederatedIdentityStorage
 }
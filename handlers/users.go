@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
 	"net/http"
 	"net/url"
@@ -10,8 +12,11 @@ import (
 	jwtmiddleware "github.com/auth0/go-jwt-middleware"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/federation"
+	"github.com/ecadlabs/auth/hasher"
 	"github.com/ecadlabs/auth/jq"
 	"github.com/ecadlabs/auth/jsonpatch"
+	"github.com/ecadlabs/auth/keys"
 	"github.com/ecadlabs/auth/middleware"
 	"github.com/ecadlabs/auth/notification"
 	"github.com/ecadlabs/auth/rbac"
@@ -20,7 +25,6 @@ import (
 	"github.com/gorilla/mux"
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -31,21 +35,42 @@ type Users struct {
 	Storage Storage
 	Timeout time.Duration
 
-	JWTSecretGetter  func() ([]byte, error)
-	JWTSigningMethod jwt.SigningMethod
-
-	UsersPath       string
-	RefreshPath     string
-	ResetPath       string
-	LogPath         string
-	EmailUpdatePath string
-	Namespace       string
+	Keyring      *keys.Keyring
+	TokenFactory *TokenFactory
+
+	UsersPath        string
+	RefreshPath      string
+	ResetPath        string
+	LogPath          string
+	EmailUpdatePath  string
+	UserInfoPath     string
+	JWKSPath         string
+	AuthorizePath    string
+	TokenPath        string
+	IntrospectPath   string
+	MFAPath          string
+	WebAuthnPath     string
+	LoginLinkPath    string
+	OIDCCallbackPath string
+	SAMLMetadataPath string
+	SAMLACSPath      string
+	VerifyEmailPath  string
+	Namespace        string
 
 	Notifier notification.Notifier
 
 	Enforcer rbac.Enforcer
 
 	AuxLogger *log.Logger
+
+	Hasher hasher.Hasher // Algorithm used for new password hashes, defaults to bcrypt
+
+	Federation *federation.Client // Upstream identity providers client
+
+	ResendLimit *middleware.RateLimit // Throttles verification emails sent again to the same address, unlimited if nil
+
+	SAMLKey         *rsa.PrivateKey // Signs SAML authentication requests if set
+	SAMLCertificate *x509.Certificate
 }
 
 func (u *Users) UsersURL(c *middleware.DomainConfigData) string {
@@ -270,14 +295,7 @@ func (u *Users) resetToken(user *storage.User, conf *middleware.DomainConfigData
 		utils.NSClaim(u.Namespace, "gen"): user.PasswordGen,
 	}
 
-	token := jwt.NewWithClaims(u.JWTSigningMethod, claims)
-
-	secret, err := u.JWTSecretGetter()
-	if err != nil {
-		return "", err
-	}
-
-	return token.SignedString(secret)
+	return u.Keyring.Sign(claims)
 }
 
 func (u *Users) NewUser(w http.ResponseWriter, r *http.Request) {
@@ -455,6 +473,24 @@ func (u *Users) PatchUser(w http.ResponseWriter, r *http.Request) {
 
 	delete(ops.Update, "password_hash")
 
+	var unlock bool
+	if v, ok := ops.Update["locked"]; ok {
+		delete(ops.Update, "locked")
+
+		// Only unlocking is allowed and only by the administrator
+		if locked, ok := v.(bool); !ok || locked {
+			utils.JSONError(w, "Only `false' value is allowed for `locked'", errors.CodeBadRequest)
+			return
+		}
+
+		if _, err = u.checkWritePermissions(role, user.Type, false); err != nil {
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		unlock = true
+	}
+
 	if typ == storage.AccountRegular && (ops.Add["address_whitelist"] != nil || ops.Remove["address_whitelist"] != nil) {
 		utils.JSONErrorResponse(w, errors.ErrForbidden)
 		return
@@ -469,8 +505,17 @@ func (u *Users) PatchUser(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+			if err = u.checkPassword(ctx, user, p, site); err != nil {
+				if _, ok := err.(*errors.PasswordPolicyError); !ok {
+					log.Error(err)
+				}
+				utils.JSONErrorResponse(w, err)
+				return
+			}
+
 			var hash []byte
-			if hash, err = bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost); err != nil {
+			if hash, err = u.hasher().Hash(p); err != nil {
 				log.Error(err)
 				utils.JSONErrorResponse(w, err)
 				return
@@ -487,6 +532,18 @@ func (u *Users) PatchUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if unlock {
+		if err = u.Storage.ResetLoginFailures(ctx, storage.LoginFailuresUser, uid.String()); err != nil {
+			log.Error(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		if u.AuxLogger != nil {
+			u.AuxLogger.WithFields(logFields(EvUnlock, member.ID, uid, r)).Printf("User %v unlocked login for account %v from tenant %v", self.ID, uid, member.TenantID)
+		}
+	}
+
 	// Log
 	if u.AuxLogger != nil {
 		if len(ops.Update) != 0 {
@@ -635,19 +692,13 @@ func (u *Users) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify token
-	token, err := jwt.Parse(request.Token, func(token *jwt.Token) (interface{}, error) { return u.JWTSecretGetter() })
+	token, err := u.Keyring.Parse(request.Token, nil)
 	if err != nil {
 		log.Error(err)
 		utils.JSONError(w, err.Error(), errors.CodeTokenFmt)
 		return
 	}
 
-	if u.JWTSigningMethod.Alg() != token.Header["alg"] {
-		log.Errorf("Expected %s signing method but token specified %s", u.JWTSigningMethod.Alg(), token.Header["alg"])
-		utils.JSONErrorResponse(w, errors.ErrInvalidToken)
-		return
-	}
-
 	if !token.Valid {
 		log.Errorln("Invalid token")
 		utils.JSONErrorResponse(w, errors.ErrInvalidToken)
@@ -681,15 +732,32 @@ func (u *Users) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, id)
 	if err != nil {
-		log.Error(err)
+		if err != errors.ErrUserNotFound {
+			log.Error(err)
+		}
 		utils.JSONErrorResponse(w, err)
 		return
 	}
 
-	ctx, cancel := u.context(r)
-	defer cancel()
+	if err = u.checkPassword(ctx, user, request.Password, site); err != nil {
+		if _, ok := err.(*errors.PasswordPolicyError); !ok {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	hash, err := u.hasher().Hash(request.Password)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
 
 	err = u.Storage.UpdatePasswordWithGen(ctx, id, hash, int(gen))
 	if err != nil {
@@ -844,16 +912,7 @@ func (u *Users) SendUpdateEmailRequest(w http.ResponseWriter, r *http.Request) {
 		utils.NSClaim(u.Namespace, "gen"):   user.EmailGen,
 	}
 
-	token := jwt.NewWithClaims(u.JWTSigningMethod, claims)
-
-	secret, err := u.JWTSecretGetter()
-	if err != nil {
-		log.Error(err)
-		utils.JSONErrorResponse(w, err)
-		return
-	}
-
-	tokStr, err := token.SignedString(secret)
+	tokStr, err := u.Keyring.Sign(claims)
 	if err != nil {
 		log.Error(err)
 		utils.JSONErrorResponse(w, err)
@@ -901,19 +960,13 @@ func (u *Users) UpdateEmail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify token
-	token, err := jwt.Parse(request.Token, func(token *jwt.Token) (interface{}, error) { return u.JWTSecretGetter() })
+	token, err := u.Keyring.Parse(request.Token, nil)
 	if err != nil {
 		log.Error(err)
 		utils.JSONError(w, err.Error(), errors.CodeTokenFmt)
 		return
 	}
 
-	if u.JWTSigningMethod.Alg() != token.Header["alg"] {
-		log.Errorf("Expected %s signing method but token specified %s", u.JWTSigningMethod.Alg(), token.Header["alg"])
-		utils.JSONErrorResponse(w, errors.ErrInvalidToken)
-		return
-	}
-
 	if !token.Valid {
 		log.Errorln("Invalid token")
 		utils.JSONErrorResponse(w, errors.ErrInvalidToken)
@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/middleware"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/ecadlabs/auth/webauthn"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultWebAuthnCeremonyMaxAge = 5 * time.Minute
+
+// WebAuthn ceremonies
+const (
+	ceremonyRegister = "register"
+	ceremonyLogin    = "login"
+)
+
+type webAuthnCeremony struct {
+	PublicKey interface{} `json:"publicKey"`
+	State     string      `json:"state"`
+}
+
+func (u *Users) relyingParty(site *middleware.DomainConfigData) (*webauthn.RelyingParty, error) {
+	rp, err := webauthn.NewRelyingParty(site.GetBaseURL(), site.TemplateData.AppName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Passwordless login needs more than a mere presence
+	rp.RequireUserVerification = true
+	return rp, nil
+}
+
+// ceremonyState returns the signed ceremony state. The challenge is kept on the client side
+func (u *Users) ceremonyState(user *storage.User, ceremony string, challenge []byte, claims jwt.MapClaims, site *middleware.DomainConfigData) (string, error) {
+	if claims == nil {
+		claims = jwt.MapClaims{}
+	}
+	claims["webauthn_ceremony"] = ceremony
+	claims["webauthn_challenge"] = base64.RawURLEncoding.EncodeToString(challenge)
+
+	return u.TokenFactory.Create(claims, user, u.WebAuthnPath, defaultWebAuthnCeremonyMaxAge, site)
+}
+
+// consumeCeremonyState verifies the ceremony state and makes sure it's used only once
+func (u *Users) consumeCeremonyState(ctx context.Context, state, ceremony string) (*jwt.Token, []byte, error) {
+	if state == "" {
+		return nil, nil, errors.ErrTokenEmpty
+	}
+
+	token, err := u.TokenFactory.Verify(state)
+	if err != nil {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience(u.WebAuthnPath, true) {
+		return nil, nil, errors.ErrAudience
+	}
+
+	if c, _ := u.TokenFactory.GetClaim(token, "webauthn_ceremony").(string); c != ceremony {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	chStr, _ := u.TokenFactory.GetClaim(token, "webauthn_challenge").(string)
+	challenge, err := base64.RawURLEncoding.DecodeString(chStr)
+	if err != nil || len(challenge) == 0 {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	jti, ok := claimUUID(claims, "jti")
+	if !ok {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	revoked, err := u.Storage.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if revoked {
+		return nil, nil, errors.ErrTokenRevoked
+	}
+
+	uid, _ := claimUUID(claims, "sub")
+	var expires *time.Time
+	if exp, ok := claims["exp"].(float64); ok {
+		t := time.Unix(int64(exp), 0)
+		expires = &t
+	}
+
+	if err := u.Storage.RevokeToken(ctx, jti, uid, expires); err != nil {
+		return nil, nil, err
+	}
+
+	return token, challenge, nil
+}
+
+// BeginWebAuthnRegistration returns credential creation options
+func (u *Users) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	// Credentials are registered by the user only
+	if self.ID != uid || self.Type != storage.AccountRegular {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	rp, err := u.relyingParty(site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	creds, err := u.Storage.GetWebAuthnCredentials(ctx, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	exclude := make([][]byte, len(creds))
+	for i, c := range creds {
+		exclude[i] = c.CredentialID
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	state, err := u.ceremonyState(self, ceremonyRegister, challenge, nil, site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	displayName := self.Name
+	if displayName == "" {
+		displayName = self.Email
+	}
+
+	user := webauthn.UserEntity{
+		ID:          uid.Bytes(),
+		Name:        self.Email,
+		DisplayName: displayName,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, http.StatusOK, &webAuthnCeremony{
+		PublicKey: rp.CreationOptions(challenge, &user, exclude),
+		State:     state,
+	})
+}
+
+// FinishWebAuthnRegistration verifies the new credential and stores it
+func (u *Users) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	if self.ID != uid {
+		utils.JSONErrorResponse(w, errors.ErrForbidden)
+		return
+	}
+
+	var request struct {
+		State      string                        `json:"state"`
+		Name       string                        `json:"name"`
+		Credential *webauthn.AttestationResponse `json:"credential"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Credential == nil {
+		utils.JSONError(w, "Invalid request", errors.CodeBadRequest)
+		return
+	}
+
+	rp, err := u.relyingParty(site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	token, challenge, err := u.consumeCeremonyState(ctx, request.State, ceremonyRegister)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if sub, _ := claimUUID(token.Claims.(jwt.MapClaims), "sub"); sub != uid {
+		utils.JSONErrorResponse(w, errors.ErrInvalidToken)
+		return
+	}
+
+	cred, err := rp.VerifyRegistration(challenge, request.Credential)
+	if err != nil {
+		log.WithField("user", uid).Warn(err)
+		utils.JSONErrorResponse(w, errors.ErrWebAuthn)
+		return
+	}
+
+	res, err := u.Storage.NewWebAuthnCredential(ctx, &storage.WebAuthnCredential{
+		CredentialID: cred.ID,
+		UserID:       uid,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		AAGUID:       cred.AAGUID,
+		Name:         request.Name,
+	})
+	if err != nil {
+		if err != errors.ErrCredentialExists {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvMFAEnroll, self.ID, uid, r)).WithFields(log.Fields{"method": "webauthn", "credential": res.ID}).Printf("User %v registered WebAuthn credential %v", uid, res.ID)
+	}
+
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
+
+func (u *Users) GetWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkReadPermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	creds, err := u.Storage.GetWebAuthnCredentials(ctx, uid)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if len(creds) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, creds)
+}
+
+func (u *Users) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	self := r.Context().Value(middleware.UserContextKey).(*storage.User)
+	member := r.Context().Value(middleware.MembershipContextKey).(*storage.Membership)
+
+	uid, err := uuid.FromString(mux.Vars(r)["userId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	cid, err := uuid.FromString(mux.Vars(r)["credentialId"])
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	role, err := u.Enforcer.GetRole(ctx, member.Roles.Get()...)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if _, err = u.checkWritePermissions(role, storage.AccountRegular, self.ID == uid); err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	if err = u.Storage.DeleteWebAuthnCredential(ctx, uid, cid); err != nil {
+		if err != errors.ErrCredentialNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	// Log
+	if u.AuxLogger != nil {
+		u.AuxLogger.WithFields(logFields(EvMFADisable, self.ID, uid, r)).WithFields(log.Fields{"method": "webauthn", "credential": cid}).Printf("User %v removed WebAuthn credential %v of user %v", self.ID, cid, uid)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginWebAuthnLogin returns credential request options. Without email the authenticator offers discoverable credentials (passkeys)
+func (u *Users) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	var request struct {
+		Email       string    `json:"email"`
+		Tenant      uuid.UUID `json:"tenant"`
+		Permissions *bool     `json:"permissions"`
+		Scope       string    `json:"scope"`
+		ClientID    string    `json:"client_id"`
+		Nonce       string    `json:"nonce"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.JSONError(w, err.Error(), errors.CodeBadRequest)
+			return
+		}
+	}
+
+	writePerm := true
+	if request.Permissions != nil {
+		writePerm = *request.Permissions
+	} else if v := r.FormValue("permissions"); v != "" {
+		writePerm, _ = strconv.ParseBool(v)
+	}
+
+	rp, err := u.relyingParty(site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	user := &storage.User{}
+	var allow [][]byte
+
+	if request.Email != "" {
+		// Unknown users get the same response as discoverable login to prevent enumeration
+		if res, err := u.Storage.GetUserByEmail(ctx, storage.AccountRegular, request.Email); err == nil {
+			creds, err := u.Storage.GetWebAuthnCredentials(ctx, res.ID)
+			if err != nil {
+				log.Error(err)
+				utils.JSONErrorResponse(w, err)
+				return
+			}
+
+			for _, c := range creds {
+				allow = append(allow, c.CredentialID)
+			}
+
+			if len(allow) != 0 {
+				user = res
+			}
+		} else if err != errors.ErrUserNotFound {
+			log.Error(err)
+		}
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	state, err := u.ceremonyState(user, ceremonyLogin, challenge, jwt.MapClaims{
+		"login_tenant":      request.Tenant,
+		"login_permissions": writePerm,
+		"login_scope":       request.Scope,
+		"login_client_id":   request.ClientID,
+		"login_nonce":       request.Nonce,
+	}, site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, http.StatusOK, &webAuthnCeremony{
+		PublicKey: rp.RequestOptions(challenge, allow),
+		State:     state,
+	})
+}
+
+// FinishWebAuthnLogin verifies the assertion and issues the same token as the password login
+func (u *Users) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	site := r.Context().Value(middleware.DomainConfigContextKey).(*middleware.DomainConfigData)
+
+	var request struct {
+		State      string                      `json:"state"`
+		Credential *webauthn.AssertionResponse `json:"credential"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Credential == nil {
+		utils.JSONError(w, "Invalid request", errors.CodeBadRequest)
+		return
+	}
+
+	rp, err := u.relyingParty(site)
+	if err != nil {
+		log.Error(err)
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	ctx, cancel := u.context(r)
+	defer cancel()
+
+	token, challenge, err := u.consumeCeremonyState(ctx, request.State, ceremonyLogin)
+	if err != nil {
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	cred, err := u.Storage.GetWebAuthnCredential(ctx, request.Credential.RawID)
+	if err != nil {
+		if err != errors.ErrCredentialNotFound {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, errors.ErrWebAuthn)
+		return
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if sub, _ := claimUUID(claims, "sub"); sub != uuid.Nil && sub != cred.UserID {
+		utils.JSONErrorResponse(w, errors.ErrWebAuthn)
+		return
+	}
+
+	if h := request.Credential.Response.UserHandle; len(h) != 0 && !bytes.Equal(h, cred.UserID.Bytes()) {
+		utils.JSONErrorResponse(w, errors.ErrWebAuthn)
+		return
+	}
+
+	signCount, err := rp.VerifyAssertion(challenge, &webauthn.Credential{
+		ID:        cred.CredentialID,
+		PublicKey: cred.PublicKey,
+		SignCount: uint32(cred.SignCount),
+	}, request.Credential)
+	if err != nil {
+		log.WithField("user", cred.UserID).Warn(err)
+		if u.AuxLogger != nil {
+			u.AuxLogger.WithFields(logFields(EvMFAFailure, cred.UserID, cred.UserID, r)).WithField("method", "webauthn").Printf("WebAuthn assertion failed for user %v: %v", cred.UserID, err)
+		}
+		utils.JSONErrorResponse(w, errors.ErrWebAuthn)
+		return
+	}
+
+	if err := u.Storage.UpdateWebAuthnSignCount(ctx, cred.ID, cred.SignCount, int64(signCount)); err != nil {
+		if err != errors.ErrWebAuthn {
+			log.Error(err)
+		}
+		utils.JSONErrorResponse(w, err)
+		return
+	}
+
+	user, err := u.Storage.GetUserByID(ctx, storage.AccountRegular, cred.UserID)
+	if err != nil {
+		log.Error(err)
+		utils.JSONError(w, "", errors.CodeUnauthorized)
+		return
+	}
+
+	if !user.EmailVerified {
+		utils.JSONErrorResponse(w, errors.ErrEmailNotVerified)
+		return
+	}
+
+	params := loginParams{
+		// User verifying authenticator is a multi-factor credential on its own
+		mfaMethod: mfaMethodWebAuthn,
+	}
+	params.tenantID, _ = claimUUID(claims, utils.NSClaim(u.Namespace, "login_tenant"))
+	params.writePerm, _ = u.TokenFactory.GetClaim(token, "login_permissions").(bool)
+	params.scope, _ = u.TokenFactory.GetClaim(token, "login_scope").(string)
+	params.clientID, _ = u.TokenFactory.GetClaim(token, "login_client_id").(string)
+	params.nonce, _ = u.TokenFactory.GetClaim(token, "login_nonce").(string)
+
+	if params.tenantID == uuid.Nil {
+		params.tenantID = user.GetDefaultMembership()
+	}
+
+	u.completeLogin(ctx, w, r, user, nil, &params)
+}
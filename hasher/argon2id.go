@@ -0,0 +1,144 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id defaults, see RFC 9106
+const (
+	DefaultArgon2idMemory  = 64 * 1024
+	DefaultArgon2idTime    = 3
+	DefaultArgon2idThreads = 4
+	DefaultKeyLen          = 32
+	DefaultSaltLen         = 16
+)
+
+// Argon2id produces $argon2id$v=19$m=...,t=...,p=...$salt$hash encoded hashes
+type Argon2id struct {
+	Memory  uint32 `yaml:"memory"`  // KiB
+	Time    uint32 `yaml:"time"`    // Number of passes
+	Threads uint8  `yaml:"threads"` // Degree of parallelism
+	KeyLen  int    `yaml:"key_len"`
+	SaltLen int    `yaml:"salt_len"`
+}
+
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func (p *argon2idParams) key(password string, salt []byte, keyLen int) []byte {
+	return argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(keyLen))
+}
+
+func (a *Argon2id) params() *argon2idParams {
+	p := argon2idParams{
+		memory:  a.Memory,
+		time:    a.Time,
+		threads: a.Threads,
+	}
+
+	if p.memory == 0 {
+		p.memory = DefaultArgon2idMemory
+	}
+
+	if p.time == 0 {
+		p.time = DefaultArgon2idTime
+	}
+
+	if p.threads == 0 {
+		p.threads = DefaultArgon2idThreads
+	}
+
+	return &p
+}
+
+func (a *Argon2id) keyLen() int {
+	if a.KeyLen != 0 {
+		return a.KeyLen
+	}
+	return DefaultKeyLen
+}
+
+// Hash implements Hasher
+func (a *Argon2id) Hash(password string) ([]byte, error) {
+	salt, err := newSalt(a.SaltLen)
+	if err != nil {
+		return nil, err
+	}
+
+	p := a.params()
+	key := p.key(password, salt, a.keyLen())
+
+	return []byte(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgArgon2id, argon2.Version, p.memory, p.time, p.threads, b64.EncodeToString(salt), b64.EncodeToString(key))), nil
+}
+
+// NeedsRehash implements Hasher
+func (a *Argon2id) NeedsRehash(hash []byte) bool {
+	if Algorithm(hash) != AlgArgon2id {
+		return true
+	}
+
+	p, _, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return *p != *a.params() || len(key) != a.keyLen()
+}
+
+func parseArgon2id(hash []byte) (p *argon2idParams, salt, key []byte, err error) {
+	params, salt, key, err := splitPHC(hash, AlgArgon2id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if v, ok := params["v"]; ok && v != strconv.Itoa(argon2.Version) {
+		return nil, nil, nil, fmt.Errorf("hasher: unsupported argon2 version %s", v)
+	}
+
+	m, err := paramUint(params, "m", 32)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	t, err := paramUint(params, "t", 32)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	th, err := paramUint(params, "p", 8)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if t == 0 || th == 0 {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	p = &argon2idParams{
+		memory:  uint32(m),
+		time:    uint32(t),
+		threads: uint8(th),
+	}
+
+	return p, salt, key, nil
+}
+
+func newSalt(n int) ([]byte, error) {
+	if n == 0 {
+		n = DefaultSaltLen
+	}
+
+	salt := make([]byte, n)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
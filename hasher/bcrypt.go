@@ -0,0 +1,32 @@
+package hasher
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt produces standard $2a$ encoded hashes
+type Bcrypt struct {
+	Cost int `yaml:"cost"` // Defaults to bcrypt.DefaultCost
+}
+
+func (b *Bcrypt) cost() int {
+	if b.Cost != 0 {
+		return b.Cost
+	}
+	return bcrypt.DefaultCost
+}
+
+// Hash implements Hasher
+func (b *Bcrypt) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), b.cost())
+}
+
+// NeedsRehash implements Hasher
+func (b *Bcrypt) NeedsRehash(hash []byte) bool {
+	if Algorithm(hash) != AlgBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != b.cost()
+}
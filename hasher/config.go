@@ -0,0 +1,27 @@
+package hasher
+
+import (
+	"fmt"
+)
+
+// Config selects the algorithm and its parameters used for new hashes
+type Config struct {
+	Algorithm string   `yaml:"algorithm"` // bcrypt (default), argon2id or scrypt
+	Bcrypt    Bcrypt   `yaml:"bcrypt"`
+	Argon2id  Argon2id `yaml:"argon2id"`
+	Scrypt    Scrypt   `yaml:"scrypt"`
+}
+
+// New returns configured Hasher
+func (c *Config) New() (Hasher, error) {
+	switch c.Algorithm {
+	case "", AlgBcrypt:
+		return &c.Bcrypt, nil
+	case AlgArgon2id:
+		return &c.Argon2id, nil
+	case AlgScrypt:
+		return &c.Scrypt, nil
+	}
+
+	return nil, fmt.Errorf("hasher: unknown algorithm `%s'", c.Algorithm)
+}
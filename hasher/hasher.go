@@ -0,0 +1,161 @@
+// Package hasher implements password hashing using PHC-style encoded strings
+package hasher
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+	AlgScrypt   = "scrypt"
+)
+
+var (
+	// ErrMismatch is returned by Compare if the password doesn't match the hash
+	ErrMismatch = errors.New("hasher: password does not match the hash")
+	// ErrUnknownFormat is returned if the hash was produced by unsupported algorithm
+	ErrUnknownFormat = errors.New("hasher: unknown hash format")
+	// ErrMalformed is returned if the encoded hash can't be parsed
+	ErrMalformed = errors.New("hasher: malformed hash")
+)
+
+// Hasher produces encoded password hashes
+type Hasher interface {
+	// Hash returns encoded hash of the password
+	Hash(password string) ([]byte, error)
+	// NeedsRehash reports whether the hash was produced by another algorithm or with different parameters
+	NeedsRehash(hash []byte) bool
+}
+
+// Default is used when no algorithm is configured
+var Default Hasher = &Bcrypt{}
+
+var b64 = base64.RawStdEncoding
+
+// Algorithm returns the name of the algorithm which produced the hash
+func Algorithm(hash []byte) string {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$2")):
+		return AlgBcrypt
+	case bytes.HasPrefix(hash, []byte("$"+AlgArgon2id+"$")):
+		return AlgArgon2id
+	case bytes.HasPrefix(hash, []byte("$"+AlgScrypt+"$")):
+		return AlgScrypt
+	}
+	return ""
+}
+
+// Compare checks the password against the hash encoded in any supported format
+func Compare(hash []byte, password string) error {
+	switch Algorithm(hash) {
+	case AlgBcrypt:
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return ErrMismatch
+			}
+			return err
+		}
+		return nil
+
+	case AlgArgon2id:
+		p, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		return compareKeys(key, p.key(password, salt, len(key)))
+
+	case AlgScrypt:
+		p, salt, key, err := parseScrypt(hash)
+		if err != nil {
+			return err
+		}
+		k, err := p.key(password, salt, len(key))
+		if err != nil {
+			return err
+		}
+		return compareKeys(key, k)
+	}
+
+	return ErrUnknownFormat
+}
+
+// Validate checks that the hash is encoded in a supported format
+func Validate(hash []byte) error {
+	var err error
+	switch Algorithm(hash) {
+	case AlgBcrypt:
+		_, err = bcrypt.Cost(hash)
+	case AlgArgon2id:
+		_, _, _, err = parseArgon2id(hash)
+	case AlgScrypt:
+		_, _, _, err = parseScrypt(hash)
+	default:
+		err = ErrUnknownFormat
+	}
+	return err
+}
+
+func compareKeys(a, b []byte) error {
+	if subtle.ConstantTimeCompare(a, b) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// splitPHC splits $id$params$salt$hash string and decodes the salt and the hash
+func splitPHC(hash []byte, id string) (params map[string]string, salt, key []byte, err error) {
+	parts := strings.Split(string(hash), "$")
+	params = make(map[string]string)
+
+	// Optional version field: $id$v=19$params$salt$hash
+	if len(parts) == 6 && strings.HasPrefix(parts[2], "v=") {
+		params["v"] = parts[2][2:]
+		parts = append(parts[:2], parts[3:]...)
+	}
+
+	if len(parts) != 5 || parts[0] != "" || parts[1] != id {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	for _, kv := range strings.Split(parts[2], ",") {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return nil, nil, nil, ErrMalformed
+		}
+		params[kv[:i]] = kv[i+1:]
+	}
+
+	if salt, err = b64.DecodeString(parts[3]); err != nil {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	if key, err = b64.DecodeString(parts[4]); err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	return params, salt, key, nil
+}
+
+func paramUint(params map[string]string, name string, bits int) (uint64, error) {
+	s, ok := params[name]
+	if !ok {
+		return 0, fmt.Errorf("hasher: missing parameter `%s'", name)
+	}
+
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("hasher: invalid parameter `%s': %v", name, err)
+	}
+
+	return v, nil
+}
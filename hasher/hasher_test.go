@@ -0,0 +1,96 @@
+package hasher
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRoundTrip(t *testing.T) {
+	hashers := map[string]Hasher{
+		AlgBcrypt:   &Bcrypt{Cost: bcrypt.MinCost},
+		AlgArgon2id: &Argon2id{Memory: 1024, Time: 1, Threads: 1},
+		AlgScrypt:   &Scrypt{LogN: 10},
+	}
+
+	for alg, h := range hashers {
+		hash, err := h.Hash("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if a := Algorithm(hash); a != alg {
+			t.Errorf("%s: unexpected algorithm %q", alg, a)
+		}
+
+		if err := Validate(hash); err != nil {
+			t.Errorf("%s: %v", alg, err)
+		}
+
+		if err := Compare(hash, "password"); err != nil {
+			t.Errorf("%s: %v", alg, err)
+		}
+
+		if err := Compare(hash, "passw0rd"); err != ErrMismatch {
+			t.Errorf("%s: expected mismatch, got %v", alg, err)
+		}
+
+		if h.NeedsRehash(hash) {
+			t.Errorf("%s: unexpected rehash", alg)
+		}
+
+		for other, o := range hashers {
+			if other != alg && !o.NeedsRehash(hash) {
+				t.Errorf("%s: %s hash must be rehashed", other, alg)
+			}
+		}
+	}
+}
+
+func TestParamsChange(t *testing.T) {
+	hash, err := (&Argon2id{Memory: 1024, Time: 1, Threads: 1}).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !(&Argon2id{Memory: 2048, Time: 1, Threads: 1}).NeedsRehash(hash) {
+		t.Error("memory change must trigger rehash")
+	}
+
+	hash, err = (&Bcrypt{Cost: bcrypt.MinCost}).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !(&Bcrypt{}).NeedsRehash(hash) {
+		t.Error("cost change must trigger rehash")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Produced by Python's hashlib.scrypt
+	hash := []byte("$scrypt$ln=10,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$BVMRKqdiVYikKAaPR1wucsKUKvw4TuPLkdEYtoSHas4")
+	if err := Compare(hash, "password"); err != nil {
+		t.Error(err)
+	}
+
+	malformed := []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=1024,t=1$c2FsdA$aGFzaA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$",
+		"$scrypt$ln=x,r=8,p=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=10,r=8,p=1$!!!$aGFzaA",
+	}
+
+	for _, m := range malformed {
+		if err := Validate([]byte(m)); err == nil {
+			t.Errorf("%q: error expected", m)
+		}
+
+		if err := Compare([]byte(m), "password"); err == nil || err == ErrMismatch {
+			t.Errorf("%q: format error expected, got %v", m, err)
+		}
+	}
+}
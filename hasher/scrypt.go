@@ -0,0 +1,126 @@
+package hasher
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Scrypt defaults
+const (
+	DefaultScryptLogN = 15
+	DefaultScryptR    = 8
+	DefaultScryptP    = 1
+)
+
+// Scrypt produces $scrypt$ln=...,r=...,p=...$salt$hash encoded hashes
+type Scrypt struct {
+	LogN    uint8 `yaml:"ln"` // Log2 of the CPU/memory cost parameter N
+	R       int   `yaml:"r"`  // Block size
+	P       int   `yaml:"p"`  // Parallelization
+	KeyLen  int   `yaml:"key_len"`
+	SaltLen int   `yaml:"salt_len"`
+}
+
+type scryptParams struct {
+	logN uint8
+	r    int
+	p    int
+}
+
+func (p *scryptParams) key(password string, salt []byte, keyLen int) ([]byte, error) {
+	return scrypt.Key([]byte(password), salt, 1<<p.logN, p.r, p.p, keyLen)
+}
+
+func (s *Scrypt) params() *scryptParams {
+	p := scryptParams{
+		logN: s.LogN,
+		r:    s.R,
+		p:    s.P,
+	}
+
+	if p.logN == 0 {
+		p.logN = DefaultScryptLogN
+	}
+
+	if p.r == 0 {
+		p.r = DefaultScryptR
+	}
+
+	if p.p == 0 {
+		p.p = DefaultScryptP
+	}
+
+	return &p
+}
+
+func (s *Scrypt) keyLen() int {
+	if s.KeyLen != 0 {
+		return s.KeyLen
+	}
+	return DefaultKeyLen
+}
+
+// Hash implements Hasher
+func (s *Scrypt) Hash(password string) ([]byte, error) {
+	salt, err := newSalt(s.SaltLen)
+	if err != nil {
+		return nil, err
+	}
+
+	p := s.params()
+	key, err := p.key(password, salt, s.keyLen())
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s", AlgScrypt, p.logN, p.r, p.p, b64.EncodeToString(salt), b64.EncodeToString(key))), nil
+}
+
+// NeedsRehash implements Hasher
+func (s *Scrypt) NeedsRehash(hash []byte) bool {
+	if Algorithm(hash) != AlgScrypt {
+		return true
+	}
+
+	p, _, key, err := parseScrypt(hash)
+	if err != nil {
+		return true
+	}
+
+	return *p != *s.params() || len(key) != s.keyLen()
+}
+
+func parseScrypt(hash []byte) (p *scryptParams, salt, key []byte, err error) {
+	params, salt, key, err := splitPHC(hash, AlgScrypt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ln, err := paramUint(params, "ln", 8)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	r, err := paramUint(params, "r", 30)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	pp, err := paramUint(params, "p", 30)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if ln == 0 || ln > 62 || r == 0 || pp == 0 {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	p = &scryptParams{
+		logN: uint8(ln),
+		r:    int(r),
+		p:    int(pp),
+	}
+
+	return p, salt, key, nil
+}
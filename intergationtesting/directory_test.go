@@ -0,0 +1,181 @@
+package intergationtesting
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ecadlabs/auth/directory"
+	"github.com/ecadlabs/auth/directory/ldaptest"
+)
+
+// testDirectories are used by the default domain of the test server
+var testDirectories []*directory.Directory
+
+func TestLDAPDirectory(t *testing.T) {
+	alice := &ldaptest.Entry{
+		DN:       "uid=alice,ou=people,dc=example,dc=com",
+		Password: "secret",
+		Attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"alice"},
+			"mail":        {"alice@ldap.example.com"},
+			"cn":          {"Alice"},
+			"memberOf":    {"cn=operators,ou=groups,dc=example,dc=com"},
+		},
+	}
+
+	ldapSrv := ldaptest.NewServer(
+		&ldaptest.Entry{DN: "cn=service,dc=example,dc=com", Password: "service"},
+		alice,
+	)
+	defer ldapSrv.Close()
+
+	dir := &directory.Directory{
+		Name:            "ldap",
+		Domains:         []string{"ldap.example.com"},
+		RoleMapping:     map[string]string{"operators": "regular", "managers": "owner"},
+		JITProvisioning: true,
+		LDAP: &directory.LDAP{
+			URL:            ldapSrv.URL,
+			BindDN:         "cn=service,dc=example,dc=com",
+			BindPassword:   "service",
+			BaseDN:         "dc=example,dc=com",
+			GroupAttribute: "memberOf",
+		},
+	}
+
+	testDirectories = []*directory.Directory{dir}
+	defer func() { testDirectories = nil }()
+
+	srv, _, token, _, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	tenant, err := givenTenantExists(srv, "ldap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir.TenantID = tenant.ID
+
+	// Wrong password
+	code, _, _, err := doLogin(srv, "alice@ldap.example.com", "wrong", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusUnauthorized {
+		t.Errorf("wrong password: %d", code)
+	}
+
+	// Just-in-time provisioning with mapped roles
+	code, _, _, err = doLogin(srv, "alice", "secret", &tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Fatalf("login: %d", code)
+	}
+
+	_, members, err := getTenantMembershipsList(srv, token, tenant.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := findMembership(members, "alice@ldap.example.com")
+	if m == nil || len(m.Roles) != 1 || m.Roles["regular"] == nil {
+		t.Fatalf("unexpected membership: %#v", m)
+	}
+
+	// Roles follow the directory groups
+	alice.Attributes["memberOf"] = []string{"cn=managers,ou=groups,dc=example,dc=com"}
+
+	if code, _, _, err = doLogin(srv, "alice@ldap.example.com", "secret", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Fatalf("second login: %d", code)
+	}
+
+	if _, members, err = getTenantMembershipsList(srv, token, tenant.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m = findMembership(members, "alice@ldap.example.com")
+	if m == nil || len(m.Roles) != 1 || m.Roles["owner"] == nil {
+		t.Errorf("unexpected membership: %#v", m)
+	}
+
+	// Local accounts outside of the directory are unaffected
+	if code, _, _, err = doLogin(srv, superUserEmail, testPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Errorf("local login: %d", code)
+	}
+}
+
+func TestLDAPDirectoryWithoutDomains(t *testing.T) {
+	ldapSrv := ldaptest.NewServer(
+		&ldaptest.Entry{DN: "cn=service,dc=example,dc=com", Password: "service"},
+		&ldaptest.Entry{
+			DN:       "uid=mallory,ou=people,dc=example,dc=com",
+			Password: "secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"mallory"},
+				"mail":        {genTestEmail(0)},
+			},
+		},
+	)
+	defer ldapSrv.Close()
+
+	dir := &directory.Directory{
+		Name:            "ldap",
+		JITProvisioning: true,
+		LDAP: &directory.LDAP{
+			URL:          ldapSrv.URL,
+			BindDN:       "cn=service,dc=example,dc=com",
+			BindPassword: "service",
+			BaseDN:       "dc=example,dc=com",
+		},
+	}
+
+	testDirectories = []*directory.Directory{dir}
+	defer func() { testDirectories = nil }()
+
+	srv, userList, token, _, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	tenant, err := givenTenantExists(srv, "ldap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir.TenantID = tenant.ID
+
+	// The tenant's directory asserts the address of an account outside of the tenant
+	code, _, _, err := doLogin(srv, "mallory", "secret", &tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Errorf("login: %d", code)
+	}
+
+	_, members, err := getTenantMembershipsList(srv, token, tenant.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m := findMembership(members, userList[0].Email); m != nil {
+		t.Errorf("account linked: %#v", m)
+	}
+}
@@ -0,0 +1,210 @@
+package intergationtesting
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/keys"
+	"github.com/ecadlabs/auth/storage"
+)
+
+// fakeIdP approves any authorization request on behalf of the configured user
+type fakeIdP struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	email string
+	nonce string
+}
+
+func newFakeIdP() (*fakeIdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	idp := &fakeIdP{key: key}
+
+	m := http.NewServeMux()
+	m.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+
+	m.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		j := keys.NewJWK(&idp.key.PublicKey)
+		j.KeyID = "idp"
+		json.NewEncoder(w).Encode(&keys.JWKSet{Keys: []*keys.JWK{j}})
+	})
+
+	m.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		idp.nonce = r.FormValue("nonce")
+		dest, _ := url.Parse(r.FormValue("redirect_uri"))
+		dest.RawQuery = url.Values{"code": {"code"}, "state": {r.FormValue("state")}}.Encode()
+		http.Redirect(w, r, dest.String(), http.StatusFound)
+	})
+
+	m.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            idp.URL,
+			"sub":            idp.email,
+			"aud":            "client",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          idp.nonce,
+			"email":          idp.email,
+			"email_verified": true,
+			"name":           "Federated User",
+		})
+		token.Header["kid"] = "idp"
+
+		idToken, _ := token.SignedString(idp.key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+
+	idp.Server = httptest.NewServer(m)
+	return idp, nil
+}
+
+func createOIDCConnection(srv *httptest.Server, token string, tenant *storage.TenantModel, conn interface{}) (int, *storage.OIDCConnection, error) {
+	buf, err := json.Marshal(conn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req, err := http.NewRequest("POST", srv.URL+"/tenants/"+tenant.ID.String()+"/oidc/", bytes.NewReader(buf))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	var res storage.OIDCConnection
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, &res, nil
+}
+
+func doOIDCLogin(srv *httptest.Server, conn *storage.OIDCConnection) (int, string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(srv.URL + "/login/oidc/" + conn.ID.String())
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", nil
+	}
+
+	var res tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, "", err
+	}
+
+	return resp.StatusCode, res.Token, nil
+}
+
+func TestOIDCFederation(t *testing.T) {
+	srv, _, token, tokenCh, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	idp, err := newFakeIdP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idp.Close()
+
+	tenant, err := givenTenantExists(srv, "federated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, conn, err := createOIDCConnection(srv, token, tenant, map[string]interface{}{
+		"issuer":           idp.URL,
+		"client_id":        "client",
+		"client_secret":    "secret",
+		"jit_provisioning": true,
+		"domains":          []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusCreated {
+		t.Fatalf("create connection: %d", code)
+	}
+
+	// Just-in-time provisioning creates an unverified account
+	idp.email = "federated@example.com"
+	code, tok, err := doOIDCLogin(srv, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Fatalf("unverified login: %d", code)
+	}
+
+	if code, err = doPost(srv, "/signup/verify", map[string]string{"token": <-tokenCh}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusNoContent {
+		t.Fatalf("verify: %d", code)
+	}
+
+	if code, tok, err = doOIDCLogin(srv, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK || tok == "" {
+		t.Fatalf("login: %d", code)
+	}
+
+	// Second login matches the provisioned user
+	if code, _, err = doOIDCLogin(srv, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Errorf("second login: %d", code)
+	}
+
+	// Existing users not linked to the connection must not be taken over
+	idp.email = superUserEmail
+	if code, _, err = doOIDCLogin(srv, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Errorf("unlinked login: %d", code)
+	}
+}
@@ -0,0 +1,89 @@
+package intergationtesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func doImpersonate(srv *httptest.Server, token string, uid uuid.UUID) (int, string, error) {
+	req, err := http.NewRequest("POST", srv.URL+"/users/"+uid.String()+"/impersonate", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", nil
+	}
+
+	var res tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, "", err
+	}
+
+	return resp.StatusCode, res.Token, nil
+}
+
+func TestImpersonationCantChangeCredentials(t *testing.T) {
+	srv, userList, token, _, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	target := userList[0]
+
+	code, impToken, err := doImpersonate(srv, token, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK || impToken == "" {
+		t.Fatalf("impersonate: %d", code)
+	}
+
+	buf, err := json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/password", "value": "operator chosen password"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("PATCH", srv.URL+"/users/"+target.ID.String(), bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+impToken)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("password change with impersonation token: %d", resp.StatusCode)
+	}
+
+	// The password is unchanged
+	if code, _, _, err = doLogin(srv, target.Email, testPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Errorf("login: %d", code)
+	}
+}
@@ -0,0 +1,46 @@
+package intergationtesting
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLockedAccountIsNotDisclosed(t *testing.T) {
+	srv, userList, _, _, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	target := userList[0]
+
+	// Default threshold is 5
+	for i := 0; i < 5; i++ {
+		code, _, _, err := doLogin(srv, target.Email, "wrong password", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: %d", code)
+		}
+	}
+
+	// Locked account gets the same response as an unknown one even with the right password
+	code, _, _, err := doLogin(srv, target.Email, testPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusUnauthorized {
+		t.Errorf("locked account: %d", code)
+	}
+
+	if code, _, _, err = doLogin(srv, "nobody@example.com", testPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusUnauthorized {
+		t.Errorf("unknown account: %d", code)
+	}
+}
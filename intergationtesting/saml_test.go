@@ -0,0 +1,341 @@
+package intergationtesting
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/ecadlabs/auth/storage"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const fakeSAMLEntityID = "https://idp.example.com/metadata"
+
+// fakeSAMLIdP signs assertions on behalf of the configured user
+type fakeSAMLIdP struct {
+	key    *rsa.PrivateKey
+	cert   *x509.Certificate
+	email  string
+	groups []string
+}
+
+func newFakeSAMLIdP() (*fakeSAMLIdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tpl, &tpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeSAMLIdP{key: key, cert: cert}, nil
+}
+
+func (idp *fakeSAMLIdP) metadata() string {
+	return fmt.Sprintf(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="%s">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></KeyDescriptor>
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`, fakeSAMLEntityID, base64.StdEncoding.EncodeToString(idp.cert.Raw))
+}
+
+// response returns the signed response to the redirect binding request
+func (idp *fakeSAMLIdP) response(authnURL string) (string, string, error) {
+	u, err := url.Parse(authnURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return "", "", err
+	}
+
+	req := etree.NewDocument()
+	if err := req.ReadFromBytes(data); err != nil {
+		return "", "", err
+	}
+
+	id := req.Root().SelectAttrValue("ID", "")
+	acs := req.Root().SelectAttrValue("AssertionConsumerServiceURL", "")
+	audience := req.Root().SelectElement("Issuer").Text()
+
+	var values string
+	for _, g := range idp.groups {
+		values += "<saml:AttributeValue>" + g + "</saml:AttributeValue>"
+	}
+
+	now := time.Now().UTC()
+	src := fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_response" Version="2.0" IssueInstant="%[1]s" Destination="%[2]s" InResponseTo="%[3]s">
+  <saml:Issuer>%[4]s</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion ID="_assertion" Version="2.0" IssueInstant="%[1]s">
+    <saml:Issuer>%[4]s</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">%[5]s</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData NotOnOrAfter="%[6]s" Recipient="%[2]s" InResponseTo="%[3]s"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="%[1]s" NotOnOrAfter="%[6]s">
+      <saml:AudienceRestriction><saml:Audience>%[7]s</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="groups">%[8]s</saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`, now.Format(time.RFC3339), acs, id, fakeSAMLEntityID, idp.email, now.Add(5*time.Minute).Format(time.RFC3339), audience, values)
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(src); err != nil {
+		return "", "", err
+	}
+
+	ctx, err := dsig.NewSigningContext(idp.key, [][]byte{idp.cert.Raw})
+	if err != nil {
+		return "", "", err
+	}
+
+	signed, err := ctx.SignEnveloped(doc.Root())
+	if err != nil {
+		return "", "", err
+	}
+	doc.SetRoot(signed)
+
+	buf, err := doc.WriteToBytes()
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf), acs, nil
+}
+
+func createSAMLConnection(srv *httptest.Server, token string, tenant *storage.TenantModel, conn interface{}) (int, *storage.SAMLConnection, error) {
+	buf, err := json.Marshal(conn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req, err := http.NewRequest("POST", srv.URL+"/tenants/"+tenant.ID.String()+"/saml/", bytes.NewReader(buf))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	var res storage.SAMLConnection
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, &res, nil
+}
+
+func doSAMLLogin(srv *httptest.Server, idp *fakeSAMLIdP, conn *storage.SAMLConnection) (int, string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(srv.URL + "/login/saml/" + conn.ID.String())
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return resp.StatusCode, "", nil
+	}
+
+	location := resp.Header.Get("Location")
+	samlResponse, acs, err := idp.response(location)
+	if err != nil {
+		return 0, "", err
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return 0, "", err
+	}
+
+	form := url.Values{
+		"SAMLResponse": {samlResponse},
+		"RelayState":   {u.Query().Get("RelayState")},
+	}
+
+	resp, err = client.Post(acs, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", nil
+	}
+
+	var res tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, "", err
+	}
+
+	return resp.StatusCode, res.Token, nil
+}
+
+func findMembership(list []*storage.Membership, email string) *storage.Membership {
+	for _, m := range list {
+		if m.Email == email {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestSAMLFederation(t *testing.T) {
+	srv, _, token, tokenCh, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	idp, err := newFakeSAMLIdP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := givenTenantExists(srv, "saml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, conn, err := createSAMLConnection(srv, token, tenant, map[string]interface{}{
+		"metadata":         idp.metadata(),
+		"role_attribute":   "groups",
+		"role_mapping":     map[string]string{"operators": "regular", "managers": "owner"},
+		"jit_provisioning": true,
+		"domains":          []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusCreated || conn.EntityID != fakeSAMLEntityID {
+		t.Fatalf("create connection: %d", code)
+	}
+
+	// Just-in-time provisioning with mapped roles
+	idp.email = "saml@example.com"
+	idp.groups = []string{"operators", "unknown"}
+
+	code, tok, err := doSAMLLogin(srv, idp, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Fatalf("unverified login: %d", code)
+	}
+
+	if code, err = doPost(srv, "/signup/verify", map[string]string{"token": <-tokenCh}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusNoContent {
+		t.Fatalf("verify: %d", code)
+	}
+
+	if code, tok, err = doSAMLLogin(srv, idp, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK || tok == "" {
+		t.Fatalf("login: %d", code)
+	}
+
+	_, members, err := getTenantMembershipsList(srv, token, tenant.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := findMembership(members, idp.email)
+	if m == nil || len(m.Roles) != 1 || m.Roles["regular"] == nil {
+		t.Fatalf("unexpected membership: %#v", m)
+	}
+
+	// Roles follow the identity provider
+	idp.groups = []string{"managers"}
+	if code, _, err = doSAMLLogin(srv, idp, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Fatalf("second login: %d", code)
+	}
+
+	if _, members, err = getTenantMembershipsList(srv, token, tenant.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m = findMembership(members, idp.email)
+	if m == nil || len(m.Roles) != 1 || m.Roles["owner"] == nil {
+		t.Errorf("unexpected membership: %#v", m)
+	}
+
+	// Existing users not linked to the connection must not be taken over
+	idp.email = superUserEmail
+	if code, _, err = doSAMLLogin(srv, idp, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Errorf("unlinked login: %d", code)
+	}
+}
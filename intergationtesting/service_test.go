@@ -127,8 +127,9 @@ func createUser(srv *httptest.Server, u *storage.CreateUser, token string, token
 }
 
 type tokenResponse struct {
-	Token      string `json:"token"`
-	RefreshURL string `json:"refresh"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	RefreshURL   string `json:"refresh"`
 }
 
 func doLogin(srv *httptest.Server, email, password string, tenantID *uuid.UUID) (code int, token string, refresh string, err error) {
@@ -168,7 +169,48 @@ func doLogin(srv *httptest.Server, email, password string, tenantID *uuid.UUID)
 		return 0, "", "", err
 	}
 
-	return resp.StatusCode, res.Token, res.RefreshURL, nil
+	return resp.StatusCode, res.Token, res.RefreshToken, nil
+}
+
+func doRefresh(srv *httptest.Server, refreshToken string) (code int, res *tokenResponse, err error) {
+	buf, err := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := srv.Client().Post(srv.URL+"/refresh", "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, nil, nil
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, &tr, nil
+}
+
+func doRevoke(srv *httptest.Server, token string) (int, error) {
+	req, err := http.NewRequest("POST", srv.URL+"/revoke", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
 }
 
 func deleteUser(srv *httptest.Server, token string, uid uuid.UUID) (int, error) {
@@ -344,6 +386,11 @@ var testRBAC = rbac.StaticRBAC{
 				"com.ecadlabs.users.delegate:regular": struct{}{},
 				"com.ecadlabs.users.full_control":     struct{}{},
 				"com.ecadlabs.tenants.full_control":   struct{}{},
+				"com.ecadlabs.users.impersonate":      struct{}{},
+				"com.ecadlabs.tenants.read_owned":     struct{}{},
+				"com.ecadlabs.tenants.write_owned":    struct{}{},
+				"com.ecadlabs.users.read_self":        struct{}{},
+				"com.ecadlabs.users.write_self":       struct{}{},
 			},
 		},
 		"owner": &rbac.StaticRole{
@@ -373,6 +420,7 @@ var testRBAC = rbac.StaticRBAC{
 		"com.ecadlabs.users.delegate:owner": "Assign `owner' role",
 		"com.ecadlabs.users.delegate:ops":   "Assign `ops' role",
 		"com.ecadlabs.users.full_control":   "Allows user to manage all accounts",
+		"com.ecadlabs.users.impersonate":    "Allows user to impersonate less privileged users",
 		"com.ecadlabs.tenants.full_control": "Allows user to manage all tenants",
 		"com.ecadlabs.users.read":           "Allows user to view users",
 		"com.ecadlabs.users.read_logs":      "Allows user to access logs",
@@ -392,7 +440,7 @@ func beforeTest() (srv *httptest.Server, userList []*storage.User, token string,
 	}
 	defer db.Close()
 
-	_, err = db.Exec(`DROP TABLE IF EXISTS bootstrap, log, membership, roles, schema_migrations, service_account_ip, service_account_keys, tenants, users`)
+	_, err = db.Exec(`DROP TABLE IF EXISTS bootstrap, log, membership, oauth_clients, oauth_codes, refresh_tokens, roles, schema_migrations, service_account_ip, service_account_keys, sessions, revoked_tokens, mfa_totp, mfa_recovery_codes, webauthn_credentials, login_failures, rate_limit_buckets, password_history, oidc_connections, saml_connections, federated_identities, tenants, users`)
 	if err != nil {
 		return
 	}
@@ -402,7 +450,7 @@ func beforeTest() (srv *httptest.Server, userList []*storage.User, token string,
 		return
 	}
 
-	_, err = db.Exec(`DROP FUNCTION IF EXISTS ip_overlap_check CASCADE`)
+	_, err = db.Exec(`DROP FUNCTION IF EXISTS ip_overlap_check, password_history_update CASCADE`)
 	if err != nil {
 		return
 	}
@@ -428,12 +476,15 @@ func beforeTest() (srv *httptest.Server, userList []*storage.User, token string,
 				ResetTokenMaxAge:       72 * time.Hour,
 				TenantInviteMaxAge:     72 * time.Hour,
 				EmailUpdateTokenMaxAge: 72 * time.Hour,
+				Directories:            testDirectories,
+				Signup:                 testSignup,
 			},
 		},
 		JWTSecret:   testJWTSecret,
 		PostgresURL: *dbURL,
 		DBTimeout:   10 * 60 * 60,
 		Notifier:    testNotifier(tokenCh),
+		Federation:  service.FederationConfig{AllowInsecure: true},
 	}
 
 	svc, err := service.New(&config, &testRBAC, *enableLog)
@@ -520,35 +571,67 @@ func TestService(t *testing.T) {
 			return
 		}
 
+		// Refresh within a separate session, reuse detection revokes it
+		code, sessionToken, refresh, err := doLogin(srv, genTestEmail(0), testPassword, nil)
+		if err != nil || code != http.StatusOK {
+			t.Error(code, err)
+			return
+		}
+
 		// Test refresh
-		req, err := http.NewRequest("GET", refresh, nil)
+		code, res, err := doRefresh(srv, refresh)
 		if err != nil {
 			t.Error(err)
 			return
 		}
-		req.Header.Set("Authorization", "Bearer "+token)
 
-		resp, err := srv.Client().Do(req)
-		if err != nil {
-			t.Error(err)
+		if code != http.StatusOK {
+			t.Error(code)
 			return
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			t.Error(resp.StatusCode)
+		if res.RefreshToken == "" || res.RefreshToken == refresh {
+			t.Error("Refresh token is not rotated")
+			return
+		}
+
+		// Reuse of the rotated token must revoke the whole family
+		if code, _, err = doRefresh(srv, refresh); err != nil || code != http.StatusBadRequest {
+			t.Error(code, err)
 			return
 		}
 
-		var res tokenResponse
+		if code, _, err = doRefresh(srv, res.RefreshToken); err != nil || code != http.StatusBadRequest {
+			t.Error(code, err)
+			return
+		}
 
-		dec := json.NewDecoder(resp.Body)
-		if err := dec.Decode(&res); err != nil {
-			t.Error(err)
+		// Access tokens of the revoked session are rejected too
+		for _, tk := range []string{sessionToken, res.Token} {
+			if code, _, err := getTenantList(srv, tk, url.Values{}); err != nil || code != http.StatusUnauthorized {
+				t.Error(code, err)
+				return
+			}
+		}
+
+		// Revoked access token is rejected while the session is still alive
+		code, revokedToken, _, err := doLogin(srv, genTestEmail(0), testPassword, nil)
+		if err != nil || code != http.StatusOK {
+			t.Error(code, err)
+			return
+		}
+
+		if code, err := doRevoke(srv, revokedToken); err != nil || code != http.StatusOK {
+			t.Error(code, err)
+			return
+		}
+
+		if code, _, err := getTenantList(srv, revokedToken, url.Values{}); err != nil || code != http.StatusUnauthorized {
+			t.Error(code, err)
 			return
 		}
 
-		tok, err := jwt.Parse(res.Token, func(t *jwt.Token) (interface{}, error) {
+		tok, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
 			return []byte([]byte(testJWTSecret)), nil
 		})
 		if err != nil {
@@ -574,7 +657,7 @@ func TestService(t *testing.T) {
 		}
 
 		t.Run("TestGetSelf", func(t *testing.T) {
-			code, _, err := getUser(srv, res.Token, uid)
+			code, _, err := getUser(srv, token, uid)
 			if err != nil {
 				t.Error(err)
 				return
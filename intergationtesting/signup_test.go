@@ -0,0 +1,131 @@
+package intergationtesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecadlabs/auth/middleware"
+)
+
+// testSignup is used by the default domain of the test server
+var testSignup *middleware.Signup
+
+func doPost(srv *httptest.Server, path string, body interface{}) (int, error) {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := srv.Client().Post(srv.URL+path, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func TestSignup(t *testing.T) {
+	// Disabled by default
+	srv, _, _, _, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := doPost(srv, "/signup", map[string]string{"email": "new@example.com", "password": testPassword})
+	srv.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusNotFound {
+		t.Fatalf("disabled sign-up: %d", code)
+	}
+
+	testSignup = &middleware.Signup{DeniedDomains: []string{"blocked.example.com"}}
+	defer func() { testSignup = nil }()
+
+	srv, _, _, tokenCh, _, err := beforeTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// Denied domain
+	if code, err = doPost(srv, "/signup", map[string]string{"email": "new@blocked.example.com", "password": testPassword}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Errorf("denied domain: %d", code)
+	}
+
+	signup := map[string]string{"email": "new@example.com", "name": "New User", "password": testPassword}
+	if code, err = doPost(srv, "/signup", signup); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusNoContent {
+		t.Fatalf("sign-up: %d", code)
+	}
+
+	token := <-tokenCh
+
+	// Unverified account can't log in
+	if code, _, _, err = doLogin(srv, "new@example.com", testPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusForbidden {
+		t.Errorf("unverified login: %d", code)
+	}
+
+	// Repeated sign-up resends the verification email and replaces the password of the unverified account,
+	// so whoever signed up first with someone else's address loses access once the owner verifies it
+	const ownerPassword = "owner password"
+	signup["password"] = ownerPassword
+	if code, err = doPost(srv, "/signup", signup); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusNoContent {
+		t.Fatalf("repeated sign-up: %d", code)
+	}
+	<-tokenCh
+
+	if code, err = doPost(srv, "/signup/verify", map[string]string{"token": token}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusNoContent {
+		t.Fatalf("verify: %d", code)
+	}
+
+	// Single use
+	if code, err = doPost(srv, "/signup/verify", map[string]string{"token": token}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code == http.StatusNoContent {
+		t.Error("verification token reused")
+	}
+
+	if code, _, _, err = doLogin(srv, "new@example.com", testPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code == http.StatusOK {
+		t.Error("first sign-up password still works")
+	}
+
+	if code, _, _, err = doLogin(srv, "new@example.com", ownerPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Errorf("login: %d", code)
+	}
+}
@@ -30,6 +30,7 @@ type Query struct {
 	Last       *string
 	LastID     *string
 	Limit      int
+	Offset     int // Rows to skip, for clients which can't use the keyset pagination
 	TotalCount bool
 	Expr       *Expr
 	RawExpr    string
@@ -247,5 +248,11 @@ func (q *Query) SelectStmt(o *Options) (string, []interface{}, error) {
 		args = append(args, q.Limit)
 	}
 
+	if q.Offset > 0 {
+		index++
+		stmt.WriteString(" OFFSET " + o.driverParams().pos(index))
+		args = append(args, q.Offset)
+	}
+
 	return stmt.String(), args, nil
 }
@@ -0,0 +1,215 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK represents a public JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKSet represents a JSON Web Key Set
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func b64Int(i *big.Int, size int) string {
+	buf := i.Bytes()
+	if len(buf) < size {
+		tmp := make([]byte, size)
+		copy(tmp[size-len(buf):], buf)
+		buf = tmp
+	}
+	return b64(buf)
+}
+
+// NewJWK returns a JWK representation of the public key
+func NewJWK(pub crypto.PublicKey) *JWK {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			N:       b64(k.N.Bytes()),
+			E:       b64(big.NewInt(int64(k.E)).Bytes()),
+		}
+
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return &JWK{
+			KeyType: "EC",
+			Curve:   k.Curve.Params().Name,
+			X:       b64Int(k.X, size),
+			Y:       b64Int(k.Y, size),
+		}
+
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       b64(k),
+		}
+	}
+
+	return nil
+}
+
+func unb64Int(s string) (*big.Int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, errors.New("jwk: empty value")
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// PublicKey returns the public key represented by the JWK
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := unb64Int(j.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := unb64Int(j.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("jwk: invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwk: unsupported curve `%s'", j.Curve)
+		}
+
+		x, err := unb64Int(j.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := unb64Int(j.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("jwk: point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if j.Curve != "Ed25519" {
+			return nil, fmt.Errorf("jwk: unsupported curve `%s'", j.Curve)
+		}
+
+		buf, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(buf) != ed25519.PublicKeySize {
+			return nil, errors.New("jwk: invalid Ed25519 key size")
+		}
+
+		return ed25519.PublicKey(buf), nil
+	}
+
+	return nil, fmt.Errorf("jwk: unsupported key type `%s'", j.KeyType)
+}
+
+// Thumbprint returns RFC 7638 key thumbprint
+func (j *JWK) Thumbprint() (string, error) {
+	var v interface{}
+
+	// Required members only, in lexicographic order
+	switch j.KeyType {
+	case "RSA":
+		v = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.KeyType, j.N}
+
+	case "EC":
+		v = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Curve, j.KeyType, j.X, j.Y}
+
+	default:
+		v = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Curve, j.KeyType, j.X}
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf)
+	return b64(sum[:]), nil
+}
+
+// JWK returns the public JWK representation of the key or nil for shared secrets
+func (k *Key) JWK() *JWK {
+	if k.Private == nil {
+		return nil
+	}
+
+	j := NewJWK(k.Private.Public())
+	if j == nil {
+		return nil
+	}
+
+	j.Use = "sig"
+	j.Algorithm = k.Method.Alg()
+	j.KeyID = k.ID
+
+	return j
+}
@@ -0,0 +1,213 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Key represents a JWT signing key
+type Key struct {
+	ID       string
+	Method   jwt.SigningMethod
+	Secret   []byte        // HMAC only
+	Private  crypto.Signer // Asymmetric only
+	NotAfter time.Time     // Zero value means no expiration
+}
+
+var (
+	ErrNoKey            = errors.New("No private key found")
+	ErrUnsupportedKey   = errors.New("Unsupported private key type")
+	ErrUnsupportedCurve = errors.New("Unsupported elliptic curve")
+)
+
+// NewSecretKey returns a shared secret key. Its ID is derived from the secret so tokens signed with
+// a single legacy secret still carry a stable kid and can be rotated later
+func NewSecretKey(secret []byte, method jwt.SigningMethod) *Key {
+	return &Key{
+		ID:     secretKeyID(secret),
+		Method: method,
+		Secret: secret,
+	}
+}
+
+// secretKeyID is a MAC of a fixed label so the ID doesn't reveal the secret itself
+func secretKeyID(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("kid"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// NewPrivateKey returns an asymmetric key. Signing method is derived from the key type
+func NewPrivateKey(priv crypto.Signer) (*Key, error) {
+	var method jwt.SigningMethod
+
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, ErrUnsupportedCurve
+		}
+
+	case ed25519.PrivateKey:
+		method = SigningMethodEdDSA
+
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	key := Key{
+		Method:  method,
+		Private: priv,
+	}
+
+	id, err := key.JWK().Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+
+	return &key, nil
+}
+
+// Expired returns true if the key can't be used for verification anymore
+func (k *Key) Expired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && now.After(k.NotAfter)
+}
+
+// SigningKey returns a value suitable for jwt.Token.SignedString
+func (k *Key) SigningKey() interface{} {
+	if k.Private != nil {
+		return k.Private
+	}
+	return k.Secret
+}
+
+// VerificationKey returns a value suitable for jwt.Keyfunc
+func (k *Key) VerificationKey() interface{} {
+	if k.Private != nil {
+		return k.Private.Public()
+	}
+	return k.Secret
+}
+
+// Symmetric returns true if the key is a shared secret which must never be published
+func (k *Key) Symmetric() bool {
+	return k.Private == nil
+}
+
+// ParsePEM parses the first private key found in PEM encoded data
+func ParsePEM(data []byte) (*Key, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, ErrNoKey
+		}
+
+		var (
+			priv interface{}
+			err  error
+		)
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			priv, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			// Skip parameters, certificates etc.
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, ErrUnsupportedKey
+		}
+
+		return NewPrivateKey(signer)
+	}
+}
+
+// LoadFile loads a private key from the PEM file
+func LoadFile(name string) (*Key, error) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePEM(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return key, nil
+}
+
+// Load loads private keys from the PEM file or from all *.pem files within the directory.
+// In the latter case keys are sorted by the file name
+func Load(name string) ([]*Key, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		key, err := LoadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return []*Key{key}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(name, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: %v", name, ErrNoKey)
+	}
+
+	sort.Strings(files)
+
+	res := make([]*Key, len(files))
+	for i, f := range files {
+		if res[i], err = LoadFile(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
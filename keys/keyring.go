@@ -0,0 +1,141 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("Signing key not found")
+	ErrKeyExpired    = errors.New("Signing key expired")
+	ErrDuplicateKey  = errors.New("Duplicate key ID")
+	ErrActiveExpired = errors.New("Active signing key is expired")
+)
+
+// Keyring holds the active signing key and retiring keys accepted for verification until their not-after dates
+type Keyring struct {
+	keys   []*Key
+	active *Key
+	now    func() time.Time
+}
+
+// NewKeyring returns a new keyring. The last key is used for signing
+func NewKeyring(keys ...*Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+
+	ids := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := ids[key.ID]; ok {
+			return nil, fmt.Errorf("%v: %s", ErrDuplicateKey, key.ID)
+		}
+		ids[key.ID] = struct{}{}
+	}
+
+	kr := Keyring{
+		keys: keys,
+		now:  time.Now,
+	}
+
+	if err := kr.setActive(keys[len(keys)-1]); err != nil {
+		return nil, err
+	}
+
+	return &kr, nil
+}
+
+func (k *Keyring) setActive(key *Key) error {
+	if key.Expired(k.now()) {
+		return fmt.Errorf("%v: %s", ErrActiveExpired, key.ID)
+	}
+	k.active = key
+	return nil
+}
+
+// SetActive selects the signing key by ID
+func (k *Keyring) SetActive(id string) error {
+	for _, key := range k.keys {
+		if key.ID == id {
+			return k.setActive(key)
+		}
+	}
+	return fmt.Errorf("%v: %s", ErrKeyNotFound, id)
+}
+
+// SigningKey returns the active key
+func (k *Keyring) SigningKey() *Key {
+	return k.active
+}
+
+// Sign signs the claims using the active key
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	if k.active.ID != "" {
+		token.Header["kid"] = k.active.ID
+	}
+
+	return token.SignedString(k.active.SigningKey())
+}
+
+// lookup finds the verification key. Tokens issued before key IDs were introduced
+// are checked against the first (oldest) key using the same algorithm
+func (k *Keyring) lookup(kid, alg string) (*Key, error) {
+	for _, key := range k.keys {
+		if kid != "" && key.ID == kid || kid == "" && key.Method.Alg() == alg {
+			if key.Expired(k.now()) {
+				return nil, ErrKeyExpired
+			}
+			return key, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// Keyfunc returns the verification key for the token. It's suitable for jwt.Parse
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := k.lookup(kid, token.Method.Alg())
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Method.Alg())
+	}
+
+	return key.VerificationKey(), nil
+}
+
+// Parse parses and verifies the token
+func (k *Keyring) Parse(token string, claims jwt.Claims) (*jwt.Token, error) {
+	if claims == nil {
+		claims = jwt.MapClaims{}
+	}
+	return jwt.ParseWithClaims(token, claims, k.Keyfunc)
+}
+
+// JWKSet returns the public keys which are not expired yet
+func (k *Keyring) JWKSet() *JWKSet {
+	set := JWKSet{
+		Keys: []*JWK{},
+	}
+
+	now := k.now()
+	for _, key := range k.keys {
+		if key.Expired(now) {
+			continue
+		}
+
+		if j := key.JWK(); j != nil {
+			set.Keys = append(set.Keys, j)
+		}
+	}
+
+	return &set
+}
@@ -0,0 +1,237 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func genKeys(t *testing.T) []crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []crypto.Signer{rsaKey, ecKey, edKey}
+}
+
+func TestSignVerify(t *testing.T) {
+	expectAlg := []string{"RS256", "ES256", "EdDSA"}
+
+	for i, priv := range genKeys(t) {
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key.Method.Alg() != expectAlg[i] {
+			t.Errorf("alg: %s != %s", key.Method.Alg(), expectAlg[i])
+		}
+
+		kr, err := NewKeyring(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tokStr, err := kr.Sign(jwt.MapClaims{"sub": "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tok, err := kr.Parse(tokStr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !tok.Valid || tok.Header["kid"] != key.ID {
+			t.Errorf("invalid token: %v", tok.Header)
+		}
+
+		set := kr.JWKSet()
+		if len(set.Keys) != 1 || set.Keys[0].KeyID != key.ID || set.Keys[0].Algorithm != expectAlg[i] {
+			t.Errorf("unexpected JWK set: %v", set.Keys)
+		}
+	}
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	secret := []byte("secret")
+	hmacRing, err := NewKeyring(NewSecretKey(secret, jwt.SigningMethodHS256))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hmacRing.JWKSet().Keys) != 0 {
+		t.Error("shared secret must not be published")
+	}
+
+	// Legacy secrets get a stable derived key ID
+	if id := hmacRing.SigningKey().ID; id == "" || id != NewSecretKey(secret, jwt.SigningMethodHS256).ID || id == NewSecretKey([]byte("other"), jwt.SigningMethodHS256).ID {
+		t.Errorf("unexpected key ID: %q", id)
+	}
+
+	tokStr, err := hmacRing.Sign(jwt.MapClaims{"sub": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tok, err := hmacRing.Parse(tokStr, nil); err != nil {
+		t.Error(err)
+	} else if tok.Header["kid"] != hmacRing.SigningKey().ID {
+		t.Errorf("unexpected kid: %v", tok.Header["kid"])
+	}
+
+	key, err := NewPrivateKey(genKeys(t)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaRing, err := NewKeyring(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HS256 token signed with the public key as a secret must be rejected
+	pub, err := x509.MarshalPKIXPublicKey(key.Private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "test"})
+	token.Header["kid"] = key.ID
+	tokStr, err = token.SignedString(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rsaRing.Parse(tokStr, nil); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 7638 Section 3.1
+	j := JWK{
+		KeyType: "RSA",
+		N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:       "AQAB",
+	}
+
+	tp, err := j.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tp != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("thumbprint: %s", tp)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	now := time.Now()
+
+	oldKey := NewSecretKey([]byte("old"), jwt.SigningMethodHS256)
+	oldKey.ID = "old"
+	oldKey.NotAfter = now.Add(time.Hour)
+
+	newKey, err := NewPrivateKey(genKeys(t)[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldRing, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken, err := oldRing.Sign(jwt.MapClaims{"sub": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issued before key IDs were introduced
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "test"}).SignedString([]byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr, err := NewKeyring(oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newToken, err := kr.Sign(jwt.MapClaims{"sub": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tok := range []string{oldToken, legacyToken, newToken} {
+		if _, err := kr.Parse(tok, nil); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Retiring key is past its not-after date
+	kr.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	for _, tok := range []string{oldToken, legacyToken} {
+		if _, err := kr.Parse(tok, nil); err == nil {
+			t.Error("error expected")
+		}
+	}
+
+	if _, err := kr.Parse(newToken, nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := kr.SetActive("old"); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	for _, priv := range genKeys(t) {
+		j := NewJWK(priv.Public())
+
+		pub, err := j.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		type equaler interface {
+			Equal(crypto.PublicKey) bool
+		}
+
+		if !priv.Public().(equaler).Equal(pub) {
+			t.Errorf("%s: keys don't match", j.KeyType)
+		}
+	}
+
+	if _, err := (&JWK{KeyType: "EC", Curve: "P-256", X: "AQ", Y: "AQ"}).PublicKey(); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -17,6 +17,7 @@ const (
 	DefaultTargetIDKey = "id"
 	DefaultEventKey    = "event"
 	DefaultAddrKey     = "addr"
+	DefaultActorIDKey  = "actor_id" // Real operator behind an impersonation token
 )
 
 var hookLevels = []logrus.Level{logrus.InfoLevel}
@@ -98,8 +99,9 @@ func (h *Hook) Fire(entry *logrus.Entry) error {
 	tid := entry.Data[h.targetIDKey()]
 	ev := entry.Data[h.eventKey()]
 	addr := entry.Data[h.addrKey()]
+	actor := entry.Data[DefaultActorIDKey]
 
-	_, err = h.DB.Exec("INSERT INTO "+pq.QuoteIdentifier(h.table())+" (id, ts, event, source_id, target_id, source_type, target_type, addr, msg, data) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", uuid.NewV4(), entry.Time, ev, uid, tid, sourceIdType, targetIdType, addr, entry.Message, buf)
+	_, err = h.DB.Exec("INSERT INTO "+pq.QuoteIdentifier(h.table())+" (id, ts, event, source_id, target_id, source_type, target_type, addr, msg, data, actor_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)", uuid.NewV4(), entry.Time, ev, uid, tid, sourceIdType, targetIdType, addr, entry.Message, buf, actor)
 	if err != nil {
 		return err
 	}
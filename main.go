@@ -71,8 +71,10 @@ func main() {
 	flag.StringVar(&config.Address, "http", ":8000", "HTTP service address.")
 	flag.StringVar(&config.HealthAddress, "health", ":8001", "Health service address.")
 	flag.StringVar(&config.JWTSecret, "secret", "", "JWT signing secret.")
+	flag.StringVar(&config.JWTPrivateKey, "private_key", "", "JWT signing private key PEM file or directory.")
 	flag.StringVar(&config.JWTNamespace, "namespace", service.DefaultNamespace, "JWT namespace prefix.")
 	flag.DurationVar(&config.DomainsConfig.Default.SessionMaxAge, "max_age", 72*time.Hour, "Session max age.")
+	flag.DurationVar(&config.DomainsConfig.Default.AccessTokenMaxAge, "access_token_max_age", 15*time.Minute, "Access token max age.")
 	flag.DurationVar(&config.DomainsConfig.Default.ResetTokenMaxAge, "reset_token_max_age", 3*time.Hour, "Password reset token max age.")
 	flag.DurationVar(&config.DomainsConfig.Default.TenantInviteMaxAge, "tenant_invite_max_age", 24*time.Hour, "Tenant invite token max age.")
 	flag.DurationVar(&config.DomainsConfig.Default.EmailUpdateTokenMaxAge, "email_token_max_age", 3*time.Hour, "Email update token max age.")
@@ -103,7 +105,7 @@ func main() {
 		}
 	}
 
-	if (config.JWTSecret == "" || ac == nil) && !migrateOnly {
+	if ((config.JWTSecret == "" && config.JWTPrivateKey == "" && len(config.JWTKeys) == 0) || ac == nil) && !migrateOnly {
 		flag.Usage()
 		os.Exit(0)
 	}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+)
+
+type actorContextKey struct{}
+
+// ActorContextKey holds the ID of the real operator behind an impersonation token
+var ActorContextKey interface{} = actorContextKey{}
+
+// Actor extracts the `act` claim of impersonation tokens and marks the request
+type Actor struct{}
+
+func (a *Actor) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(TokenContextKey).(*jwt.Token)
+		if !ok {
+			utils.JSONError(w, "", errors.CodeUnauthorized)
+			return
+		}
+
+		claims := token.Claims.(jwt.MapClaims)
+
+		act, ok := claims["act"]
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		var id uuid.UUID
+		if m, ok := act.(map[string]interface{}); ok {
+			if sub, ok := m["sub"].(string); ok {
+				id = uuid.FromStringOrNil(sub)
+			}
+		}
+
+		if id == uuid.Nil {
+			utils.JSONErrorResponse(w, errors.ErrInvalidToken)
+			return
+		}
+
+		AddLogField(r, "actor", id)
+		if sub, ok := claims["sub"].(string); ok {
+			AddLogField(r, "impersonated", sub)
+		}
+
+		req := r.WithContext(context.WithValue(r.Context(), ActorContextKey, id))
+		h.ServeHTTP(w, req)
+	})
+}
+
+// Deny rejects requests made with impersonation tokens. It must run after Handler
+func (a *Actor) Deny(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(ActorContextKey).(uuid.UUID); ok {
+			utils.JSONErrorResponse(w, errors.ErrImpersonated)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestActorDeny(t *testing.T) {
+	a := Actor{}
+	h := a.Handler(a.Deny(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	cases := []struct {
+		claims jwt.MapClaims
+		status int
+	}{
+		{claims: jwt.MapClaims{"sub": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}, status: http.StatusOK},
+		{claims: jwt.MapClaims{"sub": "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "act": map[string]interface{}{"sub": "6ba7b811-9dad-11d1-80b4-00c04fd430c8"}}, status: http.StatusForbidden},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest("POST", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), TokenContextKey, &jwt.Token{Claims: c.claims}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("%d: expected %d, got %d", i, c.status, w.Code)
+		}
+	}
+}
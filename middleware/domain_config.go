@@ -4,24 +4,73 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/ecadlabs/auth/directory"
 	"github.com/ecadlabs/auth/errors"
 	"github.com/ecadlabs/auth/notification"
+	"github.com/ecadlabs/auth/password"
 	"github.com/ecadlabs/auth/utils"
 	log "github.com/sirupsen/logrus"
 )
 
 type DomainConfigData struct {
-	SessionMaxAge          time.Duration                  `yaml:"session_max_age"`
+	SessionMaxAge          time.Duration                  `yaml:"session_max_age"` // Refresh token family lifetime
+	AccessTokenMaxAge      time.Duration                  `yaml:"access_token_max_age"`
 	ResetTokenMaxAge       time.Duration                  `yaml:"reset_token_max_age"`
 	TenantInviteMaxAge     time.Duration                  `yaml:"tenant_invite_max_age"`
 	EmailUpdateTokenMaxAge time.Duration                  `yaml:"email_update_token_max_age"`
+	AuthCodeMaxAge         time.Duration                  `yaml:"auth_code_max_age"`
+	ServiceTokenMaxAge     time.Duration                  `yaml:"service_token_max_age"`
+	MFAChallengeMaxAge     time.Duration                  `yaml:"mfa_challenge_max_age"`
+	LoginLinkMaxAge        time.Duration                  `yaml:"login_link_max_age"`
+	ImpersonationMaxAge    time.Duration                  `yaml:"impersonation_max_age"`
+	LockoutThreshold       int                            `yaml:"lockout_threshold"`      // Failed attempts per account before the lockout
+	AddrLockoutThreshold   int                            `yaml:"addr_lockout_threshold"` // Failed attempts per source address before the lockout
+	LockoutDuration        time.Duration                  `yaml:"lockout_duration"`       // Initial lockout duration, doubled on each subsequent failure
+	LockoutMaxDuration     time.Duration                  `yaml:"lockout_max_duration"`
+	LockoutWindow          time.Duration                  `yaml:"lockout_window"` // Failures older than that are forgotten
+	PasswordPolicy         password.Policy                `yaml:"password_policy"`
+	Directories            []*directory.Directory         `yaml:"directories"` // Checked in order, the first matching one authenticates the login
+	Signup                 *Signup                        `yaml:"signup"`      // Public registration is disabled if unset
 	BaseURL                string                         `yaml:"base_url"`
 	TemplateData           notification.EmailTemplateData `yaml:"template"`
 	BaseURLFunc            func() string                  `yaml:"-"` // Testing only
 }
 
+// Signup holds public registration settings
+type Signup struct {
+	AllowedDomains     []string      `yaml:"allowed_domains"` // Email domains allowed to sign up, any if empty
+	DeniedDomains      []string      `yaml:"denied_domains"`  // Take precedence over the allowed ones
+	VerificationMaxAge time.Duration `yaml:"verification_max_age"`
+}
+
+func matchDomain(domain string, list []string) bool {
+	for _, d := range list {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// DomainAllowed returns true if the email address may sign up. Listed domains match their subdomains too
+func (s *Signup) DomainAllowed(email string) bool {
+	i := strings.LastIndexByte(email, '@')
+	if i < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[i+1:])
+
+	if matchDomain(domain, s.DeniedDomains) {
+		return false
+	}
+
+	return len(s.AllowedDomains) == 0 || matchDomain(domain, s.AllowedDomains)
+}
+
 func (c *DomainConfigData) GetBaseURL() string {
 	if c.BaseURLFunc != nil {
 		return c.BaseURLFunc()
@@ -0,0 +1,34 @@
+package middleware
+
+import "testing"
+
+func TestSignupDomainAllowed(t *testing.T) {
+	s := Signup{
+		AllowedDomains: []string{"example.com", ".example.org"},
+		DeniedDomains:  []string{"blocked.example.com"},
+	}
+
+	cases := map[string]bool{
+		"user@example.com":            true,
+		"user@EXAMPLE.com":            true,
+		"user@sales.example.com":      true,
+		"user@example.org":            true,
+		"user@blocked.example.com":    false,
+		"user@eu.blocked.example.com": false,
+		"user@notexample.com":         false,
+		"user@example.net":            false,
+		"user":                        false,
+	}
+
+	for email, expected := range cases {
+		if s.DomainAllowed(email) != expected {
+			t.Errorf("%s: expected %t", email, expected)
+		}
+	}
+
+	// Anything but denied domains
+	s.AllowedDomains = nil
+	if !s.DomainAllowed("user@example.net") || s.DomainAllowed("user@blocked.example.com") {
+		t.Error("unexpected result with an empty allow list")
+	}
+}
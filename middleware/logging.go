@@ -3,6 +3,7 @@ package middleware
 // Logging middleware inspired by github.com/urfave/negroni
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -21,22 +22,32 @@ func (l *Logging) log() log.FieldLogger {
 	return log.StandardLogger()
 }
 
+type logFieldsContextKey struct{}
+
+// AddLogField attaches an extra field to the request log entry
+func AddLogField(r *http.Request, key string, value interface{}) {
+	if fields, ok := r.Context().Value(logFieldsContextKey{}).(log.Fields); ok {
+		fields[key] = value
+	}
+}
+
 // Handler wraps provided http.Handler with middleware
 func (l *Logging) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		timestamp := time.Now()
 
+		// Filled by inner handlers
+		fields := make(log.Fields)
+
 		rw := NewResponseStatusWriter(w)
-		h.ServeHTTP(rw, r)
-
-		fields := log.Fields{
-			"start_time": timestamp.Format(time.RFC3339),
-			"duration":   time.Since(timestamp),
-			"status":     rw.Status(),
-			"hostname":   r.Host,
-			"method":     r.Method,
-			"path":       r.URL.Path,
-		}
+		h.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), logFieldsContextKey{}, fields)))
+
+		fields["start_time"] = timestamp.Format(time.RFC3339)
+		fields["duration"] = time.Since(timestamp)
+		fields["status"] = rw.Status()
+		fields["hostname"] = r.Host
+		fields["method"] = r.Method
+		fields["path"] = r.URL.Path
 
 		l.log().WithFields(fields).Println(r.Method + " " + r.URL.Path)
 	})
@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rate limit key types
+const (
+	RateLimitKeyAddr     = "addr"
+	RateLimitKeyIdentity = "identity"
+)
+
+const maxIdentityBodySize = 64 * 1024
+
+// RateLimitBackend keeps token buckets
+type RateLimitBackend interface {
+	// TakeToken takes a token from the bucket. If the bucket is empty it returns false along with the time until the next token
+	TakeToken(ctx context.Context, key string, rate float64, burst int) (ok bool, wait time.Duration, err error)
+}
+
+// RateLimit is a token bucket rate limiter
+type RateLimit struct {
+	Name    string  // Route name, used in bucket keys and metrics
+	Rate    float64 // Tokens per second
+	Burst   int
+	KeyType string // RateLimitKeyAddr or RateLimitKeyIdentity
+	Backend RateLimitBackend
+}
+
+// KeyByAddr returns the client address
+func KeyByAddr(r *http.Request) string {
+	return utils.GetRemoteAddr(r)
+}
+
+var identityFields = []string{"name", "email", "username", "client_id"}
+
+// KeyByIdentity returns the user name the request is made on behalf of. The body is left intact for the handler
+func KeyByIdentity(r *http.Request) string {
+	if name, _, ok := r.BasicAuth(); ok && name != "" {
+		return strings.ToLower(name)
+	}
+
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxIdentityBodySize))
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var values map[string]interface{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if json.Unmarshal(body, &values) != nil {
+			return ""
+		}
+	} else {
+		// Parse a copy so the handler is able to parse the form itself
+		req := *r
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.Form, req.PostForm = nil, nil
+		if req.ParseForm() != nil {
+			return ""
+		}
+
+		values = make(map[string]interface{}, len(req.Form))
+		for k := range req.Form {
+			values[k] = req.Form.Get(k)
+		}
+	}
+
+	for _, f := range identityFields {
+		if v, ok := values[f].(string); ok && v != "" {
+			return strings.ToLower(v)
+		}
+	}
+
+	return ""
+}
+
+var (
+	rateLimitCounter     *prometheus.CounterVec
+	rateLimitCounterOnce sync.Once
+)
+
+func rateLimitRejections() *prometheus.CounterVec {
+	rateLimitCounterOnce.Do(func() {
+		counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_rate_limited_requests_total",
+			Help: "Total number of requests rejected by the rate limiter",
+		}, []string{"route", "key_type"})
+
+		if err := prometheus.Register(counter); err != nil {
+			// Reuse collector
+			if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				counter = are.ExistingCollector.(*prometheus.CounterVec)
+			} else {
+				panic(err)
+			}
+		}
+
+		rateLimitCounter = counter
+	})
+
+	return rateLimitCounter
+}
+
+func (rl *RateLimit) Handler(h http.Handler) http.Handler {
+	keyType := rl.KeyType
+	keyFunc := KeyByAddr
+	if keyType == RateLimitKeyIdentity {
+		keyFunc = KeyByIdentity
+	} else {
+		keyType = RateLimitKeyAddr
+	}
+
+	counter := rateLimitRejections()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := keyFunc(r)
+		if key == "" {
+			// Nothing to count
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait, err := rl.Backend.TakeToken(r.Context(), rl.Name+":"+keyType+":"+key, rl.Rate, rl.Burst)
+		if err != nil {
+			// Fail open, the limiter must not take the service down
+			log.Error(err)
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !ok {
+			counter.With(prometheus.Labels{"route": rl.Name, "key_type": keyType}).Inc()
+
+			retry := int(math.Ceil(wait.Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			utils.JSONErrorResponse(w, errors.ErrRateLimit)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Allow takes a token from the bucket of the key. It's used by handlers to throttle side effects like
+// outgoing emails which aren't tied to the request's address or identity field. Fails open like Handler
+func (rl *RateLimit) Allow(ctx context.Context, key string) bool {
+	ok, _, err := rl.Backend.TakeToken(ctx, rl.Name+":"+RateLimitKeyIdentity+":"+strings.ToLower(key), rl.Rate, rl.Burst)
+	if err != nil {
+		log.Error(err)
+		return true
+	}
+
+	if !ok {
+		rateLimitRejections().With(prometheus.Labels{"route": rl.Name, "key_type": RateLimitKeyIdentity}).Inc()
+	}
+
+	return ok
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryRateLimit is a process local rate limit backend
+type MemoryRateLimit struct {
+	buckets map[string]*tokenBucket
+	mtx     sync.Mutex
+	purged  time.Time
+	now     func() time.Time // Testing only
+}
+
+func NewMemoryRateLimit() *MemoryRateLimit {
+	return &MemoryRateLimit{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+const memoryRateLimitPurgeInterval = time.Minute
+
+// TakeToken implements RateLimitBackend
+func (m *MemoryRateLimit) TakeToken(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := m.now()
+
+	// Drop idle buckets which are full by now anyway
+	if now.Sub(m.purged) > memoryRateLimitPurgeInterval {
+		for k, b := range m.buckets {
+			if now.Sub(b.updated) > memoryRateLimitPurgeInterval && b.tokens+now.Sub(b.updated).Seconds()*rate >= float64(burst) {
+				delete(m.buckets, k)
+			}
+		}
+		m.purged = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+	}
+
+	b.tokens--
+	return true, 0, nil
+}
+
+var _ RateLimitBackend = &MemoryRateLimit{}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemoryRateLimit()
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := m.TakeToken(context.Background(), "k", 1, 3); !ok {
+			t.Fatalf("request %d rejected within burst", i)
+		}
+	}
+
+	ok, wait, _ := m.TakeToken(context.Background(), "k", 1, 3)
+	if ok {
+		t.Fatal("request accepted with empty bucket")
+	}
+	if wait != time.Second {
+		t.Errorf("expected 1s wait, got %v", wait)
+	}
+
+	if ok, _, _ := m.TakeToken(context.Background(), "other", 1, 3); !ok {
+		t.Error("buckets are not independent")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _, _ := m.TakeToken(context.Background(), "k", 1, 3); !ok {
+		t.Error("bucket is not refilled")
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	rl := &RateLimit{
+		Name:    "login",
+		Rate:    0.1,
+		Burst:   1,
+		KeyType: RateLimitKeyIdentity,
+		Backend: NewMemoryRateLimit(),
+	}
+
+	var body string
+	h := rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = r.FormValue("email")
+	}))
+
+	do := func(email string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/login", strings.NewReader("email="+email))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("user@example.com"); w.Code != http.StatusOK || body != "user@example.com" {
+		t.Fatalf("first request: status %d, body %q", w.Code, body)
+	}
+
+	w := do("User@example.com")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "10" {
+		t.Errorf("unexpected Retry-After: %q", w.Header().Get("Retry-After"))
+	}
+
+	if w := do("other@example.com"); w.Code != http.StatusOK {
+		t.Errorf("other identity: expected 200, got %d", w.Code)
+	}
+}
+
+func TestRateLimitAllow(t *testing.T) {
+	rl := &RateLimit{Name: "resend", Rate: 0.001, Burst: 1, Backend: NewMemoryRateLimit()}
+	ctx := context.Background()
+
+	if !rl.Allow(ctx, "user@example.com") {
+		t.Fatal("first request rejected")
+	}
+
+	if rl.Allow(ctx, "User@Example.com") {
+		t.Error("keys are case sensitive")
+	}
+
+	if !rl.Allow(ctx, "other@example.com") {
+		t.Error("keys are not independent")
+	}
+}
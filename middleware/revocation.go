@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Revocation rejects tokens whose jti is in the revocation list. Every issued token has a jti,
+// tokens without one predate revocation support and can't be revoked so they are rejected too
+type Revocation struct {
+	Storage storage.RevocationStorage
+}
+
+func (rv *Revocation) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(TokenContextKey).(*jwt.Token)
+		if !ok {
+			utils.JSONError(w, "", errors.CodeUnauthorized)
+			return
+		}
+
+		claims := token.Claims.(jwt.MapClaims)
+
+		jti, ok := claims["jti"].(string)
+		if !ok {
+			utils.JSONErrorResponse(w, errors.ErrInvalidToken)
+			return
+		}
+
+		id, err := uuid.FromString(jti)
+		if err != nil {
+			utils.JSONError(w, "", errors.CodeUnauthorized)
+			return
+		}
+
+		revoked, err := rv.Storage.IsTokenRevoked(r.Context(), id)
+		if err != nil {
+			log.Errorln(err)
+			utils.JSONErrorResponse(w, err)
+			return
+		}
+
+		if revoked {
+			utils.JSONErrorResponse(w, errors.ErrTokenRevoked)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
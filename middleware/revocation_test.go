@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestRevocationWithoutJTI(t *testing.T) {
+	rv := Revocation{}
+	h := rv.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("token without jti accepted")
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), TokenContextKey, &jwt.Token{Claims: jwt.MapClaims{"sub": "1"}}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
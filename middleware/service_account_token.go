@@ -6,6 +6,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/rbac"
 	"github.com/ecadlabs/auth/storage"
 	"github.com/ecadlabs/auth/utils"
 	uuid "github.com/satori/go.uuid"
@@ -59,6 +60,11 @@ func (s *ServiceAPI) Handler(h http.Handler) http.Handler {
 			return
 		}
 
+		if key.Expired() {
+			utils.JSONErrorResponse(w, errors.ErrKeyExpired)
+			return
+		}
+
 		membership, err := s.Storage.GetMembership(r.Context(), key.TenantID, uid)
 		if err != nil {
 			log.Errorln(err)
@@ -66,7 +72,11 @@ func (s *ServiceAPI) Handler(h http.Handler) http.Handler {
 			return
 		}
 
-		req := r.WithContext(context.WithValue(r.Context(), MembershipContextKey, membership))
+		// Narrow the membership down to the key scope
+		membership.Roles = key.ScopeRoles(membership.Roles)
+
+		ctx := rbac.WithScope(r.Context(), key.Permissions)
+		req := r.WithContext(context.WithValue(ctx, MembershipContextKey, membership))
 		h.ServeHTTP(w, req)
 	})
 }
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/storage"
+	"github.com/ecadlabs/auth/utils"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Session rejects tokens which belong to revoked or expired sessions. Only API key and IP login tokens
+// may come without a session
+type Session struct {
+	Storage   storage.SessionStorage
+	Namespace string
+}
+
+func (s *Session) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(TokenContextKey).(*jwt.Token)
+		if !ok {
+			utils.JSONError(w, "", errors.CodeUnauthorized)
+			return
+		}
+
+		claims := token.Claims.(jwt.MapClaims)
+
+		sid, ok := claims["sid"].(string)
+		if !ok {
+			_, key := claims[utils.NSClaim(s.Namespace, "api_key")]
+			_, addr := claims[utils.NSClaim(s.Namespace, "address")]
+			if key || addr {
+				// Sessionless token (API key or IP login)
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			utils.JSONErrorResponse(w, errors.ErrInvalidToken)
+			return
+		}
+
+		id, err := uuid.FromString(sid)
+		if err != nil {
+			utils.JSONError(w, "", errors.CodeUnauthorized)
+			return
+		}
+
+		session, err := s.Storage.GetSession(r.Context(), id)
+		if err != nil {
+			if err != errors.ErrSessionNotFound {
+				log.Errorln(err)
+				utils.JSONErrorResponse(w, err)
+				return
+			}
+		} else if session.Active() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		utils.JSONErrorResponse(w, errors.ErrSessionRevoked)
+	})
+}
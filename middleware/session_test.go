@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestSessionlessTokens(t *testing.T) {
+	s := Session{Namespace: "com.example"}
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	cases := []struct {
+		claims jwt.MapClaims
+		status int
+	}{
+		{claims: jwt.MapClaims{"sub": "1", "com.example.api_key": "2"}, status: http.StatusOK},
+		{claims: jwt.MapClaims{"sub": "1", "com.example.address": "127.0.0.1"}, status: http.StatusOK},
+		{claims: jwt.MapClaims{"sub": "1"}, status: http.StatusUnauthorized},
+		{claims: jwt.MapClaims{"sub": "1", "act": map[string]interface{}{"sub": "2"}}, status: http.StatusUnauthorized},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), TokenContextKey, &jwt.Token{Claims: c.claims}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("%d: expected %d, got %d", i, c.status, w.Code)
+		}
+	}
+}
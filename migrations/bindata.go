@@ -24,8 +24,46 @@
 // data/1_add_users_table.up.sql
 // data/20_service_accounts_ip_validation.down.sql
 // data/20_service_accounts_ip_validation.up.sql
+// data/21_oauth_clients.down.sql
+// data/21_oauth_clients.up.sql
+// data/22_api_key_secrets.down.sql
+// data/22_api_key_secrets.up.sql
+// data/23_refresh_tokens.down.sql
+// data/23_refresh_tokens.up.sql
+// data/24_sessions.down.sql
+// data/24_sessions.up.sql
+// data/25_revoked_tokens.down.sql
+// data/25_revoked_tokens.up.sql
+// data/26_api_key_scope.down.sql
+// data/26_api_key_scope.up.sql
+// data/27_mfa_totp.down.sql
+// data/27_mfa_totp.up.sql
+// data/28_webauthn_credentials.down.sql
+// data/28_webauthn_credentials.up.sql
+// data/29_tenant_mfa_policy.down.sql
+// data/29_tenant_mfa_policy.up.sql
 // data/2_add_roles_table.down.sql
 // data/2_add_roles_table.up.sql
+// data/30_login_failures.down.sql
+// data/30_login_failures.up.sql
+// data/31_rate_limit_buckets.down.sql
+// data/31_rate_limit_buckets.up.sql
+// data/32_password_history.down.sql
+// data/32_password_history.up.sql
+// data/33_add_login_gen_column.down.sql
+// data/33_add_login_gen_column.up.sql
+// data/34_add_log_actor_column.down.sql
+// data/34_add_log_actor_column.up.sql
+// data/35_oidc_connections.down.sql
+// data/35_oidc_connections.up.sql
+// data/36_saml_connections.down.sql
+// data/36_saml_connections.up.sql
+// data/37_scim.down.sql
+// data/37_scim.up.sql
+// data/38_federated_identities.down.sql
+// data/38_federated_identities.up.sql
+// data/39_provisioned_by.down.sql
+// data/39_provisioned_by.up.sql
 // data/3_add_log_table.down.sql
 // data/3_add_log_table.up.sql
 // data/4_not_null.down.sql
@@ -587,6 +625,366 @@ func _20_service_accounts_ip_validationUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __21_oauth_clientsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xc8\x4f\x2c\x2d\xc9\x88\x4f\xce\x4f\x49\x2d\xb6\xc6\x22\x9e\x93\x99\x9a\x57\x52\x6c\xcd\xc5\xe5\xec\xef\xeb\xeb\x19\x62\xcd\x05\x18\x00\x3d\xb8\xfe\x12\x43\x00\x00\x00")
+
+func _21_oauth_clientsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__21_oauth_clientsDownSql,
+		"21_oauth_clients.down.sql",
+	)
+}
+
+func _21_oauth_clientsDownSql() (*asset, error) {
+	bytes, err := _21_oauth_clientsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "21_oauth_clients.down.sql", size: 67, mode: os.FileMode(420), modTime: time.Unix(1792262478, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __21_oauth_clientsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x94\x92\xd1\x6e\xb2\x40\x10\x85\xef\x79\x8a\xb9\x53\x93\xff\x0d\xbc\x5a\x61\xfc\xbb\x29\x2c\x06\x97\xa8\x6d\x9a\x0d\x65\xa7\x65\x13\x01\x03\x98\x98\x34\x7d\xf7\x46\xd8\x56\xac\x34\xc4\x3b\xd8\xf9\xce\xce\xce\x99\xb3\xc0\xff\x5c\xcc\x1d\xc7\x8d\x90\x49\x04\xc9\x16\x3e\x42\x99\x1c\x9b\x4c\xa5\x7b\x43\x45\x53\x4f\x1d\x00\x00\xa3\x41\xe2\x56\xc2\x2a\xe2\x01\x8b\x76\xf0\x88\xbb\x7f\x6d\xa1\x48\x72\xea\x4a\x22\x94\x20\x62\xdf\x07\x0f\x97\x2c\xf6\x25\x4c\x26\x1d\x52\x53\x5a\x51\xa3\xb2\xa4\xce\x46\xc8\x8a\xb4\xa9\x28\x6d\xd4\xb1\x32\x75\xcb\x3e\xbf\x0c\xd0\x1f\x9f\x96\x4f\xb4\x26\x0d\x92\x07\xb8\x96\x2c\x58\xc1\x86\xcb\x87\xf6\x17\x9e\x42\x81\xb7\x4a\x11\x6e\xa6\xb3\x4e\x9a\x97\xda\xbc\x99\xfb\xd5\xce\xec\x0f\xb7\x4a\x4d\xd6\xab\xf3\x67\x6f\xda\x1b\xcb\x3a\x63\xd5\xb7\xa5\x3f\x7d\x22\x5c\x62\x84\xc2\xc5\xf5\xaf\x0d\x18\x3d\x83\x50\x80\x87\x3e\x4a\x04\x97\xad\x5d\xe6\xe1\xf9\x24\x5e\x79\xec\x72\x62\x07\xa3\xfc\x95\xaa\x3a\x33\x87\x73\x87\x38\xe6\xde\x60\x87\x0b\x76\xdf\xf5\xfd\x15\x5d\xbf\xdf\x2e\x3b\x2d\x0f\x63\x81\x28\xca\x22\x1d\x63\x5a\x13\xd3\x2c\xd9\xef\xa9\x78\xa7\xa1\x4e\xd7\x84\xca\xa9\xc9\x4a\x3d\x04\xb6\x5e\x36\x26\xa7\x7b\x77\xdd\xc9\xe9\x74\x30\x15\xd5\xe3\xe2\x7e\x34\xb8\xf0\x70\xdb\x8f\x86\xb2\xd7\x28\xa3\x4f\x10\x8a\xab\xd4\xd8\x52\x2b\x0f\x83\x80\xcb\xb9\xf3\x35\x00\xea\xb2\xc5\x14\x98\x03\x00\x00")
+
+func _21_oauth_clientsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__21_oauth_clientsUpSql,
+		"21_oauth_clients.up.sql",
+	)
+}
+
+func _21_oauth_clientsUpSql() (*asset, error) {
+	bytes, err := _21_oauth_clientsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "21_oauth_clients.up.sql", size: 920, mode: os.FileMode(420), modTime: time.Unix(1792262478, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __22_api_key_secretsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x4e\x2d\x2a\xcb\x4c\x4e\x8d\x4f\x4c\x4e\xce\x2f\xcd\x2b\x89\xcf\x4e\xad\x2c\x56\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\x28\x4e\x4d\x2e\x4a\x2d\x89\xcf\x48\x2c\xce\xb0\xe6\x02\x0c\x00\x78\xf1\x5c\xe4\x3a\x00\x00\x00")
+
+func _22_api_key_secretsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__22_api_key_secretsDownSql,
+		"22_api_key_secrets.down.sql",
+	)
+}
+
+func _22_api_key_secretsDownSql() (*asset, error) {
+	bytes, err := _22_api_key_secretsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "22_api_key_secrets.down.sql", size: 58, mode: os.FileMode(420), modTime: time.Unix(1792262606, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __22_api_key_secretsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x04\xc0\xc1\x0a\x02\x20\x0c\x06\xe0\x7b\x4f\xf1\xdf\x7c\x88\x4e\x2b\xd7\x69\x29\xc4\x84\x6e\x22\x63\x60\x04\x05\xce\x82\xde\xbe\x8f\x44\xf9\x06\xa5\x93\x30\xc2\xd7\xf7\x61\xde\x87\xd9\xfb\xf3\xda\xfd\xe9\xbf\x00\xe5\x8c\x73\x95\x76\x2d\x08\xb7\xe5\xbb\xcf\x11\x13\xca\x77\x45\xa9\x8a\xd2\x44\x90\xf9\x42\x4d\x14\x29\x1d\x0f\xff\x01\x00\xe9\x2d\x1e\xe1\x52\x00\x00\x00")
+
+func _22_api_key_secretsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__22_api_key_secretsUpSql,
+		"22_api_key_secrets.up.sql",
+	)
+}
+
+func _22_api_key_secretsUpSql() (*asset, error) {
+	bytes, err := _22_api_key_secretsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "22_api_key_secrets.up.sql", size: 82, mode: os.FileMode(420), modTime: time.Unix(1792262606, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __23_refresh_tokensDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\x4a\x4d\x2b\x4a\x2d\xce\x88\x2f\xc9\xcf\x4e\xcd\x2b\xb6\xe6\x02\x0c\x00\x36\xd4\x70\xde\x1b\x00\x00\x00")
+
+func _23_refresh_tokensDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__23_refresh_tokensDownSql,
+		"23_refresh_tokens.down.sql",
+	)
+}
+
+func _23_refresh_tokensDownSql() (*asset, error) {
+	bytes, err := _23_refresh_tokensDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "23_refresh_tokens.down.sql", size: 27, mode: os.FileMode(420), modTime: time.Unix(1792262666, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __23_refresh_tokensUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8c\x92\xcd\x8e\xe2\x30\x0c\x80\xef\x7d\x0a\xdf\xa0\xd2\x1e\xf7\xc6\x29\xb4\x66\x37\xda\x36\x65\xdb\x44\xc0\x5c\xaa\x0e\x31\xd3\x08\xfa\xa3\x04\x10\xf3\xf6\x23\x5a\x7e\x66\x40\x0c\x73\xac\xfd\xf9\xab\x1d\x7b\x8c\x7f\xb8\x18\x79\x5e\x90\x22\x93\x08\x92\x8d\x23\x04\x4b\x2b\x4b\xae\xcc\xb7\xcd\x9a\x6a\x37\xf4\x00\x00\x8c\x06\xa5\x78\x08\x22\x91\x20\x54\x14\xc1\x34\xe5\x31\x4b\x17\xf0\x0f\x17\x10\xe2\x84\xa9\x48\xc2\x6e\x67\x74\xfe\x46\x35\xd9\x62\x4b\xf9\xfe\xf7\xd0\xff\xd5\x15\x77\xa2\xbc\x2c\x5c\x09\x12\xe7\xf2\x2a\x51\x82\xff\x57\xd8\x43\xab\xa2\x32\x9b\xf7\xfc\xf6\x47\x7d\xb2\xa2\xea\x95\xac\x2b\x4d\x7b\x07\x40\x8a\x13\x4c\x51\x04\x98\x7d\xc2\x86\x46\xfb\x90\x08\x08\x31\x42\x89\x10\xb0\x2c\x60\x21\x1e\x23\x6a\x1a\xb2\x6b\xa4\xd7\x2f\x37\x86\xea\xed\x51\xfd\xb5\xbf\xf3\x60\x83\x41\xcf\xb9\x65\xd3\xd2\x13\xa6\x25\x5b\x19\xe7\x4c\x53\x3b\x18\x27\x49\x84\x4c\xdc\xc3\x32\x3d\x8f\xbd\x73\xa4\x1f\x73\x13\x16\x65\x27\xd0\xd2\xbe\x59\xff\x90\x2d\xb4\x26\x0d\x92\xc7\x98\x49\x16\x4f\x61\xc6\xe5\xdf\xee\x13\x5e\x12\x81\xf7\xa5\x22\x99\x9d\x77\x45\x87\xd6\x58\x72\xcf\x8b\x3d\xff\x7a\x37\x5c\x84\x38\xbf\xb9\x9b\xfc\xb2\xd0\xc3\xf1\xd9\x6f\x8e\xea\x92\xf4\x47\xdf\x4a\x4e\xed\x3c\xb0\x9c\xb2\x5d\x27\x49\x1c\x73\x39\xf2\x3e\x06\x00\x11\xa8\x8b\xe2\xd2\x02\x00\x00")
+
+func _23_refresh_tokensUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__23_refresh_tokensUpSql,
+		"23_refresh_tokens.up.sql",
+	)
+}
+
+func _23_refresh_tokensUpSql() (*asset, error) {
+	bytes, err := _23_refresh_tokensUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "23_refresh_tokens.up.sql", size: 722, mode: os.FileMode(420), modTime: time.Unix(1792262666, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __24_sessionsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x7c\x8e\xcd\x6a\x86\x30\x10\x45\xf7\x79\x8a\x79\x8f\xac\x46\x33\x16\x61\x32\x53\xf2\x8d\xeb\x50\x30\xd2\x60\xab\x60\x4a\xc1\xb7\x2f\xf4\x87\x82\x0b\xd7\x87\x73\xee\xed\xe8\x69\x14\xef\x5c\x20\x26\x23\x18\x92\x46\x38\xca\x72\x94\xf6\x9a\x3f\xf6\xb5\x6c\xcd\x3b\x64\xa3\x04\x86\x1d\xd3\x85\x41\x48\xfa\x0c\xbd\xca\xc3\x12\x8e\x62\x17\x9c\x5b\x69\xad\xee\x5b\xae\x73\x5e\xd6\x72\xde\xa6\x12\x09\x46\x82\x5e\x79\x8a\x02\xff\x26\x98\xc2\xf2\xf2\x5e\xdf\xce\x5c\xe7\xdb\x02\x86\xf0\xa7\x1f\xe5\x73\x5f\xcb\x0c\x9d\x2a\x13\x0a\x88\x1a\xc8\xc4\x0c\x81\x06\x9c\xd8\x60\x40\x7e\x90\x77\xdf\xff\x7f\x62\xbf\x8b\xcd\x3b\xd7\x6b\x8c\xa3\x79\xf7\x35\x00\x89\x5c\xb2\x5c\x1d\x01\x00\x00")
+
+func _24_sessionsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__24_sessionsDownSql,
+		"24_sessions.down.sql",
+	)
+}
+
+func _24_sessionsDownSql() (*asset, error) {
+	bytes, err := _24_sessionsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "24_sessions.down.sql", size: 285, mode: os.FileMode(420), modTime: time.Unix(1792262760, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __24_sessionsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9c\x92\xcd\x6e\xdb\x30\x10\x84\xef\x7a\x8a\xbd\xc5\x06\x9a\x5b\x6f\x3e\xd1\xe2\x2a\x25\xca\x1f\x83\xa2\x90\xa4\x17\x42\x2d\xd7\x29\xe1\x44\x2e\x44\x39\x48\xde\xbe\x90\xac\x9f\xb4\x06\x92\xa2\x47\x72\xe7\x1b\x2c\x76\x66\x8b\x37\x42\x6f\xb2\x2c\xb7\xc8\x1c\x82\x63\x5b\x89\x90\x28\xa5\x78\x6c\xd2\x2a\x03\x00\x88\x01\xaa\x4a\x70\xd0\xc6\x81\xae\xa4\x84\x9d\x15\x8a\xd9\x7b\xf8\x8a\xf7\xc0\xb1\x60\x95\x74\x70\x3a\xc5\xe0\x1f\xa8\xa1\xb6\xee\xc8\x3f\x7f\x5e\xad\x3f\x0d\xf0\x29\x51\xeb\x2f\x1c\x2c\x16\x68\x51\xe7\x58\x0e\x82\xb4\x8a\x61\x0d\x46\x03\x47\x89\x0e\x21\x67\x65\xce\x38\xf6\x3f\xd5\x8e\xb3\xe5\xe7\x8d\x67\xfd\x40\x4d\x07\x0e\xef\xdc\x62\x3b\x2d\x73\x75\x75\x16\xd6\x21\xb4\x1f\x48\x5a\x7a\x3e\x1e\x28\xc0\xd6\x18\x89\x4c\x5f\x0a\x0b\x26\x4b\x9c\xed\x28\x80\x13\x0a\x4b\xc7\xd4\x0e\x6e\x85\xfb\x32\x3c\xe1\x9b\xd1\x78\x89\x6a\x73\x3b\x9d\xe1\xb1\x4e\x9d\x4f\x44\xcd\xff\xe1\xf4\xf2\x2b\xb6\x94\x3e\x86\xb3\xf5\x92\xa5\xd0\x1c\xef\xe6\x2c\xfd\x98\x84\x8f\xe1\xa5\x3f\xec\x9c\xf1\xf8\xdf\x83\xd7\xd7\x60\x69\xdf\x52\xfa\x09\xdd\xf1\x40\x0d\xec\xeb\xa7\xf8\xf8\x0a\xdf\xe9\xc7\xf1\x89\x12\xd4\x13\x95\x8d\x41\x15\xd6\x28\x68\xcf\x88\x1f\x90\xb4\xc9\x98\x74\x68\xc7\x26\xfd\x39\x03\x6e\xcd\x0e\x72\x23\x2b\xa5\xa7\xcb\xbf\xab\xb7\xa8\x99\xc2\x89\x38\x6f\xd3\xb7\xc9\x99\x69\x13\x1f\xdf\x77\x60\x9c\x43\x6e\x74\xe9\x2c\x13\xda\xfd\x35\xf5\x8b\x89\xdf\x1f\xe8\x15\x0a\x63\x51\xdc\xe8\xa1\xd9\xab\x65\xb8\x7e\x5b\xd8\xf9\x70\xff\xd8\xd9\x3e\x10\xa3\x94\x70\x9b\xec\xf7\x00\x70\x47\x4a\x89\x6d\x03\x00\x00")
+
+func _24_sessionsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__24_sessionsUpSql,
+		"24_sessions.up.sql",
+	)
+}
+
+func _24_sessionsUpSql() (*asset, error) {
+	bytes, err := _24_sessionsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "24_sessions.up.sql", size: 877, mode: os.FileMode(420), modTime: time.Unix(1792262760, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __25_revoked_tokensDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\x4a\x2d\xcb\xcf\x4e\x4d\x89\x2f\xc9\xcf\x4e\xcd\x2b\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x86\x43\xbc\x0d\x2c\x00\x00\x00")
+
+func _25_revoked_tokensDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__25_revoked_tokensDownSql,
+		"25_revoked_tokens.down.sql",
+	)
+}
+
+func _25_revoked_tokensDownSql() (*asset, error) {
+	bytes, err := _25_revoked_tokensDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "25_revoked_tokens.down.sql", size: 44, mode: os.FileMode(420), modTime: time.Unix(1792263073, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __25_revoked_tokensUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x74\x8f\xcf\x4a\x87\x40\x14\x85\xf7\xf3\x14\x67\xf9\x13\x7a\x03\x57\x63\xde\x6a\x68\xfe\x88\xdd\xc1\x6c\x33\x04\x33\x8b\x49\xc8\x50\x0b\x1f\x3f\x50\x41\x12\x5a\x5e\xce\x39\x1f\xdf\xad\xe8\x51\xd9\x52\x88\xfb\x96\x24\x13\x58\x56\x9a\x30\xa5\x9f\x71\x48\x31\x2c\xe3\x90\x3e\xe7\x9b\x00\x80\x8f\x25\xc3\x7b\x55\xc3\x3a\x86\xf5\x5a\xa3\x69\x95\x91\x6d\x8f\x67\xea\xef\xb6\xca\xf7\x9c\xa6\x90\xe3\xdf\xda\x1e\xbd\xc7\x98\x22\x58\x19\x7a\x61\x69\x1a\x74\x8a\x9f\xb6\x13\x6f\xce\xd2\xc9\xac\xe9\x41\x7a\xcd\xb0\xae\xbb\x15\xfb\x34\xad\x5f\x79\x4a\xf3\xbf\x63\x51\x9c\xfa\xca\xd6\xf4\x7a\xd1\x0f\x07\x20\xe4\xb8\xc2\xd9\xeb\x73\x47\xba\x41\x9c\x31\x8a\x4b\xf1\x3b\x00\xa5\xf0\x5a\xcf\x14\x01\x00\x00")
+
+func _25_revoked_tokensUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__25_revoked_tokensUpSql,
+		"25_revoked_tokens.up.sql",
+	)
+}
+
+func _25_revoked_tokensUpSql() (*asset, error) {
+	bytes, err := _25_revoked_tokensUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "25_revoked_tokens.up.sql", size: 276, mode: os.FileMode(420), modTime: time.Unix(1792263073, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __26_api_key_scopeDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x6c\x8b\x4b\x0e\x82\x30\x10\x86\xf7\x73\x8a\x39\x00\x37\xe8\x0a\xb0\x31\x24\x2d\x35\xa4\xae\x1b\xac\xb3\x68\xac\x94\xf4\x07\xa3\xb7\xf7\x00\xb0\xfd\x1e\x9d\xbe\x0e\xa3\x22\x6a\x8d\xd7\x13\xfb\xb6\x33\x9a\x21\xf5\x93\xa2\x84\x39\xc6\xb2\x2f\x5b\x78\xc9\x0f\xc4\xcc\x7c\x99\xdc\x8d\x7b\x67\xee\x76\xe4\x3c\x3f\x24\x37\x07\x2c\xdf\x35\x55\x41\x73\xd2\x63\x0b\x3b\xe4\x79\x54\xb5\xe4\xb3\x63\x95\xfa\x4e\x40\x2a\x0b\x14\x51\xef\xac\x1d\xbc\xa2\xff\x00\xb7\x93\xff\x7e\xb1\x00\x00\x00")
+
+func _26_api_key_scopeDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__26_api_key_scopeDownSql,
+		"26_api_key_scope.down.sql",
+	)
+}
+
+func _26_api_key_scopeDownSql() (*asset, error) {
+	bytes, err := _26_api_key_scopeDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "26_api_key_scope.down.sql", size: 177, mode: os.FileMode(420), modTime: time.Unix(1792263200, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __26_api_key_scopeUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8c\x8e\xcf\x4a\x87\x40\x14\x85\xf7\xf3\x14\x67\xf7\xdb\xf8\x06\xae\x46\xbd\xd5\xc0\xfc\x89\xba\x92\x14\x21\x66\x77\x31\x64\x2a\x33\x1a\x45\xf4\xee\x51\xcb\x8c\x68\x79\xe0\x7c\xe7\x7c\x15\x9d\x1b\x5f\x2a\xa5\x2d\xd3\x15\x58\x57\x96\x90\x25\xbd\xc4\x51\xfa\x61\x1c\x97\x7d\xde\xfa\x27\x79\xcb\x0a\x00\x74\xd3\xa0\x0e\xb6\x75\x1e\xd3\xf0\x20\x13\x98\x3a\x86\x0f\x0c\xdf\x5a\x8b\x86\xce\x74\x6b\x19\xa7\x53\xf1\xb3\x2e\xaf\x6b\x4c\x92\xc1\xc6\xd1\x35\x6b\x77\x89\x1b\xc3\x17\xdf\x11\xb7\xc1\x53\x71\xdc\xcf\x5b\xbf\x67\x79\xfc\x3f\x92\x96\xe9\xeb\x81\x3a\xbe\xbb\xff\x45\xea\xfd\xe3\xa8\xb5\x4a\x7a\x8e\x39\xc7\x65\xfe\x1b\x2c\x95\xaa\x83\x73\x86\x4b\xf5\x39\x00\xc1\x74\x4d\xc3\x31\x01\x00\x00")
+
+func _26_api_key_scopeUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__26_api_key_scopeUpSql,
+		"26_api_key_scope.up.sql",
+	)
+}
+
+func _26_api_key_scopeUpSql() (*asset, error) {
+	bytes, err := _26_api_key_scopeUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "26_api_key_scope.up.sql", size: 305, mode: os.FileMode(420), modTime: time.Unix(1792263200, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __27_mfa_totpDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xc8\x4d\x4b\x8c\x2f\x4a\x4d\xce\x2f\x4b\x2d\xaa\x8c\x4f\xce\x4f\x49\x2d\xb6\x46\x97\x2e\xc9\x2f\x29\xb0\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\xe9\x84\x05\x43\x45\x00\x00\x00")
+
+func _27_mfa_totpDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__27_mfa_totpDownSql,
+		"27_mfa_totp.down.sql",
+	)
+}
+
+func _27_mfa_totpDownSql() (*asset, error) {
+	bytes, err := _27_mfa_totpDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "27_mfa_totp.down.sql", size: 69, mode: os.FileMode(420), modTime: time.Unix(1792263343, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __27_mfa_totpUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa4\x90\x3d\x6f\xf2\x30\x14\x85\xf7\xfc\x8a\x3b\x26\x12\xc3\x3b\xbc\x1b\x93\x93\x5c\xa8\x55\xc7\xa6\xc9\xb5\x28\x5d\xac\x28\xbe\x94\x48\x85\xa0\x38\x41\xea\xbf\xaf\xf8\x68\x19\x40\xa8\x52\x47\x1f\x1f\x3f\x3e\x7a\x52\x9c\x4b\x3d\x8d\xa2\xac\x44\x41\x08\x24\x52\x85\xb0\x5d\xd7\x6e\xe8\x86\x7d\x1c\x01\x00\x8c\x81\x7b\xd7\x7a\xb0\x56\xe6\xa0\x0d\x81\xb6\x4a\xc1\xa2\x94\x85\x28\x57\xf0\x8c\x2b\x28\x71\x86\x25\xea\x0c\xab\x53\x39\xc4\xad\x4f\xc0\x68\xc8\x51\x21\x21\x64\xa2\xca\x44\x8e\xc7\xc4\x2e\x72\x71\x4d\x26\x27\x7e\xe0\xa6\xe7\x01\x08\x5f\xe9\x07\x7f\xbe\x69\xba\xdd\xba\xed\xb7\xec\x21\x35\x46\xa1\xd0\xd7\xef\x73\x9c\x09\xab\x08\x66\x42\x55\x17\xce\x47\x1d\x06\x17\x06\xde\x43\x2a\xe7\x52\xd3\x6d\xf9\xdf\xb9\x58\x7b\xcf\x1e\x48\x16\x58\x91\x28\x16\xb0\x94\xf4\x74\x3a\xc2\x9b\xd1\x78\xfb\x4c\x9b\x65\x9c\x44\xc9\x3d\x4b\x3d\x37\xdd\x81\xfb\x4f\xd7\x74\x9e\xc3\xd9\xd7\x43\x55\xdf\xcc\x71\x6c\xbd\x7b\xe7\x1d\xf7\xf5\xc0\xee\xf0\x3f\x4e\x26\x0f\x64\xff\x4d\xf0\x71\x9b\xdb\xd4\x61\x73\xcf\xf1\x18\x7e\xa9\xd7\x6a\xf9\x62\x11\xe2\xcb\xc2\xc9\x15\x7b\x71\x63\x8a\x42\xd2\x34\xfa\x1a\x00\xed\x75\xc6\x4d\x52\x02\x00\x00")
+
+func _27_mfa_totpUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__27_mfa_totpUpSql,
+		"27_mfa_totp.up.sql",
+	)
+}
+
+func _27_mfa_totpUpSql() (*asset, error) {
+	bytes, err := _27_mfa_totpUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "27_mfa_totp.up.sql", size: 594, mode: os.FileMode(420), modTime: time.Unix(1792263343, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __28_webauthn_credentialsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\x4f\x4d\x4a\x2c\x2d\xc9\xc8\x8b\x4f\x2e\x4a\x4d\x49\xcd\x2b\xc9\x4c\xcc\x29\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x1e\x6c\x85\x6d\x32\x00\x00\x00")
+
+func _28_webauthn_credentialsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__28_webauthn_credentialsDownSql,
+		"28_webauthn_credentials.down.sql",
+	)
+}
+
+func _28_webauthn_credentialsDownSql() (*asset, error) {
+	bytes, err := _28_webauthn_credentialsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "28_webauthn_credentials.down.sql", size: 50, mode: os.FileMode(420), modTime: time.Unix(1792263545, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __28_webauthn_credentialsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x7c\x51\xbd\x6e\xf2\x30\x14\xdd\xf3\x14\x77\x03\xa4\x6f\xf8\x86\x6e\x4c\x4e\x7c\xa1\x56\x13\x87\x86\x6b\x01\x5d\x2c\x83\x2d\x6a\x95\xba\x15\x8e\xfb\xf3\xf6\x15\x09\x28\x52\x41\x1d\x7d\x7c\x7e\xec\x73\x72\x9c\x0b\x39\xcd\xb2\xa2\x41\x46\x08\xc4\xf2\x12\xe1\xd3\x6d\x4d\x6a\x9f\x83\xde\x1d\x9d\x75\xa1\xf5\xe6\x10\xc7\x19\x00\x80\xb7\xa0\x94\xe0\x20\x6b\x02\xa9\xca\x12\x16\x8d\xa8\x58\xb3\x81\x07\xdc\x00\xc7\x19\x53\x25\x41\x4a\xde\xea\xbd\x0b\xee\x68\x5a\xa7\x3f\xee\xc6\x93\x7f\x9d\x78\x70\xd3\xde\x42\xbe\x21\x64\x83\x91\x92\xe2\x51\x61\x4f\x4c\xd1\x1d\xf5\x55\x54\x83\x33\x6c\x50\x16\xb8\xec\x08\x71\xec\xed\x04\x6a\x09\x1c\x4b\x24\x84\x82\x2d\x0b\xc6\xf1\x84\xa8\x05\x67\x03\xd2\x7b\xbe\xa7\xed\xc1\xef\xf4\x8b\xfb\xfe\x95\xdc\x5f\x47\xbf\x0f\x7a\xf7\x96\x42\x0b\xb9\x98\x0b\x49\x43\xee\xe5\x5b\xff\x7b\xa6\x31\xfb\x74\x79\x7e\x8f\x04\xf3\xea\x80\x70\x7d\x43\x33\x1a\x9d\x45\xd6\x3a\x0b\x24\x2a\x5c\x12\xab\x16\xb0\x12\x74\xdf\x1d\xe1\xa9\x96\x78\xad\x93\xf5\xea\xd2\xda\xc1\xc4\x56\xa7\xf8\x87\x3c\x9b\x0c\x03\x0a\xc9\x71\x7d\x73\x40\x7d\x6e\xf5\xeb\xd4\xd0\xcd\x85\xcf\x84\xce\xad\xae\x2a\x41\xd3\xec\x67\x00\x69\x3b\x8e\x0d\x1f\x02\x00\x00")
+
+func _28_webauthn_credentialsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__28_webauthn_credentialsUpSql,
+		"28_webauthn_credentials.up.sql",
+	)
+}
+
+func _28_webauthn_credentialsUpSql() (*asset, error) {
+	bytes, err := _28_webauthn_credentialsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "28_webauthn_credentials.up.sql", size: 543, mode: os.FileMode(420), modTime: time.Unix(1792263544, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __29_tenant_mfa_policyDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\xc8\x4f\x2c\x2d\xc9\x88\x4f\xce\x4f\x49\x2d\x56\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\xc8\x4d\x4b\x8c\xcf\x4d\x2d\xc9\xc8\x4f\xb1\x46\x51\x5f\x9c\x5a\x5c\x9c\x99\x9f\x47\x9c\xe2\x92\xd4\xbc\xc4\xbc\x12\x5c\x6a\x8b\x89\x53\x5c\x90\x9f\x93\x99\x5c\x69\xcd\xc5\xe5\xec\xef\xeb\xeb\x19\x62\xcd\x05\x18\x00\xdc\x51\x6d\x48\xc7\x00\x00\x00")
+
+func _29_tenant_mfa_policyDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__29_tenant_mfa_policyDownSql,
+		"29_tenant_mfa_policy.down.sql",
+	)
+}
+
+func _29_tenant_mfa_policyDownSql() (*asset, error) {
+	bytes, err := _29_tenant_mfa_policyDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "29_tenant_mfa_policy.down.sql", size: 199, mode: os.FileMode(420), modTime: time.Unix(1792263743, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __29_tenant_mfa_policyUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x94\xcf\xb1\x4a\xc5\x30\x14\xc6\xf1\x3d\x4f\xf1\x6d\x9d\xee\x13\x64\xca\xbd\x8d\x52\x48\x53\xd0\x14\x04\x91\x12\x92\x94\x16\xda\x9c\xd2\x9c\x0e\x22\xbe\xbb\x20\x2e\x8a\x05\x5d\xcf\xf0\xfb\x9f\xef\xaa\xef\x1b\x2b\x85\x50\xc6\xe9\x07\x38\x75\x35\x1a\x9c\xb2\xcf\x5c\xa0\xea\x1a\xb7\xce\xf4\xad\xc5\x3a\xfa\x61\xa3\x65\x0e\xaf\x70\xfa\xc9\xc1\x76\x0e\xb6\x37\x06\xb5\xbe\x53\xbd\x71\xa8\x68\xe3\x99\xb2\x5f\x2a\xf9\x17\x6b\x4d\x3c\x51\x2c\x9f\xd8\xf3\xcb\x2f\xdc\xdb\x7b\x25\x85\xb8\x5c\xf0\x98\x02\xe5\x88\xd1\x07\xa6\x1d\x47\x49\x11\x4c\x28\xec\x77\x06\x4f\x09\x25\x95\x32\x53\xfe\x16\xfd\xba\x9d\x54\xcf\x16\xfc\xf8\x9c\xfc\xc1\xd3\x10\x28\xa6\xff\x3b\xe2\xd6\xb5\x6d\xe3\xa4\xf8\x18\x00\x56\x33\x1d\xd2\x5f\x01\x00\x00")
+
+func _29_tenant_mfa_policyUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__29_tenant_mfa_policyUpSql,
+		"29_tenant_mfa_policy.up.sql",
+	)
+}
+
+func _29_tenant_mfa_policyUpSql() (*asset, error) {
+	bytes, err := _29_tenant_mfa_policyUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "29_tenant_mfa_policy.up.sql", size: 351, mode: os.FileMode(420), modTime: time.Unix(1792263743, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var __2_add_roles_tableDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\xca\xcf\x49\x2d\xb6\x06\x04\x00\x00\xff\xff\xf9\xdd\xb1\x51\x11\x00\x00\x00")
 
 func _2_add_roles_tableDownSqlBytes() ([]byte, error) {
@@ -627,6 +1025,406 @@ func _2_add_roles_tableUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __30_login_failuresDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xc8\xc9\x4f\xcf\xcc\x8b\x4f\x4b\xcc\xcc\x29\x2d\x4a\x2d\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x9d\x51\x4d\xae\x2c\x00\x00\x00")
+
+func _30_login_failuresDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__30_login_failuresDownSql,
+		"30_login_failures.down.sql",
+	)
+}
+
+func _30_login_failuresDownSql() (*asset, error) {
+	bytes, err := _30_login_failuresDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "30_login_failures.down.sql", size: 44, mode: os.FileMode(420), modTime: time.Unix(1792263872, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __30_login_failuresUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x74\x50\x41\x4e\xc3\x30\x10\xbc\xfb\x15\x73\x4c\xa4\x1e\xb8\xf7\xe4\xd2\xa5\x58\xd8\xeb\xca\x6c\xd5\x96\x8b\x55\x91\x80\xac\x5a\xa9\x44\x5b\x09\x7e\x8f\x92\x10\x88\x02\x1c\x77\x76\x66\x76\x76\x16\xb4\x32\x3c\x57\xea\x36\x90\x16\x82\xe8\x85\x25\xe4\xd3\x6b\x6a\xe2\xcb\x21\xe5\xeb\x5b\x7d\x2e\x14\x00\x1c\x53\x53\x41\x68\x27\x60\x2f\xe0\x8d\xb5\xb3\x1e\xaf\x3f\xfe\x82\x07\x31\x0c\x0b\xad\x28\x7c\xaf\xb1\xa4\x3b\xbd\xb1\x82\x9b\x9e\x98\x0f\xe7\xcb\x70\x0a\x62\x1c\x3d\x8a\x76\x6b\x6c\x8d\xdc\x77\x23\x9e\x3c\xd3\x6f\x35\xfb\x6d\x51\x7e\x39\x9c\x9e\x8f\x75\x15\xaf\xcd\x25\xe5\x7f\x1d\x7a\xea\x3a\x18\xa7\xc3\x1e\x0f\xb4\x47\xd1\x7e\x34\x6b\xf3\x97\xaa\xfc\x69\xc0\xf0\x92\x76\x93\x06\xe2\x38\x64\x4c\xd5\x3b\x3c\x4f\x4b\x1a\x53\x3a\x3b\xef\x9c\x91\xb9\xfa\x1c\x00\x68\x68\x92\xf0\x61\x01\x00\x00")
+
+func _30_login_failuresUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__30_login_failuresUpSql,
+		"30_login_failures.up.sql",
+	)
+}
+
+func _30_login_failuresUpSql() (*asset, error) {
+	bytes, err := _30_login_failuresUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "30_login_failures.up.sql", size: 353, mode: os.FileMode(420), modTime: time.Unix(1792263872, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __31_rate_limit_bucketsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\x4a\x2c\x49\x8d\xcf\xc9\xcc\xcd\x2c\x89\x4f\x2a\x4d\xce\x4e\x2d\x29\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x41\xe9\xc2\xb0\x30\x00\x00\x00")
+
+func _31_rate_limit_bucketsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__31_rate_limit_bucketsDownSql,
+		"31_rate_limit_buckets.down.sql",
+	)
+}
+
+func _31_rate_limit_bucketsDownSql() (*asset, error) {
+	bytes, err := _31_rate_limit_bucketsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "31_rate_limit_buckets.down.sql", size: 48, mode: os.FileMode(420), modTime: time.Unix(1792263985, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __31_rate_limit_bucketsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x6c\x8f\xc1\x4e\xc3\x30\x10\x44\xef\xf9\x8a\x39\xb6\x12\x7f\xd0\x93\xd3\x2c\xc1\xc2\xde\x8d\xdc\xb5\xda\x72\x89\x02\xf1\x21\x4a\xa0\x88\xba\x02\xfe\x1e\x91\x56\xea\xa1\x3d\x8e\xe6\xcd\x48\xaf\xa4\xda\xf2\xaa\x28\xd6\x81\x8c\x12\x22\x3b\xa9\x6b\xaa\xa0\xa6\x74\x84\xaf\x2e\xa7\x76\x1a\xde\x87\xdc\xbe\x9e\xde\xc6\x94\x8f\x8b\x02\x00\xc6\xf4\x0b\xa5\x9d\xa2\x09\xd6\x9b\xb0\xc7\x33\xed\x1f\xe6\x26\x1f\xc6\xf4\x71\x44\x25\xf1\x7f\xdf\x04\x5a\xdb\x8d\x15\x06\x8b\x82\xa3\x73\x67\xaa\x9b\xa6\xc3\x77\xea\x51\x8a\x38\x32\xd7\x16\x15\x3d\x9a\xe8\x14\x1a\x22\x9d\xd1\xd3\x67\xdf\xe5\xd4\x43\xad\xa7\x8d\x1a\xdf\x60\x6b\xf5\x69\x8e\x78\x11\xa6\xdb\x2d\xcb\x76\xb1\x2c\x96\x57\x29\xcb\x15\xed\xee\xb8\xb4\x97\xef\x76\xe8\x7f\x20\x7c\xcf\xf6\x42\xcc\x67\xe2\xbd\xd5\x55\xf1\x37\x00\xdf\xf4\x8b\xed\x32\x01\x00\x00")
+
+func _31_rate_limit_bucketsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__31_rate_limit_bucketsUpSql,
+		"31_rate_limit_buckets.up.sql",
+	)
+}
+
+func _31_rate_limit_bucketsUpSql() (*asset, error) {
+	bytes, err := _31_rate_limit_bucketsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "31_rate_limit_buckets.up.sql", size: 306, mode: os.FileMode(420), modTime: time.Unix(1792263985, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __32_password_historyDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x70\x0b\xf5\x73\x0e\xf1\xf4\xf7\x53\x28\x48\x2c\x2e\x2e\xcf\x2f\x4a\x89\xcf\xc8\x2c\x2e\xc9\x2f\xaa\x8c\x2f\x2d\x48\x49\x2c\x49\x55\x70\x76\x0c\x76\x76\x74\x71\xb5\x86\x28\x0f\x71\x74\xf2\x71\xc5\x50\x6b\xcd\xc5\xe5\xec\xef\xeb\xeb\x19\x62\xcd\x05\x18\x00\x42\x85\x8f\xbe\x5d\x00\x00\x00")
+
+func _32_password_historyDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__32_password_historyDownSql,
+		"32_password_history.down.sql",
+	)
+}
+
+func _32_password_historyDownSql() (*asset, error) {
+	bytes, err := _32_password_historyDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "32_password_history.down.sql", size: 93, mode: os.FileMode(420), modTime: time.Unix(1792264065, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __32_password_historyUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x94\x53\xcf\x6f\x9b\x30\x14\xbe\xf3\x57\x7c\x87\x48\x05\xa9\xdd\x61\xda\x2d\xdb\x24\x17\x3f\x12\x6b\x60\x57\xc6\x2c\xd9\x2e\x11\x0a\x56\x40\xaa\x16\x06\x49\xb3\xfe\xf7\x93\x4d\x1a\x9a\xd2\x1d\xe6\x13\xb6\x1f\xdf\x8f\xe7\xef\xdd\xd3\x42\xc8\x79\x10\xc4\x9a\x98\x21\x18\x76\x9f\x12\xda\xb2\xef\x4f\xfb\xae\xda\xd4\x4d\x7f\xd8\x77\xcf\x61\x00\x00\xc7\xde\x76\x9b\xa6\x42\x51\x08\x0e\xa9\x0c\x64\x91\xa6\xd0\x94\x90\x26\x19\x53\xee\x0b\xfa\xb0\xa9\x22\x28\x09\x4e\x29\x19\x42\xcc\xf2\x98\x71\x72\x27\xc5\x03\x67\xe3\xc9\xad\xc7\x1c\x99\xca\xbe\x86\xa1\xb5\xb9\x20\x0f\x05\x65\x55\xd9\x0a\x46\x64\x94\x1b\x96\x3d\x60\x25\xcc\xd2\x6f\xf1\x53\x49\xba\x14\x83\x53\xc2\x8a\xd4\xfd\xbd\x0a\xa3\x20\x1a\x1d\x09\xc9\x69\x3d\x71\xb4\x39\x9b\xd9\x34\xd5\x1f\xa7\x6d\xe2\xf8\x7c\x7f\x3b\x08\x70\x78\x77\x77\xf8\x66\x6d\x8b\xb6\xb3\x4f\xcd\xfe\xd8\xc3\x49\xb6\x3d\x4e\x75\xb3\xad\xed\x93\xed\x70\x2a\x9f\x71\xa8\xed\x05\x0c\x4d\x8f\x6d\x5d\xfe\xda\xd9\xea\x45\x4d\x52\xc8\xd8\x88\x77\x08\x37\xc7\xb6\x2a\x0f\x36\x8c\xa0\xc9\x14\x5a\xe6\x38\x74\xcd\x6e\x67\x3b\xb0\x1c\xb3\x59\x00\x00\xfe\xad\xfc\x97\x5b\x22\x81\x4a\xf9\x87\xeb\x0e\x7e\xfe\x8a\x9b\x1b\x30\xc9\xdf\xb9\x13\x39\xb8\xc8\x8d\x90\xb1\x41\xa2\x55\x06\x49\xab\x37\x25\x66\x49\x23\x81\x27\x91\x39\x69\x03\x21\x8d\x9a\x48\xc6\xd8\xa4\x2b\x94\x08\xdf\x59\x5a\x50\x8e\xd0\x69\x70\xd7\x13\x2d\xd1\xfc\x8a\xe5\x9c\x15\x2f\x6a\xc2\xb2\x5a\x92\xa6\x4b\xf8\xbe\x60\x00\xf5\x1e\xb7\x87\xa6\xf2\x11\x10\x12\xe1\x15\xa2\x5b\x39\xa5\x14\x9b\xa1\xe8\xbf\xa0\x95\xe6\xa4\x71\xff\xe3\x1c\x3e\x4e\x79\x8c\x54\x64\xc2\xe0\xe3\xa7\x2b\x96\x57\x36\x48\x72\x88\x64\xdc\x0f\xaf\xe8\x5a\x3c\x9c\x91\xe4\xf3\x60\x36\x43\xca\xe4\xa2\x60\x0b\x42\xfb\xd8\xee\xfa\xdf\x8f\xaf\x46\x4f\x8b\xc5\x82\xf4\x34\x19\xe7\x20\x78\x18\x96\x18\xd2\x2f\x93\xa4\x92\x37\xf3\xa3\xe4\x30\x83\xbe\x34\x51\x1a\xc4\xe2\x25\xb4\x5a\x0d\x12\xd6\x14\x17\x86\xf0\xa0\x55\x4c\xbc\xd0\xf4\xef\x10\x3a\x55\x2a\xcb\x84\x99\x07\x7f\x07\x00\x09\x5d\x28\x4e\x21\x04\x00\x00")
+
+func _32_password_historyUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__32_password_historyUpSql,
+		"32_password_history.up.sql",
+	)
+}
+
+func _32_password_historyUpSql() (*asset, error) {
+	bytes, err := _32_password_historyUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "32_password_history.up.sql", size: 1057, mode: os.FileMode(420), modTime: time.Unix(1792264058, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __33_add_login_gen_columnDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x2d\x4e\x2d\x2a\x56\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\xc8\xc9\x4f\xcf\xcc\x8b\x4f\x4f\xcd\xb3\xe6\x02\x0c\x00\x0e\x7a\x54\x01\x29\x00\x00\x00")
+
+func _33_add_login_gen_columnDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__33_add_login_gen_columnDownSql,
+		"33_add_login_gen_column.down.sql",
+	)
+}
+
+func _33_add_login_gen_columnDownSql() (*asset, error) {
+	bytes, err := _33_add_login_gen_columnDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "33_add_login_gen_column.down.sql", size: 41, mode: os.FileMode(420), modTime: time.Unix(1792264701, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __33_add_login_gen_columnUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x2d\x4e\x2d\x2a\x56\x70\x74\x71\x51\x70\xf6\xf7\x09\xf5\xf5\x53\xc8\xc9\x4f\xcf\xcc\x8b\x4f\x4f\xcd\x53\xf0\xf4\x0b\x71\x75\x77\x0d\x52\xf0\xf3\x0f\x51\xf0\x0b\xf5\xf1\x51\x70\x71\x75\x73\x0c\xf5\x09\x51\x30\xb0\xe6\x02\x0c\x00\x0e\x89\xc2\xf1\x43\x00\x00\x00")
+
+func _33_add_login_gen_columnUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__33_add_login_gen_columnUpSql,
+		"33_add_login_gen_column.up.sql",
+	)
+}
+
+func _33_add_login_gen_columnUpSql() (*asset, error) {
+	bytes, err := _33_add_login_gen_columnUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "33_add_login_gen_column.up.sql", size: 67, mode: os.FileMode(420), modTime: time.Unix(1792264701, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __34_add_log_actor_columnDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\xc8\xc9\x4f\x57\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\x48\x4c\x2e\xc9\x2f\x8a\xcf\x4c\xb1\xe6\x02\x0c\x00\x97\x92\xcd\x04\x26\x00\x00\x00")
+
+func _34_add_log_actor_columnDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__34_add_log_actor_columnDownSql,
+		"34_add_log_actor_column.down.sql",
+	)
+}
+
+func _34_add_log_actor_columnDownSql() (*asset, error) {
+	bytes, err := _34_add_log_actor_columnDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "34_add_log_actor_column.down.sql", size: 38, mode: os.FileMode(420), modTime: time.Unix(1792264835, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __34_add_log_actor_columnUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\xc8\xc9\x4f\x57\x70\x74\x71\x51\x70\xf6\xf7\x09\xf5\xf5\x53\x48\x4c\x2e\xc9\x2f\x8a\xcf\x4c\x51\x08\x0d\xf5\x74\xb1\xe6\x02\x0c\x00\xe4\x21\xb9\xe3\x2a\x00\x00\x00")
+
+func _34_add_log_actor_columnUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__34_add_log_actor_columnUpSql,
+		"34_add_log_actor_column.up.sql",
+	)
+}
+
+func _34_add_log_actor_columnUpSql() (*asset, error) {
+	bytes, err := _34_add_log_actor_columnUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "34_add_log_actor_column.up.sql", size: 42, mode: os.FileMode(420), modTime: time.Unix(1792264835, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __35_oidc_connectionsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xc8\xcf\x4c\x49\x8e\x4f\xce\xcf\xcb\x4b\x4d\x2e\xc9\xcc\xcf\x2b\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x42\xa0\x66\x54\x2e\x00\x00\x00")
+
+func _35_oidc_connectionsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__35_oidc_connectionsDownSql,
+		"35_oidc_connections.down.sql",
+	)
+}
+
+func _35_oidc_connectionsDownSql() (*asset, error) {
+	bytes, err := _35_oidc_connectionsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "35_oidc_connections.down.sql", size: 46, mode: os.FileMode(420), modTime: time.Unix(1792265264, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __35_oidc_connectionsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x94\x93\x4f\x6f\xdb\x30\x0c\xc5\xef\xfe\x14\xbc\x35\x01\x7a\xdc\xad\x27\x25\x66\x36\x63\xb6\x1c\x38\x32\xda\x6e\x18\x04\xc3\x62\x0a\x0e\xb6\x14\x58\x72\x30\x60\xd8\x77\x1f\xaa\xfc\x5b\xe1\xb5\x4d\x8f\x26\x7f\xef\x91\xa0\xf5\x16\xf8\x39\x93\x77\x49\xb2\xac\x50\x28\x04\x25\x16\x39\x82\x63\xd3\xea\xd6\x59\x4b\x6d\x60\x67\xfd\x2c\x01\x00\x60\x03\x75\x9d\xa5\x20\x4b\x05\xb2\xce\x73\x58\x57\x59\x21\xaa\x47\xf8\x8a\x8f\x90\xe2\x4a\xd4\xb9\x82\x71\x64\xa3\x9f\xc8\xd2\xd0\x04\xd2\xfb\x4f\xb3\xf9\x6d\x14\x07\xb2\x8d\x0d\x7a\xe2\x51\xe1\x0a\x2b\x94\x4b\xdc\x1c\x11\x3f\x63\x33\x87\x52\x42\x8a\x39\x2a\x84\xa5\xd8\x2c\x45\x8a\xcf\x95\x7a\x9d\x8a\x4b\xe5\xe0\x6b\x9b\x9e\x40\xe1\x83\xba\x58\x9e\x56\xb9\xb9\x39\x20\xec\xfd\x48\xc3\x4b\xe8\xd0\x69\x3b\xa6\xc3\x52\xaf\x37\x3d\xb5\x03\x85\x77\x46\xf8\xd6\xed\xc8\x47\xe8\xfb\x8f\xff\x60\xbf\xa9\x6f\xb8\xbb\xdd\x0d\x6e\xcb\x1d\xfd\x39\xaa\x62\x51\xb7\x5d\xc3\xfd\x6b\xfe\x11\x79\x81\xef\x69\xe0\x2d\x93\xb9\x42\x77\x66\x8f\x06\x61\x18\x7d\xd0\xb1\x05\x8b\xb2\xcc\x51\xc8\xa9\x74\x25\xf2\xcd\x3f\xb7\x7d\x7b\xcc\x33\x71\x34\x37\xb4\x6d\xc6\x2e\xe8\xc1\x75\xef\xfd\x90\x9f\x1c\xf4\x6e\x70\x7b\xf6\xec\x2c\xdb\xa7\xab\x96\x31\xae\x6f\xd8\xbe\x75\xe3\xd3\x59\x1b\x63\xc8\x80\xca\x0a\xdc\x28\x51\xac\xe1\x3e\x53\x5f\xe2\x27\x7c\x2b\x25\x4e\x95\xb2\xbc\x3f\xbd\xd2\xde\x99\x78\xb0\x8f\xaa\x93\xf9\x25\x42\x99\x4c\xf1\x61\x12\x21\x7d\x0e\xc0\x2f\x28\xe5\x34\x61\xe7\x76\x74\x2a\x8b\x22\x53\x77\xc9\xdf\x01\x00\x59\x43\x01\x2d\x9d\x03\x00\x00")
+
+func _35_oidc_connectionsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__35_oidc_connectionsUpSql,
+		"35_oidc_connections.up.sql",
+	)
+}
+
+func _35_oidc_connectionsUpSql() (*asset, error) {
+	bytes, err := _35_oidc_connectionsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "35_oidc_connections.up.sql", size: 925, mode: os.FileMode(420), modTime: time.Unix(1792265302, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __36_saml_connectionsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\x4e\xcc\xcd\x89\x4f\xce\xcf\xcb\x4b\x4d\x2e\xc9\xcc\xcf\x2b\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x14\x93\x6d\x96\x2e\x00\x00\x00")
+
+func _36_saml_connectionsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__36_saml_connectionsDownSql,
+		"36_saml_connections.down.sql",
+	)
+}
+
+func _36_saml_connectionsDownSql() (*asset, error) {
+	bytes, err := _36_saml_connectionsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "36_saml_connections.down.sql", size: 46, mode: os.FileMode(420), modTime: time.Unix(1792265836, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __36_saml_connectionsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x94\x92\x4d\x6f\xa3\x30\x10\x86\xef\xfc\x8a\xb9\x25\x91\xf6\xb8\xb7\x9c\x0c\x4c\x76\xd9\x05\x3b\x02\xa3\x24\xad\x2a\xcb\x8d\x9d\xc8\x15\x98\x08\x4c\xd4\xaa\xea\x7f\xaf\x20\x5f\xaa\x88\x9a\xe6\xc8\xcc\xf3\x3e\x8c\xac\xd7\xc7\x3f\x11\x9d\x7a\x5e\x90\x22\xe1\x08\x9c\xf8\x31\x42\x23\xcb\x42\xac\x2b\x6b\xf5\xda\x99\xca\x36\x63\x0f\x00\xc0\x28\xc8\xf3\x28\x04\xca\x38\xd0\x3c\x8e\x61\x9e\x46\x09\x49\x57\xf0\x1f\x57\x10\xe2\x8c\xe4\x31\x87\xb6\x35\x4a\x6c\xb5\xd5\xb5\x74\x5a\xec\x7f\x8f\x27\xbf\xfa\xb0\xd3\x56\x5a\x27\x06\x8e\x14\x67\x98\x22\x0d\x30\x3b\x22\xcd\xd8\xa8\x09\x30\x0a\x21\xc6\xc8\x11\x02\x92\x05\x24\xc4\x6e\x92\xcf\x43\x72\x99\x1c\xbc\x56\x96\x1a\x38\x2e\xf9\x45\x79\x3a\x65\x34\x3a\x20\xa5\x76\x52\x49\x27\xbf\x62\x87\x9d\xb6\xce\xb8\xb7\xee\xac\x6b\xcb\x52\x9a\x42\x48\xe7\x6a\xf3\xdc\xba\x5b\xbf\xe9\x2e\xf9\x31\x5c\x57\xc5\x9d\x70\x29\x77\x3b\x63\xb7\xf0\x2f\x63\xd4\xbf\xc2\xbe\x7f\x1c\x69\xa5\x37\xb2\x2d\x9c\xe8\x52\x37\xc4\x2f\xc6\x89\x5d\x5d\xed\x4d\x63\x2a\xdb\xc9\x7d\xc6\x62\x24\x74\x98\x98\x91\x38\x3b\xbe\xb8\xaa\x4a\x69\x6c\xd3\xab\x1f\x9f\xbe\xbb\x44\x2a\xa5\x15\xf0\x28\xc1\x8c\x93\x64\x0e\x8b\x88\xff\xed\x3f\xe1\x81\x51\x1c\x26\x29\x5b\x9c\xea\x52\x56\xca\x6c\xcc\xfd\x69\x6f\x72\xe9\x72\x44\x43\x5c\x0e\xba\x2c\xce\x4d\x7c\x05\x46\x87\x55\x3f\xaf\x7b\x13\x4b\x92\x88\x4f\xbd\xcf\x01\x00\xa3\x90\x31\xc7\x26\x03\x00\x00")
+
+func _36_saml_connectionsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__36_saml_connectionsUpSql,
+		"36_saml_connections.up.sql",
+	)
+}
+
+func _36_saml_connectionsUpSql() (*asset, error) {
+	bytes, err := _36_saml_connectionsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "36_saml_connections.up.sql", size: 806, mode: os.FileMode(420), modTime: time.Unix(1792265836, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __37_scimDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9c\x92\xcd\x6e\xab\x30\x10\x85\xf7\xf3\x14\xb3\xe3\x5e\xa9\x4f\x80\xd5\x85\x03\xd3\x14\x09\x1b\x64\x6c\x35\x5d\x21\x5a\x5b\xaa\xa5\x84\x46\xb1\x13\xe5\xf1\xab\xfc\x50\x51\x01\x9b\x6c\x67\xce\x99\xf9\x7c\xc6\x2b\x5a\x17\x92\x01\xe4\xaa\xaa\xb1\x90\x39\x6d\x70\xe7\x76\x1f\xee\x10\xbe\xfc\xbe\x75\xe7\xe8\x0e\x7d\xb7\x6d\xbd\x6d\xbd\x3d\x33\xe0\xa5\x26\x85\x9a\xaf\x4a\x1a\xe9\xf0\xea\xce\xaa\xd2\x08\x89\x23\x0f\x03\x30\x75\xce\xf5\x1f\x6d\x43\x7a\xbc\x22\xc4\x2e\x1e\x03\x3e\x63\xd2\x7d\x46\x7f\x72\x09\xbe\xbd\x92\xa2\x79\x49\x38\x86\xbd\xeb\xad\xb3\x09\x83\x81\xe5\xbd\x9e\x13\x2b\x92\x5c\x10\xea\x6a\xda\x6b\xbf\xb7\x96\x41\xa6\xe8\x02\xb6\x60\xe7\x0d\x92\x34\x02\xff\x0d\x54\x4f\x98\xf8\xfe\xe4\xa3\xb3\xc9\x7f\x06\x4b\x39\xdc\xca\xf7\x20\xa6\x53\xaf\x31\xe5\xf4\xc2\x4d\xa9\xd9\x83\x33\x16\x80\x4d\x53\xc8\xf5\xb4\x9e\xa6\x9a\x36\x3a\x4d\x27\x8d\x47\xd7\x5f\xae\x77\x7f\xc1\xef\xc5\x86\xef\x33\x8f\x76\x8b\x1b\xb2\x4a\x88\x42\x33\xf8\x19\x00\x08\xad\x07\xdb\x71\x02\x00\x00")
+
+func _37_scimDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__37_scimDownSql,
+		"37_scim.down.sql",
+	)
+}
+
+func _37_scimDownSql() (*asset, error) {
+	bytes, err := _37_scimDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "37_scim.down.sql", size: 625, mode: os.FileMode(420), modTime: time.Unix(1792266642, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __37_scimUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9c\x91\xc1\x8e\x82\x30\x10\x86\xef\x7d\x8a\xb9\xa1\x89\xbe\x00\x9c\x50\x66\x5d\x12\x28\x2e\x94\xac\x7b\x22\xd5\x4e\x62\x13\xac\xa6\x2d\xc4\x7d\xfb\x0d\x6b\xd8\xb0\x51\x2f\x26\x1c\xc8\x4c\xe6\xff\xbe\x99\xae\x70\x93\xf2\x88\xb1\xe5\x12\xd0\x74\x27\xe8\x65\xdb\x91\x83\x83\x34\x81\x87\x3d\x81\x54\x8a\x14\x68\xe3\xb4\x22\x90\xe0\xad\x34\x4e\x1e\xbc\x3e\x1b\x18\xbe\x56\x91\x05\x47\xb6\x27\xeb\x16\xe0\xce\xe0\x8f\x04\xfe\xfb\x42\xa0\x1d\x58\x3a\x58\x92\x9e\x14\x8b\x33\x81\x25\x88\xaf\x2d\xc2\x89\x4e\x7b\xb2\xee\xa8\x2f\x8d\xf3\xd2\x77\x0e\x4a\xe4\x71\x8e\x20\x8a\xfb\x5e\x73\x6e\x55\xc4\xd6\x25\xc6\x02\x9f\x8d\xc7\x15\x20\xaf\x73\x98\x05\x83\x57\x4f\xc1\x02\x02\x6d\x7a\xed\x49\x0d\xbf\xae\x73\x17\x32\x8a\x54\x30\x8f\xd8\x28\x12\xaf\xb2\x69\x14\xdc\xca\xeb\x22\xab\x73\xfe\x00\x91\x94\xc5\x16\x12\x7c\x8b\xeb\x4c\x44\x2f\x66\x3c\xb1\xaf\xab\x94\x6f\xee\xeb\x61\x28\x70\x27\xc2\xf0\xae\xf1\x2a\xbe\x42\x31\x6e\x00\xe3\xa1\x22\xc6\x7e\x37\x7b\xac\x76\xbb\xfd\x53\x5c\x92\x8c\x30\xba\x7a\xb2\x46\xb6\x8d\x56\x30\x58\xff\x3d\x58\xcd\xd3\x8f\x1a\x21\xe5\x09\xee\xa6\xf9\x93\x81\x46\xab\x2b\x14\x53\xe1\x99\x27\x23\x8d\x6f\xb4\x5a\x4c\x93\xe7\xf0\xf9\x8e\x25\xfe\x83\xa5\x15\xf0\x42\x00\xaf\xb3\x2c\x62\x6c\x5d\xe4\x79\x2a\x22\xf6\x33\x00\x39\x56\x29\x5e\xd2\x02\x00\x00")
+
+func _37_scimUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__37_scimUpSql,
+		"37_scim.up.sql",
+	)
+}
+
+func _37_scimUpSql() (*asset, error) {
+	bytes, err := _37_scimUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "37_scim.up.sql", size: 722, mode: os.FileMode(420), modTime: time.Unix(1792266642, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __38_federated_identitiesDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x48\x4b\x4d\x49\x2d\x4a\x2c\x49\x4d\x89\xcf\x4c\x49\xcd\x2b\xc9\x2c\xc9\x4c\x2d\xb6\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\x0b\x64\xbd\x87\x32\x00\x00\x00")
+
+func _38_federated_identitiesDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__38_federated_identitiesDownSql,
+		"38_federated_identities.down.sql",
+	)
+}
+
+func _38_federated_identitiesDownSql() (*asset, error) {
+	bytes, err := _38_federated_identitiesDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "38_federated_identities.down.sql", size: 50, mode: os.FileMode(420), modTime: time.Unix(1792268407, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __38_federated_identitiesUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x6c\x90\x41\x6e\xea\x30\x18\x84\xf7\x3e\xc5\x2c\x13\x09\x4e\xc0\xca\xc4\x3f\xef\x59\x4d\x1c\x14\x1c\x51\xba\x41\x51\xec\x12\x4b\xad\x5d\x25\xa6\x2d\xb7\xaf\x08\x54\x51\x54\x96\x1e\xcd\x7c\x9e\xf9\xd7\xf4\x4f\xaa\x15\x63\xcb\x25\x78\xdb\x86\xb3\x8f\x03\xbe\x3a\xd7\x76\x78\x6f\x2e\x78\x0b\x27\x38\x8f\xd8\xf5\xe1\x7c\xea\xd0\x78\x38\x63\x7d\x74\xf1\x82\x8f\x3e\x7c\x3a\x63\x7b\xb4\xc1\x7b\xdb\x46\x17\x3c\xcb\x2a\xe2\x9a\xa0\xf9\x3a\x27\xbc\x5a\x63\xfb\x26\x5a\x73\xbc\x67\x9c\x1d\x12\x06\x00\xe7\xc1\xf6\x47\x67\x50\xd7\x52\x40\x95\x1a\xaa\xce\x73\x54\xb4\xa1\x8a\x54\x46\xbb\xd1\x30\x24\xce\xa4\x28\x15\x04\xe5\xa4\x09\x19\xdf\x65\x5c\xd0\x55\xa9\xb7\x82\x4f\xca\x62\x64\x4e\x2d\xfe\x90\x6f\x86\xc6\x18\x6b\xa0\x65\x41\x3b\xcd\x8b\x2d\xf6\x52\xff\x1f\x9f\x78\x29\x15\x4d\x35\x04\x6d\x78\x9d\x6b\xa8\x72\x9f\xa4\xb7\xe8\xb6\x92\x05\xaf\x0e\x78\xa2\x03\x92\x7b\xf9\xc5\xfc\xc7\x94\xa5\x2b\xf6\xbb\x5f\x2a\x41\xcf\x0f\xf7\x1f\x67\xa1\xef\xeb\x98\x87\x67\x9a\xb3\xaf\xe4\xb2\x28\xa4\x5e\xb1\x9f\x01\x00\xa9\x2c\x65\x1e\xaf\x01\x00\x00")
+
+func _38_federated_identitiesUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__38_federated_identitiesUpSql,
+		"38_federated_identities.up.sql",
+	)
+}
+
+func _38_federated_identitiesUpSql() (*asset, error) {
+	bytes, err := _38_federated_identitiesUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "38_federated_identities.up.sql", size: 431, mode: os.FileMode(420), modTime: time.Unix(1792268390, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __39_provisioned_byDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x2d\x4e\x2d\x2a\x56\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\x28\x28\xca\x2f\xcb\x2c\xce\xcc\xcf\x4b\x4d\x89\x4f\xaa\xb4\xe6\xe2\x72\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x0c\x00\xa2\x60\x84\xf2\x3f\x00\x00\x00")
+
+func _39_provisioned_byDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__39_provisioned_byDownSql,
+		"39_provisioned_by.down.sql",
+	)
+}
+
+func _39_provisioned_byDownSql() (*asset, error) {
+	bytes, err := _39_provisioned_byDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "39_provisioned_by.down.sql", size: 63, mode: os.FileMode(420), modTime: time.Unix(1792269778, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __39_provisioned_byUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x4c\xce\xb1\x4e\x85\x30\x14\x06\xe0\xbd\x4f\xf1\x8f\x3a\x5c\x5f\x80\x89\x0b\x47\x43\x52\x4a\x02\x65\x36\xbd\xe5\x84\x36\xd1\xd6\xb4\xa7\x1a\xde\xde\xc4\xc9\xf9\x5b\xbe\x3b\xbd\x4d\xa6\x53\xea\x76\x83\xe5\xe4\x92\xe0\x27\x44\x1f\xe0\x0b\x3b\xe1\x03\x12\x18\xce\xfb\xdc\x92\xbc\x60\x49\x1f\x17\xa2\xe0\xd3\x5d\xf0\xc1\xa5\x93\xff\x3b\x9c\x48\x89\x8f\x26\x5c\x21\xa1\xe4\x76\x06\x6c\xc3\x34\xab\x5e\x5b\x5a\x61\xfb\xbb\x26\xb4\xca\xa5\xa2\x1f\x47\x0c\x8b\xde\x67\x83\xaf\x92\xbf\x63\x8d\x39\xf1\xf1\xfe\xb8\xb0\xef\xd3\x88\x95\x5e\x69\x25\x33\xd0\x06\xf9\x4b\xd5\xa7\x78\x3c\x63\x31\x18\x49\x93\x25\x6c\x64\x61\x76\xad\x3b\xa5\x86\x65\x9e\x27\xdb\xa9\xdf\x01\x00\x05\x3c\x09\xf2\xc9\x00\x00\x00")
+
+func _39_provisioned_byUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__39_provisioned_byUpSql,
+		"39_provisioned_by.up.sql",
+	)
+}
+
+func _39_provisioned_byUpSql() (*asset, error) {
+	bytes, err := _39_provisioned_byUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "39_provisioned_by.up.sql", size: 201, mode: os.FileMode(420), modTime: time.Unix(1792269778, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var __3_add_log_tableDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xc8\xc9\x4f\xb7\x06\x04\x00\x00\xff\xff\x5e\x0c\xb6\xd7\x0f\x00\x00\x00")
 
 func _3_add_log_tableDownSqlBytes() ([]byte, error) {
@@ -983,8 +1781,46 @@ var _bindata = map[string]func() (*asset, error){
 	"1_add_users_table.up.sql": _1_add_users_tableUpSql,
 	"20_service_accounts_ip_validation.down.sql": _20_service_accounts_ip_validationDownSql,
 	"20_service_accounts_ip_validation.up.sql": _20_service_accounts_ip_validationUpSql,
+	"21_oauth_clients.down.sql": _21_oauth_clientsDownSql,
+	"21_oauth_clients.up.sql": _21_oauth_clientsUpSql,
+	"22_api_key_secrets.down.sql": _22_api_key_secretsDownSql,
+	"22_api_key_secrets.up.sql": _22_api_key_secretsUpSql,
+	"23_refresh_tokens.down.sql": _23_refresh_tokensDownSql,
+	"23_refresh_tokens.up.sql": _23_refresh_tokensUpSql,
+	"24_sessions.down.sql": _24_sessionsDownSql,
+	"24_sessions.up.sql": _24_sessionsUpSql,
+	"25_revoked_tokens.down.sql": _25_revoked_tokensDownSql,
+	"25_revoked_tokens.up.sql": _25_revoked_tokensUpSql,
+	"26_api_key_scope.down.sql": _26_api_key_scopeDownSql,
+	"26_api_key_scope.up.sql": _26_api_key_scopeUpSql,
+	"27_mfa_totp.down.sql": _27_mfa_totpDownSql,
+	"27_mfa_totp.up.sql": _27_mfa_totpUpSql,
+	"28_webauthn_credentials.down.sql": _28_webauthn_credentialsDownSql,
+	"28_webauthn_credentials.up.sql": _28_webauthn_credentialsUpSql,
+	"29_tenant_mfa_policy.down.sql": _29_tenant_mfa_policyDownSql,
+	"29_tenant_mfa_policy.up.sql": _29_tenant_mfa_policyUpSql,
 	"2_add_roles_table.down.sql": _2_add_roles_tableDownSql,
 	"2_add_roles_table.up.sql": _2_add_roles_tableUpSql,
+	"30_login_failures.down.sql": _30_login_failuresDownSql,
+	"30_login_failures.up.sql": _30_login_failuresUpSql,
+	"31_rate_limit_buckets.down.sql": _31_rate_limit_bucketsDownSql,
+	"31_rate_limit_buckets.up.sql": _31_rate_limit_bucketsUpSql,
+	"32_password_history.down.sql": _32_password_historyDownSql,
+	"32_password_history.up.sql": _32_password_historyUpSql,
+	"33_add_login_gen_column.down.sql": _33_add_login_gen_columnDownSql,
+	"33_add_login_gen_column.up.sql": _33_add_login_gen_columnUpSql,
+	"34_add_log_actor_column.down.sql": _34_add_log_actor_columnDownSql,
+	"34_add_log_actor_column.up.sql": _34_add_log_actor_columnUpSql,
+	"35_oidc_connections.down.sql": _35_oidc_connectionsDownSql,
+	"35_oidc_connections.up.sql": _35_oidc_connectionsUpSql,
+	"36_saml_connections.down.sql": _36_saml_connectionsDownSql,
+	"36_saml_connections.up.sql": _36_saml_connectionsUpSql,
+	"37_scim.down.sql": _37_scimDownSql,
+	"37_scim.up.sql": _37_scimUpSql,
+	"38_federated_identities.down.sql": _38_federated_identitiesDownSql,
+	"38_federated_identities.up.sql": _38_federated_identitiesUpSql,
+	"39_provisioned_by.down.sql": _39_provisioned_byDownSql,
+	"39_provisioned_by.up.sql": _39_provisioned_byUpSql,
 	"3_add_log_table.down.sql": _3_add_log_tableDownSql,
 	"3_add_log_table.up.sql": _3_add_log_tableUpSql,
 	"4_not_null.down.sql": _4_not_nullDownSql,
@@ -1065,8 +1901,46 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"1_add_users_table.up.sql": &bintree{_1_add_users_tableUpSql, map[string]*bintree{}},
 	"20_service_accounts_ip_validation.down.sql": &bintree{_20_service_accounts_ip_validationDownSql, map[string]*bintree{}},
 	"20_service_accounts_ip_validation.up.sql": &bintree{_20_service_accounts_ip_validationUpSql, map[string]*bintree{}},
+	"21_oauth_clients.down.sql": &bintree{_21_oauth_clientsDownSql, map[string]*bintree{}},
+	"21_oauth_clients.up.sql": &bintree{_21_oauth_clientsUpSql, map[string]*bintree{}},
+	"22_api_key_secrets.down.sql": &bintree{_22_api_key_secretsDownSql, map[string]*bintree{}},
+	"22_api_key_secrets.up.sql": &bintree{_22_api_key_secretsUpSql, map[string]*bintree{}},
+	"23_refresh_tokens.down.sql": &bintree{_23_refresh_tokensDownSql, map[string]*bintree{}},
+	"23_refresh_tokens.up.sql": &bintree{_23_refresh_tokensUpSql, map[string]*bintree{}},
+	"24_sessions.down.sql": &bintree{_24_sessionsDownSql, map[string]*bintree{}},
+	"24_sessions.up.sql": &bintree{_24_sessionsUpSql, map[string]*bintree{}},
+	"25_revoked_tokens.down.sql": &bintree{_25_revoked_tokensDownSql, map[string]*bintree{}},
+	"25_revoked_tokens.up.sql": &bintree{_25_revoked_tokensUpSql, map[string]*bintree{}},
+	"26_api_key_scope.down.sql": &bintree{_26_api_key_scopeDownSql, map[string]*bintree{}},
+	"26_api_key_scope.up.sql": &bintree{_26_api_key_scopeUpSql, map[string]*bintree{}},
+	"27_mfa_totp.down.sql": &bintree{_27_mfa_totpDownSql, map[string]*bintree{}},
+	"27_mfa_totp.up.sql": &bintree{_27_mfa_totpUpSql, map[string]*bintree{}},
+	"28_webauthn_credentials.down.sql": &bintree{_28_webauthn_credentialsDownSql, map[string]*bintree{}},
+	"28_webauthn_credentials.up.sql": &bintree{_28_webauthn_credentialsUpSql, map[string]*bintree{}},
+	"29_tenant_mfa_policy.down.sql": &bintree{_29_tenant_mfa_policyDownSql, map[string]*bintree{}},
+	"29_tenant_mfa_policy.up.sql": &bintree{_29_tenant_mfa_policyUpSql, map[string]*bintree{}},
 	"2_add_roles_table.down.sql": &bintree{_2_add_roles_tableDownSql, map[string]*bintree{}},
 	"2_add_roles_table.up.sql": &bintree{_2_add_roles_tableUpSql, map[string]*bintree{}},
+	"30_login_failures.down.sql": &bintree{_30_login_failuresDownSql, map[string]*bintree{}},
+	"30_login_failures.up.sql": &bintree{_30_login_failuresUpSql, map[string]*bintree{}},
+	"31_rate_limit_buckets.down.sql": &bintree{_31_rate_limit_bucketsDownSql, map[string]*bintree{}},
+	"31_rate_limit_buckets.up.sql": &bintree{_31_rate_limit_bucketsUpSql, map[string]*bintree{}},
+	"32_password_history.down.sql": &bintree{_32_password_historyDownSql, map[string]*bintree{}},
+	"32_password_history.up.sql": &bintree{_32_password_historyUpSql, map[string]*bintree{}},
+	"33_add_login_gen_column.down.sql": &bintree{_33_add_login_gen_columnDownSql, map[string]*bintree{}},
+	"33_add_login_gen_column.up.sql": &bintree{_33_add_login_gen_columnUpSql, map[string]*bintree{}},
+	"34_add_log_actor_column.down.sql": &bintree{_34_add_log_actor_columnDownSql, map[string]*bintree{}},
+	"34_add_log_actor_column.up.sql": &bintree{_34_add_log_actor_columnUpSql, map[string]*bintree{}},
+	"35_oidc_connections.down.sql": &bintree{_35_oidc_connectionsDownSql, map[string]*bintree{}},
+	"35_oidc_connections.up.sql": &bintree{_35_oidc_connectionsUpSql, map[string]*bintree{}},
+	"36_saml_connections.down.sql": &bintree{_36_saml_connectionsDownSql, map[string]*bintree{}},
+	"36_saml_connections.up.sql": &bintree{_36_saml_connectionsUpSql, map[string]*bintree{}},
+	"37_scim.down.sql": &bintree{_37_scimDownSql, map[string]*bintree{}},
+	"37_scim.up.sql": &bintree{_37_scimUpSql, map[string]*bintree{}},
+	"38_federated_identities.down.sql": &bintree{_38_federated_identitiesDownSql, map[string]*bintree{}},
+	"38_federated_identities.up.sql": &bintree{_38_federated_identitiesUpSql, map[string]*bintree{}},
+	"39_provisioned_by.down.sql": &bintree{_39_provisioned_byDownSql, map[string]*bintree{}},
+	"39_provisioned_by.up.sql": &bintree{_39_provisioned_byUpSql, map[string]*bintree{}},
 	"3_add_log_table.down.sql": &bintree{_3_add_log_tableDownSql, map[string]*bintree{}},
 	"3_add_log_table.up.sql": &bintree{_3_add_log_tableUpSql, map[string]*bintree{}},
 	"4_not_null.down.sql": &bintree{_4_not_nullDownSql, map[string]*bintree{}},
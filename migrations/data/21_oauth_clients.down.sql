@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE oauth_codes;
+DROP TABLE oauth_clients;
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TABLE oauth_clients(
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    secret_hash TEXT NOT NULL DEFAULT '',
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE oauth_codes(
+    code_hash TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    membership_id UUID NOT NULL REFERENCES membership(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    code_challenge_method TEXT NOT NULL,
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX oauth_codes_expires_idx ON oauth_codes(expires);
+
+COMMIT;
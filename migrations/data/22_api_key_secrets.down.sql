@@ -0,0 +1 @@
+ALTER TABLE service_account_keys DROP COLUMN secret_hash;
@@ -0,0 +1 @@
+ALTER TABLE service_account_keys ADD COLUMN secret_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE refresh_tokens(
+    id UUID NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+    token_hash TEXT NOT NULL UNIQUE,
+    family_id UUID NOT NULL,
+    membership_id UUID NOT NULL REFERENCES membership(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    client_id TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT '',
+    permissions BOOLEAN NOT NULL DEFAULT TRUE,
+    used BOOLEAN NOT NULL DEFAULT FALSE,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens(family_id);
+CREATE INDEX refresh_tokens_expires_idx ON refresh_tokens(expires);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens DROP CONSTRAINT refresh_tokens_session_id_fkey;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+ALTER TABLE refresh_tokens ADD COLUMN revoked BOOLEAN NOT NULL DEFAULT FALSE;
+DROP TABLE sessions;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE TABLE revoked_tokens(
+    jti UUID NOT NULL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX revoked_tokens_expires_idx ON revoked_tokens(expires);
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE service_account_keys
+    DROP COLUMN label,
+    DROP COLUMN expires,
+    DROP COLUMN last_used,
+    DROP COLUMN roles,
+    DROP COLUMN permissions;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE service_account_keys
+    ADD COLUMN label TEXT NOT NULL DEFAULT '',
+    ADD COLUMN expires TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN last_used TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN permissions TEXT[] NOT NULL DEFAULT '{}';
+
+COMMIT;
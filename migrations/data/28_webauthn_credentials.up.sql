@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE webauthn_credentials(
+    id UUID NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+    credential_id BYTEA NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid BYTEA,
+    name TEXT NOT NULL DEFAULT '',
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX webauthn_credentials_user_idx ON webauthn_credentials(user_id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE oauth_codes DROP COLUMN mfa_method;
+ALTER TABLE sessions DROP COLUMN mfa_method;
+ALTER TABLE tenants DROP COLUMN mfa_methods;
+ALTER TABLE tenants DROP COLUMN mfa_policy;
+
+COMMIT;
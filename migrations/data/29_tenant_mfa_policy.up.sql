@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE tenants ADD COLUMN mfa_policy TEXT NOT NULL DEFAULT 'optional';
+ALTER TABLE tenants ADD COLUMN mfa_methods TEXT[] NOT NULL DEFAULT '{}';
+
+-- Second factor used to start the session
+ALTER TABLE sessions ADD COLUMN mfa_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE oauth_codes ADD COLUMN mfa_method TEXT NOT NULL DEFAULT '';
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE login_failures;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE login_failures(
+    kind TEXT NOT NULL,
+    key TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (kind, key)
+);
+
+CREATE INDEX login_failures_last_failure_idx ON login_failures(last_failure);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE UNLOGGED TABLE rate_limit_buckets(
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL DEFAULT TRUE,
+    updated TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX rate_limit_buckets_updated_idx ON rate_limit_buckets(updated);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP FUNCTION password_history_update CASCADE;
+DROP TABLE password_history;
+
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE password_history(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    password_hash TEXT NOT NULL,
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX password_history_user_id_idx ON password_history(user_id, added);
+
+-- Keep previous hashes whichever way the password is changed
+CREATE FUNCTION password_history_update() RETURNS trigger AS $$
+    BEGIN
+        IF OLD.password_hash <> '' AND OLD.password_hash IS DISTINCT FROM NEW.password_hash THEN
+            INSERT INTO password_history (user_id, password_hash) VALUES (OLD.id, OLD.password_hash);
+            DELETE FROM password_history WHERE user_id = OLD.id AND ctid NOT IN (
+                SELECT ctid FROM password_history WHERE user_id = OLD.id ORDER BY added DESC LIMIT 24
+            );
+        END IF;
+        RETURN NEW;
+    END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER password_history_trigger
+    AFTER UPDATE OF password_hash ON users
+    FOR EACH ROW
+    EXECUTE PROCEDURE password_history_update();
+
+COMMIT;
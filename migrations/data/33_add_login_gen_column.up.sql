@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN login_gen INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE log DROP COLUMN actor_id;
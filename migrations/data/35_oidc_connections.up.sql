@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE oidc_connections(
+    id UUID NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    issuer TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    client_secret TEXT NOT NULL DEFAULT '',
+    scopes TEXT[] NOT NULL DEFAULT '{email,profile}',
+    email_claim TEXT NOT NULL DEFAULT 'email',
+    email_verified_claim TEXT NOT NULL DEFAULT 'email_verified',
+    trust_email BOOLEAN NOT NULL DEFAULT FALSE,
+    name_claim TEXT NOT NULL DEFAULT 'name',
+    default_role TEXT NOT NULL DEFAULT '',
+    jit_provisioning BOOLEAN NOT NULL DEFAULT FALSE,
+    domains TEXT[] NOT NULL DEFAULT '{}',
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX oidc_connections_tenant_idx ON oidc_connections(tenant_id);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE saml_connections;
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE saml_connections(
+    id UUID NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    email_attribute TEXT NOT NULL DEFAULT '',
+    name_attribute TEXT NOT NULL DEFAULT '',
+    role_attribute TEXT NOT NULL DEFAULT '',
+    role_mapping JSONB NOT NULL DEFAULT '{}',
+    default_role TEXT NOT NULL DEFAULT '',
+    jit_provisioning BOOLEAN NOT NULL DEFAULT FALSE,
+    domains TEXT[] NOT NULL DEFAULT '{}',
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX saml_connections_tenant_idx ON saml_connections(tenant_id);
+
+COMMIT;
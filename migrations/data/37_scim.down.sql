@@ -0,0 +1,17 @@
+BEGIN;
+
+DROP INDEX membership_external_id_idx;
+ALTER TABLE membership DROP COLUMN external_id;
+
+UPDATE membership SET membership_status = 'active' WHERE membership_status = 'suspended';
+
+ALTER TYPE membership_status RENAME TO membership_status_old;
+CREATE TYPE membership_status AS ENUM ('active', 'invited');
+
+ALTER TABLE membership ALTER COLUMN membership_status DROP DEFAULT;
+ALTER TABLE membership ALTER COLUMN membership_status TYPE membership_status USING membership_status::TEXT::membership_status;
+ALTER TABLE membership ALTER COLUMN membership_status SET DEFAULT 'active';
+
+DROP TYPE membership_status_old;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Enum values can't be added inside a transaction on older servers, so the type is recreated
+ALTER TYPE membership_status RENAME TO membership_status_old;
+CREATE TYPE membership_status AS ENUM ('active', 'invited', 'suspended');
+
+ALTER TABLE membership ALTER COLUMN membership_status DROP DEFAULT;
+ALTER TABLE membership ALTER COLUMN membership_status TYPE membership_status USING membership_status::TEXT::membership_status;
+ALTER TABLE membership ALTER COLUMN membership_status SET DEFAULT 'active';
+
+DROP TYPE membership_status_old;
+
+ALTER TABLE membership ADD COLUMN external_id TEXT;
+CREATE UNIQUE INDEX membership_external_id_idx ON membership(tenant_id, external_id) WHERE external_id IS NOT NULL;
+
+COMMIT;
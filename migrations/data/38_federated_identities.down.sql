@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE federated_identities;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Accounts which may log in through an identity provider connection
+CREATE TABLE federated_identities(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    connection_id UUID NOT NULL,
+    added TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, connection_id)
+);
+
+CREATE INDEX federated_identities_connection_idx ON federated_identities(connection_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Tenant which created the account. Only it may change the account attributes through SCIM
+ALTER TABLE users ADD COLUMN provisioned_by UUID REFERENCES tenants(id) ON DELETE SET NULL;
+
+COMMIT;
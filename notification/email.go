@@ -22,6 +22,8 @@ type EmailTemplateData struct {
 	TenantInvitePrefix   string `yaml:"tenant_invite_prefix"`
 	ResetURLPrefix       string `yaml:"reset_url_prefix"`
 	UpdateEmailURLPrefix string `yaml:"update_email_prefix"`
+	LoginURLPrefix       string `yaml:"login_url_prefix"`
+	VerifyEmailURLPrefix string `yaml:"verify_email_prefix"`
 	AppName              string `yaml:"app_name"`
 	SupportEmail         string `yaml:"support_email"`
 }
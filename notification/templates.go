@@ -63,6 +63,43 @@ Click the link to accept the invitation.
 
 {{.Misc.TenantInvitePrefix}}{{.Token| urlquery}}
 
+Thank you
+{{- end}}
+
+{{define "password_change_subject"}}Your {{.Misc.AppName}} password has been changed{{end}}
+{{define "password_change_body" -}}
+Hello {{.TargetUser.Name}}
+
+The password for your {{.Misc.AppName}} account {{.TargetUser.Email}} has been changed. This was done from the IP address {{.Addr}} on {{.Timestamp.Format "Mon, 02 Jan 2006 15:04:05 MST"}}
+
+If you did not make this change, you should reset your password and contact {{.Misc.SupportEmail}} immediately. Otherwise, you can ignore this notice.
+
+Thank you
+{{- end}}
+
+{{define "login_link_subject"}}Your {{.Misc.AppName}} login link{{end}}
+{{define "login_link_body" -}}
+Hello {{.TargetUser.Name}}
+
+Click the link to log in to {{.Misc.AppName}}. The link can be used only once.
+
+{{.Misc.LoginURLPrefix}}{{.Token| urlquery}}
+
+This link was requested from the IP address {{.Addr}} on {{.Timestamp.Format "Mon, 02 Jan 2006 15:04:05 MST"}}. If you didn't request it, then you can just ignore this email.
+
+Thank you
+{{- end}}
+
+{{define "email_verification_subject"}}Confirm your email address for {{.Misc.AppName}}{{end}}
+{{define "email_verification_body" -}}
+Hello {{.TargetUser.Name}}
+
+Thank you for signing up for {{.Misc.AppName}}. Click the link to confirm your email address and activate your account.
+
+{{.Misc.VerifyEmailURLPrefix}}{{.Token| urlquery}}
+
+This sign-up was made from the IP address {{.Addr}} on {{.Timestamp.Format "Mon, 02 Jan 2006 15:04:05 MST"}}. If it wasn't you, then you can just ignore this email.
+
 Thank you
 {{- end}}`
 )
@@ -25,6 +25,9 @@ const (
 	NotificationReset              = "reset"
 	NotificationEmailUpdateRequest = "email_update_request"
 	NotificationEmailUpdate        = "email_update"
+	NotificationPasswordChange     = "password_change"
+	NotificationLoginLink          = "login_link"
+	NotificationEmailVerification  = "email_verification"
 )
 
 type Notifier interface {
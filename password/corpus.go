@@ -0,0 +1,60 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const corpusPrefixLen = 5
+
+// Corpus is an offline breached password list in the k-anonymity range format. The directory contains
+// a file for each five hex digit SHA-1 prefix (optionally with .txt extension) listing the remaining
+// hash suffixes as SUFFIX:COUNT lines
+type Corpus struct {
+	Dir string
+}
+
+func (c *Corpus) open(prefix string) (*os.File, error) {
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		f, err := os.Open(filepath.Join(c.Dir, name))
+		if err == nil {
+			return f, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// Contains returns true if the password's hash is found in the corpus
+func (c *Corpus) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:corpusPrefixLen], hash[corpusPrefixLen:]
+
+	f, err := c.open(prefix)
+	if err != nil || f == nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		p := strings.SplitN(line, ":", 2)
+
+		if strings.EqualFold(p[0], suffix) {
+			// Zero count entries are padding
+			return len(p) < 2 || strings.TrimSpace(p[1]) != "0", nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,106 @@
+// Package password implements password policy checks
+package password
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/hasher"
+)
+
+// Policy describes password requirements. Zero value accepts any non-empty password
+type Policy struct {
+	MinLength      int    `yaml:"min_length"`
+	MaxLength      int    `yaml:"max_length"`
+	RequireUpper   bool   `yaml:"require_upper"`
+	RequireLower   bool   `yaml:"require_lower"`
+	RequireDigit   bool   `yaml:"require_digit"`
+	RequireSymbol  bool   `yaml:"require_symbol"`
+	History        int    `yaml:"history"`         // Number of last passwords including the current one which can't be reused
+	BreachedCorpus string `yaml:"breached_corpus"` // Directory of SHA-1 hash prefix files
+}
+
+func (p *Policy) checkClasses(password string) (violations []string) {
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case !unicode.IsLetter(c):
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		violations = append(violations, errors.ViolationUpper)
+	}
+
+	if p.RequireLower && !lower {
+		violations = append(violations, errors.ViolationLower)
+	}
+
+	if p.RequireDigit && !digit {
+		violations = append(violations, errors.ViolationDigit)
+	}
+
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, errors.ViolationSymbol)
+	}
+
+	return
+}
+
+// Check returns *errors.PasswordPolicyError if the password violates the policy. History contains
+// hashes of the current and previous passwords, most recent first
+func (p *Policy) Check(password string, history [][]byte) error {
+	if password == "" {
+		return errors.ErrPasswordEmpty
+	}
+
+	var violations []string
+
+	n := utf8.RuneCountInString(password)
+	if p.MinLength != 0 && n < p.MinLength {
+		violations = append(violations, errors.ViolationMinLength)
+	}
+
+	if p.MaxLength != 0 && n > p.MaxLength {
+		violations = append(violations, errors.ViolationMaxLength)
+	}
+
+	violations = append(violations, p.checkClasses(password)...)
+
+	if len(history) > p.History {
+		history = history[:p.History]
+	}
+
+	for _, h := range history {
+		if len(h) != 0 && hasher.Compare(h, password) == nil {
+			violations = append(violations, errors.ViolationReused)
+			break
+		}
+	}
+
+	if p.BreachedCorpus != "" {
+		c := Corpus{Dir: p.BreachedCorpus}
+		breached, err := c.Contains(password)
+		if err != nil {
+			return err
+		}
+
+		if breached {
+			violations = append(violations, errors.ViolationBreached)
+		}
+	}
+
+	if len(violations) != 0 {
+		return &errors.PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
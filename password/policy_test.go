@@ -0,0 +1,99 @@
+package password
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ecadlabs/auth/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func violations(err error) []string {
+	if e, ok := err.(*errors.PasswordPolicyError); ok {
+		return e.Violations
+	}
+	return nil
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{
+		MinLength:     8,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	tests := []struct {
+		password string
+		expect   []string
+	}{
+		{password: "Passw0rd!", expect: nil},
+		{password: "Pa0!", expect: []string{errors.ViolationMinLength}},
+		{password: "password", expect: []string{errors.ViolationUpper, errors.ViolationDigit, errors.ViolationSymbol}},
+		{password: "ПАРОЛЬ1234!", expect: []string{errors.ViolationLower}},
+	}
+
+	for _, test := range tests {
+		err := p.Check(test.password, nil)
+		if test.expect == nil && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.password, err)
+		} else if v := violations(err); !reflect.DeepEqual(v, test.expect) {
+			t.Errorf("%s: expected %v, got %v", test.password, test.expect, v)
+		}
+	}
+
+	if err := p.Check("", nil); err != errors.ErrPasswordEmpty {
+		t.Errorf("empty password: %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var history [][]byte
+	for _, p := range []string{"current", "previous", "old"} {
+		h, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		history = append(history, h)
+	}
+
+	p := Policy{History: 2}
+
+	for _, pw := range []string{"current", "previous"} {
+		if v := violations(p.Check(pw, history)); !reflect.DeepEqual(v, []string{errors.ViolationReused}) {
+			t.Errorf("%s: expected reuse violation, got %v", pw, v)
+		}
+	}
+
+	if err := p.Check("old", history); err != nil {
+		t.Errorf("password beyond history rejected: %v", err)
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	data := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Policy{BreachedCorpus: dir}
+
+	if v := violations(p.Check("password", nil)); !reflect.DeepEqual(v, []string{errors.ViolationBreached}) {
+		t.Errorf("expected breached violation, got %v", v)
+	}
+
+	if err := p.Check("correct horse battery staple", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
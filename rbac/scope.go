@@ -0,0 +1,91 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+)
+
+type scopeContextKey struct{}
+
+// WithScope returns context which limits roles obtained through ScopedEnforcer to the specified permissions
+func WithScope(ctx context.Context, permissions []string) context.Context {
+	if len(permissions) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, scopeContextKey{}, permissions)
+}
+
+// ScopeFromContext returns permission scope set by WithScope, if any
+func ScopeFromContext(ctx context.Context) []string {
+	if s, ok := ctx.Value(scopeContextKey{}).([]string); ok {
+		return s
+	}
+	return nil
+}
+
+type restrictedRole struct {
+	Role
+	scope map[string]struct{}
+}
+
+// Restrict narrows the role down to the specified permissions. Empty list means no restriction
+func Restrict(role Role, permissions []string) Role {
+	if len(permissions) == 0 {
+		return role
+	}
+
+	scope := make(map[string]struct{}, len(permissions))
+	for _, p := range permissions {
+		scope[p] = struct{}{}
+	}
+
+	return &restrictedRole{
+		Role:  role,
+		scope: scope,
+	}
+}
+
+func (r *restrictedRole) filter(perm []string) []string {
+	res := make([]string, 0, len(perm))
+	for _, p := range perm {
+		if _, ok := r.scope[p]; ok {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (r *restrictedRole) IsAllGranted(perm ...string) (bool, error) {
+	if len(r.filter(perm)) != len(perm) {
+		return false, nil
+	}
+	return r.Role.IsAllGranted(perm...)
+}
+
+func (r *restrictedRole) IsAnyGranted(perm ...string) (bool, error) {
+	filtered := r.filter(perm)
+	if len(filtered) == 0 {
+		return false, nil
+	}
+	return r.Role.IsAnyGranted(filtered...)
+}
+
+func (r *restrictedRole) Permissions() []string {
+	res := r.filter(r.Role.Permissions())
+	sort.Strings(res)
+	return res
+}
+
+// ScopedEnforcer applies the context permission scope to the roles returned by the underlying enforcer
+type ScopedEnforcer struct {
+	Enforcer
+}
+
+func (s *ScopedEnforcer) GetRole(ctx context.Context, ids ...string) (Role, error) {
+	role, err := s.Enforcer.GetRole(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	return Restrict(role, ScopeFromContext(ctx)), nil
+}
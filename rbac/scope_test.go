@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type testEnforcer struct {
+	role Role
+}
+
+func (t *testEnforcer) GetRole(ctx context.Context, ids ...string) (Role, error) {
+	return t.role, nil
+}
+
+func TestRestrict(t *testing.T) {
+	role := &StaticRole{
+		RoleName: "test",
+		RolePermissions: map[string]struct{}{
+			"read":  struct{}{},
+			"write": struct{}{},
+		},
+	}
+
+	e := &ScopedEnforcer{Enforcer: &testEnforcer{role: role}}
+
+	r, err := e.GetRole(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := r.IsAnyGranted("write"); !ok {
+		t.Error("unscoped role must be granted write")
+	}
+
+	r, err = e.GetRole(WithScope(context.Background(), []string{"read", "delete"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := r.IsAnyGranted("write"); ok {
+		t.Error("scoped role must not be granted write")
+	}
+
+	if ok, _ := r.IsAnyGranted("write", "read"); !ok {
+		t.Error("scoped role must be granted read")
+	}
+
+	if ok, _ := r.IsAllGranted("read", "write"); ok {
+		t.Error("scoped role must not be granted read and write")
+	}
+
+	if ok, _ := r.IsAnyGranted("delete"); ok {
+		t.Error("scope must not extend the role")
+	}
+
+	if p := r.Permissions(); !reflect.DeepEqual(p, []string{"read"}) {
+		t.Errorf("unexpected permissions: %v", p)
+	}
+}
@@ -0,0 +1,457 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2)
+type Filter interface {
+	String() string
+}
+
+// Compare is an attribute expression. Value is nil for the pr operator
+type Compare struct {
+	Attr  string // Lowercase attribute path without the core schema prefix
+	Op    string // Lowercase operator
+	Value interface{}
+}
+
+func (c *Compare) String() string {
+	if c.Op == OpPresent {
+		return c.Attr + " " + c.Op
+	}
+	v, _ := json.Marshal(c.Value)
+	return c.Attr + " " + c.Op + " " + string(v)
+}
+
+// And is a logical conjunction
+type And struct {
+	Left, Right Filter
+}
+
+func (a *And) String() string { return "(" + a.Left.String() + " and " + a.Right.String() + ")" }
+
+// Or is a logical disjunction
+type Or struct {
+	Left, Right Filter
+}
+
+func (o *Or) String() string { return "(" + o.Left.String() + " or " + o.Right.String() + ")" }
+
+// Not is a negation
+type Not struct {
+	Filter Filter
+}
+
+func (n *Not) String() string { return "not (" + n.Filter.String() + ")" }
+
+// Comparison operators
+const (
+	OpEqual          = "eq"
+	OpNotEqual       = "ne"
+	OpContains       = "co"
+	OpStartsWith     = "sw"
+	OpEndsWith       = "ew"
+	OpPresent        = "pr"
+	OpGreater        = "gt"
+	OpGreaterOrEqual = "ge"
+	OpLess           = "lt"
+	OpLessOrEqual    = "le"
+)
+
+var compareOps = map[string]struct{}{
+	OpEqual:          struct{}{},
+	OpNotEqual:       struct{}{},
+	OpContains:       struct{}{},
+	OpStartsWith:     struct{}{},
+	OpEndsWith:       struct{}{},
+	OpPresent:        struct{}{},
+	OpGreater:        struct{}{},
+	OpGreaterOrEqual: struct{}{},
+	OpLess:           struct{}{},
+	OpLessOrEqual:    struct{}{},
+}
+
+// Core schema prefixes are stripped from attribute paths
+var schemaPrefixes = []string{
+	strings.ToLower(SchemaUser) + ":",
+	strings.ToLower(SchemaGroup) + ":",
+}
+
+const (
+	tokWord = iota
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokEOF
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:$+", r)
+}
+
+func tokenize(s string) ([]token, error) {
+	var res []token
+	rs := []rune(s)
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			res = append(res, token{tokLParen, "(", i})
+			i++
+
+		case r == ')':
+			res = append(res, token{tokRParen, ")", i})
+			i++
+
+		case r == '[':
+			res = append(res, token{tokLBracket, "[", i})
+			i++
+
+		case r == ']':
+			res = append(res, token{tokRBracket, "]", i})
+			i++
+
+		case r == '"':
+			j := i + 1
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+
+			var v string
+			if err := json.Unmarshal([]byte(string(rs[i:j+1])), &v); err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %v", i, err)
+			}
+			res = append(res, token{tokString, v, i})
+			i = j + 1
+
+		case isWordChar(r):
+			j := i
+			for ; j < len(rs) && isWordChar(rs[j]); j++ {
+			}
+			res = append(res, token{tokWord, string(rs[i:j]), i})
+			i = j
+
+		default:
+			return nil, fmt.Errorf("unexpected character `%c' at %d", r, i)
+		}
+	}
+
+	return append(res, token{tokEOF, "", len(rs)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	prefix string // Parent attribute inside of a value path
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(t token, kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at %d", fmt.Sprintf(format, args...), t.pos)
+}
+
+// filter = term *("or" term)
+func (p *parser) filter() (Filter, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword(p.peek(), "or") {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{left, right}
+	}
+
+	return left, nil
+}
+
+// term = factor *("and" factor)
+func (p *parser) term() (Filter, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword(p.peek(), "and") {
+		p.next()
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{left, right}
+	}
+
+	return left, nil
+}
+
+// factor = "not" "(" filter ")" / "(" filter ")" / attrExp / valuePath
+func (p *parser) factor() (Filter, error) {
+	t := p.next()
+
+	switch {
+	case p.keyword(t, "not"):
+		if t := p.next(); t.kind != tokLParen {
+			return nil, p.errorf(t, "`(' expected")
+		}
+		f, err := p.filter()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorf(t, "`)' expected")
+		}
+		return &Not{f}, nil
+
+	case t.kind == tokLParen:
+		f, err := p.filter()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorf(t, "`)' expected")
+		}
+		return f, nil
+
+	case t.kind == tokWord:
+		attr := p.prefix + normalizeAttr(t.text)
+
+		if p.peek().kind == tokLBracket {
+			if p.prefix != "" {
+				return nil, p.errorf(p.peek(), "nested value path")
+			}
+			p.next()
+
+			p.prefix = attr + "."
+			f, err := p.filter()
+			p.prefix = ""
+			if err != nil {
+				return nil, err
+			}
+
+			if t := p.next(); t.kind != tokRBracket {
+				return nil, p.errorf(t, "`]' expected")
+			}
+			return f, nil
+		}
+
+		opTok := p.next()
+		op := strings.ToLower(opTok.text)
+		if _, ok := compareOps[op]; opTok.kind != tokWord || !ok {
+			return nil, p.errorf(opTok, "operator expected")
+		}
+
+		if op == OpPresent {
+			return &Compare{Attr: attr, Op: op}, nil
+		}
+
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Compare{Attr: attr, Op: op, Value: value}, nil
+	}
+
+	return nil, p.errorf(t, "unexpected `%s'", t.text)
+}
+
+func (p *parser) value() (interface{}, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+
+	case tokWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+
+		var n json.Number
+		if err := json.Unmarshal([]byte(t.text), &n); err == nil {
+			return n, nil
+		}
+	}
+
+	return nil, p.errorf(t, "value expected")
+}
+
+func normalizeAttr(s string) string {
+	s = strings.ToLower(s)
+	for _, prefix := range schemaPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			return s[len(prefix):]
+		}
+	}
+	return s
+}
+
+// ParseFilter parses the filter expression
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, NewError(ErrInvalidFilter, err.Error())
+	}
+
+	p := parser{tokens: tokens}
+	f, err := p.filter()
+	if err != nil {
+		return nil, NewError(ErrInvalidFilter, err.Error())
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, NewError(ErrInvalidFilter, p.errorf(t, "unexpected `%s'", t.text).Error())
+	}
+
+	return f, nil
+}
+
+// Path is a PATCH operation target (RFC 7644 section 3.5.2)
+type Path struct {
+	Attr    string // Lowercase attribute name
+	Filter  Filter // Value filter, attribute names are relative to Attr
+	SubAttr string
+}
+
+// ParsePath parses the PATCH operation path, i.e. members[value eq "2819c223"]
+func ParsePath(s string) (*Path, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, NewError(ErrInvalidPath, err.Error())
+	}
+
+	p := parser{tokens: tokens}
+
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, NewError(ErrInvalidPath, p.errorf(t, "attribute expected").Error())
+	}
+
+	path := Path{Attr: normalizeAttr(t.text)}
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		if path.Filter, err = p.filter(); err != nil {
+			return nil, NewError(ErrInvalidPath, err.Error())
+		}
+
+		if t := p.next(); t.kind != tokRBracket {
+			return nil, NewError(ErrInvalidPath, p.errorf(t, "`]' expected").Error())
+		}
+
+		// Optional sub-attribute after the value filter, i.e. emails[type eq "work"].value
+		if t := p.peek(); t.kind == tokWord && strings.HasPrefix(t.text, ".") {
+			p.next()
+			path.SubAttr = strings.ToLower(t.text[1:])
+		}
+	} else if i := strings.IndexByte(path.Attr, '.'); i >= 0 {
+		path.Attr, path.SubAttr = path.Attr[:i], path.Attr[i+1:]
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, NewError(ErrInvalidPath, p.errorf(t, "unexpected `%s'", t.text).Error())
+	}
+
+	return &path, nil
+}
+
+func matchString(op, value, s string) (bool, error) {
+	value, s = strings.ToLower(value), strings.ToLower(s)
+
+	switch op {
+	case OpEqual:
+		return s == value, nil
+	case OpNotEqual:
+		return s != value, nil
+	case OpContains:
+		return strings.Contains(s, value), nil
+	case OpStartsWith:
+		return strings.HasPrefix(s, value), nil
+	case OpEndsWith:
+		return strings.HasSuffix(s, value), nil
+	}
+
+	return false, NewError(ErrInvalidFilter, fmt.Sprintf("operator `%s' is not supported", op))
+}
+
+// Match evaluates the filter against string attributes in memory. Keys are lowercase attribute paths,
+// comparisons are case insensitive
+func Match(f Filter, values map[string]string) (bool, error) {
+	switch f := f.(type) {
+	case *And:
+		ok, err := Match(f.Left, values)
+		if err != nil || !ok {
+			return false, err
+		}
+		return Match(f.Right, values)
+
+	case *Or:
+		ok, err := Match(f.Left, values)
+		if err != nil || ok {
+			return ok, err
+		}
+		return Match(f.Right, values)
+
+	case *Not:
+		ok, err := Match(f.Filter, values)
+		return !ok, err
+
+	case *Compare:
+		v, ok := values[f.Attr]
+		if f.Op == OpPresent {
+			return ok && v != "", nil
+		}
+
+		s, isString := f.Value.(string)
+		if !isString {
+			return false, NewError(ErrInvalidFilter, fmt.Sprintf("`%s' is a string attribute", f.Attr))
+		}
+
+		return matchString(f.Op, s, v)
+	}
+
+	return false, NewError(ErrInvalidFilter, "unknown expression")
+}
@@ -0,0 +1,207 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ecadlabs/auth/jq"
+)
+
+func TestParseFilter(t *testing.T) {
+	type testCase struct {
+		src string
+		res string
+		err bool
+	}
+
+	cases := []testCase{
+		{src: `userName eq "bjensen"`, res: `username eq "bjensen"`},
+		{src: `urn:ietf:params:scim:schemas:core:2.0:User:userName Eq "x"`, res: `username eq "x"`},
+		{src: `title pr`, res: `title pr`},
+		{src: `active eq true`, res: `active eq true`},
+		{src: `a eq 1 or b eq 2 and c eq 3`, res: `(a eq 1 or (b eq 2 and c eq 3))`},
+		{src: `(a eq 1 or b eq 2) and not (c eq "\"3\"")`, res: `((a eq 1 or b eq 2) and not (c eq "\"3\""))`},
+		{src: `emails[type eq "work" and value co "@example.com"]`, res: `(emails.type eq "work" and emails.value co "@example.com")`},
+		{src: `meta.lastModified gt "2011-05-13T04:42:34Z"`, res: `meta.lastmodified gt "2011-05-13T04:42:34Z"`},
+		{src: `userName`, err: true},
+		{src: `userName xx "a"`, err: true},
+		{src: `userName eq`, err: true},
+		{src: `userName eq "a`, err: true},
+		{src: `(userName eq "a"`, err: true},
+		{src: `userName eq "a" junk`, err: true},
+		{src: `a[b[c eq 1]]`, err: true},
+	}
+
+	for _, c := range cases {
+		f, err := ParseFilter(c.src)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: error expected", c.src)
+			} else if e, ok := err.(*Error); !ok || e.Type != ErrInvalidFilter {
+				t.Errorf("%s: unexpected error %v", c.src, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+
+		if s := f.String(); s != c.res {
+			t.Errorf("%s: expected %s, got %s", c.src, c.res, s)
+		}
+	}
+}
+
+var testAttributes = Attributes{
+	"id":           {Column: "id", Type: TypeID},
+	"username":     {Column: "email"},
+	"externalid":   {Column: "external_id", CaseExact: true},
+	"meta.created": {Column: "added", Type: TypeDateTime},
+	"active": {Expr: func(op string, value interface{}) (jq.Node, error) {
+		return &jq.EQExpr{Key: "status", Value: "active"}, nil
+	}},
+}
+
+func TestAttributesExpr(t *testing.T) {
+	type testCase struct {
+		src  string
+		sql  string
+		args []interface{}
+		err  bool
+	}
+
+	created := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []testCase{
+		{
+			src:  `userName eq "a.b@example.com"`,
+			sql:  `email ~* $1`,
+			args: []interface{}{`^a\.b@example\.com$`},
+		},
+		{
+			src:  `externalId eq "X" or id ne "1"`,
+			sql:  `(external_id = $1) OR (NOT (id = $2))`,
+			args: []interface{}{"X", "1"},
+		},
+		{
+			src:  `userName sw "a" and meta.created ge "2019-01-02T03:04:05Z"`,
+			sql:  `(LOWER(email) LIKE CONCAT(LOWER(CAST($1 AS TEXT)), '%')) AND (added >= $2)`,
+			args: []interface{}{"a", created},
+		},
+		{
+			src:  `externalId pr and active eq true`,
+			sql:  `(external_id <> $1) AND (status = $2)`,
+			args: []interface{}{"", "active"},
+		},
+		{src: `id co "1"`, err: true},
+		{src: `meta.created pr`, err: true},
+		{src: `meta.created gt "yesterday"`, err: true},
+		{src: `userName eq 1`, err: true},
+		{src: `nickName eq "a"`, err: true},
+	}
+
+	for _, c := range cases {
+		f, err := ParseFilter(c.src)
+		if err != nil {
+			t.Fatalf("%s: %v", c.src, err)
+		}
+
+		expr, err := testAttributes.Expr(f)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: error expected", c.src)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+
+		var idx int
+		sql, args, err := expr.SQL(&idx, jq.PostgresDriverParams, nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+
+		if sql != c.sql || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: unexpected result %s %v", c.src, sql, args)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	values := map[string]string{"displayname": "Operators", "id": "ops"}
+
+	cases := map[string]bool{
+		`displayName eq "operators"`:                true,
+		`displayName ne "operators"`:                false,
+		`displayName sw "op" and id ew "s"`:         true,
+		`not (id eq "ops") or displayName co "rat"`: true,
+		`externalId pr`:                             false,
+		`id pr`:                                     true,
+	}
+
+	for src, expected := range cases {
+		f, err := ParseFilter(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+
+		ok, err := Match(f, values)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+
+		if ok != expected {
+			t.Errorf("%s: expected %t", src, expected)
+		}
+	}
+
+	f, _ := ParseFilter(`id gt "a"`)
+	if _, err := Match(f, values); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	type testCase struct {
+		src string
+		res Path
+		err bool
+	}
+
+	cases := []testCase{
+		{src: `active`, res: Path{Attr: "active"}},
+		{src: `name.givenName`, res: Path{Attr: "name", SubAttr: "givenname"}},
+		{src: `members[value eq "2819c223"]`, res: Path{Attr: "members", Filter: &Compare{Attr: "value", Op: OpEqual, Value: "2819c223"}}},
+		{src: `emails[type eq "work"].value`, res: Path{Attr: "emails", Filter: &Compare{Attr: "type", Op: OpEqual, Value: "work"}, SubAttr: "value"}},
+		{src: `members[value eq "1"`, err: true},
+		{src: `"members"`, err: true},
+	}
+
+	for _, c := range cases {
+		p, err := ParsePath(c.src)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: error expected", c.src)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(*p, c.res) {
+			t.Errorf("%s: unexpected result %#v", c.src, p)
+		}
+	}
+}
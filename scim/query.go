@@ -0,0 +1,166 @@
+package scim
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ecadlabs/auth/jq"
+)
+
+// Attribute types
+const (
+	TypeString   = iota
+	TypeID       // Compared exactly, only eq and ne are allowed
+	TypeDateTime // RFC 3339 strings
+)
+
+// Attribute maps a SCIM attribute onto a jq column
+type Attribute struct {
+	Column    string
+	Type      int
+	CaseExact bool // Compare strings exactly with eq and ne
+	Sort      bool
+
+	// Expr overrides the default translation, i.e. for attributes without a direct column counterpart
+	Expr func(op string, value interface{}) (jq.Node, error)
+}
+
+// Attributes maps lowercase attribute paths onto columns
+type Attributes map[string]*Attribute
+
+func unsupported(attr, op string) error {
+	return NewError(ErrInvalidFilter, fmt.Sprintf("operator `%s' is not supported for `%s'", op, attr))
+}
+
+func (a *Attribute) node(attr, op string, value interface{}) (jq.Node, error) {
+	if a.Expr != nil {
+		return a.Expr(op, value)
+	}
+
+	if op == OpPresent {
+		if a.Type != TypeString {
+			return nil, unsupported(attr, op)
+		}
+		// NULL and empty values are both absent
+		return &jq.NEExpr{Key: a.Column, Value: ""}, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, NewError(ErrInvalidValue, fmt.Sprintf("`%s' requires a string value", attr))
+	}
+
+	var v interface{} = s
+	switch a.Type {
+	case TypeID:
+		if op != OpEqual && op != OpNotEqual {
+			return nil, unsupported(attr, op)
+		}
+
+	case TypeDateTime:
+		switch op {
+		case OpContains, OpStartsWith, OpEndsWith:
+			return nil, unsupported(attr, op)
+		}
+
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, NewError(ErrInvalidValue, err.Error())
+		}
+		v = t
+	}
+
+	switch op {
+	case OpEqual, OpNotEqual:
+		var n jq.Node
+		if a.Type != TypeString || a.CaseExact {
+			n = &jq.EQExpr{Key: a.Column, Value: v}
+		} else {
+			// Case insensitive match
+			n = &jq.ReExpr{Key: a.Column, Value: "^" + regexp.QuoteMeta(s) + "$"}
+		}
+
+		if op == OpNotEqual {
+			return &jq.NOTExpr{Node: n}, nil
+		}
+		return n, nil
+
+	case OpContains:
+		return &jq.SubExpr{Key: a.Column, Value: v}, nil
+	case OpStartsWith:
+		return &jq.PExpr{Key: a.Column, Value: v}, nil
+	case OpEndsWith:
+		return &jq.SExpr{Key: a.Column, Value: v}, nil
+	case OpGreater:
+		return &jq.GTExpr{Key: a.Column, Value: v}, nil
+	case OpGreaterOrEqual:
+		return &jq.GEExpr{Key: a.Column, Value: v}, nil
+	case OpLess:
+		return &jq.LTExpr{Key: a.Column, Value: v}, nil
+	case OpLessOrEqual:
+		return &jq.LEExpr{Key: a.Column, Value: v}, nil
+	}
+
+	return nil, unsupported(attr, op)
+}
+
+func (a Attributes) node(f Filter) (jq.Node, error) {
+	switch f := f.(type) {
+	case *And:
+		l, err := a.node(f.Left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := a.node(f.Right)
+		if err != nil {
+			return nil, err
+		}
+		return jq.ANDExpr{&jq.Expr{Node: l}, &jq.Expr{Node: r}}, nil
+
+	case *Or:
+		l, err := a.node(f.Left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := a.node(f.Right)
+		if err != nil {
+			return nil, err
+		}
+		return jq.ORExpr{&jq.Expr{Node: l}, &jq.Expr{Node: r}}, nil
+
+	case *Not:
+		n, err := a.node(f.Filter)
+		if err != nil {
+			return nil, err
+		}
+		return &jq.NOTExpr{Node: n}, nil
+
+	case *Compare:
+		attr, ok := a[f.Attr]
+		if !ok {
+			return nil, NewError(ErrInvalidFilter, fmt.Sprintf("unknown attribute `%s'", f.Attr))
+		}
+		return attr.node(f.Attr, f.Op, f.Value)
+	}
+
+	return nil, NewError(ErrInvalidFilter, "unknown expression")
+}
+
+// Expr translates the filter into a jq expression
+func (a Attributes) Expr(f Filter) (*jq.Expr, error) {
+	n, err := a.node(f)
+	if err != nil {
+		return nil, err
+	}
+	return &jq.Expr{Node: n}, nil
+}
+
+// SortColumn returns the column to sort by the attribute
+func (a Attributes) SortColumn(attr string) (string, error) {
+	c, ok := a[normalizeAttr(attr)]
+	if !ok || !c.Sort {
+		return "", NewError(ErrInvalidValue, fmt.Sprintf("can't sort by `%s'", attr))
+	}
+	return c.Column, nil
+}
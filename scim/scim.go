@@ -0,0 +1,280 @@
+// Package scim implements the subset of System for Cross-domain Identity Management 2.0 (RFC 7643, RFC 7644)
+// used for user provisioning: resource representations, filters and PATCH requests
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema URNs
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaConfig       = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// ContentType is the SCIM media type
+const ContentType = "application/scim+json"
+
+// Error types (RFC 7644 section 3.12)
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrTooMany       = "tooMany"
+	ErrUniqueness    = "uniqueness"
+	ErrMutability    = "mutability"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrNoTarget      = "noTarget"
+	ErrInvalidValue  = "invalidValue"
+)
+
+// Error is a SCIM error response
+type Error struct {
+	Status int    `json:"-"`
+	Type   string `json:"scimType,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Type != "" {
+		return e.Type + ": " + e.Detail
+	}
+	return e.Detail
+}
+
+// NewError returns a bad request error of the type
+func NewError(typ, detail string) *Error {
+	status := http.StatusBadRequest
+	if typ == ErrUniqueness {
+		status = http.StatusConflict
+	}
+	return &Error{Status: status, Type: typ, Detail: detail}
+}
+
+// WriteResponse writes the resource as SCIM JSON
+func WriteResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes the error response. Errors other than *Error are sent with the status and their text
+func WriteError(w http.ResponseWriter, status int, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Status: status, Detail: err.Error()}
+	}
+
+	WriteResponse(w, e.Status, &struct {
+		Schemas []string `json:"schemas"`
+		Status  string   `json:"status"`
+		*Error
+	}{
+		Schemas: []string{SchemaError},
+		Status:  strconv.Itoa(e.Status),
+		Error:   e,
+	})
+}
+
+// Meta is the resource metadata
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// Value is a multi-valued attribute item
+type Value struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Name is the user's name components
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// String returns the formatted name or joins the components
+func (n *Name) String() string {
+	if n == nil {
+		return ""
+	}
+
+	if n.Formatted != "" {
+		return n.Formatted
+	}
+
+	return strings.TrimSpace(n.GivenName + " " + n.FamilyName)
+}
+
+// User is the User resource
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Value  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Groups      []Value  `json:"groups,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Email returns the primary email, the first one or the user name
+func (u *User) Email() string {
+	for _, e := range u.Emails {
+		if e.Primary && e.Value != "" {
+			return e.Value
+		}
+	}
+
+	for _, e := range u.Emails {
+		if e.Value != "" {
+			return e.Value
+		}
+	}
+
+	return u.UserName
+}
+
+// FullName returns the display name or the name
+func (u *User) FullName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Name.String()
+}
+
+// Group is the Group resource
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Value  `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// ListResponse is the query response
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// NewListResponse returns a response page
+func NewListResponse(total, start int, resources []interface{}) *ListResponse {
+	if resources == nil {
+		resources = []interface{}{}
+	}
+
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   start,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Patch operations
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+// PatchOperation is a single modification. Op is lowercased by Validate as some clients capitalize it
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchRequest is the PATCH request body
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// Validate checks the message schema and operations
+func (p *PatchRequest) Validate() error {
+	var ok bool
+	for _, s := range p.Schemas {
+		if s == SchemaPatchOp {
+			ok = true
+		}
+	}
+
+	if !ok {
+		return NewError(ErrInvalidSyntax, "PatchOp schema expected")
+	}
+
+	if len(p.Operations) == 0 {
+		return NewError(ErrInvalidSyntax, "no operations")
+	}
+
+	for i := range p.Operations {
+		op := &p.Operations[i]
+		op.Op = strings.ToLower(op.Op)
+
+		switch op.Op {
+		case PatchAdd, PatchReplace:
+			if len(op.Value) == 0 {
+				return NewError(ErrInvalidValue, "value is required")
+			}
+		case PatchRemove:
+			if op.Path == "" {
+				return NewError(ErrNoTarget, "path is required")
+			}
+		default:
+			return NewError(ErrInvalidSyntax, "unknown operation `"+op.Op+"'")
+		}
+	}
+
+	return nil
+}
+
+// ParseBool accepts booleans and their string representation sent by some clients
+func ParseBool(data json.RawMessage) (bool, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return false, NewError(ErrInvalidValue, err.Error())
+	}
+
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+
+	return false, NewError(ErrInvalidValue, "boolean expected")
+}
+
+// ParseString decodes the string value
+func ParseString(data json.RawMessage) (string, error) {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", NewError(ErrInvalidValue, "string expected")
+	}
+	return v, nil
+}
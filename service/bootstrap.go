@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net"
 
 	uuid "github.com/satori/go.uuid"
 
+	"github.com/ecadlabs/auth/hasher"
 	"github.com/ecadlabs/auth/storage"
 	"github.com/jmoiron/sqlx"
 )
@@ -52,6 +54,13 @@ func (s *Service) Bootstrap(c *BootstrapConfig, defaultRole string) (user *stora
 	}
 
 	for _, cUser := range c.Users {
+		if cUser.Hash != "" {
+			if err = hasher.Validate([]byte(cUser.Hash)); err != nil {
+				err = fmt.Errorf("%s: %v", cUser.Email, err)
+				return
+			}
+		}
+
 		roles := make(storage.Roles)
 		roles[cUser.Role] = true
 
@@ -112,6 +121,23 @@ func (s *Service) Bootstrap(c *BootstrapConfig, defaultRole string) (user *stora
 		}
 	}
 
+	for _, client := range c.OAuthClients {
+		cl := storage.OAuthClient{
+			ID:           client.ID,
+			Name:         client.Name,
+			SecretHash:   []byte(client.SecretHash),
+			RedirectURIs: client.RedirectURIs,
+		}
+
+		if cl.RedirectURIs == nil {
+			cl.RedirectURIs = []string{}
+		}
+
+		if _, err = storage.NewOAuthClientInt(context.Background(), tx, &cl); err != nil {
+			return
+		}
+	}
+
 	_, err = tx.Exec("UPDATE bootstrap SET val = TRUE WHERE NOT val")
 	return
 }
@@ -1,8 +1,16 @@
 package service
 
 import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"time"
 
+	"github.com/ecadlabs/auth/hasher"
+	"github.com/ecadlabs/auth/keys"
 	"github.com/ecadlabs/auth/middleware"
 	"github.com/ecadlabs/auth/notification"
 	"github.com/ecadlabs/auth/utils"
@@ -23,12 +31,132 @@ type DomainsConfig struct {
 	Domains map[string]*middleware.DomainConfigData `yaml:"list"`
 }
 
+// JWTKeyConfig describes a single keyring entry. Either secret or private key must be specified
+type JWTKeyConfig struct {
+	ID         string    `yaml:"id"`
+	Secret     string    `yaml:"secret"`
+	SecretFile string    `yaml:"secret_file"`
+	PrivateKey string    `yaml:"private_key"` // PEM file
+	NotAfter   time.Time `yaml:"not_after"`
+}
+
+func (k *JWTKeyConfig) key() (*keys.Key, error) {
+	var (
+		key *keys.Key
+		err error
+	)
+
+	switch {
+	case k.PrivateKey != "":
+		if key, err = keys.LoadFile(k.PrivateKey); err != nil {
+			return nil, err
+		}
+
+		if k.ID != "" {
+			key.ID = k.ID
+		}
+
+	case k.Secret != "" || k.SecretFile != "":
+		secret := []byte(k.Secret)
+		if k.SecretFile != "" {
+			if secret, err = ioutil.ReadFile(k.SecretFile); err != nil {
+				return nil, err
+			}
+		}
+
+		key = keys.NewSecretKey(secret, JWTSigningMethod)
+		if k.ID != "" {
+			key.ID = k.ID
+		}
+
+	default:
+		return nil, fmt.Errorf("%s: %v", k.ID, keys.ErrNoKey)
+	}
+
+	key.NotAfter = k.NotAfter
+	return key, nil
+}
+
+// RateLimitRule is a token bucket applied to a route
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate"` // Requests per second
+	Burst int     `yaml:"burst"`
+	Key   string  `yaml:"key"` // "addr" (default) or "identity"
+}
+
+// RateLimitConfig maps route names to their limits
+type RateLimitConfig struct {
+	Backend string                      `yaml:"backend"` // "memory" (default) or "postgres" to share counters between replicas
+	Routes  map[string][]*RateLimitRule `yaml:"routes"`
+}
+
+func (c *RateLimitConfig) validate() error {
+	switch c.Backend {
+	case "", "memory", "postgres":
+	default:
+		return fmt.Errorf("Unknown rate limit backend: %s", c.Backend)
+	}
+
+	for route, rules := range c.Routes {
+		for _, r := range rules {
+			if r.Rate <= 0 || r.Burst < 0 {
+				return fmt.Errorf("%s: invalid rate limit", route)
+			}
+
+			switch r.Key {
+			case "", middleware.RateLimitKeyAddr, middleware.RateLimitKeyIdentity:
+			default:
+				return fmt.Errorf("%s: unknown rate limit key: %s", route, r.Key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SAMLConfig holds the service provider key pair used to sign authentication requests
+type SAMLConfig struct {
+	KeyFile  string `yaml:"key_file"`
+	CertFile string `yaml:"cert_file"`
+}
+
+type FederationConfig struct {
+	AllowInsecure bool `yaml:"allow_insecure"` // Plain http and private network identity providers, for testing only
+}
+
+// KeyPair returns nil values if no key pair is configured
+func (c *SAMLConfig) KeyPair() (*rsa.PrivateKey, *x509.Certificate, error) {
+	if c.KeyFile == "" && c.CertFile == "" {
+		return nil, nil, nil
+	}
+
+	pair, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("SAML signing key must be RSA")
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, cert, nil
+}
+
 type Config struct {
 	TLS                bool                  `yaml:"tls"`
 	TLSCert            string                `yaml:"tls_cert"`
 	TLSKey             string                `yaml:"tls_key"`
 	JWTSecret          string                `yaml:"jwt_secret"`
 	JWTSecretFile      string                `yaml:"jwt_secret_file"`
+	JWTPrivateKey      string                `yaml:"jwt_private_key"` // PEM file or directory
+	JWTKeys            []*JWTKeyConfig       `yaml:"jwt_keys"`
+	JWTActiveKey       string                `yaml:"jwt_active_key"`
 	JWTNamespace       string                `yaml:"jwt_namespace"`
 	DomainsConfig      DomainsConfig         `yaml:"domains"`
 	PostgresURL        string                `yaml:"db_url"`
@@ -38,6 +166,10 @@ type Config struct {
 	HealthAddress      string                `yaml:"health_address"`
 	DBTimeout          int                   `yaml:"db_timeout"`
 	Email              EmailConfig           `yaml:"email"`
+	RateLimit          RateLimitConfig       `yaml:"rate_limit"`
+	PasswordHash       hasher.Config         `yaml:"password_hash"`
+	SAML               SAMLConfig            `yaml:"saml"`
+	Federation         FederationConfig      `yaml:"federation"`
 	Notifier           notification.Notifier `yaml:"-"` // Testing only
 }
 
@@ -48,7 +180,7 @@ type BootstrapTenant struct {
 
 type BootstrapUser struct {
 	Email            string   `yaml:"email"`
-	Hash             string   `yaml:"hash"`
+	Hash             string   `yaml:"hash"` // bcrypt, argon2id or scrypt encoded hash
 	ID               string   `yaml:"uuid"`
 	Role             string   `yaml:"role"`
 	Type             string   `yaml:"account_type"`
@@ -61,10 +193,18 @@ type BootstrapMember struct {
 	Role     string `yaml:"role"`
 }
 
+type BootstrapOAuthClient struct {
+	ID           string   `yaml:"client_id"`
+	Name         string   `yaml:"name"`
+	SecretHash   string   `yaml:"secret_hash"` // Bcrypt hash, empty for public clients
+	RedirectURIs []string `yaml:"redirect_uris"`
+}
+
 type BootstrapConfig struct {
-	Tenants    []BootstrapTenant `yaml:"tenants"`
-	Users      []BootstrapUser   `yaml:"users"`
-	Membership []BootstrapMember `yaml:"memberships"`
+	Tenants      []BootstrapTenant      `yaml:"tenants"`
+	Users        []BootstrapUser        `yaml:"users"`
+	Membership   []BootstrapMember      `yaml:"memberships"`
+	OAuthClients []BootstrapOAuthClient `yaml:"oauth_clients"`
 }
 
 func (c *BootstrapConfig) Load(name string) error {
@@ -102,6 +242,43 @@ func (c *Config) Load(name string) error {
 	return nil
 }
 
+// Keyring returns JWT signing keys. Legacy jwt_secret and jwt_private_key go first and are followed by jwt_keys entries.
+// The key specified by jwt_active_key or the last one is used for signing
+func (c *Config) Keyring() (*keys.Keyring, error) {
+	var list []*keys.Key
+
+	if c.JWTPrivateKey != "" {
+		l, err := keys.Load(c.JWTPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l...)
+	} else if c.JWTSecret != "" {
+		list = append(list, keys.NewSecretKey([]byte(c.JWTSecret), JWTSigningMethod))
+	}
+
+	for _, kc := range c.JWTKeys {
+		key, err := kc.key()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, key)
+	}
+
+	kr, err := keys.NewKeyring(list...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.JWTActiveKey != "" {
+		if err := kr.SetActive(c.JWTActiveKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return kr, nil
+}
+
 func (c *Config) Namespace() string {
 	if c.JWTNamespace != "" {
 		return c.JWTNamespace
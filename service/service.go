@@ -1,6 +1,8 @@
 package service
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
 	"database/sql"
 	"io/ioutil"
 	"net/http"
@@ -12,7 +14,10 @@ import (
 	jwtmiddleware "github.com/auth0/go-jwt-middleware"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/ecadlabs/auth/errors"
+	"github.com/ecadlabs/auth/federation"
 	"github.com/ecadlabs/auth/handlers"
+	"github.com/ecadlabs/auth/hasher"
+	"github.com/ecadlabs/auth/keys"
 	"github.com/ecadlabs/auth/logger"
 	"github.com/ecadlabs/auth/middleware"
 	"github.com/ecadlabs/auth/notification"
@@ -28,14 +33,22 @@ import (
 const (
 	version               = "0.0.1"
 	defaultConnectTimeout = 10
+
+	// One verification email per address every 10 minutes after a burst of 3
+	defaultResendRate  = 1.0 / 600
+	defaultResendBurst = 3
 )
 
 var JWTSigningMethod = jwt.SigningMethodHS256
 
 type Service struct {
 	config    Config
+	keyring   *keys.Keyring
 	storage   *storage.Storage
 	notifier  notification.Notifier
+	hasher    hasher.Hasher
+	samlKey   *rsa.PrivateKey
+	samlCert  *x509.Certificate
 	DB        *sql.DB
 	ac        rbac.RBAC
 	enableLog bool
@@ -72,13 +85,36 @@ func New(c *Config, ac rbac.RBAC, enableLog bool) (*Service, error) {
 		}
 	}
 
+	keyring, err := c.Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.RateLimit.validate(); err != nil {
+		return nil, err
+	}
+
+	passwordHasher, err := c.PasswordHash.New()
+	if err != nil {
+		return nil, err
+	}
+
+	samlKey, samlCert, err := c.SAML.KeyPair()
+	if err != nil {
+		return nil, err
+	}
+
 	var dbCon = sqlx.NewDb(db, "postgres")
 
 	return &Service{
 		config:    *c,
+		keyring:   keyring,
 		storage:   &storage.Storage{DB: dbCon, DefaultRole: ac.GetDefaultRole()},
 		DB:        db,
 		notifier:  notifier,
+		hasher:    passwordHasher,
+		samlKey:   samlKey,
+		samlCert:  samlCert,
 		ac:        ac,
 		enableLog: enableLog,
 	}, nil
@@ -95,58 +131,76 @@ func (s *Service) APIHandler() http.Handler {
 
 	tokenFactory := &handlers.TokenFactory{
 		Namespace: s.config.Namespace(),
-		JWTSecretGetter: func() ([]byte, error) {
-			return []byte(s.config.JWTSecret), nil
-		},
-		JWTSigningMethod: JWTSigningMethod,
+		Keyring:   s.keyring,
 	}
 
+	// Honour API key permission scope
+	enforcer := &rbac.ScopedEnforcer{Enforcer: s.ac}
+
 	usersHandler := &handlers.Users{
 		Storage: s.storage,
 		Timeout: time.Duration(s.config.DBTimeout) * time.Second,
 
-		JWTSecretGetter: func() ([]byte, error) {
-			return []byte(s.config.JWTSecret), nil
-		},
-		JWTSigningMethod: JWTSigningMethod,
-
-		UsersPath:       "/users/",
-		RefreshPath:     "/refresh",
-		ResetPath:       "/password_reset",
-		LogPath:         "/logs/",
-		EmailUpdatePath: "/email_update",
-		Namespace:       s.config.Namespace(),
+		Keyring:      s.keyring,
+		TokenFactory: tokenFactory,
 
-		Enforcer: s.ac,
+		UsersPath:        "/users/",
+		RefreshPath:      "/refresh",
+		ResetPath:        "/password_reset",
+		LogPath:          "/logs/",
+		EmailUpdatePath:  "/email_update",
+		UserInfoPath:     "/userinfo",
+		JWKSPath:         "/.well-known/jwks.json",
+		AuthorizePath:    "/oauth/authorize",
+		TokenPath:        "/oauth/token",
+		IntrospectPath:   "/introspect",
+		MFAPath:          "/login/mfa",
+		WebAuthnPath:     "/webauthn",
+		LoginLinkPath:    "/login/email",
+		OIDCCallbackPath: "/login/oidc/callback",
+		SAMLMetadataPath: "/saml/metadata",
+		SAMLACSPath:      "/saml/acs",
+		VerifyEmailPath:  "/signup/verify",
+		Namespace:        s.config.Namespace(),
+
+		Enforcer: enforcer,
 
 		AuxLogger: dbLogger,
 		Notifier:  s.notifier,
+		Hasher:    s.hasher,
+
+		Federation: &federation.Client{
+			HTTPClient: federation.NewHTTPClient(s.config.Federation.AllowInsecure),
+		},
+		SAMLKey:         s.samlKey,
+		SAMLCertificate: s.samlCert,
 	}
 
 	tenantsHandler := &handlers.Tenants{
 		Storage:  s.storage,
 		Timeout:  time.Duration(s.config.DBTimeout) * time.Second,
-		Enforcer: s.ac,
+		Enforcer: enforcer,
 
 		TenantsPath:  "/tenants/",
 		InvitePath:   "/tenants/accept_invite",
 		TokenFactory: tokenFactory,
 		AuxLogger:    dbLogger,
 		Notifier:     s.notifier,
+
+		InsecureFederation: s.config.Federation.AllowInsecure,
 	}
 
 	membershipsHandler := &handlers.Memberships{
 		Storage:     s.storage,
 		Timeout:     time.Duration(s.config.DBTimeout) * time.Second,
-		Enforcer:    s.ac,
+		Enforcer:    enforcer,
 		TenantsPath: "/tenants/",
 		UsersPath:   "/users/",
 		AuxLogger:   dbLogger,
 	}
 
 	jwtOptions := jwtmiddleware.Options{
-		ValidationKeyGetter: func(token *jwt.Token) (interface{}, error) { return []byte(s.config.JWTSecret), nil },
-		SigningMethod:       JWTSigningMethod,
+		ValidationKeyGetter: s.keyring.Keyfunc,
 		UserProperty:        middleware.TokenContextKey,
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err string) {
 			utils.JSONError(w, err, errors.CodeUnauthorized)
@@ -163,10 +217,68 @@ func (s *Service) APIHandler() http.Handler {
 		Namespace: s.config.Namespace(),
 	}
 
+	// Reject tokens of revoked sessions
+	session := &middleware.Session{
+		Storage:   s.storage,
+		Namespace: s.config.Namespace(),
+	}
+
+	// Reject revoked tokens
+	revocation := &middleware.Revocation{
+		Storage: s.storage,
+	}
+
+	// Mark requests made with impersonation tokens
+	actor := &middleware.Actor{}
+
 	domainData := &middleware.DomainConfig{
 		Storage: &s.config,
 	}
 
+	// Throttle public endpoints
+	var rateLimitBackend middleware.RateLimitBackend
+	if s.config.RateLimit.Backend == "postgres" {
+		rateLimitBackend = s.storage
+	} else {
+		rateLimitBackend = middleware.NewMemoryRateLimit()
+	}
+
+	limit := func(route string, h http.HandlerFunc) http.Handler {
+		var res http.Handler = h
+		rules := s.config.RateLimit.Routes[route]
+		for i := len(rules) - 1; i >= 0; i-- {
+			burst := rules[i].Burst
+			if burst == 0 {
+				burst = 1
+			}
+
+			rl := &middleware.RateLimit{
+				Name:    route,
+				Rate:    rules[i].Rate,
+				Burst:   burst,
+				KeyType: rules[i].Key,
+				Backend: rateLimitBackend,
+			}
+			res = rl.Handler(res)
+		}
+		return res
+	}
+
+	// Verification emails sent again to the same address
+	resend := &middleware.RateLimit{
+		Name:    "resend_verification",
+		Rate:    defaultResendRate,
+		Burst:   defaultResendBurst,
+		Backend: rateLimitBackend,
+	}
+	if rules := s.config.RateLimit.Routes[resend.Name]; len(rules) != 0 {
+		resend.Rate, resend.Burst = rules[0].Rate, rules[0].Burst
+		if resend.Burst == 0 {
+			resend.Burst = 1
+		}
+	}
+	usersHandler.ResendLimit = resend
+
 	m := mux.NewRouter()
 
 	m.Use(middleware.NewPrometheusWithHandlerID().Handler)
@@ -176,11 +288,33 @@ func (s *Service) APIHandler() http.Handler {
 	m.Use((&middleware.Recover{}).Handler)
 	m.Use(domainData.Handler)
 
+	m.Methods("GET").Path("/.well-known/jwks.json").Handler(&handlers.JWKSHandler{Keyring: s.keyring})
+	m.Methods("GET").Path("/.well-known/openid-configuration").HandlerFunc(usersHandler.OpenIDConfiguration)
+
 	// Login API
-	m.Methods("POST").Path("/password_reset").HandlerFunc(usersHandler.ResetPassword)
-	m.Methods("GET", "POST").Path("/request_password_reset").HandlerFunc(usersHandler.SendResetRequest)
-	m.Methods("GET", "POST").Path("/login/{id}").HandlerFunc(usersHandler.Login)
-	m.Methods("GET", "POST").Path("/login").HandlerFunc(usersHandler.Login)
+	m.Methods("POST").Path("/password_reset").Handler(limit("password_reset", usersHandler.ResetPassword))
+	m.Methods("GET", "POST").Path("/request_password_reset").Handler(limit("request_password_reset", usersHandler.SendResetRequest))
+	m.Methods("POST").Path("/login/mfa").Handler(limit("login_mfa", usersHandler.LoginMFA))
+	m.Methods("POST").Path("/login/email").Handler(limit("login_email", usersHandler.EmailLogin))
+	m.Methods("POST").Path("/login/webauthn/begin").Handler(limit("login_webauthn", usersHandler.BeginWebAuthnLogin))
+	m.Methods("POST").Path("/login/webauthn/finish").Handler(limit("login_webauthn", usersHandler.FinishWebAuthnLogin))
+	m.Methods("GET").Path("/login/oidc/callback").Handler(limit("login_oidc", usersHandler.OIDCCallback))
+	m.Methods("GET").Path("/login/oidc/{connectionId}").Handler(limit("login_oidc", usersHandler.BeginOIDCLogin))
+	m.Methods("GET").Path("/login/saml/{connectionId}").Handler(limit("login_saml", usersHandler.BeginSAMLLogin))
+	m.Methods("GET", "POST").Path("/login/{id}").Handler(limit("login", usersHandler.Login))
+	m.Methods("GET", "POST").Path("/login").Handler(limit("login", usersHandler.Login))
+
+	// Self-service registration, enabled per domain
+	m.Methods("POST").Path("/signup").Handler(limit("signup", usersHandler.Signup))
+	m.Methods("POST").Path("/signup/verify").Handler(limit("verify_email", usersHandler.VerifyEmail))
+
+	// SAML service provider
+	m.Methods("GET").Path("/saml/metadata").HandlerFunc(usersHandler.SAMLMetadata)
+	m.Methods("POST").Path("/saml/acs").Handler(limit("login_saml", usersHandler.SAMLACS))
+
+	// OAuth2 API
+	m.Methods("GET", "POST").Path("/oauth/authorize").Handler(limit("oauth_authorize", usersHandler.Authorize))
+	m.Methods("POST").Path("/oauth/token").Handler(limit("oauth_token", usersHandler.Token))
 
 	userdata := &middleware.UserData{
 		Storage: s.storage,
@@ -196,14 +330,26 @@ func (s *Service) APIHandler() http.Handler {
 		Namespace: s.config.Namespace(),
 	}
 
-	m.Methods("GET").Path("/refresh").Handler(jwtMiddleware.Handler(aud.Handler(userdata.Handler(membershipData.Handler(http.HandlerFunc(usersHandler.Refresh))))))
+	// Every token authenticated endpoint goes through the same chain
+	authenticated := func(h http.Handler) http.Handler {
+		return jwtMiddleware.Handler(session.Handler(revocation.Handler(actor.Handler(serviceAPI.Handler(aud.Handler(h))))))
+	}
+
+	m.Methods("POST").Path("/refresh").Handler(limit("refresh", usersHandler.Refresh))
+	m.Methods("POST").Path("/introspect").Handler(limit("introspect", usersHandler.Introspect))
+	m.Methods("POST").Path("/revoke").Handler(authenticated(userdata.Handler(membershipData.Handler(http.HandlerFunc(usersHandler.RevokeToken)))))
+	m.Methods("GET", "POST").Path("/userinfo").Handler(authenticated(actor.Deny(userdata.Handler(http.HandlerFunc(usersHandler.UserInfo)))))
+	m.Methods("POST").Path("/logout").Handler(authenticated(userdata.Handler(http.HandlerFunc(usersHandler.Logout))))
 
 	// Users API
-	m.Methods("POST").Path("/request_email_update").Handler(jwtMiddleware.Handler(aud.Handler(userdata.Handler(http.HandlerFunc(usersHandler.SendUpdateEmailRequest)))))
-	m.Methods("POST").Path("/email_update").HandlerFunc(usersHandler.UpdateEmail)
+	m.Methods("POST").Path("/request_email_update").Handler(authenticated(actor.Deny(userdata.Handler(http.HandlerFunc(usersHandler.SendUpdateEmailRequest)))))
+	m.Methods("POST").Path("/email_update").Handler(limit("email_update", usersHandler.UpdateEmail))
 
 	umux := m.PathPrefix("/users").Subrouter()
 	umux.Use(jwtMiddleware.Handler)
+	umux.Use(session.Handler)
+	umux.Use(revocation.Handler)
+	umux.Use(actor.Handler)
 	umux.Use(serviceAPI.Handler)
 	umux.Use(aud.Handler)
 	umux.Use(userdata.Handler)
@@ -212,19 +358,43 @@ func (s *Service) APIHandler() http.Handler {
 	umux.Methods("POST").Path("/").HandlerFunc(usersHandler.NewUser)
 	umux.Methods("GET").Path("/").HandlerFunc(usersHandler.GetUsers)
 	umux.Methods("GET").Path("/{id}").HandlerFunc(usersHandler.GetUser)
-	umux.Methods("PATCH").Path("/{id}").HandlerFunc(usersHandler.PatchUser)
-	umux.Methods("DELETE").Path("/{id}").HandlerFunc(usersHandler.DeleteUser)
+	umux.Methods("PATCH").Path("/{id}").Handler(actor.Deny(http.HandlerFunc(usersHandler.PatchUser)))
+	umux.Methods("DELETE").Path("/{id}").Handler(actor.Deny(http.HandlerFunc(usersHandler.DeleteUser)))
 	umux.Methods("GET").Path("/{userId}/memberships/").HandlerFunc(membershipsHandler.FindUserMemberships)
 
-	umux.Methods("POST").Path("/{userId}/api_keys/").HandlerFunc(usersHandler.NewAPIKey)
+	umux.Methods("POST").Path("/{userId}/api_keys/").Handler(actor.Deny(http.HandlerFunc(usersHandler.NewAPIKey)))
 	umux.Methods("GET").Path("/{userId}/api_keys/{keyId}").HandlerFunc(usersHandler.GetAPIKey)
 	umux.Methods("GET").Path("/{userId}/api_keys/").HandlerFunc(usersHandler.GetAPIKeys)
 	umux.Methods("DELETE").Path("/{userId}/api_keys/{keyId}").HandlerFunc(usersHandler.DeleteAPIKey)
-	umux.Methods("GET").Path("/{userId}/api_keys/{keyId}/token").HandlerFunc(usersHandler.GetAPIToken)
+	umux.Methods("GET", "POST").Path("/{userId}/api_keys/{keyId}/token").Handler(actor.Deny(http.HandlerFunc(usersHandler.GetAPIToken)))
+	umux.Methods("POST").Path("/{userId}/api_keys/{keyId}/rotate").Handler(actor.Deny(http.HandlerFunc(usersHandler.RotateAPIKey)))
+
+	umux.Methods("POST").Path("/{userId}/password").Handler(actor.Deny(http.HandlerFunc(usersHandler.ChangePassword)))
+	umux.Methods("POST").Path("/{userId}/impersonate").Handler(actor.Deny(http.HandlerFunc(usersHandler.Impersonate)))
+
+	umux.Methods("POST").Path("/{userId}/mfa/totp").Handler(actor.Deny(http.HandlerFunc(usersHandler.EnrollTOTP)))
+	umux.Methods("POST").Path("/{userId}/mfa/totp/verify").Handler(actor.Deny(http.HandlerFunc(usersHandler.ConfirmTOTP)))
+	umux.Methods("DELETE").Path("/{userId}/mfa/totp").Handler(actor.Deny(http.HandlerFunc(usersHandler.DeleteTOTP)))
+
+	umux.Methods("POST").Path("/{userId}/webauthn/register/begin").Handler(actor.Deny(http.HandlerFunc(usersHandler.BeginWebAuthnRegistration)))
+	umux.Methods("POST").Path("/{userId}/webauthn/register/finish").Handler(actor.Deny(http.HandlerFunc(usersHandler.FinishWebAuthnRegistration)))
+	umux.Methods("GET").Path("/{userId}/webauthn/").HandlerFunc(usersHandler.GetWebAuthnCredentials)
+	umux.Methods("DELETE").Path("/{userId}/webauthn/{credentialId}").Handler(actor.Deny(http.HandlerFunc(usersHandler.DeleteWebAuthnCredential)))
+
+	umux.Methods("POST").Path("/{userId}/identities/").Handler(actor.Deny(http.HandlerFunc(usersHandler.NewFederatedIdentity)))
+	umux.Methods("GET").Path("/{userId}/identities/").HandlerFunc(usersHandler.GetFederatedIdentities)
+	umux.Methods("DELETE").Path("/{userId}/identities/{connectionId}").Handler(actor.Deny(http.HandlerFunc(usersHandler.DeleteFederatedIdentity)))
+
+	umux.Methods("GET").Path("/{userId}/sessions/").Handler(actor.Deny(http.HandlerFunc(usersHandler.GetSessions)))
+	umux.Methods("DELETE").Path("/{userId}/sessions/").Handler(actor.Deny(http.HandlerFunc(usersHandler.DeleteSessions)))
+	umux.Methods("DELETE").Path("/{userId}/sessions/{sessionId}").Handler(actor.Deny(http.HandlerFunc(usersHandler.DeleteSession)))
 
 	// Tenants API
 	tmux := m.PathPrefix("/tenants").Subrouter()
 	tmux.Use(jwtMiddleware.Handler)
+	tmux.Use(session.Handler)
+	tmux.Use(revocation.Handler)
+	tmux.Use(actor.Handler)
 	tmux.Use(serviceAPI.Handler)
 	tmux.Use(aud.Handler)
 	tmux.Use(membershipData.Handler)
@@ -240,13 +410,29 @@ func (s *Service) APIHandler() http.Handler {
 	tmux.Methods("PATCH").Path("/{tenantId}/members/{userId}").HandlerFunc(membershipsHandler.PatchMembership)
 	tmux.Methods("DELETE").Path("/{tenantId}/members/{userId}").HandlerFunc(membershipsHandler.DeleteMembership)
 
+	tmux.Methods("POST").Path("/{tenantId}/oidc/").HandlerFunc(tenantsHandler.NewOIDCConnection)
+	tmux.Methods("GET").Path("/{tenantId}/oidc/").HandlerFunc(tenantsHandler.GetOIDCConnections)
+	tmux.Methods("GET").Path("/{tenantId}/oidc/{connectionId}").HandlerFunc(tenantsHandler.GetOIDCConnection)
+	tmux.Methods("PATCH").Path("/{tenantId}/oidc/{connectionId}").HandlerFunc(tenantsHandler.PatchOIDCConnection)
+	tmux.Methods("DELETE").Path("/{tenantId}/oidc/{connectionId}").HandlerFunc(tenantsHandler.DeleteOIDCConnection)
+
+	tmux.Methods("POST").Path("/{tenantId}/saml/").HandlerFunc(tenantsHandler.NewSAMLConnection)
+	tmux.Methods("GET").Path("/{tenantId}/saml/").HandlerFunc(tenantsHandler.GetSAMLConnections)
+	tmux.Methods("GET").Path("/{tenantId}/saml/{connectionId}").HandlerFunc(tenantsHandler.GetSAMLConnection)
+	tmux.Methods("PATCH").Path("/{tenantId}/saml/{connectionId}").HandlerFunc(tenantsHandler.PatchSAMLConnection)
+	tmux.Methods("DELETE").Path("/{tenantId}/saml/{connectionId}").HandlerFunc(tenantsHandler.DeleteSAMLConnection)
+
 	amux := m.PathPrefix("/tenants/accept_invite").Subrouter()
 
-	amux.Methods("POST").Path("").HandlerFunc(tenantsHandler.AcceptInvite)
+	amux.Methods("POST").Path("").Handler(limit("accept_invite", tenantsHandler.AcceptInvite))
 
 	// Members API
 	mmux := m.PathPrefix("/members").Subrouter()
 	mmux.Use(jwtMiddleware.Handler)
+	mmux.Use(session.Handler)
+	mmux.Use(revocation.Handler)
+	mmux.Use(actor.Handler)
+	mmux.Use(serviceAPI.Handler)
 	mmux.Use(aud.Handler)
 	mmux.Use(userdata.Handler)
 	mmux.Use(membershipData.Handler)
@@ -256,6 +442,9 @@ func (s *Service) APIHandler() http.Handler {
 	// Log API
 	lmux := m.PathPrefix("/logs").Subrouter()
 	lmux.Use(jwtMiddleware.Handler)
+	lmux.Use(session.Handler)
+	lmux.Use(revocation.Handler)
+	lmux.Use(actor.Handler)
 	lmux.Use(serviceAPI.Handler)
 	lmux.Use(aud.Handler)
 	lmux.Use(userdata.Handler)
@@ -271,6 +460,8 @@ func (s *Service) APIHandler() http.Handler {
 
 	rmux := m.PathPrefix("/rbac").Subrouter()
 	rmux.Use(jwtMiddleware.Handler)
+	rmux.Use(session.Handler)
+	rmux.Use(actor.Handler)
 	rmux.Use(serviceAPI.Handler)
 
 	rmux.Methods("GET").Path("/roles/").HandlerFunc(rbacHandler.GetRoles)
@@ -278,6 +469,40 @@ func (s *Service) APIHandler() http.Handler {
 	rmux.Methods("GET").Path("/permissions/").HandlerFunc(rbacHandler.GetPermissions)
 	rmux.Methods("GET").Path("/permissions/{id}").HandlerFunc(rbacHandler.GetPermission)
 
+	// SCIM provisioning API
+	scimHandler := &handlers.SCIM{
+		Storage:   s.storage,
+		Timeout:   time.Duration(s.config.DBTimeout) * time.Second,
+		Enforcer:  enforcer,
+		Roles:     s.ac,
+		BasePath:  "/scim/v2/",
+		AuxLogger: dbLogger,
+
+		TokenFactory: tokenFactory,
+		InvitePath:   "/tenants/accept_invite",
+		Notifier:     s.notifier,
+	}
+
+	smux := m.PathPrefix("/scim/v2").Subrouter()
+	smux.Use(func(h http.Handler) http.Handler { return limit("scim", h.ServeHTTP) })
+	smux.Use(scimHandler.Handler)
+
+	smux.Methods("GET").Path("/ServiceProviderConfig").HandlerFunc(scimHandler.ServiceProviderConfig)
+
+	smux.Methods("GET").Path("/Users").HandlerFunc(scimHandler.GetUsers)
+	smux.Methods("POST").Path("/Users").HandlerFunc(scimHandler.NewUser)
+	smux.Methods("GET").Path("/Users/{id}").HandlerFunc(scimHandler.GetUser)
+	smux.Methods("PUT").Path("/Users/{id}").HandlerFunc(scimHandler.ReplaceUser)
+	smux.Methods("PATCH").Path("/Users/{id}").HandlerFunc(scimHandler.PatchUser)
+	smux.Methods("DELETE").Path("/Users/{id}").HandlerFunc(scimHandler.DeleteUser)
+
+	smux.Methods("GET").Path("/Groups").HandlerFunc(scimHandler.GetGroups)
+	smux.Methods("POST").Path("/Groups").HandlerFunc(scimHandler.NewGroup)
+	smux.Methods("GET").Path("/Groups/{id}").HandlerFunc(scimHandler.GetGroup)
+	smux.Methods("PUT").Path("/Groups/{id}").HandlerFunc(scimHandler.ReplaceGroup)
+	smux.Methods("PATCH").Path("/Groups/{id}").HandlerFunc(scimHandler.PatchGroup)
+	smux.Methods("DELETE").Path("/Groups/{id}").HandlerFunc(scimHandler.DeleteGroup)
+
 	m.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		utils.JSONErrorResponse(w, errors.ErrResourceNotFound)
 		if !s.enableLog {
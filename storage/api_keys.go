@@ -5,6 +5,7 @@ import (
 	"database/sql"
 
 	"github.com/ecadlabs/auth/errors"
+	"github.com/lib/pq"
 	"github.com/satori/go.uuid"
 )
 
@@ -14,7 +15,13 @@ const apiKeyQuery = `
       service_account_keys.membership_id,
       membership.user_id,
       membership.tenant_id,
-      service_account_keys.added
+      service_account_keys.label,
+      service_account_keys.added,
+      service_account_keys.expires,
+      service_account_keys.last_used,
+      service_account_keys.roles,
+      service_account_keys.permissions,
+      service_account_keys.secret_hash
     FROM
       service_account_keys
       INNER JOIN membership ON service_account_keys.membership_id = membership.id
@@ -34,6 +41,20 @@ func (s *Storage) GetKey(ctx context.Context, userID, keyID uuid.UUID) (*APIKey,
 	return &key, nil
 }
 
+// GetKeyByID returns the key regardless of the owner. Used for client credentials authentication
+func (s *Storage) GetKeyByID(ctx context.Context, keyID uuid.UUID) (*APIKey, error) {
+	var key APIKey
+	if err := s.DB.GetContext(ctx, &key, apiKeyQuery+" WHERE service_account_keys.id = $1", keyID); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
 func (s *Storage) GetKeys(ctx context.Context, uid uuid.UUID) ([]*APIKey, error) {
 	var keys []*APIKey
 	if err := s.DB.SelectContext(ctx, &keys, apiKeyQuery+" WHERE users.id = $1 ORDER BY service_account_keys.added", uid); err != nil {
@@ -43,13 +64,18 @@ func (s *Storage) GetKeys(ctx context.Context, uid uuid.UUID) ([]*APIKey, error)
 	return keys, nil
 }
 
-func (s *Storage) NewKey(ctx context.Context, userID, tenantID uuid.UUID) (*APIKey, error) {
+func (s *Storage) NewKey(ctx context.Context, key *APIKey) (*APIKey, error) {
 	q := `
 		WITH k AS (
 		  INSERT INTO
-		    service_account_keys (membership_id)
+		    service_account_keys (membership_id, secret_hash, label, expires, roles, permissions)
 		  SELECT
-		    membership.id
+		    membership.id,
+		    $3,
+		    $4,
+		    $5,
+		    $6,
+		    $7
 		  FROM
 		    membership
 		    INNER JOIN users ON membership.user_id = users.id
@@ -63,13 +89,28 @@ func (s *Storage) NewKey(ctx context.Context, userID, tenantID uuid.UUID) (*APIK
 		  k.membership_id,
 		  membership.user_id,
 		  membership.tenant_id,
-		  k.added
+		  k.label,
+		  k.added,
+		  k.expires,
+		  k.last_used,
+		  k.roles,
+		  k.permissions,
+		  k.secret_hash
 		FROM
 		  k
 		  INNER JOIN membership ON k.membership_id = membership.id`
 
-	var key APIKey
-	if err := s.DB.GetContext(ctx, &key, q, userID, tenantID); err != nil {
+	roles, permissions := key.Roles, key.Permissions
+	if roles == nil {
+		roles = pq.StringArray{}
+	}
+
+	if permissions == nil {
+		permissions = pq.StringArray{}
+	}
+
+	var res APIKey
+	if err := s.DB.GetContext(ctx, &res, q, key.UserID, key.TenantID, key.SecretHash, key.Label, key.Expires, roles, permissions); err != nil {
 		if err == sql.ErrNoRows {
 			err = errors.ErrMembershipNotFound
 		}
@@ -77,7 +118,43 @@ func (s *Storage) NewKey(ctx context.Context, userID, tenantID uuid.UUID) (*APIK
 		return nil, err
 	}
 
-	return &key, nil
+	return &res, nil
+}
+
+// UpdateKeySecret replaces the key secret keeping the membership binding
+func (s *Storage) UpdateKeySecret(ctx context.Context, userID, keyID uuid.UUID, secretHash string) (*APIKey, error) {
+	q := `
+        UPDATE
+          service_account_keys
+        SET
+          secret_hash = $3
+        FROM
+          membership
+        WHERE
+          service_account_keys.membership_id = membership.id
+          AND service_account_keys.id = $1
+          AND membership.user_id = $2`
+
+	res, err := s.DB.ExecContext(ctx, q, keyID, userID, secretHash)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rows == 0 {
+		return nil, errors.ErrKeyNotFound
+	}
+
+	return s.GetKey(ctx, userID, keyID)
+}
+
+func (s *Storage) UpdateKeyLastUsed(ctx context.Context, keyID uuid.UUID) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE service_account_keys SET last_used = NOW() WHERE id = $1", keyID)
+	return err
 }
 
 func (s *Storage) DeleteKey(ctx context.Context, userID, keyID uuid.UUID) error {
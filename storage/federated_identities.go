@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// FederatedIdentity allows the account to log in through the identity provider connection. Links are created
+// by the account holder, by an administrator or when the connection provisions the account
+type FederatedIdentity struct {
+	UserID       uuid.UUID `db:"user_id" json:"user_id"`
+	ConnectionID uuid.UUID `db:"connection_id" json:"connection_id"`
+	Added        time.Time `db:"added" json:"added"`
+}
+
+func (s *Storage) NewFederatedIdentity(ctx context.Context, userID, connectionID uuid.UUID) (*FederatedIdentity, error) {
+	q := `
+		INSERT INTO federated_identities (user_id, connection_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, connection_id) DO UPDATE SET added = federated_identities.added
+		RETURNING *`
+
+	var res FederatedIdentity
+	if err := s.DB.GetContext(ctx, &res, q, userID, connectionID); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// HasFederatedIdentity returns true if the account is linked to the connection
+func (s *Storage) HasFederatedIdentity(ctx context.Context, userID, connectionID uuid.UUID) (bool, error) {
+	var ok bool
+	err := s.DB.GetContext(ctx, &ok, "SELECT EXISTS (SELECT 1 FROM federated_identities WHERE user_id = $1 AND connection_id = $2)", userID, connectionID)
+	return ok, err
+}
+
+func (s *Storage) GetFederatedIdentities(ctx context.Context, userID uuid.UUID) ([]*FederatedIdentity, error) {
+	var res []*FederatedIdentity
+	if err := s.DB.SelectContext(ctx, &res, "SELECT * FROM federated_identities WHERE user_id = $1 ORDER BY added", userID); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *Storage) DeleteFederatedIdentity(ctx context.Context, userID, connectionID uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM federated_identities WHERE user_id = $1 AND connection_id = $2", userID, connectionID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrIdentityNotFound
+	}
+
+	return nil
+}
@@ -24,6 +24,7 @@ type logEntryModel struct {
 	Data       []byte         `db:"data"`
 	Address    string         `db:"addr"`
 	Message    sql.NullString `db:"msg"`
+	ActorID    uuid.NullUUID  `db:"actor_id"`
 	SortedBy   string         `db:"_sorted_by"` // Output only
 }
 
@@ -40,6 +41,10 @@ func (l *logEntryModel) toLogEntry() *LogEntry {
 		Message:    l.Message.String,
 	}
 
+	if l.ActorID.Valid {
+		ret.ActorID = &l.ActorID.UUID
+	}
+
 	if len(l.Data) != 0 {
 		if err := json.Unmarshal(l.Data, &ret.Data); err != nil {
 			log.Error(err)
@@ -56,6 +61,7 @@ var logQueryColumns = jq.Columns{
 	"source_id": {ColumnExpr: "source_id", Sort: true},
 	"target_id": {ColumnExpr: "target_id", Sort: true},
 	"addr":      {ColumnExpr: "addr", Sort: true},
+	"actor_id":  {ColumnExpr: "actor_id", Sort: true},
 }
 
 // GetLogs retrive logs from the database as a paged results
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Login failure counter kinds
+const (
+	LoginFailuresUser = "user"
+	LoginFailuresAddr = "addr"
+)
+
+// LoginFailures counts consecutive failed login attempts per account or source address
+type LoginFailures struct {
+	Kind        string     `db:"kind" json:"-"`
+	Key         string     `db:"key" json:"-"`
+	Failures    int        `db:"failures" json:"failures"`
+	LastFailure time.Time  `db:"last_failure" json:"last_failure"`
+	LockedUntil *time.Time `db:"locked_until" json:"locked_until,omitempty"`
+}
+
+// Locked returns true if login attempts are not allowed at the moment
+func (l *LoginFailures) Locked() bool {
+	return l.LockedUntil != nil && time.Now().Before(*l.LockedUntil)
+}
+
+// GetLoginFailures returns the counter state. Zero value is returned if nothing was recorded
+func (s *Storage) GetLoginFailures(ctx context.Context, kind, key string) (*LoginFailures, error) {
+	var res LoginFailures
+	if err := s.DB.GetContext(ctx, &res, "SELECT * FROM login_failures WHERE kind = $1 AND key = $2", kind, key); err != nil {
+		if err == sql.ErrNoRows {
+			return &LoginFailures{Kind: kind, Key: key}, nil
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// AddLoginFailure increments the counter. Failures older than the window are forgotten
+func (s *Storage) AddLoginFailure(ctx context.Context, kind, key string, window time.Duration) (*LoginFailures, error) {
+	q := `
+		INSERT INTO
+		  login_failures (kind, key, failures)
+		VALUES
+		  ($1, $2, 1) ON CONFLICT (kind, key) DO
+		UPDATE
+		SET
+		  failures = CASE
+		    WHEN login_failures.last_failure < NOW() - $3 * INTERVAL '1 second' THEN 1
+		    ELSE login_failures.failures + 1
+		  END,
+		  last_failure = NOW() RETURNING *`
+
+	// Purge stale counters
+	if _, err := s.DB.ExecContext(ctx, "DELETE FROM login_failures WHERE last_failure < NOW() - $1 * INTERVAL '1 second' AND (locked_until IS NULL OR locked_until < NOW())", window.Seconds()); err != nil {
+		return nil, err
+	}
+
+	var res LoginFailures
+	if err := s.DB.GetContext(ctx, &res, q, kind, key, window.Seconds()); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// LockLogin disables login attempts until the specified time
+func (s *Storage) LockLogin(ctx context.Context, kind, key string, until time.Time) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE login_failures SET locked_until = $3 WHERE kind = $1 AND key = $2", kind, key, until)
+	return err
+}
+
+// ResetLoginFailures clears the counter and the lockout
+func (s *Storage) ResetLoginFailures(ctx context.Context, kind, key string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM login_failures WHERE kind = $1 AND key = $2", kind, key)
+	return err
+}
@@ -22,8 +22,10 @@ type membershipModel struct {
 	MembershipStatus string         `db:"membership_status"`
 	Added            time.Time      `db:"added"`
 	Modified         time.Time      `db:"modified"`
+	ExternalID       sql.NullString `db:"external_id"`
 	Roles            pq.StringArray `db:"roles"`
 	Email            string         `db:"email"`
+	Name             string         `db:"name"`
 	SortedBy         string         `db:"_sorted_by"`
 }
 
@@ -37,6 +39,8 @@ func (m *membershipModel) toMembership() *Membership {
 		Added:            m.Added,
 		Modified:         m.Modified,
 		Email:            m.Email,
+		Name:             m.Name,
+		ExternalID:       m.ExternalID.String,
 		Roles:            make(Roles, len(m.Roles)),
 	}
 
@@ -64,6 +68,10 @@ func (s *Storage) AddMembership(ctx context.Context, id uuid.UUID, user *User, s
 		err = tx.Commit()
 	}()
 
+	if len(role) == 0 {
+		role = Roles{s.DefaultRole: true}
+	}
+
 	err = s.AddMembershipInt(ctx, tx, id, user.ID, status, membershipType, role)
 	if err != nil {
 		return err
@@ -115,7 +123,8 @@ func (s *Storage) GetMembership(ctx context.Context, id uuid.UUID, userID uuid.U
 	SELECT
 	  membership.*,
 	  r.roles,
-	  users.email
+	  users.email,
+	  COALESCE(users.name, '') AS name
 	FROM
 	  membership
 	  INNER JOIN users ON membership.user_id = users.id
@@ -148,6 +157,7 @@ func (s *Storage) GetMembership(ctx context.Context, id uuid.UUID, userID uuid.U
 var membershipUpdatePath = map[string]struct{}{
 	"membership_type":   struct{}{},
 	"membership_status": struct{}{},
+	"external_id":       struct{}{},
 }
 
 // UpdateMembership update a membership
@@ -201,6 +211,8 @@ func (s *Storage) UpdateMembership(ctx context.Context, id uuid.UUID, userID uui
 	if err = tx.GetContext(ctx, &u, expr, args...); err != nil {
 		if err == sql.ErrNoRows {
 			err = errors.ErrUserNotFound
+		} else if isUniqueViolation(err, "membership_external_id_idx") {
+			err = errors.ErrExternalIDInUse
 		}
 		return nil, err
 	}
@@ -269,6 +281,10 @@ var membershipsQueryColumns = jq.Columns{
 	"membership_type":   {ColumnExpr: "membership.membership_type", Sort: true},
 	"membership_status": {ColumnExpr: "membership.membership_status", Sort: true},
 	"roles":             {ColumnExpr: "r.roles"},
+	"email":             {ColumnExpr: "users.email", Sort: true},
+	"name":              {ColumnExpr: "COALESCE(users.name, '')", Sort: true},
+	"external_id":       {ColumnExpr: "membership.external_id", Sort: true},
+	"account_type":      {ColumnExpr: "users.account_type"},
 }
 
 // GetMemberships get memberships from the database as a paged result
@@ -286,7 +302,7 @@ func (s *Storage) GetMemberships(ctx context.Context, query *jq.Query) (membersh
 	}
 
 	selOpt := jq.Options{
-		SelectExpr: fmt.Sprintf("SELECT membership.*, r.roles, users.email, %s AS _sorted_by", sortExpr),
+		SelectExpr: fmt.Sprintf("SELECT membership.*, r.roles, users.email, COALESCE(users.name, '') AS name, %s AS _sorted_by", sortExpr),
 		FromExpr: `
 		FROM
 			membership
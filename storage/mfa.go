@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// TOTP represents user's authenticator enrollment. The enrollment becomes effective after confirmation
+type TOTP struct {
+	UserID    uuid.UUID `db:"user_id"`
+	Secret    string    `db:"secret"`
+	Confirmed bool      `db:"confirmed"`
+	LastStep  int64     `db:"last_step"`
+	Added     time.Time `db:"added"`
+}
+
+// NewTOTP starts a new enrollment replacing unconfirmed one
+func (s *Storage) NewTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	q := `
+		INSERT INTO
+		  mfa_totp (user_id, secret)
+		VALUES
+		  ($1, $2) ON CONFLICT (user_id) DO
+		UPDATE
+		SET
+		  secret = EXCLUDED.secret,
+		  last_step = 0,
+		  added = NOW()
+		WHERE
+		  NOT mfa_totp.confirmed`
+
+	res, err := s.DB.ExecContext(ctx, q, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrMFAEnrolled
+	}
+
+	return nil
+}
+
+func (s *Storage) GetTOTP(ctx context.Context, userID uuid.UUID) (*TOTP, error) {
+	var res TOTP
+	if err := s.DB.GetContext(ctx, &res, "SELECT * FROM mfa_totp WHERE user_id = $1", userID); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// ConfirmTOTP completes the enrollment and replaces recovery codes
+func (s *Storage) ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryHashes []string) (err error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE mfa_totp SET confirmed = TRUE, last_step = $2 WHERE user_id = $1 AND NOT confirmed", userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrMFANotEnrolled
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	for _, h := range recoveryHashes {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the used time step. Steps not newer than the last used one are refused to prevent code replay
+func (s *Storage) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	res, err := s.DB.ExecContext(ctx, "UPDATE mfa_totp SET last_step = $2 WHERE user_id = $1 AND confirmed AND last_step < $2", userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrMFACode
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks the recovery code as used
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	res, err := s.DB.ExecContext(ctx, "UPDATE mfa_recovery_codes SET used = TRUE WHERE user_id = $1 AND code_hash = $2 AND NOT used", userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrMFACode
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the enrollment along with recovery codes
+func (s *Storage) DeleteTOTP(ctx context.Context, userID uuid.UUID) (err error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM mfa_totp WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrMFANotEnrolled
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	return err
+}
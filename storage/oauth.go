@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// OAuthClient represents registered OAuth2 client
+type OAuthClient struct {
+	ID           string         `db:"id" json:"id"`
+	Name         string         `db:"name" json:"name"`
+	SecretHash   []byte         `db:"secret_hash" json:"-"` // Empty for public clients
+	RedirectURIs pq.StringArray `db:"redirect_uris" json:"redirect_uris"`
+	Added        time.Time      `db:"added" json:"added"`
+	Modified     time.Time      `db:"modified" json:"modified"`
+}
+
+// Public returns true if the client can't keep a secret (SPA, native)
+func (c *OAuthClient) Public() bool {
+	return len(c.SecretHash) == 0
+}
+
+// ValidRedirectURI returns true if the URI exactly matches one of registered ones
+func (c *OAuthClient) ValidRedirectURI(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthCode represents OAuth2 authorization code grant
+type AuthCode struct {
+	CodeHash            string    `db:"code_hash"`
+	ClientID            string    `db:"client_id"`
+	MembershipID        uuid.UUID `db:"membership_id"`
+	UserID              uuid.UUID `db:"user_id"`   // Output only
+	TenantID            uuid.UUID `db:"tenant_id"` // Output only
+	RedirectURI         string    `db:"redirect_uri"`
+	Scope               string    `db:"scope"`
+	Nonce               string    `db:"nonce"`
+	CodeChallenge       string    `db:"code_challenge"`
+	CodeChallengeMethod string    `db:"code_challenge_method"`
+	AuthTime            time.Time `db:"auth_time"`
+	Expires             time.Time `db:"expires"`
+	MFAMethod           string    `db:"mfa_method"`
+}
+
+// NewOAuthClientInt registers a new OAuth2 client within the transaction
+func NewOAuthClientInt(ctx context.Context, tx *sqlx.Tx, client *OAuthClient) (*OAuthClient, error) {
+	q := "INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris) VALUES ($1, $2, $3, $4) RETURNING *"
+
+	var res OAuthClient
+	if err := sqlx.GetContext(ctx, tx, &res, q, client.ID, client.Name, client.SecretHash, client.RedirectURIs); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (s *Storage) GetOAuthClient(ctx context.Context, id string) (*OAuthClient, error) {
+	var client OAuthClient
+	if err := s.DB.GetContext(ctx, &client, "SELECT * FROM oauth_clients WHERE id = $1", id); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrClientNotFound
+		}
+
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func (s *Storage) NewAuthCode(ctx context.Context, code *AuthCode) error {
+	q := `
+		INSERT INTO
+		  oauth_codes (
+		    code_hash,
+		    client_id,
+		    membership_id,
+		    redirect_uri,
+		    scope,
+		    nonce,
+		    code_challenge,
+		    code_challenge_method,
+		    auth_time,
+		    expires,
+		    mfa_method
+		  )
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := s.DB.ExecContext(ctx, q, code.CodeHash, code.ClientID, code.MembershipID, code.RedirectURI, code.Scope, code.Nonce, code.CodeChallenge, code.CodeChallengeMethod, code.AuthTime, code.Expires, code.MFAMethod)
+	return err
+}
+
+// ConsumeAuthCode deletes the code and returns it so it can't be used twice. Expired codes are purged as well
+func (s *Storage) ConsumeAuthCode(ctx context.Context, codeHash string) (*AuthCode, error) {
+	q := `
+		WITH c AS (
+		  DELETE FROM
+		    oauth_codes
+		  WHERE
+		    code_hash = $1
+		    OR expires < NOW() RETURNING *
+		)
+		SELECT
+		  c.*,
+		  membership.user_id,
+		  membership.tenant_id
+		FROM
+		  c
+		  INNER JOIN membership ON c.membership_id = membership.id
+		WHERE
+		  c.code_hash = $1
+		  AND c.expires >= NOW()`
+
+	var code AuthCode
+	if err := s.DB.GetContext(ctx, &code, q, codeHash); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrInvalidGrant
+		}
+
+		return nil, err
+	}
+
+	return &code, nil
+}
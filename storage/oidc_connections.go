@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// OIDCConnection is a tenant's upstream OpenID Connect identity provider
+type OIDCConnection struct {
+	ID                 uuid.UUID      `db:"id" json:"id"`
+	TenantID           uuid.UUID      `db:"tenant_id" json:"tenant_id"`
+	Name               string         `db:"name" json:"name"`
+	Issuer             string         `db:"issuer" json:"issuer"`
+	ClientID           string         `db:"client_id" json:"client_id"`
+	ClientSecret       string         `db:"client_secret" json:"-"`
+	Scopes             pq.StringArray `db:"scopes" json:"scopes"`
+	EmailClaim         string         `db:"email_claim" json:"email_claim"`
+	EmailVerifiedClaim string         `db:"email_verified_claim" json:"email_verified_claim"`
+	TrustEmail         bool           `db:"trust_email" json:"trust_email"` // Accept emails without the verification claim
+	NameClaim          string         `db:"name_claim" json:"name_claim"`
+	DefaultRole        string         `db:"default_role" json:"default_role,omitempty"`
+	JITProvisioning    bool           `db:"jit_provisioning" json:"jit_provisioning"`
+	Domains            pq.StringArray `db:"domains" json:"domains"` // Allowed email domains, empty list allows any
+	Added              time.Time      `db:"added" json:"added"`
+	Modified           time.Time      `db:"modified" json:"modified"`
+}
+
+// DomainAllowed returns true if the email address belongs to one of allowed domains
+func (c *OIDCConnection) DomainAllowed(email string) bool {
+	return domainAllowed(c.Domains, email)
+}
+
+func domainAllowed(domains []string, email string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+
+	i := strings.LastIndexByte(email, '@')
+	if i < 0 {
+		return false
+	}
+	domain := email[i+1:]
+
+	for _, d := range domains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func defaultString(s, def string) string {
+	if s != "" {
+		return s
+	}
+	return def
+}
+
+func (s *Storage) NewOIDCConnection(ctx context.Context, conn *OIDCConnection) (*OIDCConnection, error) {
+	q := `
+		INSERT INTO
+		  oidc_connections (tenant_id, name, issuer, client_id, client_secret, scopes, email_claim, email_verified_claim, trust_email, name_claim, default_role, jit_provisioning, domains)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING *`
+
+	scopes := conn.Scopes
+	if scopes == nil {
+		scopes = pq.StringArray{"email", "profile"}
+	}
+
+	domains := conn.Domains
+	if domains == nil {
+		domains = pq.StringArray{}
+	}
+
+	var res OIDCConnection
+	if err := s.DB.GetContext(ctx, &res, q, conn.TenantID, conn.Name, conn.Issuer, conn.ClientID, conn.ClientSecret, scopes,
+		defaultString(conn.EmailClaim, "email"), defaultString(conn.EmailVerifiedClaim, "email_verified"), conn.TrustEmail,
+		defaultString(conn.NameClaim, "name"), conn.DefaultRole, conn.JITProvisioning, domains); err != nil {
+		if isForeignKeyViolation(err) {
+			err = errors.ErrTenantNotFound
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// GetOIDCConnection returns the connection of an active tenant
+func (s *Storage) GetOIDCConnection(ctx context.Context, id uuid.UUID) (*OIDCConnection, error) {
+	q := "SELECT oidc_connections.* FROM oidc_connections INNER JOIN tenants ON tenants.id = oidc_connections.tenant_id WHERE oidc_connections.id = $1 AND NOT tenants.archived"
+
+	var res OIDCConnection
+	if err := s.DB.GetContext(ctx, &res, q, id); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrConnectionNotFound
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (s *Storage) GetOIDCConnections(ctx context.Context, tenantID uuid.UUID) ([]*OIDCConnection, error) {
+	var res []*OIDCConnection
+	if err := s.DB.SelectContext(ctx, &res, "SELECT * FROM oidc_connections WHERE tenant_id = $1 ORDER BY added", tenantID); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+var oidcConnectionUpdatePaths = map[string]struct{}{
+	"name":                 struct{}{},
+	"issuer":               struct{}{},
+	"client_id":            struct{}{},
+	"client_secret":        struct{}{},
+	"scopes":               struct{}{},
+	"email_claim":          struct{}{},
+	"email_verified_claim": struct{}{},
+	"trust_email":          struct{}{},
+	"name_claim":           struct{}{},
+	"default_role":         struct{}{},
+	"jit_provisioning":     struct{}{},
+	"domains":              struct{}{},
+}
+
+// stringArrayValue converts JSON array patch values
+func stringArrayValue(path string, value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, errPatchPath(path)
+	}
+
+	res := make(pq.StringArray, len(list))
+	for i, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errPatchPath(path)
+		}
+		res[i] = s
+	}
+
+	return res, nil
+}
+
+func (s *Storage) PatchOIDCConnection(ctx context.Context, tenantID, id uuid.UUID, ops *Ops) (*OIDCConnection, error) {
+	// Verify columns
+	for k := range ops.Update {
+		if _, ok := oidcConnectionUpdatePaths[k]; !ok {
+			return nil, errPatchPath(k)
+		}
+	}
+
+	var i int
+	expr := "UPDATE oidc_connections SET "
+	args := make([]interface{}, len(ops.Update)+2)
+
+	for k, v := range ops.Update {
+		if k == "scopes" || k == "domains" {
+			var err error
+			if v, err = stringArrayValue(k, v); err != nil {
+				return nil, err
+			}
+		}
+
+		expr += fmt.Sprintf("%s = $%d, ", pq.QuoteIdentifier(k), i+1)
+		args[i] = v
+		i++
+	}
+
+	expr += fmt.Sprintf("modified = DEFAULT WHERE id = $%d AND tenant_id = $%d RETURNING *", i+1, i+2)
+	args[i] = id
+	args[i+1] = tenantID
+
+	var res OIDCConnection
+	if err := s.DB.GetContext(ctx, &res, expr, args...); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrConnectionNotFound
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (s *Storage) DeleteOIDCConnection(ctx context.Context, tenantID, id uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM oidc_connections WHERE id = $1 AND tenant_id = $2", id, tenantID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrConnectionNotFound
+	}
+
+	_, err = s.DB.ExecContext(ctx, "DELETE FROM federated_identities WHERE connection_id = $1", id)
+	return err
+}
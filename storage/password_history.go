@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"context"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetPasswordHistory returns up to n previous password hashes, most recent first. The current hash is not included
+func (s *Storage) GetPasswordHistory(ctx context.Context, userID uuid.UUID, n int) ([][]byte, error) {
+	var res [][]byte
+	if err := s.DB.SelectContext(ctx, &res, "SELECT password_hash FROM password_history WHERE user_id = $1 ORDER BY added DESC LIMIT $2", userID, n); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const rateLimitPurgeProbability = 100
+
+// TakeToken takes a token from the shared bucket. If the bucket is empty it returns false along with the time until the next token
+func (s *Storage) TakeToken(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	// Refill and take a token in a single statement so concurrent replicas don't race
+	q := `
+		INSERT INTO
+		  rate_limit_buckets AS b (key, tokens)
+		VALUES
+		  ($1, $3 - 1) ON CONFLICT (key) DO
+		UPDATE
+		SET
+		  tokens = CASE
+		    WHEN LEAST($3, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $2) >= 1 THEN LEAST($3, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $2) - 1
+		    ELSE LEAST($3, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $2)
+		  END,
+		  allowed = LEAST($3, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $2) >= 1,
+		  updated = NOW() RETURNING tokens,
+		  allowed`
+
+	// Purge idle buckets once in a while. They are likely full by now
+	if rand.Intn(rateLimitPurgeProbability) == 0 {
+		if _, err := s.DB.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated < NOW() - INTERVAL '1 day'"); err != nil {
+			return false, 0, err
+		}
+	}
+
+	var res struct {
+		Tokens  float64 `db:"tokens"`
+		Allowed bool    `db:"allowed"`
+	}
+
+	if err := s.DB.GetContext(ctx, &res, q, key, rate, burst); err != nil {
+		return false, 0, err
+	}
+
+	if !res.Allowed {
+		return false, time.Duration((1 - res.Tokens) / rate * float64(time.Second)), nil
+	}
+
+	return true, 0, nil
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// RefreshToken represents opaque one-time refresh token. Tokens obtained from the same login share the session
+type RefreshToken struct {
+	ID           uuid.UUID `db:"id"`
+	TokenHash    string    `db:"token_hash"`
+	SessionID    uuid.UUID `db:"session_id"`
+	MembershipID uuid.UUID `db:"membership_id"`
+	UserID       uuid.UUID `db:"user_id"`   // Output only
+	TenantID     uuid.UUID `db:"tenant_id"` // Output only
+	ClientID     string    `db:"client_id"`
+	Scope        string    `db:"scope"`
+	Permissions  bool      `db:"permissions"`
+	Used         bool      `db:"used"`
+	Revoked      bool      `db:"revoked"` // Output only, session state
+	Added        time.Time `db:"added"`
+	Expires      time.Time `db:"expires"`
+}
+
+const insertRefreshTokenQuery = `
+	INSERT INTO
+	  refresh_tokens (
+	    token_hash,
+	    session_id,
+	    membership_id,
+	    client_id,
+	    scope,
+	    permissions,
+	    expires
+	  )
+	VALUES
+	  ($1, $2, $3, $4, $5, $6, $7)`
+
+// NewRefreshToken stores the first token of the session. Expired tokens are purged as well
+func (s *Storage) NewRefreshToken(ctx context.Context, tok *RefreshToken) error {
+	if _, err := s.DB.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires < NOW()"); err != nil {
+		return err
+	}
+
+	_, err := s.DB.ExecContext(ctx, insertRefreshTokenQuery, tok.TokenHash, tok.SessionID, tok.MembershipID, tok.ClientID, tok.Scope, tok.Permissions, tok.Expires)
+	return err
+}
+
+// RotateRefreshToken marks the token as used and replaces it with a new one within the same session.
+// Presenting already used token revokes the whole session. A token bound to another client is rejected
+// before anything is changed, first-party tokens have empty client ID
+func (s *Storage) RotateRefreshToken(ctx context.Context, tokenHash, newHash, clientID string) (res *RefreshToken, err error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	q := `
+		SELECT
+		  refresh_tokens.*,
+		  membership.user_id,
+		  membership.tenant_id,
+		  sessions.revoked
+		FROM
+		  refresh_tokens
+		  INNER JOIN membership ON refresh_tokens.membership_id = membership.id
+		  INNER JOIN sessions ON refresh_tokens.session_id = sessions.id
+		WHERE
+		  refresh_tokens.token_hash = $1 FOR UPDATE OF refresh_tokens, sessions`
+
+	var tok RefreshToken
+	if err = sqlx.GetContext(ctx, tx, &tok, q, tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	if tok.ClientID != clientID {
+		return nil, errors.ErrInvalidGrant
+	}
+
+	if tok.Used || tok.Revoked {
+		if !tok.Revoked {
+			if _, err = tx.ExecContext(ctx, "UPDATE sessions SET revoked = TRUE WHERE id = $1", tok.SessionID); err != nil {
+				return nil, err
+			}
+
+			// Keep revocation
+			if err = tx.Commit(); err != nil {
+				return nil, err
+			}
+
+			return &tok, errors.ErrRefreshTokenReuse
+		}
+
+		return nil, errors.ErrInvalidGrant
+	}
+
+	if time.Now().After(tok.Expires) {
+		return nil, errors.ErrInvalidGrant
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET used = TRUE WHERE id = $1", tok.ID); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.ExecContext(ctx, insertRefreshTokenQuery, newHash, tok.SessionID, tok.MembershipID, tok.ClientID, tok.Scope, tok.Permissions, tok.Expires); err != nil {
+		return nil, err
+	}
+
+	return &tok, nil
+}
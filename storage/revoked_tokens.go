@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// RevokeToken adds the token ID to the revocation list. Entries are kept until the token expires, nil expiration means forever
+func (s *Storage) RevokeToken(ctx context.Context, jti, userID uuid.UUID, expires *time.Time) error {
+	if _, err := s.DB.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires < NOW()"); err != nil {
+		return err
+	}
+
+	_, err := s.DB.ExecContext(ctx, "INSERT INTO revoked_tokens (jti, user_id, expires) VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING", jti, userID, expires)
+	return err
+}
+
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	var res bool
+	if err := s.DB.GetContext(ctx, &res, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)", jti); err != nil {
+		return false, err
+	}
+
+	return res, nil
+}
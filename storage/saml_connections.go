@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// SAMLConnection is a tenant's SAML 2.0 identity provider
+type SAMLConnection struct {
+	ID              uuid.UUID         `json:"id"`
+	TenantID        uuid.UUID         `json:"tenant_id"`
+	Name            string            `json:"name"`
+	Metadata        string            `json:"metadata"`  // Identity provider metadata document
+	EntityID        string            `json:"entity_id"` // Identity provider entity ID taken from the metadata
+	EmailAttribute  string            `json:"email_attribute"`
+	NameAttribute   string            `json:"name_attribute"`
+	RoleAttribute   string            `json:"role_attribute"`
+	RoleMapping     map[string]string `json:"role_mapping"` // Attribute value to role
+	DefaultRole     string            `json:"default_role,omitempty"`
+	JITProvisioning bool              `json:"jit_provisioning"`
+	Domains         []string          `json:"domains"` // Allowed email domains, empty list allows any
+	Added           time.Time         `json:"added"`
+	Modified        time.Time         `json:"modified"`
+}
+
+// DomainAllowed returns true if the email address belongs to one of allowed domains
+func (c *SAMLConnection) DomainAllowed(email string) bool {
+	return domainAllowed(c.Domains, email)
+}
+
+// MapRoles returns roles corresponding to the attribute values
+func (c *SAMLConnection) MapRoles(values []string) Roles {
+	res := make(Roles)
+	for _, v := range values {
+		if role, ok := c.RoleMapping[v]; ok && role != "" {
+			res[role] = true
+		}
+	}
+	return res
+}
+
+// ManagedRoles returns all roles the connection may assign
+func (c *SAMLConnection) ManagedRoles() []string {
+	res := make([]string, 0, len(c.RoleMapping)+1)
+	for _, role := range c.RoleMapping {
+		res = append(res, role)
+	}
+
+	if c.DefaultRole != "" {
+		res = append(res, c.DefaultRole)
+	}
+
+	return res
+}
+
+type samlConnectionModel struct {
+	ID              uuid.UUID      `db:"id"`
+	TenantID        uuid.UUID      `db:"tenant_id"`
+	Name            string         `db:"name"`
+	Metadata        string         `db:"metadata"`
+	EntityID        string         `db:"entity_id"`
+	EmailAttribute  string         `db:"email_attribute"`
+	NameAttribute   string         `db:"name_attribute"`
+	RoleAttribute   string         `db:"role_attribute"`
+	RoleMapping     []byte         `db:"role_mapping"`
+	DefaultRole     string         `db:"default_role"`
+	JITProvisioning bool           `db:"jit_provisioning"`
+	Domains         pq.StringArray `db:"domains"`
+	Added           time.Time      `db:"added"`
+	Modified        time.Time      `db:"modified"`
+}
+
+func (c *samlConnectionModel) toSAMLConnection() *SAMLConnection {
+	ret := &SAMLConnection{
+		ID:              c.ID,
+		TenantID:        c.TenantID,
+		Name:            c.Name,
+		Metadata:        c.Metadata,
+		EntityID:        c.EntityID,
+		EmailAttribute:  c.EmailAttribute,
+		NameAttribute:   c.NameAttribute,
+		RoleAttribute:   c.RoleAttribute,
+		DefaultRole:     c.DefaultRole,
+		JITProvisioning: c.JITProvisioning,
+		Domains:         c.Domains,
+		Added:           c.Added,
+		Modified:        c.Modified,
+	}
+
+	if len(c.RoleMapping) != 0 {
+		if err := json.Unmarshal(c.RoleMapping, &ret.RoleMapping); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if ret.RoleMapping == nil {
+		ret.RoleMapping = map[string]string{}
+	}
+
+	if ret.Domains == nil {
+		ret.Domains = []string{}
+	}
+
+	return ret
+}
+
+func (s *Storage) NewSAMLConnection(ctx context.Context, conn *SAMLConnection) (*SAMLConnection, error) {
+	q := `
+		INSERT INTO
+		  saml_connections (tenant_id, name, metadata, entity_id, email_attribute, name_attribute, role_attribute, role_mapping, default_role, jit_provisioning, domains)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING *`
+
+	mapping := conn.RoleMapping
+	if mapping == nil {
+		mapping = map[string]string{}
+	}
+
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := pq.StringArray(conn.Domains)
+	if domains == nil {
+		domains = pq.StringArray{}
+	}
+
+	var res samlConnectionModel
+	if err := s.DB.GetContext(ctx, &res, q, conn.TenantID, conn.Name, conn.Metadata, conn.EntityID, conn.EmailAttribute,
+		conn.NameAttribute, conn.RoleAttribute, string(mappingJSON), conn.DefaultRole, conn.JITProvisioning, domains); err != nil {
+		if isForeignKeyViolation(err) {
+			err = errors.ErrTenantNotFound
+		}
+		return nil, err
+	}
+
+	return res.toSAMLConnection(), nil
+}
+
+// GetSAMLConnection returns the connection of an active tenant
+func (s *Storage) GetSAMLConnection(ctx context.Context, id uuid.UUID) (*SAMLConnection, error) {
+	q := "SELECT saml_connections.* FROM saml_connections INNER JOIN tenants ON tenants.id = saml_connections.tenant_id WHERE saml_connections.id = $1 AND NOT tenants.archived"
+
+	var res samlConnectionModel
+	if err := s.DB.GetContext(ctx, &res, q, id); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrConnectionNotFound
+		}
+		return nil, err
+	}
+
+	return res.toSAMLConnection(), nil
+}
+
+func (s *Storage) GetSAMLConnections(ctx context.Context, tenantID uuid.UUID) ([]*SAMLConnection, error) {
+	var models []*samlConnectionModel
+	if err := s.DB.SelectContext(ctx, &models, "SELECT * FROM saml_connections WHERE tenant_id = $1 ORDER BY added", tenantID); err != nil {
+		return nil, err
+	}
+
+	res := make([]*SAMLConnection, len(models))
+	for i, m := range models {
+		res[i] = m.toSAMLConnection()
+	}
+
+	return res, nil
+}
+
+var samlConnectionUpdatePaths = map[string]struct{}{
+	"name":             struct{}{},
+	"metadata":         struct{}{},
+	"entity_id":        struct{}{},
+	"email_attribute":  struct{}{},
+	"name_attribute":   struct{}{},
+	"role_attribute":   struct{}{},
+	"role_mapping":     struct{}{},
+	"default_role":     struct{}{},
+	"jit_provisioning": struct{}{},
+	"domains":          struct{}{},
+}
+
+// roleMappingValue converts JSON object patch values
+func roleMappingValue(path string, value interface{}) (interface{}, error) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errPatchPath(path)
+	}
+
+	for _, v := range obj {
+		if _, ok := v.(string); !ok {
+			return nil, errPatchPath(path)
+		}
+	}
+
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(buf), nil
+}
+
+func (s *Storage) PatchSAMLConnection(ctx context.Context, tenantID, id uuid.UUID, ops *Ops) (*SAMLConnection, error) {
+	// Verify columns
+	for k := range ops.Update {
+		if _, ok := samlConnectionUpdatePaths[k]; !ok {
+			return nil, errPatchPath(k)
+		}
+	}
+
+	var i int
+	expr := "UPDATE saml_connections SET "
+	args := make([]interface{}, len(ops.Update)+2)
+
+	for k, v := range ops.Update {
+		var err error
+		switch k {
+		case "domains":
+			v, err = stringArrayValue(k, v)
+		case "role_mapping":
+			v, err = roleMappingValue(k, v)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		expr += fmt.Sprintf("%s = $%d, ", pq.QuoteIdentifier(k), i+1)
+		args[i] = v
+		i++
+	}
+
+	expr += fmt.Sprintf("modified = DEFAULT WHERE id = $%d AND tenant_id = $%d RETURNING *", i+1, i+2)
+	args[i] = id
+	args[i+1] = tenantID
+
+	var res samlConnectionModel
+	if err := s.DB.GetContext(ctx, &res, expr, args...); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrConnectionNotFound
+		}
+		return nil, err
+	}
+
+	return res.toSAMLConnection(), nil
+}
+
+func (s *Storage) DeleteSAMLConnection(ctx context.Context, tenantID, id uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM saml_connections WHERE id = $1 AND tenant_id = $2", id, tenantID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrConnectionNotFound
+	}
+
+	_, err = s.DB.ExecContext(ctx, "DELETE FROM federated_identities WHERE connection_id = $1", id)
+	return err
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Session represents a login session. Access tokens issued within the session carry its ID as a sid claim
+type Session struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	UserAgent string    `db:"user_agent" json:"user_agent,omitempty"`
+	Address   string    `db:"addr" json:"addr,omitempty"`
+	Revoked   bool      `db:"revoked" json:"-"`
+	Added     time.Time `db:"added" json:"added"`
+	LastSeen  time.Time `db:"last_seen" json:"last_seen"`
+	Expires   time.Time `db:"expires" json:"expires"`
+	MFAMethod string    `db:"mfa_method" json:"mfa_method,omitempty"`
+}
+
+// Active returns true if the session is neither revoked nor expired
+func (s *Session) Active() bool {
+	return !s.Revoked && time.Now().Before(s.Expires)
+}
+
+func (s *Storage) NewSession(ctx context.Context, session *Session) (*Session, error) {
+	q := "INSERT INTO sessions (user_id, user_agent, addr, expires, mfa_method) VALUES ($1, $2, $3, $4, $5) RETURNING *"
+
+	// Purge stale sessions
+	if _, err := s.DB.ExecContext(ctx, "DELETE FROM sessions WHERE expires < NOW()"); err != nil {
+		return nil, err
+	}
+
+	var res Session
+	if err := s.DB.GetContext(ctx, &res, q, session.UserID, session.UserAgent, session.Address, session.Expires, session.MFAMethod); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (s *Storage) GetSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	var res Session
+	if err := s.DB.GetContext(ctx, &res, "SELECT * FROM sessions WHERE id = $1", id); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrSessionNotFound
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// GetSessions returns user's active sessions
+func (s *Storage) GetSessions(ctx context.Context, userID uuid.UUID) ([]*Session, error) {
+	var res []*Session
+	if err := s.DB.SelectContext(ctx, &res, "SELECT * FROM sessions WHERE user_id = $1 AND NOT revoked AND expires >= NOW() ORDER BY last_seen DESC", userID); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// TouchSession updates last seen info
+func (s *Storage) TouchSession(ctx context.Context, id uuid.UUID, addr string) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE sessions SET last_seen = NOW(), addr = $2 WHERE id = $1", id, addr)
+	return err
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, "UPDATE sessions SET revoked = TRUE WHERE id = $1 AND user_id = $2 AND NOT revoked", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes all user's sessions except the specified one
+func (s *Storage) RevokeSessions(ctx context.Context, userID, except uuid.UUID) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE sessions SET revoked = TRUE WHERE user_id = $1 AND id <> $2 AND NOT revoked", userID, except)
+	return err
+}
@@ -15,14 +15,47 @@ import (
 
 // TenantModel struct that represent tenant resource
 type TenantModel struct {
-	ID         uuid.UUID `json:"id" db:"id"`
-	Name       string    `json:"name" db:"name"`
-	Added      time.Time `json:"added" db:"added"`
-	Modified   time.Time `json:"modified" db:"modified"`
-	Protected  bool      `json:"-" db:"protected"`
-	Archived   bool      `json:"-" db:"archived"`
-	TenantType string    `json:"type" db:"tenant_type"`
-	SortedBy   string    `json:"-" db:"_sorted_by"`
+	ID         uuid.UUID      `json:"id" db:"id"`
+	Name       string         `json:"name" db:"name"`
+	Added      time.Time      `json:"added" db:"added"`
+	Modified   time.Time      `json:"modified" db:"modified"`
+	Protected  bool           `json:"-" db:"protected"`
+	Archived   bool           `json:"-" db:"archived"`
+	TenantType string         `json:"type" db:"tenant_type"`
+	MFAPolicy  string         `json:"mfa_policy" db:"mfa_policy"`
+	MFAMethods pq.StringArray `json:"mfa_methods" db:"mfa_methods"`
+	SortedBy   string         `json:"-" db:"_sorted_by"`
+}
+
+// MFA policies
+const (
+	// MFAPolicyNone disables the second factor for the tenant
+	MFAPolicyNone = "none"
+	// MFAPolicyOptional asks for the second factor only if the user has enrolled one
+	MFAPolicyOptional = "optional"
+	// MFAPolicyRequired doesn't allow to log into the tenant without the second factor
+	MFAPolicyRequired = "required"
+)
+
+// MFA methods which can be allowed by the tenant policy
+var mfaPolicyMethods = map[string]struct{}{
+	"totp":     struct{}{},
+	"webauthn": struct{}{},
+}
+
+// MFAMethodAllowed returns true if the second factor method is accepted by the tenant. Empty list allows any method
+func (t *TenantModel) MFAMethodAllowed(method string) bool {
+	if len(t.MFAMethods) == 0 {
+		return true
+	}
+
+	for _, m := range t.MFAMethods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Clone clone a TenantModel struct
@@ -35,6 +68,8 @@ func (t *TenantModel) Clone() *TenantModel {
 		Protected:  t.Protected,
 		Archived:   t.Archived,
 		TenantType: t.TenantType,
+		MFAPolicy:  t.MFAPolicy,
+		MFAMethods: append(pq.StringArray(nil), t.MFAMethods...),
 	}
 }
 
@@ -141,6 +176,7 @@ var tenantsQueryColumns = jq.Columns{
 	"modified":    {ColumnExpr: "modified", Sort: true},
 	"archived":    {ColumnExpr: "archived", Sort: true},
 	"tenant_type": {ColumnExpr: "tenant_type", Sort: true},
+	"mfa_policy":  {ColumnExpr: "mfa_policy", Sort: true},
 }
 
 // GetTenantsSoleMember get a list of tenant where the user is the only member
@@ -172,6 +208,8 @@ func (s *Storage) GetTenantsSoleMember(ctx context.Context, userID uuid.UUID) (t
 			&tenant.Protected,
 			&tenant.Archived,
 			&tenant.TenantType,
+			&tenant.MFAPolicy,
+			&tenant.MFAMethods,
 		); err != nil {
 			return
 		}
@@ -275,7 +313,48 @@ func (s *Storage) GetTenants(ctx context.Context, userID uuid.UUID, onlySelf boo
 }
 
 var tenantUpdatePaths = map[string]struct{}{
-	"name": struct{}{},
+	"name":        struct{}{},
+	"mfa_policy":  struct{}{},
+	"mfa_methods": struct{}{},
+}
+
+// mfaPolicyValue validates and converts MFA policy patch values
+func mfaPolicyValue(path string, value interface{}) (interface{}, error) {
+	switch path {
+	case "mfa_policy":
+		if v, ok := value.(string); ok {
+			switch v {
+			case MFAPolicyNone, MFAPolicyOptional, MFAPolicyRequired:
+				return v, nil
+			}
+		}
+
+	case "mfa_methods":
+		list, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+
+		methods := make(pq.StringArray, len(list))
+		for i, m := range list {
+			s, ok := m.(string)
+			if !ok {
+				return nil, errors.ErrMFAPolicy
+			}
+
+			if _, ok := mfaPolicyMethods[s]; !ok {
+				return nil, errors.ErrMFAPolicy
+			}
+			methods[i] = s
+		}
+
+		return methods, nil
+
+	default:
+		return value, nil
+	}
+
+	return nil, errors.ErrMFAPolicy
 }
 
 // PatchTenant update a tenant
@@ -307,6 +386,10 @@ func (s *Storage) PatchTenant(ctx context.Context, id uuid.UUID, ops *Ops) (*Ten
 	args := make([]interface{}, len(ops.Update)+1)
 
 	for k, v := range ops.Update {
+		if v, err = mfaPolicyValue(k, v); err != nil {
+			return nil, err
+		}
+
 		if i != 0 {
 			expr += ", "
 		}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/ecadlabs/auth/jq"
 	"github.com/ecadlabs/auth/rbac"
+	"github.com/lib/pq"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -46,6 +47,8 @@ const (
 	InvitedState = "invited"
 	// ActiveState string representing the active membership state
 	ActiveState = "active"
+	// SuspendedState string representing the membership deactivated by the provisioning client
+	SuspendedState = "suspended"
 )
 
 const (
@@ -120,11 +123,13 @@ type User struct {
 	EmailVerified    bool              `json:"email_verified"`
 	Membership       []*MembershipItem `json:"membership,omitempty"`
 	PasswordGen      int               `json:"-"`
+	LoginGen         int               `json:"-"`
 	LoginAddr        string            `json:"login_addr,omitempty"`
 	LoginTimestamp   *time.Time        `json:"login_ts,omitempty"`
 	RefreshAddr      string            `json:"refresh_addr,omitempty"`
 	RefreshTimestamp *time.Time        `json:"refresh_ts,omitempty"`
 	AddressWhiteList StringSet         `json:"address_whitelist,omitempty"`
+	ProvisionedBy    uuid.UUID         `json:"-"` // Tenant which created the account if any
 }
 
 // GetDefaultMembership retrive the default membership of this user
@@ -146,6 +151,8 @@ type Membership struct {
 	TenantID         uuid.UUID `json:"tenant_id"`
 	MembershipStatus string    `json:"status"`
 	UserID           uuid.UUID `json:"user_id"`
+	Name             string    `json:"name,omitempty"`
+	ExternalID       string    `json:"external_id,omitempty"` // Identifier assigned by the provisioning client
 	Added            time.Time `json:"added"`
 	Modified         time.Time `json:"modified"`
 	Roles            Roles     `json:"roles"`
@@ -185,6 +192,7 @@ type LogEntry struct {
 	Address    string                 `json:"addr,omitempty"`
 	Message    string                 `json:"msg,omitempty"`
 	Data       map[string]interface{} `json:"data,omitempty"`
+	ActorID    *uuid.UUID             `json:"actor_id,omitempty"` // Set for actions performed with an impersonation token
 }
 
 // Get retrieve a list of roles
@@ -213,11 +221,38 @@ const (
 
 // APIKey represents service account API key
 type APIKey struct {
-	ID           uuid.UUID `db:"id" json:"id"`
-	MembershipID uuid.UUID `db:"membership_id" json:"membership_id"`
-	UserID       uuid.UUID `db:"user_id" json:"user_id"`
-	TenantID     uuid.UUID `db:"tenant_id" json:"tenant_id"`
-	Added        time.Time `db:"added" json:"added"`
+	ID           uuid.UUID      `db:"id" json:"id"`
+	MembershipID uuid.UUID      `db:"membership_id" json:"membership_id"`
+	UserID       uuid.UUID      `db:"user_id" json:"user_id"`
+	TenantID     uuid.UUID      `db:"tenant_id" json:"tenant_id"`
+	Label        string         `db:"label" json:"label,omitempty"`
+	Added        time.Time      `db:"added" json:"added"`
+	Expires      *time.Time     `db:"expires" json:"expires,omitempty"`
+	LastUsed     *time.Time     `db:"last_used" json:"last_used,omitempty"`
+	Roles        pq.StringArray `db:"roles" json:"roles,omitempty"`             // Subset of membership roles, empty means all
+	Permissions  pq.StringArray `db:"permissions" json:"permissions,omitempty"` // Subset of role permissions, empty means all
+	SecretHash   string         `db:"secret_hash" json:"-"`
+}
+
+// Expired returns true if the key has expiration time which is passed
+func (k *APIKey) Expired() bool {
+	return k.Expires != nil && time.Now().After(*k.Expires)
+}
+
+// ScopeRoles narrows membership roles down to the key's ones
+func (k *APIKey) ScopeRoles(roles Roles) Roles {
+	if len(k.Roles) == 0 {
+		return roles
+	}
+
+	res := make(Roles)
+	for _, r := range k.Roles {
+		if v, ok := roles[r]; ok {
+			res[r] = v
+		}
+	}
+
+	return res
 }
 
 type TenantStorage interface {
@@ -240,11 +275,90 @@ type MembershipStorage interface {
 
 type APIKeyStorage interface {
 	GetKey(ctx context.Context, userID, keyID uuid.UUID) (*APIKey, error)
+	GetKeyByID(ctx context.Context, keyID uuid.UUID) (*APIKey, error)
 	GetKeys(ctx context.Context, uid uuid.UUID) ([]*APIKey, error)
-	NewKey(ctx context.Context, userID, tenantID uuid.UUID) (*APIKey, error)
+	NewKey(ctx context.Context, key *APIKey) (*APIKey, error)
+	UpdateKeySecret(ctx context.Context, userID, keyID uuid.UUID, secretHash string) (*APIKey, error)
+	UpdateKeyLastUsed(ctx context.Context, keyID uuid.UUID) error
 	DeleteKey(ctx context.Context, userID, keyID uuid.UUID) error
 }
 
+type OAuthStorage interface {
+	GetOAuthClient(ctx context.Context, id string) (*OAuthClient, error)
+	NewAuthCode(ctx context.Context, code *AuthCode) error
+	ConsumeAuthCode(ctx context.Context, codeHash string) (*AuthCode, error)
+}
+
+type RefreshTokenStorage interface {
+	NewRefreshToken(ctx context.Context, tok *RefreshToken) error
+	RotateRefreshToken(ctx context.Context, tokenHash, newHash, clientID string) (*RefreshToken, error)
+}
+
+type SessionStorage interface {
+	NewSession(ctx context.Context, session *Session) (*Session, error)
+	GetSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	GetSessions(ctx context.Context, userID uuid.UUID) ([]*Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, addr string) error
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) error
+	RevokeSessions(ctx context.Context, userID, except uuid.UUID) error
+}
+
+type RevocationStorage interface {
+	RevokeToken(ctx context.Context, jti, userID uuid.UUID, expires *time.Time) error
+	IsTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+}
+
+type LoginFailuresStorage interface {
+	GetLoginFailures(ctx context.Context, kind, key string) (*LoginFailures, error)
+	AddLoginFailure(ctx context.Context, kind, key string, window time.Duration) (*LoginFailures, error)
+	LockLogin(ctx context.Context, kind, key string, until time.Time) error
+	ResetLoginFailures(ctx context.Context, kind, key string) error
+}
+
+type OIDCConnectionStorage interface {
+	NewOIDCConnection(ctx context.Context, conn *OIDCConnection) (*OIDCConnection, error)
+	GetOIDCConnection(ctx context.Context, id uuid.UUID) (*OIDCConnection, error)
+	GetOIDCConnections(ctx context.Context, tenantID uuid.UUID) ([]*OIDCConnection, error)
+	PatchOIDCConnection(ctx context.Context, tenantID, id uuid.UUID, ops *Ops) (*OIDCConnection, error)
+	DeleteOIDCConnection(ctx context.Context, tenantID, id uuid.UUID) error
+}
+
+type SAMLConnectionStorage interface {
+	NewSAMLConnection(ctx context.Context, conn *SAMLConnection) (*SAMLConnection, error)
+	GetSAMLConnection(ctx context.Context, id uuid.UUID) (*SAMLConnection, error)
+	GetSAMLConnections(ctx context.Context, tenantID uuid.UUID) ([]*SAMLConnection, error)
+	PatchSAMLConnection(ctx context.Context, tenantID, id uuid.UUID, ops *Ops) (*SAMLConnection, error)
+	DeleteSAMLConnection(ctx context.Context, tenantID, id uuid.UUID) error
+}
+
+type FederatedIdentityStorage interface {
+	NewFederatedIdentity(ctx context.Context, userID, connectionID uuid.UUID) (*FederatedIdentity, error)
+	HasFederatedIdentity(ctx context.Context, userID, connectionID uuid.UUID) (bool, error)
+	GetFederatedIdentities(ctx context.Context, userID uuid.UUID) ([]*FederatedIdentity, error)
+	DeleteFederatedIdentity(ctx context.Context, userID, connectionID uuid.UUID) error
+}
+
+type PasswordHistoryStorage interface {
+	GetPasswordHistory(ctx context.Context, userID uuid.UUID, n int) ([][]byte, error)
+}
+
+type MFAStorage interface {
+	NewTOTP(ctx context.Context, userID uuid.UUID, secret string) error
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*TOTP, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryHashes []string) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+}
+
+type WebAuthnStorage interface {
+	NewWebAuthnCredential(ctx context.Context, cred *WebAuthnCredential) (*WebAuthnCredential, error)
+	GetWebAuthnCredential(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error)
+	GetWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]*WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(ctx context.Context, id uuid.UUID, prev, signCount int64) error
+	DeleteWebAuthnCredential(ctx context.Context, userID, id uuid.UUID) error
+}
+
 type UserStorage interface {
 	GetUserByID(ctx context.Context, typ string, id uuid.UUID) (*User, error)
 	GetUserIDByMembershipID(ctx context.Context, typ string, id uuid.UUID) (uuid.UUID, error)
@@ -252,10 +366,16 @@ type UserStorage interface {
 	GetServiceAccountByAddress(ctx context.Context, address net.IP) (*User, error)
 	GetUsers(ctx context.Context, typ string, q *jq.Query) (users []*User, count int, next *jq.Query, err error)
 	NewUser(ctx context.Context, user *CreateUser) (res *User, err error)
+	NewUserWithMembership(ctx context.Context, user *CreateUser, tenantID uuid.UUID, roles Roles) (res *User, err error)
+	NewFederatedUser(ctx context.Context, user *CreateUser, tenantID, connectionID uuid.UUID, roles Roles) (res *User, err error)
 	UpdateUser(ctx context.Context, typ string, id uuid.UUID, ops *Ops) (user *User, err error)
 	DeleteUser(ctx context.Context, typ string, id uuid.UUID) (err error)
 	UpdatePasswordWithGen(ctx context.Context, id uuid.UUID, hash []byte, expectedGen int) (err error)
+	RehashPassword(ctx context.Context, id uuid.UUID, oldHash, hash []byte) (err error)
+	UseLoginGen(ctx context.Context, id uuid.UUID, expectedGen int) (err error)
 	UpdateEmailWithGen(ctx context.Context, id uuid.UUID, email string, expectedGen int) (user *User, oldEmail string, err error)
+	VerifyEmail(ctx context.Context, id uuid.UUID, expectedGen int) (user *User, err error)
+	ReplaceUnverifiedPassword(ctx context.Context, id uuid.UUID, hash []byte) error
 	UpdateLoginInfo(ctx context.Context, id uuid.UUID, addr string) error
 	UpdateRefreshInfo(ctx context.Context, id uuid.UUID, addr string) error
 }
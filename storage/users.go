@@ -25,6 +25,7 @@ type userModel struct {
 	EmailGen         int            `db:"email_gen"`
 	PasswordHash     []byte         `db:"password_hash"`
 	PasswordGen      int            `db:"password_gen"`
+	LoginGen         int            `db:"login_gen"`
 	Name             string         `db:"name"`
 	Added            time.Time      `db:"added"`
 	Modified         time.Time      `db:"modified"`
@@ -35,6 +36,7 @@ type userModel struct {
 	RefreshAddr      string         `db:"refresh_addr"`
 	RefreshTimestamp time.Time      `db:"refresh_ts"`
 	AddressWhiteList pq.StringArray `db:"ip_whitelist"`
+	ProvisionedBy    uuid.NullUUID  `db:"provisioned_by"`
 	SortedBy         string         `db:"_sorted_by"` // Output only
 }
 
@@ -49,9 +51,11 @@ func (u *userModel) toUser() *User {
 		Modified:      u.Modified,
 		EmailVerified: u.EmailVerified,
 		PasswordGen:   u.PasswordGen,
+		LoginGen:      u.LoginGen,
 		LoginAddr:     u.LoginAddr,
 		RefreshAddr:   u.RefreshAddr,
 		EmailGen:      u.EmailGen,
+		ProvisionedBy: u.ProvisionedBy.UUID,
 	}
 
 	epoch := time.Unix(0, 0).UTC()
@@ -402,6 +406,11 @@ func isUniqueViolation(err error, constraint string) bool {
 	return ok && e.Code.Name() == "unique_violation" && e.Constraint == constraint
 }
 
+func isForeignKeyViolation(err error) bool {
+	e, ok := err.(*pq.Error)
+	return ok && e.Code.Name() == "foreign_key_violation"
+}
+
 // NewUserInt insert a new user in the database along with his initial tenant
 func NewUserInt(ctx context.Context, tx *sqlx.Tx, user *CreateUser, defaultRole string) (res *User, err error) {
 	model := userModel{
@@ -527,6 +536,62 @@ func (s *Storage) NewUser(ctx context.Context, user *CreateUser) (res *User, err
 	return s.GetUserByID(ctx, "", tmp.ID)
 }
 
+// NewUserWithMembership creates a new user along with his initial tenant and makes him a member of another tenant
+// which is recorded as the one that provisioned the account
+func (s *Storage) NewUserWithMembership(ctx context.Context, user *CreateUser, tenantID uuid.UUID, roles Roles) (res *User, err error) {
+	return s.newUserWithMembership(ctx, user, tenantID, uuid.Nil, roles)
+}
+
+// NewFederatedUser is like NewUserWithMembership but also links the new account to the identity provider connection
+func (s *Storage) NewFederatedUser(ctx context.Context, user *CreateUser, tenantID, connectionID uuid.UUID, roles Roles) (res *User, err error) {
+	return s.newUserWithMembership(ctx, user, tenantID, connectionID, roles)
+}
+
+func (s *Storage) newUserWithMembership(ctx context.Context, user *CreateUser, tenantID, connectionID uuid.UUID, roles Roles) (res *User, err error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	user.ID = uuid.NewV4()
+
+	tmp, err := NewUserInt(ctx, tx, user, s.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		roles = Roles{s.DefaultRole: true}
+	}
+
+	if err = s.AddMembershipInt(ctx, tx, tenantID, tmp.ID, ActiveState, MemberMembership, roles); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET provisioned_by = $1 WHERE id = $2", tenantID, tmp.ID); err != nil {
+		return nil, err
+	}
+
+	if connectionID != uuid.Nil {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO federated_identities (user_id, connection_id) VALUES ($1, $2)", tmp.ID, connectionID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetUserByID(ctx, "", tmp.ID)
+}
+
 func errPatchPath(p string) error {
 	return errors.Wrap(fmt.Errorf("Invalid property `%s'", p), errors.CodeBadRequest)
 }
@@ -751,6 +816,61 @@ func (s *Storage) UpdatePasswordWithGen(ctx context.Context, id uuid.UUID, hash
 	return nil
 }
 
+// RehashPassword replaces the hash of the same password. It's a no-op if the password was changed concurrently
+func (s *Storage) RehashPassword(ctx context.Context, id uuid.UUID, oldHash, hash []byte) (err error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3", hash, id, oldHash)
+	if err != nil {
+		return err
+	}
+
+	v, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if v == 0 {
+		return nil
+	}
+
+	// Not a password change, drop the entry added by the history trigger
+	_, err = tx.ExecContext(ctx, "DELETE FROM password_history WHERE user_id = $1 AND password_hash = $2", id, oldHash)
+	return err
+}
+
+// UseLoginGen invalidates the login link issued for the expected generation
+func (s *Storage) UseLoginGen(ctx context.Context, id uuid.UUID, expectedGen int) (err error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE users SET login_gen = login_gen + 1 WHERE account_type = 'regular' AND id = $1 AND login_gen = $2", id, expectedGen)
+	if err != nil {
+		return err
+	}
+
+	v, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if v == 0 {
+		log.WithFields(log.Fields{"token": expectedGen, "user": id}).Println("Login link expired")
+		return errors.ErrTokenExpired
+	}
+
+	return nil
+}
+
 // UpdateEmailWithGen update the email
 func (s *Storage) UpdateEmailWithGen(ctx context.Context, id uuid.UUID, email string, expectedGen int) (user *User, oldEmail string, err error) {
 	tx, err := s.DB.Beginx()
@@ -792,6 +912,36 @@ func (s *Storage) UpdateEmailWithGen(ctx context.Context, id uuid.UUID, email st
 	return u.toUser(), prev.Email, nil
 }
 
+// ReplaceUnverifiedPassword sets the password of the account which email isn't verified yet
+func (s *Storage) ReplaceUnverifiedPassword(ctx context.Context, id uuid.UUID, hash []byte) error {
+	res, err := s.DB.ExecContext(ctx, "UPDATE users SET password_hash = $1, modified = DEFAULT, password_gen = password_gen + 1 WHERE account_type = 'regular' AND id = $2 AND NOT email_verified", hash, id)
+	if err != nil {
+		return err
+	}
+
+	if v, err := res.RowsAffected(); err != nil {
+		return err
+	} else if v == 0 {
+		return errors.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the current email of a self registered user as verified
+func (s *Storage) VerifyEmail(ctx context.Context, id uuid.UUID, expectedGen int) (user *User, err error) {
+	var u userModel
+	if err = s.DB.GetContext(ctx, &u, "UPDATE users SET email_verified = TRUE, modified = DEFAULT, email_gen = email_gen + 1 WHERE account_type = 'regular' AND id = $1 AND email_gen = $2 RETURNING *", id, expectedGen); err != nil {
+		if err == sql.ErrNoRows {
+			log.WithFields(log.Fields{"token": expectedGen, "user": id}).Println("Email verification token expired")
+			err = errors.ErrTokenExpired
+		}
+		return nil, err
+	}
+
+	return u.toUser(), nil
+}
+
 // UpdateLoginInfo update the address and time of last login
 func (s *Storage) UpdateLoginInfo(ctx context.Context, id uuid.UUID, addr string) error {
 	_, err := s.DB.ExecContext(ctx, "UPDATE users SET login_addr = $1, login_ts = NOW() WHERE id = $2", addr, id)
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecadlabs/auth/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebAuthnCredential is a registered WebAuthn public key credential
+type WebAuthnCredential struct {
+	ID           uuid.UUID  `db:"id" json:"id"`
+	CredentialID []byte     `db:"credential_id" json:"-"`
+	UserID       uuid.UUID  `db:"user_id" json:"user_id"`
+	PublicKey    []byte     `db:"public_key" json:"-"`
+	SignCount    int64      `db:"sign_count" json:"-"`
+	AAGUID       []byte     `db:"aaguid" json:"-"`
+	Name         string     `db:"name" json:"name,omitempty"`
+	Added        time.Time  `db:"added" json:"added"`
+	LastUsed     *time.Time `db:"last_used" json:"last_used,omitempty"`
+}
+
+func (s *Storage) NewWebAuthnCredential(ctx context.Context, cred *WebAuthnCredential) (*WebAuthnCredential, error) {
+	q := `
+		INSERT INTO
+		  webauthn_credentials (credential_id, user_id, public_key, sign_count, aaguid, name)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6) RETURNING *`
+
+	var res WebAuthnCredential
+	if err := s.DB.GetContext(ctx, &res, q, cred.CredentialID, cred.UserID, cred.PublicKey, cred.SignCount, cred.AAGUID, cred.Name); err != nil {
+		if isUniqueViolation(err, "webauthn_credentials_credential_id_key") {
+			err = errors.ErrCredentialExists
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// GetWebAuthnCredential looks up the credential by its authenticator assigned ID
+func (s *Storage) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error) {
+	var res WebAuthnCredential
+	if err := s.DB.GetContext(ctx, &res, "SELECT * FROM webauthn_credentials WHERE credential_id = $1", credentialID); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.ErrCredentialNotFound
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (s *Storage) GetWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]*WebAuthnCredential, error) {
+	var res []*WebAuthnCredential
+	if err := s.DB.SelectContext(ctx, &res, "SELECT * FROM webauthn_credentials WHERE user_id = $1 ORDER BY added", userID); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// UpdateWebAuthnSignCount stores the new signature counter value. Concurrent use of the same counter value is refused
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, id uuid.UUID, prev, signCount int64) error {
+	res, err := s.DB.ExecContext(ctx, "UPDATE webauthn_credentials SET sign_count = $3, last_used = NOW() WHERE id = $1 AND sign_count = $2", id, prev, signCount)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrWebAuthn
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.ErrCredentialNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B, SHA1
+var testVectors = []struct {
+	t    int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, v := range testVectors {
+		if c := code(key, Step(time.Unix(v.t, 0)), 8); c != v.code {
+			t.Errorf("%d: %s != %s", v.t, c, v.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	c, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c != "050471" {
+		t.Errorf("unexpected code %s", c)
+	}
+
+	if step, ok := Validate(secret, c, now.Add(Period)); !ok || step != Step(now) {
+		t.Error("adjacent step must be accepted")
+	}
+
+	if _, ok := Validate(secret, c, now.Add(3*Period)); ok {
+		t.Error("stale code must be rejected")
+	}
+
+	if _, ok := Validate(secret, "000000", now); ok {
+		t.Error("wrong code must be rejected")
+	}
+}
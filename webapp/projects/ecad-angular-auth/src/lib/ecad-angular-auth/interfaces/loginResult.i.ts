@@ -1,4 +1,5 @@
 export interface LoginResult {
     token: string;
     refresh: string;
+    refresh_token?: string;
 }
@@ -41,6 +41,13 @@ export class StandardLoginService implements ILoginService {
     return obserbable.pipe(
       tap(result => this.config.tokenSetter(result.token)),
       tap(result => localStorage.setItem('refreshTokenUrl', result.refresh)),
+      tap(result => {
+        if (result.refresh_token) {
+          localStorage.setItem('refreshToken', result.refresh_token);
+        } else {
+          localStorage.removeItem('refreshToken');
+        }
+      }),
       tap(() => this.user.next(this.getTokenAndCheckExp()))
     );
   }
@@ -66,9 +73,10 @@ export class StandardLoginService implements ILoginService {
             switchMap(() => {
               return this.refreshToken().pipe(
                 catchError(err => {
-                  // If we get a 401 from the refresh endpoint it means that the user or tenant no longer exsits
+                  // If we get a 401 from the refresh endpoint it means that the user or tenant no longer exsits,
+                  // a 400 means the refresh token is used, revoked or expired
                   // We logout in order to force the user to reauthenticate
-                  if (err instanceof HttpErrorResponse && err.status === 401) {
+                  if (err instanceof HttpErrorResponse && (err.status === 401 || err.status === 400)) {
                     this.logout().subscribe();
                     return throwError(err);
                   } else {
@@ -238,6 +246,7 @@ export class StandardLoginService implements ILoginService {
     return Observable.create((observer: Observer<Boolean>) => {
       this.config.tokenSetter('');
       localStorage.removeItem('refreshTokenUrl');
+      localStorage.removeItem('refreshToken');
       this.user.next(this.token);
       observer.next(true);
     });
@@ -248,17 +257,19 @@ export class StandardLoginService implements ILoginService {
   }
 
   /*
-   * Refresh the JWT by querying the refresh url provided in previous login response
+   * Refresh the JWT by exchanging the one-time refresh token from the previous login or refresh response
    */
   public refreshToken(): Observable<boolean> {
-    const headers = {
-      headers: {
-        Authorization: `Bearer ${this.config.tokenGetter()}`
-      }
-    };
-    return this.httpClient.get(this.getRefreshUrl(), { ...headers }).pipe(
-      this.postLoginOperations,
-      map(() => true)
-    );
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (!refreshToken) {
+      return observableOf(false);
+    }
+
+    return this.httpClient
+      .post<LoginResult>(this.getRefreshUrl(), { refresh_token: refreshToken })
+      .pipe(
+        this.postLoginOperations,
+        map(() => true)
+      );
   }
 }
@@ -0,0 +1,202 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Minimal CBOR (RFC 7049) support sufficient for attestation objects and COSE keys.
+// Indefinite length items and tags are not used by authenticators and aren't supported
+
+var errCBOR = errors.New("webauthn: malformed CBOR")
+
+const maxCBORDepth = 16
+
+// cborDecode decodes a single item and returns the rest of the buffer.
+// Integers are returned as int64, byte strings as []byte, maps as map[interface{}]interface{}
+func cborDecode(data []byte) (v interface{}, rest []byte, err error) {
+	return cborDecodeDepth(data, 0)
+}
+
+func cborHead(data []byte) (major byte, arg uint64, rest []byte, err error) {
+	if len(data) == 0 {
+		return 0, 0, nil, errCBOR
+	}
+
+	major = data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(data) < 1 {
+			return 0, 0, nil, errCBOR
+		}
+		arg, data = uint64(data[0]), data[1:]
+	case info == 25:
+		if len(data) < 2 {
+			return 0, 0, nil, errCBOR
+		}
+		arg, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+	case info == 26:
+		if len(data) < 4 {
+			return 0, 0, nil, errCBOR
+		}
+		arg, data = uint64(binary.BigEndian.Uint32(data)), data[4:]
+	case info == 27:
+		if len(data) < 8 {
+			return 0, 0, nil, errCBOR
+		}
+		arg, data = binary.BigEndian.Uint64(data), data[8:]
+	default:
+		return 0, 0, nil, errCBOR
+	}
+
+	return major, arg, data, nil
+}
+
+func cborDecodeDepth(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errCBOR
+	}
+
+	major, arg, data, err := cborHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(arg), data, nil
+
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(arg), data, nil
+
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		if major == 2 {
+			return data[:arg], data[arg:], nil
+		}
+		return string(data[:arg]), data[arg:], nil
+
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		res := make([]interface{}, arg)
+		for i := range res {
+			if res[i], data, err = cborDecodeDepth(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return res, data, nil
+
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		res := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var k, v interface{}
+			if k, data, err = cborDecodeDepth(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if v, data, err = cborDecodeDepth(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			res[k] = v
+		}
+		return res, data, nil
+
+	case 7:
+		switch arg {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		}
+	}
+
+	return nil, nil, errCBOR
+}
+
+func cborAppendHead(buf []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(buf, major<<5|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, major<<5|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return append(buf, major<<5|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		return append(buf, major<<5|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], arg)
+	return append(append(buf, major<<5|27), tmp[:]...)
+}
+
+// cborEncode encodes the subset of types produced by cborDecode. Map keys are written in the order given
+func cborEncode(buf []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case int:
+		return cborEncode(buf, int64(x))
+	case int64:
+		if x >= 0 {
+			return cborAppendHead(buf, 0, uint64(x))
+		}
+		return cborAppendHead(buf, 1, uint64(-1-x))
+	case []byte:
+		return append(cborAppendHead(buf, 2, uint64(len(x))), x...)
+	case string:
+		return append(cborAppendHead(buf, 3, uint64(len(x))), x...)
+	case []interface{}:
+		buf = cborAppendHead(buf, 4, uint64(len(x)))
+		for _, e := range x {
+			buf = cborEncode(buf, e)
+		}
+		return buf
+	case cborMap:
+		buf = cborAppendHead(buf, 5, uint64(len(x)))
+		for _, kv := range x {
+			buf = cborEncode(cborEncode(buf, kv.key), kv.value)
+		}
+		return buf
+	case bool:
+		if x {
+			return append(buf, 0xf5)
+		}
+		return append(buf, 0xf4)
+	case nil:
+		return append(buf, 0xf6)
+	}
+
+	panic("webauthn: unsupported CBOR type")
+}
+
+type cborPair struct {
+	key   interface{}
+	value interface{}
+}
+
+// cborMap keeps key order for deterministic encoding
+type cborMap []cborPair
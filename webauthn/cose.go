@@ -0,0 +1,137 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 8152)
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE key parameters
+const (
+	coseKty     = 1
+	coseAlg     = 3
+	coseCrv     = -1
+	coseX       = -2
+	coseY       = -3
+	coseRSAN    = -1
+	coseRSAE    = -2
+	coseKtyOKP  = 1
+	coseKtyEC2  = 2
+	coseKtyRSA  = 3
+	coseP256    = 1
+	coseEd25519 = 6
+)
+
+var ErrUnsupportedKey = errors.New("webauthn: unsupported credential public key")
+
+// PublicKey is a parsed COSE credential public key
+type PublicKey struct {
+	Alg int64
+	Key crypto.PublicKey
+}
+
+func intParam(m map[interface{}]interface{}, k int64) (int64, bool) {
+	v, ok := m[k].(int64)
+	return v, ok
+}
+
+func bytesParam(m map[interface{}]interface{}, k int64) ([]byte, bool) {
+	v, ok := m[k].([]byte)
+	return v, ok
+}
+
+// ParsePublicKey parses COSE_Key encoded credential public key
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	v, _, err := cborDecode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := intParam(m, coseKty)
+	alg, _ := intParam(m, coseAlg)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := intParam(m, coseCrv)
+		x, okx := bytesParam(m, coseX)
+		y, oky := bytesParam(m, coseY)
+		if crv != coseP256 || !okx || !oky {
+			return nil, ErrUnsupportedKey
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Alg: alg, Key: pub}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := intParam(m, coseCrv)
+		x, ok := bytesParam(m, coseX)
+		if crv != coseEd25519 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Alg: alg, Key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, okn := bytesParam(m, coseRSAN)
+		e, oke := bytesParam(m, coseRSAE)
+		if !okn || !oke || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+
+		var exp int
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return &PublicKey{Alg: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}}, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+// Verify checks the signature over the data
+func (p *PublicKey) Verify(data, sig []byte) bool {
+	switch key := p.Key.(type) {
+	case *ecdsa.PublicKey:
+		var s struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(sig, &s); err != nil || len(rest) != 0 {
+			return false
+		}
+		digest := sha256.Sum256(data)
+		return ecdsa.Verify(key, digest[:], s.R, s.S)
+
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, sig)
+
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+
+	return false
+}
@@ -0,0 +1,413 @@
+// Package webauthn implements relying party side of WebAuthn registration and authentication ceremonies.
+// Attestation statements are not verified, so authenticator provenance is not established
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const (
+	challengeSize = 32
+	// Timeout is a ceremony timeout hint in milliseconds
+	Timeout = 5 * 60 * 1000
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// Client data types
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+var (
+	ErrClientData   = errors.New("webauthn: invalid client data")
+	ErrChallenge    = errors.New("webauthn: challenge mismatch")
+	ErrOrigin       = errors.New("webauthn: origin mismatch")
+	ErrAuthData     = errors.New("webauthn: invalid authenticator data")
+	ErrRPID         = errors.New("webauthn: relying party ID mismatch")
+	ErrUserPresence = errors.New("webauthn: user presence or verification flag is not set")
+	ErrSignature    = errors.New("webauthn: invalid signature")
+	ErrSignCount    = errors.New("webauthn: signature counter did not increase, the authenticator may be cloned")
+)
+
+// URLEncodedBytes is a byte slice represented as unpadded base64url in JSON
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+
+	*b = v
+	return nil
+}
+
+// RelyingParty holds the server identity
+type RelyingParty struct {
+	ID     string // Effective domain
+	Name   string
+	Origin string
+	// Require user verification (PIN, biometrics) in addition to user presence
+	RequireUserVerification bool
+}
+
+// NewRelyingParty derives relying party ID and origin from the service base URL
+func NewRelyingParty(baseURL, name string) (*RelyingParty, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, errors.New("webauthn: base URL must be absolute")
+	}
+
+	if name == "" {
+		name = u.Hostname()
+	}
+
+	return &RelyingParty{
+		ID:     u.Hostname(),
+		Name:   name,
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+// NewChallenge returns a random ceremony challenge
+func NewChallenge() ([]byte, error) {
+	buf := make([]byte, challengeSize)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+type RPEntity struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string          `json:"type"`
+	ID   URLEncodedBytes `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions is passed to navigator.credentials.create()
+type CreationOptions struct {
+	Challenge              URLEncodedBytes         `json:"challenge"`
+	RP                     RPEntity                `json:"rp"`
+	User                   UserEntity              `json:"user"`
+	PubKeyCredParams       []CredentialParameter   `json:"pubKeyCredParams"`
+	Timeout                int                     `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor  `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection *AuthenticatorSelection `json:"authenticatorSelection,omitempty"`
+	Attestation            string                  `json:"attestation,omitempty"`
+}
+
+// RequestOptions is passed to navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	Timeout          int                    `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId,omitempty"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification,omitempty"`
+}
+
+func (rp *RelyingParty) userVerification() string {
+	if rp.RequireUserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	res := make([]CredentialDescriptor, len(ids))
+	for i, id := range ids {
+		res[i] = CredentialDescriptor{Type: "public-key", ID: id}
+	}
+	return res
+}
+
+// CreationOptions returns registration ceremony options. Already registered credentials are excluded
+func (rp *RelyingParty) CreationOptions(challenge []byte, user *UserEntity, exclude [][]byte) *CreationOptions {
+	return &CreationOptions{
+		Challenge: challenge,
+		RP: RPEntity{
+			ID:   rp.ID,
+			Name: rp.Name,
+		},
+		User: *user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            Timeout,
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: &AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: rp.userVerification(),
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns authentication ceremony options. Empty allow list lets the authenticator choose a discoverable credential
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow [][]byte) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout,
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: rp.userVerification(),
+	}
+}
+
+// AttestationResponse is a serialized PublicKeyCredential returned by navigator.credentials.create()
+type AttestationResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is a serialized PublicKeyCredential returned by navigator.credentials.get()
+type AssertionResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Credential is a registered public key credential
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key
+	SignCount uint32
+	AAGUID    []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func (rp *RelyingParty) verifyClientData(data []byte, typ string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(data, &cd); err != nil {
+		return ErrClientData
+	}
+
+	if cd.Type != typ {
+		return ErrClientData
+	}
+
+	c, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cd.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(c, challenge) != 1 {
+		return ErrChallenge
+	}
+
+	if cd.Origin != rp.Origin {
+		return ErrOrigin
+	}
+
+	return nil
+}
+
+type authData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	publicKey []byte
+}
+
+func parseAuthData(data []byte) (*authData, error) {
+	if len(data) < 37 {
+		return nil, ErrAuthData
+	}
+
+	res := authData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if res.flags&flagAttestedCredData != 0 {
+		rest := data[37:]
+		if len(rest) < 18 {
+			return nil, ErrAuthData
+		}
+
+		res.aaguid = rest[:16]
+		l := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < l {
+			return nil, ErrAuthData
+		}
+
+		res.credID, rest = rest[:l], rest[l:]
+
+		// Credential public key is followed by optional extensions
+		_, tail, err := cborDecode(rest)
+		if err != nil {
+			return nil, ErrAuthData
+		}
+		res.publicKey = rest[:len(rest)-len(tail)]
+	}
+
+	return &res, nil
+}
+
+func (rp *RelyingParty) verifyAuthData(ad *authData) error {
+	h := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, h[:]) {
+		return ErrRPID
+	}
+
+	if ad.flags&flagUserPresent == 0 || (rp.RequireUserVerification && ad.flags&flagUserVerified == 0) {
+		return ErrUserPresence
+	}
+
+	return nil
+}
+
+// VerifyRegistration validates the registration ceremony result and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, res *AttestationResponse) (*Credential, error) {
+	if err := rp.verifyClientData(res.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	v, _, err := cborDecode(res.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrAuthData
+	}
+
+	raw, ok := obj["authData"].([]byte)
+	if !ok {
+		return nil, ErrAuthData
+	}
+
+	ad, err := parseAuthData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyAuthData(ad); err != nil {
+		return nil, err
+	}
+
+	if ad.credID == nil {
+		return nil, ErrAuthData
+	}
+
+	if len(res.RawID) != 0 && !bytes.Equal(res.RawID, ad.credID) {
+		return nil, ErrAuthData
+	}
+
+	if _, err := ParsePublicKey(ad.publicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        ad.credID,
+		PublicKey: ad.publicKey,
+		SignCount: ad.signCount,
+		AAGUID:    ad.aaguid,
+	}, nil
+}
+
+// VerifyAssertion validates the authentication ceremony result against the stored credential and returns the new signature counter value
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, cred *Credential, res *AssertionResponse) (uint32, error) {
+	if err := rp.verifyClientData(res.Response.ClientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+
+	ad, err := parseAuthData(res.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := rp.verifyAuthData(ad); err != nil {
+		return 0, err
+	}
+
+	pub, err := ParsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	cdHash := sha256.Sum256(res.Response.ClientDataJSON)
+	signed := append(append([]byte{}, res.Response.AuthenticatorData...), cdHash[:]...)
+
+	if !pub.Verify(signed, res.Response.Signature) {
+		return 0, ErrSignature
+	}
+
+	// Authenticators which don't implement the counter always return zero
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return ad.signCount, nil
+}
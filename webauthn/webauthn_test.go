@@ -0,0 +1,217 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+// softAuthenticator is a software P-256 authenticator
+type softAuthenticator struct {
+	key       *ecdsa.PrivateKey
+	credID    []byte
+	signCount uint32
+	origin    string
+}
+
+func newSoftAuthenticator(origin string) (*softAuthenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &softAuthenticator{
+		key:    key,
+		credID: id,
+		origin: origin,
+	}, nil
+}
+
+func (a *softAuthenticator) clientData(typ string, challenge []byte) []byte {
+	buf, _ := json.Marshal(map[string]interface{}{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+	return buf
+}
+
+func (a *softAuthenticator) authData(rpID string, attested bool) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	a.signCount++
+
+	flags := byte(flagUserPresent | flagUserVerified)
+	if attested {
+		flags |= flagAttestedCredData
+	}
+
+	buf := append(h[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[33:], a.signCount)
+
+	if attested {
+		buf = append(buf, make([]byte, 16)...) // AAGUID
+		buf = append(buf, byte(len(a.credID)>>8), byte(len(a.credID)))
+		buf = append(buf, a.credID...)
+		buf = cborEncode(buf, cborMap{
+			{int64(coseKty), int64(coseKtyEC2)},
+			{int64(coseAlg), int64(AlgES256)},
+			{int64(coseCrv), int64(coseP256)},
+			{int64(coseX), a.key.X.FillBytes(make([]byte, 32))},
+			{int64(coseY), a.key.Y.FillBytes(make([]byte, 32))},
+		})
+	}
+
+	return buf
+}
+
+func (a *softAuthenticator) create(opt *CreationOptions) *AttestationResponse {
+	var res AttestationResponse
+	res.ID = base64.RawURLEncoding.EncodeToString(a.credID)
+	res.RawID = a.credID
+	res.Type = "public-key"
+	res.Response.ClientDataJSON = a.clientData(typeCreate, opt.Challenge)
+	res.Response.AttestationObject = cborEncode(nil, cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authData(opt.RP.ID, true)},
+	})
+	return &res
+}
+
+func (a *softAuthenticator) get(opt *RequestOptions) (*AssertionResponse, error) {
+	var res AssertionResponse
+	res.ID = base64.RawURLEncoding.EncodeToString(a.credID)
+	res.RawID = a.credID
+	res.Type = "public-key"
+	res.Response.ClientDataJSON = a.clientData(typeGet, opt.Challenge)
+	res.Response.AuthenticatorData = a.authData(opt.RPID, false)
+
+	cdHash := sha256.Sum256(res.Response.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, res.Response.AuthenticatorData...), cdHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	res.Response.Signature = sig
+
+	return &res, nil
+}
+
+func TestCeremonies(t *testing.T) {
+	rp, err := NewRelyingParty("https://auth.example.com", "Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp.RequireUserVerification = true
+
+	auth, err := newSoftAuthenticator(rp.Origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Registration
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copt := rp.CreationOptions(challenge, &UserEntity{ID: []byte("user"), Name: "user@example.com", DisplayName: "User"}, nil)
+
+	// Round trip through JSON like a browser would do
+	var att AttestationResponse
+	buf, _ := json.Marshal(auth.create(copt))
+	if err := json.Unmarshal(buf, &att); err != nil {
+		t.Fatal(err)
+	}
+
+	cred, err := rp.VerifyRegistration(challenge, &att)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(cred.ID) != string(auth.credID) {
+		t.Error("credential ID mismatch")
+	}
+
+	// Wrong challenge
+	if _, err := rp.VerifyRegistration([]byte("other"), &att); err != ErrChallenge {
+		t.Errorf("expected challenge error, got %v", err)
+	}
+
+	// Authentication
+	if challenge, err = NewChallenge(); err != nil {
+		t.Fatal(err)
+	}
+
+	ropt := rp.RequestOptions(challenge, [][]byte{cred.ID})
+	as, err := auth.get(ropt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := rp.VerifyAssertion(challenge, cred, as)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != auth.signCount {
+		t.Errorf("sign count: %d != %d", count, auth.signCount)
+	}
+
+	// Replay is detected by the counter
+	cred.SignCount = count
+	if _, err := rp.VerifyAssertion(challenge, cred, as); err != ErrSignCount {
+		t.Errorf("expected counter error, got %v", err)
+	}
+
+	// Tampered signature
+	if as, err = auth.get(ropt); err != nil {
+		t.Fatal(err)
+	}
+	as.Response.Signature[len(as.Response.Signature)-1] ^= 1
+	if _, err := rp.VerifyAssertion(challenge, cred, as); err != ErrSignature {
+		t.Errorf("expected signature error, got %v", err)
+	}
+
+	// Phishing origin
+	auth.origin = "https://auth.example.com.evil.net"
+	if as, err = auth.get(ropt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(challenge, cred, as); err != ErrOrigin {
+		t.Errorf("expected origin error, got %v", err)
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	src := cborMap{
+		{int64(1), int64(-300)},
+		{"k", []interface{}{true, nil, "s", []byte{1, 2}}},
+		{int64(-2), int64(70000)},
+	}
+
+	v, rest, err := cborDecode(cborEncode(nil, src))
+	if err != nil || len(rest) != 0 {
+		t.Fatal(err, rest)
+	}
+
+	m := v.(map[interface{}]interface{})
+	if m[int64(1)] != int64(-300) || m[int64(-2)] != int64(70000) {
+		t.Errorf("unexpected value: %v", m)
+	}
+
+	if _, _, err := cborDecode([]byte{0x5a, 0xff, 0xff, 0xff, 0xff}); err == nil {
+		t.Error("truncated input must be rejected")
+	}
+}